@@ -0,0 +1,337 @@
+package main
+
+import (
+	"dum/internal/machines/adapters"
+	"dum/internal/machines/cases"
+	"dum/internal/machines/ports"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Runs administrative subcommand, e.g. "migrate --dry-run", writing its output to out.
+func runCommand(args []string, out io.Writer) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(args[1:], out)
+	case "backup":
+		return runBackup(args[1:], out)
+	case "restore":
+		return runRestore(args[1:], out)
+	case "fsck":
+		return runFsck(args[1:], out)
+	case "rotate-key":
+		return runRotateKey(args[1:], out)
+	default:
+		return fmt.Errorf("unknown command '%s'", args[0])
+	}
+}
+
+// Returns settings of store file for file based repositories. Returns false for database repositories.
+func storeFileConfig(c repositoryConfig) (adapters.FileRepositoryConfig, bool, error) {
+	switch c.Kind {
+	case fileRepositoryKind:
+		fc, err := createFileRepositoryConfig(c)
+		return fc, true, err
+	case journalRepositoryKind:
+		jc, err := createJournalRepositoryConfig(c)
+		return snapshotFileConfig(c, jc), true, err
+	default:
+		return adapters.FileRepositoryConfig{}, false, nil
+	}
+}
+
+// Upgrades configured store file to current format version.
+func runMigrate(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(out)
+	dryRun := fs.Bool("dry-run", false, "check store and report migration without writing anything")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	fc, ok, err := storeFileConfig(cfg.Repository)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		fmt.Fprintf(out, "Repository of kind %s has no store file, its schema is migrated on startup\n", cfg.Repository.Kind)
+		return nil
+	}
+
+	report, err := adapters.MigrateStoreFile(fc, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case report.FromVersion == report.ToVersion:
+		fmt.Fprintf(out, "%s has current format version %d, %d machines\n", report.Path, report.ToVersion, report.Machines)
+	case report.Migrated:
+		fmt.Fprintf(out, "%s is migrated from format version %d to %d, %d machines, backup is saved to %s\n",
+			report.Path, report.FromVersion, report.ToVersion, report.Machines, report.Backup)
+	default:
+		fmt.Fprintf(out, "%s would be migrated from format version %d to %d, %d machines\n",
+			report.Path, report.FromVersion, report.ToVersion, report.Machines)
+	}
+
+	return nil
+}
+
+// Writes archive of all machines of configured repository to file or to out.
+func runBackup(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	fs.SetOutput(out)
+	format := fs.String("format", ports.NdjsonArchiveFormat, "archive format - ndjson or json")
+	output := fs.String("output", "", "path to archive file, archive is written to stdout if it's empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	repo, closeRepository, err := openConfiguredRepository(true)
+	if err != nil {
+		return err
+	}
+	defer closeRepository()
+
+	machines, err := repo.List()
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return ports.WriteArchive(out, machines, *format, time.Now())
+	}
+
+	f, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	err = ports.WriteArchive(f, machines, *format, time.Now())
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "%d machines are saved to %s\n", len(machines), *output)
+	return nil
+}
+
+// Restores machines from archive file into configured repository.
+func runRestore(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	fs.SetOutput(out)
+	input := fs.String("input", "", "path to archive file")
+	mode := fs.String("mode", string(cases.RestoreOverwrite), "handling of existing machines - overwrite or skip")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *input == "" {
+		return fmt.Errorf("path to archive file is required")
+	}
+
+	restoreMode := cases.RestoreMode(*mode)
+	if restoreMode != cases.RestoreOverwrite && restoreMode != cases.RestoreSkip {
+		return fmt.Errorf("unknown restore mode '%s'", *mode)
+	}
+
+	f, err := os.Open(*input)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, machines, err := ports.ReadArchive(f)
+	if err != nil {
+		return err
+	}
+
+	repo, closeRepository, err := openConfiguredRepository(false)
+	if err != nil {
+		return err
+	}
+	defer closeRepository()
+
+	report := cases.RestoreMachines(repo, machines, restoreMode)
+	for _, err := range report.Errors {
+		fmt.Fprintln(out, err)
+	}
+
+	fmt.Fprintf(out, "%d machines are restored, %d skipped, %d failed\n", report.Restored, report.Skipped, len(report.Errors))
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("%d machines are not restored", len(report.Errors))
+	}
+
+	return nil
+}
+
+// Creates repository, selected by configuration. Journal of running service is read without writer lock,
+// if only reading is needed, other repositories are shared with service by themselves or refuse to open.
+func openConfiguredRepository(readOnly bool) (cases.MachineRepository, func() error, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !readOnly || cfg.Repository.Kind != journalRepositoryKind {
+		return createRepository(cfg.Repository)
+	}
+
+	jc, err := createJournalRepositoryConfig(cfg.Repository)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	jc.ReadOnly = true
+	r, err := adapters.NewJournalRepository(jc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r, r.Close, nil
+}
+
+// Checks store file of configured repository and writes JSON report to out. Returns an error,
+// if some problems are neither repaired nor quarantined.
+func runFsck(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	fs.SetOutput(out)
+	repair := fs.Bool("repair", false, "repair problems, which can be fixed")
+	quarantine := fs.Bool("quarantine", false, "move records with unrepaired problems to quarantine file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	fc, ok, err := storeFileConfig(cfg.Repository)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return fmt.Errorf("repository of kind %s has no store file to check", cfg.Repository.Kind)
+	}
+
+	if cfg.Repository.Kind == journalRepositoryKind {
+		err = compactJournal(cfg.Repository)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *repair || *quarantine {
+		// running service would overwrite repaired store or hold records in memory, so it has to be stopped
+		l, err := adapters.AcquireWriterLock(fc.Path, fc.Permissions)
+		if err != nil {
+			return fmt.Errorf("store cannot be changed, while service is running - %w", err)
+		}
+		defer l.Release()
+	}
+
+	report, err := adapters.CheckStoreFile(fc, adapters.FsckOptions{Repair: *repair, Quarantine: *quarantine})
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(&report)
+	if err != nil {
+		return err
+	}
+
+	if report.HasUnresolved() {
+		return fmt.Errorf("store has unresolved problems")
+	}
+
+	return nil
+}
+
+// Applies journal to snapshot, so the whole store can be checked in snapshot file. Fails, if journal
+// is opened by running service.
+func compactJournal(c repositoryConfig) error {
+	jc, err := createJournalRepositoryConfig(c)
+	if err != nil {
+		return err
+	}
+
+	r, err := adapters.NewJournalRepository(jc)
+	if err != nil {
+		return err
+	}
+
+	return r.Close()
+}
+
+// Re-encrypts store file of file repository with new key. Plaintext store is encrypted, if there is no
+// current key, and encrypted store is decrypted with -decrypt flag.
+func runRotateKey(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("rotate-key", flag.ContinueOnError)
+	fs.SetOutput(out)
+	newKeyFile := fs.String("new-key-file", "", "path to file with new base64 encoded key")
+	decrypt := fs.Bool("decrypt", false, "store files in plaintext instead of encrypting with new key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if (*newKeyFile == "") == !*decrypt {
+		return fmt.Errorf("either new key file or decrypt flag is required")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if cfg.Repository.Kind != fileRepositoryKind {
+		return fmt.Errorf("encryption is supported by file repository only")
+	}
+
+	fc, err := createFileRepositoryConfig(cfg.Repository)
+	if err != nil {
+		return err
+	}
+
+	var newCipher *adapters.FileCipher
+	if !*decrypt {
+		key, err := adapters.LoadKey(*newKeyFile)
+		if err != nil {
+			return fmt.Errorf("invalid new encryption key - %w", err)
+		}
+
+		newCipher, err = adapters.NewFileCipher(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	rotated, err := adapters.RotateStoreKey(fc, newCipher)
+	if err != nil {
+		return fmt.Errorf("key cannot be rotated - %w", err)
+	}
+
+	for _, path := range rotated {
+		fmt.Fprintf(out, "%s is rotated\n", path)
+	}
+
+	fmt.Fprintln(out, "Update encryption key in configuration before starting service")
+	return nil
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"dum/internal/machines/adapters"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const legacyStore string = `{"8b0e5ac4-2a5a-4b7c-bd8e-4a5b1f0c3d11":{"Id":"8b0e5ac4-2a5a-4b7c-bd8e-4a5b1f0c3d11","Name":"legacy","Version":"1","MissingUpdates":[]}}`
+
+func TestMigrateCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "machines.json")
+	err := os.WriteFile(path, []byte(legacyStore), 0600)
+	if err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+
+	os.Setenv(repositoryPathVariable, path)
+	defer os.Unsetenv(repositoryPathVariable)
+
+	out := &bytes.Buffer{}
+	err = runCommand([]string{"migrate", "--dry-run"}, out)
+	if err != nil {
+		t.Fatalf("Dry run should succeed, but got error %s", err)
+	}
+
+	if !strings.Contains(out.String(), "would be migrated from format version 1 to 2, 1 machines") {
+		t.Errorf("Unexpected dry run output: %s", out)
+	}
+
+	raw, _ := os.ReadFile(path)
+	if string(raw) != legacyStore {
+		t.Errorf("Dry run should not change store!")
+	}
+
+	out.Reset()
+	err = runCommand([]string{"migrate"}, out)
+	if err != nil {
+		t.Fatalf("Migration should succeed, but got error %s", err)
+	}
+
+	if !strings.Contains(out.String(), "is migrated from format version 1 to 2") {
+		t.Errorf("Unexpected migration output: %s", out)
+	}
+}
+
+func TestBackupAndRestoreIntoAnotherRepository(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "machines.json")
+	archivePath := filepath.Join(dir, "machines.ndjson")
+	err := os.WriteFile(filePath, []byte(legacyStore), 0600)
+	if err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+
+	defer os.Unsetenv(repositoryKindVariable)
+	defer os.Unsetenv(repositoryPathVariable)
+
+	os.Setenv(repositoryKindVariable, fileRepositoryKind)
+	os.Setenv(repositoryPathVariable, filePath)
+	err = runCommand([]string{"backup", "-output", archivePath}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Backup should succeed, but got error %s", err)
+	}
+
+	os.Setenv(repositoryKindVariable, sqliteRepositoryKind)
+	os.Setenv(repositoryPathVariable, filepath.Join(dir, "machines.db"))
+	out := &bytes.Buffer{}
+	err = runCommand([]string{"restore", "-input", archivePath}, out)
+	if err != nil {
+		t.Fatalf("Restore should succeed, but got error %s", err)
+	}
+
+	if !strings.Contains(out.String(), "1 machines are restored, 0 skipped, 0 failed") {
+		t.Errorf("Unexpected restore output: %s", out)
+	}
+
+	repo, closeRepository, err := openConfiguredRepository(true)
+	if err != nil {
+		t.Fatalf("Cannot open restored repository due to error %s", err)
+	}
+	defer closeRepository()
+
+	machines, err := repo.List()
+	if err != nil || len(machines) != 1 || machines[0].Name != "legacy" {
+		t.Errorf("Restored machines mismatch! Got %v with error %v", machines, err)
+	}
+}
+
+func TestBackupOfRunningJournal(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "machines.snapshot.json")
+	archivePath := filepath.Join(dir, "machines.ndjson")
+	err := os.WriteFile(path, []byte(legacyStore), 0600)
+	if err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+
+	os.Setenv(repositoryKindVariable, journalRepositoryKind)
+	os.Setenv(repositoryPathVariable, path)
+	defer os.Unsetenv(repositoryKindVariable)
+	defer os.Unsetenv(repositoryPathVariable)
+
+	c, err := loadConfig()
+	if err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+
+	_, closeService, err := createRepository(c.Repository)
+	if err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+	defer closeService()
+
+	snapshot, _ := os.ReadFile(path)
+	out := &bytes.Buffer{}
+	err = runCommand([]string{"backup", "-output", archivePath}, out)
+	if err != nil {
+		t.Fatalf("Backup of running journal should succeed, but got error %s", err)
+	}
+
+	if !strings.Contains(out.String(), "1 machines are saved") {
+		t.Errorf("Unexpected backup output: %s", out)
+	}
+
+	if raw, _ := os.ReadFile(path); string(raw) != string(snapshot) {
+		t.Errorf("Backup should not compact journal of running service!")
+	}
+
+	err = runCommand([]string{"restore", "-input", archivePath}, &bytes.Buffer{})
+	if err == nil {
+		t.Errorf("Restore into running journal should be refused!")
+	}
+}
+
+func TestFsckCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "machines.json")
+	store := `{"not-uuid":{"Id":"not-uuid","Name":"broken","MissingUpdates":[]}}`
+	err := os.WriteFile(path, []byte(store), 0600)
+	if err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+
+	os.Setenv(repositoryPathVariable, path)
+	defer os.Unsetenv(repositoryPathVariable)
+
+	out := &bytes.Buffer{}
+	err = runCommand([]string{"fsck"}, out)
+	if err == nil {
+		t.Errorf("Fsck should fail on unresolved problems!")
+	}
+
+	var report adapters.FsckReport
+	err = json.Unmarshal(out.Bytes(), &report)
+	if err != nil {
+		t.Fatalf("Report should be machine-readable, but got error %s", err)
+	}
+
+	if len(report.Problems) != 1 || report.Problems[0].Kind != adapters.FsckInvalidId {
+		t.Errorf("Unexpected fsck report %+v", report)
+	}
+
+	err = runCommand([]string{"fsck", "-quarantine"}, &bytes.Buffer{})
+	if err != nil {
+		t.Errorf("Fsck should quarantine broken record, but got error %s", err)
+	}
+}
+
+func TestFsckRepairRefusedWhileWriterIsRunning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "machines.json")
+	store := `{"not-uuid":{"Id":"not-uuid","Name":"broken","MissingUpdates":[]}}`
+	err := os.WriteFile(path, []byte(store), 0600)
+	if err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+
+	os.Setenv(repositoryPathVariable, path)
+	defer os.Unsetenv(repositoryPathVariable)
+
+	l, err := adapters.AcquireWriterLock(path, 0600)
+	if err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+	defer l.Release()
+
+	for _, flag := range []string{"-repair", "-quarantine"} {
+		if err := runCommand([]string{"fsck", flag}, &bytes.Buffer{}); err == nil {
+			t.Errorf("Fsck with %s should be refused while writer is running!", flag)
+		}
+	}
+
+	if raw, _ := os.ReadFile(path); string(raw) != store {
+		t.Errorf("Store should not be changed while writer is running!")
+	}
+
+	out := &bytes.Buffer{}
+	runCommand([]string{"fsck"}, out)
+	if !strings.Contains(out.String(), "not-uuid") {
+		t.Errorf("Store should be checked while writer is running, but output was %s", out)
+	}
+}
+
+func TestRotateKeyCommand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "machines.json")
+	keyPath := filepath.Join(dir, "machines.key")
+	const key string = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+	os.WriteFile(path, []byte(legacyStore), 0600)
+	os.WriteFile(keyPath, []byte(key), 0600)
+
+	os.Setenv(repositoryPathVariable, path)
+	defer os.Unsetenv(repositoryPathVariable)
+
+	err := runCommand([]string{"rotate-key", "-new-key-file", keyPath}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Key should be rotated, but got error %s", err)
+	}
+
+	raw, _ := os.ReadFile(path)
+	if strings.Contains(string(raw), "legacy") {
+		t.Errorf("Store should be encrypted!")
+	}
+
+	os.Setenv(encryptionKeyVariable, key)
+	defer os.Unsetenv(encryptionKeyVariable)
+
+	repo, closeRepository, err := openConfiguredRepository(true)
+	if err != nil {
+		t.Fatalf("Cannot open encrypted repository due to error %s", err)
+	}
+	defer closeRepository()
+
+	machines, err := repo.List()
+	if err != nil || len(machines) != 1 || machines[0].Name != "legacy" {
+		t.Errorf("Encrypted machines mismatch! Got %v with error %v", machines, err)
+	}
+}
+
+func TestRotateKeyRefusedWhileServiceIsRunning(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "machines.json")
+	keyPath := filepath.Join(dir, "machines.key")
+	os.WriteFile(path, []byte(legacyStore), 0600)
+	os.WriteFile(keyPath, []byte("MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="), 0600)
+
+	os.Setenv(repositoryPathVariable, path)
+	defer os.Unsetenv(repositoryPathVariable)
+
+	c, err := loadConfig()
+	if err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+
+	// service is not exclusive, but still refuses maintenance commands
+	_, closeService, err := createRepository(c.Repository)
+	if err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+	defer closeService()
+
+	err = runCommand([]string{"rotate-key", "-new-key-file", keyPath}, &bytes.Buffer{})
+	if err == nil {
+		t.Fatal("Key should not be rotated while service is running!")
+	}
+
+	if raw, _ := os.ReadFile(path); !strings.Contains(string(raw), "legacy") {
+		t.Errorf("Store should stay in plaintext!")
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	err := runCommand([]string{"unknown"}, &bytes.Buffer{})
+	if err == nil {
+		t.Errorf("Unknown command should fail!")
+	}
+}
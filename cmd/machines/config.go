@@ -0,0 +1,243 @@
+package main
+
+import (
+	"dum/internal/machines/adapters"
+	"dum/internal/machines/cases"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+const configPathVariable string = "DUM_CONFIG"
+const repositoryKindVariable string = "DUM_REPOSITORY_KIND"
+const repositoryPathVariable string = "DUM_REPOSITORY_PATH"
+const repositoryDsnVariable string = "DUM_REPOSITORY_DSN"
+const encryptionKeyVariable string = "DUM_ENCRYPTION_KEY"
+const webhookSecretVariable string = "DUM_WEBHOOK_SECRET"
+const smtpPasswordVariable string = "DUM_SMTP_PASSWORD"
+const incidentRoutingKeyVariable string = "DUM_INCIDENT_ROUTING_KEY"
+
+const (
+	fileRepositoryKind     string = "file"
+	sqliteRepositoryKind   string = "sqlite"
+	postgresRepositoryKind string = "postgres"
+	boltRepositoryKind     string = "bolt"
+	journalRepositoryKind  string = "journal"
+)
+
+const (
+	logNotificationKind      string = "log"
+	webhookNotificationKind  string = "webhook"
+	emailNotificationKind    string = "email"
+	chatNotificationKind     string = "chat"
+	incidentNotificationKind string = "incident"
+	routerNotificationKind   string = "router"
+)
+
+// Configuration of service. Read from JSON file, pointed by DUM_CONFIG environment variable,
+// some values can be overriden by environment variables.
+type config struct {
+	Repository    repositoryConfig
+	Notifications notificationsConfig
+	// Queue and workers of asynchronous notifications, notifications are sent by processors, if there are no workers.
+	Dispatcher cases.DispatcherConfig
+	// Escalation of machines, staying in Danger for too long.
+	Escalation escalationConfig
+	// Storage of silences and acknowledgments, which suppress notifications.
+	Silences silencesConfig
+	// Flap detection and hysteresis of machine health, applied to all notifications.
+	Flapping adapters.FlapConfig
+}
+
+// Configuration of machine repository.
+type repositoryConfig struct {
+	// Kind of repository - file, journal, sqlite, postgres or bolt.
+	Kind string
+	// Path to repository file or database, used by file, journal, sqlite and bolt repositories.
+	// Journal repository keeps its journal next to snapshot with .journal extension.
+	Path string
+	// Octal permissions of repository file, used by file and journal repositories.
+	Permissions string
+	// Count of previous repository file generations, used by file repository.
+	Generations int
+	// Time to wait for cross-process lock of repository file, used by file repository.
+	LockTimeout time.Duration
+	// Refuse to start, if another writer is working with repository file, used by file repository. Journal
+	// repository always refuses.
+	Exclusive bool
+	// Count of journal entries, after which journal is compacted into snapshot.
+	CompactionThreshold int
+	// Path to file with base64 encoded AES key, used for encryption of file repository. Key can be
+	// passed with DUM_ENCRYPTION_KEY variable instead, file is stored in plaintext if there is no key.
+	EncryptionKeyFile string
+	// Count of machines, cached in memory by any repository, cache is disabled if it's zero.
+	CacheSize int
+	// Data source name, used by postgres repository.
+	Dsn string
+	// Connection pool settings, used by postgres repository.
+	Pool adapters.PoolConfig
+}
+
+// Configuration of machine health notifications.
+type notificationsConfig struct {
+	// Kind of notification strategy - log, webhook, email, chat, incident or router.
+	Kind string
+	// Groups of machines by name patterns, used for choosing recipients and routing.
+	Groups adapters.MachineGroups
+	// Tags of machines by name patterns, used for routing.
+	Tags adapters.MachineGroups
+	// Named channels of router, every channel is configured like a single strategy.
+	Channels map[string]notificationsConfig
+	// Rules of router, choosing channels for event.
+	Routes []adapters.NotificationRoute
+	// Deduplication and rate limits of strategy or channel, events over limits are sent as digest.
+	Throttle adapters.ThrottleConfig
+	// Webhook settings, secret can be passed with DUM_WEBHOOK_SECRET variable.
+	Webhook adapters.WebhookConfig
+	// SMTP settings, password can be passed with DUM_SMTP_PASSWORD variable.
+	Email adapters.EmailConfig
+	// Slack and Teams channels settings.
+	Chat adapters.ChatConfig
+	// Incident management settings, routing key can be passed with DUM_INCIDENT_ROUTING_KEY variable.
+	Incident adapters.IncidentConfig
+}
+
+// Configuration of escalation policies.
+type escalationConfig struct {
+	// Interval of checking machines in Danger.
+	Interval time.Duration
+	// Policies, the first one matching machine is applied, escalation is disabled if there are no policies.
+	Policies []escalationPolicyConfig
+}
+
+// Configuration of escalation policy.
+type escalationPolicyConfig struct {
+	Name string
+	// Groups and tags of machines, defined in notifications configuration, policy applies to any machine, if both are empty.
+	Groups []string
+	Tags   []string
+	Tiers  []escalationTierConfig
+}
+
+// Configuration of escalation tier, which is notified, when machine is in Danger longer than After.
+type escalationTierConfig struct {
+	Name          string
+	After         time.Duration
+	Notifications notificationsConfig
+}
+
+// Configuration of silences and acknowledgments storage.
+type silencesConfig struct {
+	// Path to JSON file with silences and acknowledgments, used by file and journal repositories. Database
+	// repositories keep silences in their database.
+	Path string
+}
+
+func defaultConfig() config {
+	return config{
+		Repository: repositoryConfig{
+			Kind:                fileRepositoryKind,
+			Permissions:         "0600",
+			Generations:         3,
+			LockTimeout:         5 * time.Second,
+			CompactionThreshold: 1000,
+			Pool: adapters.PoolConfig{
+				MaxOpenConnections: 10,
+				MaxIdleConnections: 5,
+				ConnectionLifetime: 30 * time.Minute,
+			},
+		},
+		Notifications: defaultNotificationsConfig(),
+		Dispatcher:    cases.DefaultDispatcherConfig(),
+		Escalation: escalationConfig{
+			Interval: time.Minute,
+		},
+		Silences: silencesConfig{
+			Path: adapters.SilencesFileName,
+		},
+	}
+}
+
+func defaultNotificationsConfig() notificationsConfig {
+	return notificationsConfig{
+		Kind:    logNotificationKind,
+		Webhook: adapters.DefaultWebhookConfig(),
+		Email: adapters.EmailConfig{
+			Port:    25,
+			Timeout: 10 * time.Second,
+			Retries: 3,
+			Backoff: time.Second,
+		},
+		Chat:     adapters.DefaultChatConfig(),
+		Incident: adapters.DefaultIncidentConfig(),
+	}
+}
+
+// Reads notifications configuration over defaults, so router channels get defaults as well.
+func (c *notificationsConfig) UnmarshalJSON(raw []byte) error {
+	type plain notificationsConfig
+	p := plain(defaultNotificationsConfig())
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return err
+	}
+
+	*c = notificationsConfig(p)
+	return nil
+}
+
+// Overrides secrets of notifications and its channels by environment variables.
+func applyNotificationSecrets(c *notificationsConfig) {
+	if secret := os.Getenv(webhookSecretVariable); secret != "" {
+		c.Webhook.Secret = secret
+	}
+
+	if password := os.Getenv(smtpPasswordVariable); password != "" {
+		c.Email.Password = password
+	}
+
+	if key := os.Getenv(incidentRoutingKeyVariable); key != "" {
+		c.Incident.RoutingKey = key
+	}
+
+	for name, channel := range c.Channels {
+		applyNotificationSecrets(&channel)
+		c.Channels[name] = channel
+	}
+}
+
+func loadConfig() (config, error) {
+	c := defaultConfig()
+
+	if path := os.Getenv(configPathVariable); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return c, err
+		}
+
+		err = json.Unmarshal(raw, &c)
+		if err != nil {
+			return c, err
+		}
+	}
+
+	if kind := os.Getenv(repositoryKindVariable); kind != "" {
+		c.Repository.Kind = kind
+	}
+
+	if path := os.Getenv(repositoryPathVariable); path != "" {
+		c.Repository.Path = path
+	}
+
+	if dsn := os.Getenv(repositoryDsnVariable); dsn != "" {
+		c.Repository.Dsn = dsn
+	}
+
+	applyNotificationSecrets(&c.Notifications)
+	for _, p := range c.Escalation.Policies {
+		for i := range p.Tiers {
+			applyNotificationSecrets(&p.Tiers[i].Notifications)
+		}
+	}
+
+	return c, nil
+}
@@ -4,24 +4,73 @@ import (
 	"context"
 	"dum/internal/machines/adapters"
 	"dum/internal/machines/cases"
+	"dum/internal/machines/entities"
 	"dum/internal/machines/ports"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
+	"unicode"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:], os.Stdout); err != nil {
+			log.Default().Fatalf("Command %s failed: %s", os.Args[1], err)
+		}
+		return
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Default().Fatalf("Cannot load configuration: %s", err)
+	}
+
+	repo, silences, closeRepository, err := createRepositories(cfg)
+	if err != nil {
+		log.Default().Fatalf("Cannot create repository: %s", err)
+	}
+
+	strategy, err := createNotificationStrategy(cfg.Notifications)
+	if err != nil {
+		log.Default().Fatalf("Cannot create notification strategy: %s", err)
+	}
+	strategy = createSilencingDecorator(strategy, silences, cfg.Notifications)
+
+	strategy, err = createFlapDetector(strategy, cfg.Flapping)
+	if err != nil {
+		log.Default().Fatalf("Cannot create flap detector: %s", err)
+	}
+
+	strategy, err = createDispatcher(strategy, cfg.Dispatcher)
+	if err != nil {
+		log.Default().Fatalf("Cannot create notification dispatcher: %s", err)
+	}
+
+	// escalations don't need cache, but use health index of repository, if it has one
+	escalation, err := createEscalationScheduler(cfg.Escalation, cfg.Notifications, baseRepository(repo), silences)
+	if err != nil {
+		log.Default().Fatalf("Cannot create escalation scheduler: %s", err)
+	}
+
 	commandChan := make(chan cases.Command, 100)
 	processingGroup := &sync.WaitGroup{}
 
 	processingCtx, cancelProcessing := context.WithCancel(context.Background())
-	startProcessing(processingCtx, commandChan, processingGroup)
+	quarantine := cases.NewMemoryQuarantine(100)
+	supervisor := startProcessing(processingCtx, commandChan, quarantine, processingGroup)
+	if escalation != nil {
+		escalation.Start(processingCtx, processingGroup, cfg.Escalation.Interval)
+	}
 
-	httpServer := createServer(commandChan)
+	httpServer := createServer(commandChan, supervisor, quarantine, repo, strategy, silences)
 	startServer(httpServer)
 
 	waitForOsSignal()
@@ -40,14 +89,36 @@ func main() {
 	close(commandChan)
 	log.Default().Println("Waiting for processing group ...")
 	processingGroup.Wait()
+	if closer, ok := strategy.(io.Closer); ok {
+		log.Default().Println("Closing notifications ...")
+		if err := closer.Close(); err != nil {
+			log.Default().Printf("Notifications closing error: %s", err)
+		}
+	}
+	if escalation != nil {
+		if err := escalation.Close(); err != nil {
+			log.Default().Printf("Escalation closing error: %s", err)
+		}
+	}
+	log.Default().Println("Closing repository ...")
+	if err := closeRepository(); err != nil {
+		log.Default().Printf("Repository closing error: %s", err)
+	}
 	log.Default().Printf("Service is gracefully stopped!")
 
 	os.Exit(0)
 }
 
-func createServer(c chan cases.Command) *http.Server {
+func createServer(c chan cases.Command, s *cases.Supervisor, q *cases.MemoryQuarantine, r cases.MachineRepository, n entities.HealthNotificationStrategy, k cases.SilenceRepository) *http.Server {
 	mux := http.NewServeMux()
-	mux.Handle("/api/v1/machines/", createHandler(c))
+	handler := createHandler(c, r, n, k)
+	mux.Handle("/api/v1/machines/", handler)
+	mux.Handle("/api/v1/reports", handler)
+	mux.Handle("/api/v1/silences", handler)
+	mux.Handle("/api/v1/silences/", handler)
+	mux.Handle("/api/v1/acknowledgments", handler)
+	mux.Handle("/api/v1/admin/", handler)
+	mux.Handle("/health/workers", ports.NewLivenessHandler(s, q))
 	httpServer := &http.Server{
 		Addr:    ":3000",
 		Handler: mux,
@@ -56,11 +127,11 @@ func createServer(c chan cases.Command) *http.Server {
 	return httpServer
 }
 
-func startProcessing(ctx context.Context, c chan cases.Command, wg *sync.WaitGroup) {
-	for i := 0; i < 4; i++ {
-		p := cases.NewReportProcessor(c, log.Default())
-		p.Start(ctx, wg)
-	}
+func startProcessing(ctx context.Context, c chan cases.Command, q cases.Quarantine, wg *sync.WaitGroup) *cases.Supervisor {
+	s := cases.NewSupervisor(c, 4, q, log.Default())
+	s.Start(ctx, wg)
+
+	return s
 }
 
 func startServer(s *http.Server) {
@@ -72,12 +143,405 @@ func startServer(s *http.Server) {
 	}()
 }
 
-func createHandler(c chan cases.Command) http.Handler {
-	s := adapters.NewLogNotificationStrategy(log.Default())
-	r := adapters.NewFileRepository()
-	d := adapters.NewRecoveryFileRepositoryDecorator(r)
+func createRepository(c repositoryConfig) (cases.MachineRepository, func() error, error) {
+	r, closeRepository, err := createBaseRepository(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cacheRepository(r, c), closeRepository, nil
+}
+
+// Creates repository of machines and repository of silences, which shares storage with it.
+func createRepositories(c config) (cases.MachineRepository, cases.SilenceRepository, func() error, error) {
+	r, closeRepository, err := createBaseRepository(c.Repository)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	silences, err := createSilenceRepository(r, c.Silences)
+	if err != nil {
+		closeRepository()
+		return nil, nil, nil, fmt.Errorf("cannot create silence repository - %w", err)
+	}
+
+	return cacheRepository(r, c.Repository), silences, closeRepository, nil
+}
+
+// Returns repository under cache, if there is one.
+func baseRepository(r cases.MachineRepository) cases.MachineRepository {
+	if c, ok := r.(*adapters.CachingRepositoryDecorator); ok {
+		return c.Unwrap()
+	}
+
+	return r
+}
+
+func cacheRepository(r cases.MachineRepository, c repositoryConfig) cases.MachineRepository {
+	if c.CacheSize <= 0 {
+		return r
+	}
+
+	return adapters.NewCachingRepositoryDecorator(r, c.CacheSize)
+}
+
+// Keeps silences and acknowledgments in database of machines, so instances, sharing it, share silences as
+// well. File based repositories keep them in their own file.
+func createSilenceRepository(r cases.MachineRepository, c silencesConfig) (cases.SilenceRepository, error) {
+	switch r := r.(type) {
+	case *adapters.SqliteRepository:
+		return r.Silences(), nil
+	case *adapters.PostgresRepository:
+		return r.Silences(), nil
+	case *adapters.BoltRepository:
+		return r.Silences(), nil
+	default:
+		return adapters.NewFileSilenceRepository(c.Path)
+	}
+}
+
+func createBaseRepository(c repositoryConfig) (cases.MachineRepository, func() error, error) {
+	switch c.Kind {
+	case fileRepositoryKind:
+		fc, err := createFileRepositoryConfig(c)
+		if err != nil {
+			return nil, nil, err
+		}
+		release, err := lockWriter(c, fc.Path, fc.Permissions)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := migrateStore(fc); err != nil {
+			release()
+			return nil, nil, err
+		}
+		r := adapters.NewFileRepository(fc)
+		d := adapters.NewRecoveryFileRepositoryDecorator(r, fc)
+		return d, release, nil
+	case journalRepositoryKind:
+		jc, err := createJournalRepositoryConfig(c)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := migrateSnapshot(c, jc); err != nil {
+			return nil, nil, err
+		}
+		r, err := adapters.NewJournalRepository(jc)
+		if err != nil {
+			return nil, nil, err
+		}
+		return r, r.Close, nil
+	case sqliteRepositoryKind:
+		r, err := adapters.NewSqliteRepository(pathOrDefault(c.Path, "machines.db"))
+		if err != nil {
+			return nil, nil, err
+		}
+		return r, r.Close, nil
+	case boltRepositoryKind:
+		r, err := adapters.NewBoltRepository(pathOrDefault(c.Path, "machines.bolt"))
+		if err != nil {
+			return nil, nil, err
+		}
+		return r, r.Close, nil
+	case postgresRepositoryKind:
+		r, err := adapters.NewPostgresRepository(c.Dsn, c.Pool)
+		if err != nil {
+			return nil, nil, err
+		}
+		return r, r.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown repository kind '%s'", c.Kind)
+	}
+}
+
+func createFileRepositoryConfig(c repositoryConfig) (adapters.FileRepositoryConfig, error) {
+	perm, err := strconv.ParseUint(c.Permissions, 8, 32)
+	if err != nil {
+		return adapters.FileRepositoryConfig{}, fmt.Errorf("invalid repository file permissions '%s' - %w", c.Permissions, err)
+	}
+
+	cipher, err := createFileCipher(c)
+	if err != nil {
+		return adapters.FileRepositoryConfig{}, err
+	}
+
+	return adapters.FileRepositoryConfig{
+		Path:        pathOrDefault(c.Path, adapters.RepositoryFileName),
+		Permissions: os.FileMode(perm),
+		Generations: c.Generations,
+		LockTimeout: c.LockTimeout,
+		Cipher:      cipher,
+	}, nil
+}
+
+// Creates cipher with key from DUM_ENCRYPTION_KEY variable or key file. Returns nil, if there is no key.
+func createFileCipher(c repositoryConfig) (*adapters.FileCipher, error) {
+	var key []byte
+	var err error
+
+	if encoded := os.Getenv(encryptionKeyVariable); encoded != "" {
+		key, err = adapters.ParseKey(encoded)
+	} else if c.EncryptionKeyFile != "" {
+		key, err = adapters.LoadKey(c.EncryptionKeyFile)
+	} else {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key - %w", err)
+	}
+
+	return adapters.NewFileCipher(key)
+}
+
+func createJournalRepositoryConfig(c repositoryConfig) (adapters.JournalRepositoryConfig, error) {
+	perm, err := strconv.ParseUint(c.Permissions, 8, 32)
+	if err != nil {
+		return adapters.JournalRepositoryConfig{}, fmt.Errorf("invalid repository file permissions '%s' - %w", c.Permissions, err)
+	}
+
+	if c.EncryptionKeyFile != "" || os.Getenv(encryptionKeyVariable) != "" {
+		return adapters.JournalRepositoryConfig{}, fmt.Errorf("encryption is supported by file repository only")
+	}
+
+	path := pathOrDefault(c.Path, "machines.snapshot.json")
+	return adapters.JournalRepositoryConfig{
+		SnapshotPath:        path,
+		JournalPath:         path + ".journal",
+		Permissions:         os.FileMode(perm),
+		CompactionThreshold: c.CompactionThreshold,
+	}, nil
+}
+
+// Upgrades store file to current format version, if it has older one.
+func migrateStore(c adapters.FileRepositoryConfig) error {
+	report, err := adapters.MigrateStoreFile(c, false)
+	if err != nil {
+		return fmt.Errorf("cannot migrate %s - %w", c.Path, err)
+	}
+
+	if report.Migrated {
+		log.Default().Printf("Migrated %s from format version %d to %d, backup is saved to %s",
+			report.Path, report.FromVersion, report.ToVersion, report.Backup)
+	}
+
+	return nil
+}
+
+// Upgrades snapshot of journal repository under writer lock, so snapshot of running service is never
+// replaced. Repository takes the lock again, when it's opened.
+func migrateSnapshot(c repositoryConfig, jc adapters.JournalRepositoryConfig) error {
+	l, err := adapters.AcquireWriterLock(jc.SnapshotPath, jc.Permissions)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	return migrateStore(snapshotFileConfig(c, jc))
+}
+
+// Returns settings of journal repository snapshot file for file level operations.
+func snapshotFileConfig(c repositoryConfig, jc adapters.JournalRepositoryConfig) adapters.FileRepositoryConfig {
+	return adapters.FileRepositoryConfig{
+		Path:        jc.SnapshotPath,
+		Permissions: jc.Permissions,
+		LockTimeout: c.LockTimeout,
+	}
+}
+
+// Acquires single writer lock for file repository, if exclusive mode is configured, or writer lock shared
+// with other instances otherwise, so maintenance commands don't change store under running service.
+// Returns function releasing the lock.
+func lockWriter(c repositoryConfig, path string, perm os.FileMode) (func() error, error) {
+	acquire := adapters.AcquireSharedWriterLock
+	if c.Exclusive {
+		acquire = adapters.AcquireWriterLock
+	}
+
+	l, err := acquire(path, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.Release, nil
+}
+
+func createNotificationStrategy(c notificationsConfig) (entities.HealthNotificationStrategy, error) {
+	s, err := createBaseNotificationStrategy(c)
+	if err != nil || !c.Throttle.Enabled() {
+		return s, err
+	}
+
+	return adapters.NewThrottlingNotificationDecorator(s, c.Throttle, log.Default()), nil
+}
+
+// Suppresses notifications about acknowledged machines and machines, matching silences.
+func createSilencingDecorator(s entities.HealthNotificationStrategy, r cases.SilenceRepository, c notificationsConfig) entities.HealthNotificationStrategy {
+	return adapters.NewSilencingNotificationDecorator(s, r, c.Groups, c.Tags, log.Default())
+}
+
+func createFlapDetector(s entities.HealthNotificationStrategy, c adapters.FlapConfig) (entities.HealthNotificationStrategy, error) {
+	if !c.Enabled() {
+		return s, nil
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return adapters.NewFlappingNotificationDecorator(s, c, log.Default()), nil
+}
+
+func createDispatcher(s entities.HealthNotificationStrategy, c cases.DispatcherConfig) (entities.HealthNotificationStrategy, error) {
+	if c.Workers <= 0 {
+		return s, nil
+	}
+
+	return cases.NewNotificationDispatcher(s, c, log.Default())
+}
+
+func createBaseNotificationStrategy(c notificationsConfig) (entities.HealthNotificationStrategy, error) {
+	switch c.Kind {
+	case logNotificationKind, "":
+		return adapters.NewLogNotificationStrategy(log.Default()), nil
+	case webhookNotificationKind:
+		if len(c.Webhook.URLs) == 0 {
+			return nil, fmt.Errorf("no webhook URLs are configured")
+		}
+		if c.Webhook.Secret == "" {
+			return nil, fmt.Errorf("webhook secret is not configured")
+		}
+		return adapters.NewWebhookNotificationStrategy(c.Webhook, log.Default()), nil
+	case emailNotificationKind:
+		return adapters.NewEmailNotificationStrategy(c.Email, c.Groups, log.Default())
+	case chatNotificationKind:
+		return adapters.NewChatNotificationStrategy(c.Chat, log.Default())
+	case incidentNotificationKind:
+		return adapters.NewIncidentNotificationStrategy(c.Incident, log.Default())
+	case routerNotificationKind:
+		return createRoutingStrategy(c)
+	default:
+		return nil, fmt.Errorf("unknown notification kind '%s'", c.Kind)
+	}
+}
+
+// Creates router with channels, which are configured like single strategies and share groups with router.
+func createRoutingStrategy(c notificationsConfig) (entities.HealthNotificationStrategy, error) {
+	channels := map[string]entities.HealthNotificationStrategy{}
+	for name, channel := range c.Channels {
+		if channel.Kind == routerNotificationKind {
+			return nil, fmt.Errorf("channel %s cannot be a router", name)
+		}
+
+		if channel.Groups == nil {
+			channel.Groups = c.Groups
+		}
+
+		channel.Incident.StatePath = pathOrDefault(channel.Incident.StatePath, incidentStatePath(name))
+		s, err := createNotificationStrategy(channel)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create channel %s - %w", name, err)
+		}
+		channels[name] = s
+	}
+
+	return adapters.NewRoutingNotificationStrategy(c.Routes, channels, c.Groups, c.Tags, log.Default())
+}
+
+// Creates scheduler of escalation policies, returns nil, if there are no policies.
+func createEscalationScheduler(c escalationConfig, n notificationsConfig, r cases.MachineRepository, k cases.SilenceRepository) (*cases.EscalationScheduler, error) {
+	if len(c.Policies) == 0 {
+		return nil, nil
+	}
+
+	if err := n.Groups.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := n.Tags.Validate(); err != nil {
+		return nil, err
+	}
+
+	policies := []cases.EscalationPolicy{}
+	for _, p := range c.Policies {
+		groups, tags := p.Groups, p.Tags
+		policy := cases.EscalationPolicy{
+			Name: p.Name,
+			Matches: func(name string) bool {
+				return n.Groups.Contains(name, groups) && n.Tags.Contains(name, tags)
+			},
+		}
+
+		for _, t := range p.Tiers {
+			t.Notifications.Incident.StatePath = pathOrDefault(t.Notifications.Incident.StatePath, incidentStatePath(p.Name+"-"+t.Name))
+			s, err := createNotificationStrategy(t.Notifications)
+			if err != nil {
+				return nil, fmt.Errorf("cannot create tier %s of escalation policy %s - %w", t.Name, p.Name, err)
+			}
+			s = createSilencingDecorator(s, k, n)
+			policy.Tiers = append(policy.Tiers, cases.EscalationTier{Name: t.Name, After: t.After, Strategy: s})
+		}
+
+		policies = append(policies, policy)
+	}
+
+	return cases.NewEscalationScheduler(r, policies, k, k, log.Default())
+}
+
+// Returns state file of incident channel, so channels of router don't overwrite open incidents of each other.
+func incidentStatePath(channel string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, channel)
+
+	return fmt.Sprintf("incidents.%s.json", name)
+}
+
+func pathOrDefault(path string, defaultPath string) string {
+	if path == "" {
+		return defaultPath
+	}
+
+	return path
+}
+
+func createHandler(c chan cases.Command, d cases.MachineRepository, s entities.HealthNotificationStrategy, k cases.SilenceRepository) http.Handler {
+	m := ports.NewMachineHandler(d, c)
+
+	router := ports.NewRouter()
+	router.Handle(http.MethodPost, `^/api/v1/machines/[^/]+/report$`, ports.NewReportHandler(s, d, c))
+	router.Handle(http.MethodPost, `^/api/v1/reports$`, ports.NewBulkReportHandler(s, d, c))
+	router.Handle(http.MethodGet, `^/api/v1/machines/[^/]+$`, m)
+	router.Handle(http.MethodDelete, `^/api/v1/machines/[^/]+$`, m)
+	router.Handle(http.MethodPost, `^/api/v1/machines/[^/]+/decommission$`, m)
+	ack := ports.NewAcknowledgeHandler(d, k)
+	router.Handle(http.MethodPost, `^/api/v1/machines/[^/]+/acknowledge$`, ack)
+	router.Handle(http.MethodGet, `^/api/v1/machines/[^/]+/acknowledge$`, ack)
+	router.Handle(http.MethodDelete, `^/api/v1/machines/[^/]+/acknowledge$`, ack)
+	router.Handle(http.MethodGet, `^/api/v1/acknowledgments$`, ack)
+	silence := ports.NewSilenceHandler(k)
+	router.Handle(http.MethodGet, `^/api/v1/silences$`, silence)
+	router.Handle(http.MethodPost, `^/api/v1/silences$`, silence)
+	router.Handle(http.MethodGet, `^/api/v1/silences/[^/]+$`, silence)
+	router.Handle(http.MethodPut, `^/api/v1/silences/[^/]+$`, silence)
+	router.Handle(http.MethodDelete, `^/api/v1/silences/[^/]+$`, silence)
+	a := ports.NewAdminHandler(d)
+	router.Handle(http.MethodGet, `^/api/v1/admin/export$`, a)
+	router.Handle(http.MethodPost, `^/api/v1/admin/import$`, a)
+	if b, ok := baseRepository(d).(ports.BackupSource); ok {
+		router.Handle(http.MethodGet, `^/api/v1/admin/backup$`, ports.NewBackupHandler(b, "machines.bolt"))
+	}
+	if cache, ok := d.(ports.CacheStatsSource); ok {
+		router.Handle(http.MethodGet, `^/api/v1/admin/cache$`, ports.NewCacheStatsHandler(cache))
+	}
+	if dispatcher, ok := s.(ports.NotificationStatsSource); ok {
+		router.Handle(http.MethodGet, `^/api/v1/admin/notifications$`, ports.NewNotificationStatsHandler(dispatcher))
+	}
 
-	return ports.NewReportHandler(s, d, c)
+	return router
 }
 
 func waitForOsSignal() {
@@ -1,15 +1,479 @@
 package main
 
 import (
+	"dum/internal/machines/adapters"
 	"dum/internal/machines/cases"
 	"dum/internal/machines/ports"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestReturnHandler(t *testing.T) {
-	handler := createHandler(make(chan cases.Command))
+	handler := createHandler(
+		make(chan cases.Command),
+		adapters.NewFileRepository(adapters.DefaultFileRepositoryConfig()),
+		adapters.NewLogNotificationStrategy(log.Default()),
+		createTestSilenceRepository(t),
+	)
 
-	if _, ok := handler.(*ports.ReportHandler); !ok {
+	if _, ok := handler.(*ports.Router); !ok {
 		t.Errorf("Handler type mismatch!")
 	}
+
+	for _, path := range []string{"/api/v1/silences", "/api/v1/acknowledgments"} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		if recorder.Code != 200 {
+			t.Errorf("Response code mismatch for %s! Expected %d, but was %d!", path, 200, recorder.Code)
+		}
+	}
+}
+
+func TestCreateRepository(t *testing.T) {
+	var cases = []struct {
+		kind         string
+		shouldFail   bool
+		expectedType string
+	}{
+		{fileRepositoryKind, false, "*adapters.RecoveryFileRepositoryDecorator"},
+		{journalRepositoryKind, false, "*adapters.JournalRepository"},
+		{sqliteRepositoryKind, false, "*adapters.SqliteRepository"},
+		{boltRepositoryKind, false, "*adapters.BoltRepository"},
+		{postgresRepositoryKind, true, ""},
+		{"unknown", true, ""},
+	}
+
+	for _, testCase := range cases {
+		r, closeRepository, err := createRepository(repositoryConfig{
+			Kind:        testCase.kind,
+			Path:        filepath.Join(t.TempDir(), "machines.db"),
+			Permissions: "0600",
+		})
+
+		if testCase.shouldFail {
+			if err == nil {
+				t.Errorf("Repository of kind %s should not be created!", testCase.kind)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Repository of kind %s should be created, but got error %s", testCase.kind, err)
+			continue
+		}
+
+		if actual := typeName(r); actual != testCase.expectedType {
+			t.Errorf("Repository type mismatch! Expected %s, but was %s", testCase.expectedType, actual)
+		}
+
+		closeRepository()
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{ "Repository": { "Kind": "sqlite", "Path": "from-file.db" } }`), 0600)
+	if err != nil {
+		t.Errorf("Cannot setup test due to error %s", err)
+		return
+	}
+
+	os.Setenv(configPathVariable, path)
+	os.Setenv(repositoryPathVariable, "from-env.db")
+	defer os.Unsetenv(configPathVariable)
+	defer os.Unsetenv(repositoryPathVariable)
+
+	c, err := loadConfig()
+	if err != nil {
+		t.Errorf("Config should be loaded, but got error %s", err)
+		return
+	}
+
+	if c.Repository.Kind != sqliteRepositoryKind {
+		t.Errorf("Repository kind mismatch! Expected %s, but was %s", sqliteRepositoryKind, c.Repository.Kind)
+	}
+
+	if c.Repository.Path != "from-env.db" {
+		t.Errorf("Repository path mismatch! Expected %s, but was %s", "from-env.db", c.Repository.Path)
+	}
+}
+
+func TestCreateNotificationStrategy(t *testing.T) {
+	webhook := adapters.DefaultWebhookConfig()
+	webhook.URLs = []string{"http://localhost/hook"}
+	webhook.Secret = "secret"
+
+	incident := adapters.DefaultIncidentConfig()
+	incident.RoutingKey = "key"
+	incident.StatePath = filepath.Join(t.TempDir(), "incidents.json")
+
+	var cases = []struct {
+		c            notificationsConfig
+		shouldFail   bool
+		expectedType string
+	}{
+		{notificationsConfig{}, false, "*adapters.LogNotificationStrategy"},
+		{notificationsConfig{Kind: logNotificationKind}, false, "*adapters.LogNotificationStrategy"},
+		{notificationsConfig{Kind: webhookNotificationKind, Webhook: webhook}, false, "*adapters.WebhookNotificationStrategy"},
+		{notificationsConfig{Kind: webhookNotificationKind, Webhook: adapters.WebhookConfig{URLs: webhook.URLs}}, true, ""},
+		{notificationsConfig{Kind: webhookNotificationKind, Webhook: adapters.WebhookConfig{Secret: "secret"}}, true, ""},
+		{notificationsConfig{Kind: emailNotificationKind, Email: adapters.EmailConfig{Host: "localhost", From: "dum@localhost"}}, false, "*adapters.EmailNotificationStrategy"},
+		{notificationsConfig{Kind: emailNotificationKind}, true, ""},
+		{notificationsConfig{Kind: chatNotificationKind, Chat: adapters.ChatConfig{Channels: []adapters.ChatChannel{{Name: "ops", Format: adapters.SlackChatFormat, URL: "http://localhost/hook"}}}}, false, "*adapters.ChatNotificationStrategy"},
+		{notificationsConfig{Kind: chatNotificationKind}, true, ""},
+		{notificationsConfig{Kind: incidentNotificationKind, Incident: incident}, false, "*adapters.IncidentNotificationStrategy"},
+		{notificationsConfig{Kind: incidentNotificationKind, Incident: adapters.DefaultIncidentConfig()}, true, ""},
+		{notificationsConfig{Kind: logNotificationKind, Throttle: adapters.ThrottleConfig{DedupWindow: time.Minute}}, false, "*adapters.ThrottlingNotificationDecorator"},
+		{notificationsConfig{Kind: "pigeon"}, true, ""},
+	}
+
+	for _, testCase := range cases {
+		s, err := createNotificationStrategy(testCase.c)
+
+		if testCase.shouldFail {
+			if err == nil {
+				t.Errorf("Strategy of kind %s should not be created!", testCase.c.Kind)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Strategy of kind %s should be created, but got error %s", testCase.c.Kind, err)
+			continue
+		}
+
+		if actual := typeName(s); actual != testCase.expectedType {
+			t.Errorf("Strategy type mismatch! Expected %s, but was %s", testCase.expectedType, actual)
+		}
+	}
+}
+
+func TestWebhookSecretFromEnvironment(t *testing.T) {
+	os.Setenv(webhookSecretVariable, "from-env")
+	defer os.Unsetenv(webhookSecretVariable)
+
+	c, err := loadConfig()
+	if err != nil {
+		t.Fatalf("Config should be loaded, but got error %s", err)
+	}
+
+	if c.Notifications.Webhook.Secret != "from-env" {
+		t.Errorf("Webhook secret mismatch! Expected %s, but was %s", "from-env", c.Notifications.Webhook.Secret)
+	}
+
+	if c.Notifications.Kind != logNotificationKind {
+		t.Errorf("Notification kind mismatch! Expected %s, but was %s", logNotificationKind, c.Notifications.Kind)
+	}
+}
+
+func TestRouterConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	raw := fmt.Sprintf(`{ "Notifications": {
+		"Kind": "router",
+		"Tags": { "dc": ["dc-*"] },
+		"Channels": {
+			"pager": { "Kind": "incident", "Incident": { "StatePath": %q } },
+			"hooks": { "Kind": "webhook", "Webhook": { "URLs": ["http://localhost/hook"] } },
+			"log": {}
+		},
+		"Routes": [
+			{ "Levels": ["Danger"], "Tags": ["dc"], "Channels": ["pager", "hooks"] },
+			{ "Channels": ["log"] }
+		]
+	} }`, filepath.Join(dir, "incidents.json"))
+	if err := os.WriteFile(path, []byte(raw), 0600); err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+
+	os.Setenv(configPathVariable, path)
+	os.Setenv(webhookSecretVariable, "from-env")
+	os.Setenv(incidentRoutingKeyVariable, "key-from-env")
+	defer os.Unsetenv(configPathVariable)
+	defer os.Unsetenv(webhookSecretVariable)
+	defer os.Unsetenv(incidentRoutingKeyVariable)
+
+	c, err := loadConfig()
+	if err != nil {
+		t.Fatalf("Config should be loaded, but got error %s", err)
+	}
+
+	hooks := c.Notifications.Channels["hooks"].Webhook
+	if hooks.Secret != "from-env" || hooks.Timeout != adapters.DefaultWebhookConfig().Timeout {
+		t.Errorf("Channel should get defaults and secrets, but was %+v", hooks)
+	}
+
+	if kind := c.Notifications.Channels["log"].Kind; kind != logNotificationKind {
+		t.Errorf("Channel kind mismatch! Expected %s, but was %s", logNotificationKind, kind)
+	}
+
+	s, err := createNotificationStrategy(c.Notifications)
+	if err != nil {
+		t.Fatalf("Router should be created, but got error %s", err)
+	}
+
+	if actual := typeName(s); actual != "*adapters.RoutingNotificationStrategy" {
+		t.Errorf("Strategy type mismatch! Expected %s, but was %s", "*adapters.RoutingNotificationStrategy", actual)
+	}
+
+	c.Notifications.Channels["nested"] = notificationsConfig{Kind: routerNotificationKind}
+	if _, err := createNotificationStrategy(c.Notifications); err == nil {
+		t.Error("Nested router should not be created!")
+	}
+}
+
+func TestIncidentStatePath(t *testing.T) {
+	var cases = []struct {
+		channel  string
+		expected string
+	}{
+		{"pager", "incidents.pager.json"},
+		{"dc-pager_2", "incidents.dc-pager_2.json"},
+		{"../tier 2", "incidents.___tier_2.json"},
+	}
+
+	for _, testCase := range cases {
+		if actual := incidentStatePath(testCase.channel); actual != testCase.expected {
+			t.Errorf("State path mismatch! Expected %s, but was %s", testCase.expected, actual)
+		}
+	}
+}
+
+func TestEscalationConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	raw := `{
+		"Notifications": { "Tags": { "dc": ["dc-*"] } },
+		"Escalation": { "Policies": [ { "Name": "dc", "Tags": ["dc"], "Tiers": [
+			{ "Name": "tier 2", "After": 1800000000000, "Notifications": { "Kind": "webhook", "Webhook": { "URLs": ["http://localhost/hook"] } } },
+			{ "Name": "management", "After": 7200000000000, "Notifications": {} }
+		] } ] }
+	}`
+	if err := os.WriteFile(path, []byte(raw), 0600); err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+
+	os.Setenv(configPathVariable, path)
+	os.Setenv(webhookSecretVariable, "from-env")
+	defer os.Unsetenv(configPathVariable)
+	defer os.Unsetenv(webhookSecretVariable)
+
+	c, err := loadConfig()
+	if err != nil {
+		t.Fatalf("Config should be loaded, but got error %s", err)
+	}
+
+	tiers := c.Escalation.Policies[0].Tiers
+	if tiers[0].Notifications.Webhook.Secret != "from-env" || tiers[1].Notifications.Kind != logNotificationKind {
+		t.Errorf("Tiers should get defaults and secrets, but was %+v", tiers)
+	}
+
+	if c.Escalation.Interval != time.Minute {
+		t.Errorf("Escalation interval mismatch! Expected %s, but was %s", time.Minute, c.Escalation.Interval)
+	}
+
+	r := adapters.NewFileRepository(adapters.DefaultFileRepositoryConfig())
+	s, err := createEscalationScheduler(c.Escalation, c.Notifications, r, createTestSilenceRepository(t))
+	if err != nil || s == nil {
+		t.Fatalf("Scheduler should be created, but got error %v", err)
+	}
+
+	s, err = createEscalationScheduler(escalationConfig{}, c.Notifications, r, createTestSilenceRepository(t))
+	if err != nil || s != nil {
+		t.Errorf("Scheduler should not be created without policies, but was %v", s)
+	}
+}
+
+func TestCreateFileRepositoryConfig(t *testing.T) {
+	c, err := createFileRepositoryConfig(repositoryConfig{Permissions: "0640", Generations: 2})
+	if err != nil {
+		t.Errorf("Config should be created, but got error %s", err)
+		return
+	}
+
+	if c.Path != adapters.RepositoryFileName {
+		t.Errorf("Path mismatch! Expected %s, but was %s", adapters.RepositoryFileName, c.Path)
+	}
+
+	if c.Permissions != 0640 {
+		t.Errorf("Permissions mismatch! Expected %o, but was %o", 0640, c.Permissions)
+	}
+
+	_, err = createFileRepositoryConfig(repositoryConfig{Permissions: "rw-r--r--"})
+	if err == nil {
+		t.Errorf("Invalid permissions should not be accepted!")
+	}
+}
+
+func TestExclusiveRepositoryRefusesSecondWriter(t *testing.T) {
+	for _, kind := range []string{fileRepositoryKind, journalRepositoryKind} {
+		c := repositoryConfig{
+			Kind:        kind,
+			Path:        filepath.Join(t.TempDir(), "machines.json"),
+			Permissions: "0600",
+			Exclusive:   true,
+		}
+
+		_, closeRepository, err := createRepository(c)
+		if err != nil {
+			t.Errorf("Repository of kind %s should be created, but got error %s", kind, err)
+			continue
+		}
+
+		_, _, err = createRepository(c)
+		if !errors.Is(err, cases.ErrStoreBusy) {
+			t.Errorf("Second writer of kind %s should be refused, but got error %v", kind, err)
+		}
+
+		closeRepository()
+
+		_, closeRepository, err = createRepository(c)
+		if err != nil {
+			t.Errorf("Repository of kind %s should be created after first writer is closed, but got error %s", kind, err)
+			continue
+		}
+
+		closeRepository()
+	}
+}
+
+func TestSilencesShareRepositoryStorage(t *testing.T) {
+	var cases = []struct {
+		kind, expected string
+	}{
+		{sqliteRepositoryKind, "*adapters.SqlSilenceRepository"},
+		{boltRepositoryKind, "*adapters.BoltSilenceRepository"},
+		{fileRepositoryKind, "*adapters.FileSilenceRepository"},
+	}
+
+	for _, testCase := range cases {
+		dir := t.TempDir()
+		c := defaultConfig()
+		c.Repository.Kind = testCase.kind
+		c.Repository.Path = filepath.Join(dir, "machines")
+		c.Repository.CacheSize = 10
+		c.Silences.Path = filepath.Join(dir, "silences.json")
+
+		r, silences, closeRepository, err := createRepositories(c)
+		if err != nil {
+			t.Errorf("Repositories of kind %s should be created, but got error %s", testCase.kind, err)
+			continue
+		}
+
+		if actual := typeName(silences); actual != testCase.expected {
+			t.Errorf("Silence repository type of kind %s mismatch! Expected %s, but was %s", testCase.kind, testCase.expected, actual)
+		}
+
+		if actual := typeName(r); actual != "*adapters.CachingRepositoryDecorator" {
+			t.Errorf("Repository type mismatch! Expected %s, but was %s", "*adapters.CachingRepositoryDecorator", actual)
+		}
+
+		closeRepository()
+	}
+}
+
+func TestCreateCachingRepository(t *testing.T) {
+	r, closeRepository, err := createRepository(repositoryConfig{
+		Kind:      sqliteRepositoryKind,
+		Path:      filepath.Join(t.TempDir(), "machines.db"),
+		CacheSize: 10,
+	})
+	if err != nil {
+		t.Fatalf("Repository should be created, but got error %s", err)
+	}
+	defer closeRepository()
+
+	if actual := typeName(r); actual != "*adapters.CachingRepositoryDecorator" {
+		t.Errorf("Repository type mismatch! Expected %s, but was %s", "*adapters.CachingRepositoryDecorator", actual)
+	}
+
+	router := createHandler(make(chan cases.Command), r, adapters.NewLogNotificationStrategy(log.Default()), createTestSilenceRepository(t))
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/admin/cache", nil))
+	if recorder.Code != 200 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 200, recorder.Code)
+	}
+}
+
+func TestBoltBackupEndpoint(t *testing.T) {
+	r, closeRepository, err := createRepository(repositoryConfig{
+		Kind:      boltRepositoryKind,
+		Path:      filepath.Join(t.TempDir(), "machines.bolt"),
+		CacheSize: 10,
+	})
+	if err != nil {
+		t.Fatalf("Repository should be created, but got error %s", err)
+	}
+	defer closeRepository()
+
+	router := createHandler(make(chan cases.Command), r, adapters.NewLogNotificationStrategy(log.Default()), createTestSilenceRepository(t))
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/admin/backup", nil))
+	if recorder.Code != 200 || recorder.Body.Len() == 0 {
+		t.Errorf("Backup of cached bolt repository should be written, but got %d with %d bytes", recorder.Code, recorder.Body.Len())
+	}
+}
+
+func TestCreateDispatcher(t *testing.T) {
+	base := adapters.NewLogNotificationStrategy(log.Default())
+	s, err := createDispatcher(base, cases.DefaultDispatcherConfig())
+	if err != nil {
+		t.Fatalf("Dispatcher should be created, but got error %s", err)
+	}
+	defer s.(io.Closer).Close()
+
+	if actual := typeName(s); actual != "*cases.NotificationDispatcher" {
+		t.Errorf("Strategy type mismatch! Expected %s, but was %s", "*cases.NotificationDispatcher", actual)
+	}
+
+	router := createHandler(make(chan cases.Command), adapters.NewFileRepository(adapters.DefaultFileRepositoryConfig()), s, createTestSilenceRepository(t))
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/admin/notifications", nil))
+	if recorder.Code != 200 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 200, recorder.Code)
+	}
+
+	if s, _ := createDispatcher(base, cases.DispatcherConfig{}); s != base {
+		t.Errorf("Strategy should not be wrapped without workers, but was %s", typeName(s))
+	}
+}
+
+func TestCreateFlapDetector(t *testing.T) {
+	base := adapters.NewLogNotificationStrategy(log.Default())
+	s, err := createFlapDetector(base, adapters.FlapConfig{Transitions: 4, Window: time.Hour, RecoveryReports: 2})
+	if err != nil {
+		t.Fatalf("Flap detector should be created, but got error %s", err)
+	}
+
+	if actual := typeName(s); actual != "*adapters.FlappingNotificationDecorator" {
+		t.Errorf("Strategy type mismatch! Expected %s, but was %s", "*adapters.FlappingNotificationDecorator", actual)
+	}
+
+	if _, err := createFlapDetector(base, adapters.FlapConfig{Transitions: 4}); err == nil {
+		t.Error("Expected error for flap detection without window, but was nil")
+	}
+
+	if s, _ := createFlapDetector(base, adapters.FlapConfig{}); s != base {
+		t.Errorf("Strategy should not be wrapped without flap detection, but was %s", typeName(s))
+	}
+}
+
+func createTestSilenceRepository(t *testing.T) cases.SilenceRepository {
+	r, err := adapters.NewFileSilenceRepository(filepath.Join(t.TempDir(), adapters.SilencesFileName))
+	if err != nil {
+		t.Fatalf("Cannot create silence repository %s", err)
+	}
+
+	return r
+}
+
+func typeName(i interface{}) string {
+	return fmt.Sprintf("%T", i)
 }
@@ -0,0 +1,113 @@
+package adapters
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Writes data to file atomically - to temporary file at first, which is flushed to disk and renamed then.
+// Replaced content is kept in rotating set of previous generations: path.1 is the latest one.
+func writeFileAtomically(path string, data []byte, perm os.FileMode, generations int) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tmpPath, perm)
+	if err != nil {
+		return err
+	}
+
+	err = rotateGenerations(path, generations)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// Returns path of specific previous generation of file.
+func generationPath(path string, generation int) string {
+	return fmt.Sprintf("%s.%d", path, generation)
+}
+
+// Shifts previous generations and keeps current file as the latest of them. Current file itself
+// stays in place, so it is never missing, even if process crashes in the middle of rotation.
+func rotateGenerations(path string, generations int) error {
+	if generations < 1 {
+		return nil
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	for i := generations - 1; i > 0; i-- {
+		err := os.Rename(generationPath(path, i), generationPath(path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	latest := generationPath(path, 1)
+	os.Remove(latest)
+
+	if err := os.Link(path, latest); err == nil {
+		return nil
+	}
+
+	return copyFile(path, latest)
+}
+
+func copyFile(from string, to string) error {
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return err
+	}
+
+	target, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(target, source)
+	if err == nil {
+		err = target.Sync()
+	}
+
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,78 @@
+package adapters
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomicallyKeepsGenerations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), RepositoryFileName)
+
+	for _, content := range []string{"first", "second", "third", "fourth"} {
+		err := writeFileAtomically(path, []byte(content), 0600, 2)
+		if err != nil {
+			t.Errorf("Write should not return error, but was %s", err)
+			return
+		}
+	}
+
+	expected := map[string]string{
+		path:                    "fourth",
+		generationPath(path, 1): "third",
+		generationPath(path, 2): "second",
+	}
+
+	for file, content := range expected {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			t.Errorf("Cannot read %s due to error %s", file, err)
+			continue
+		}
+
+		if string(raw) != content {
+			t.Errorf("Content of %s mismatch! Expected %s, but was %s", file, content, string(raw))
+		}
+	}
+
+	if _, err := os.Stat(generationPath(path, 3)); !os.IsNotExist(err) {
+		t.Errorf("Only 2 generations should be kept!")
+	}
+}
+
+func TestWriteFileAtomicallySetsPermissionsAndCleansUp(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, RepositoryFileName)
+
+	err := writeFileAtomically(path, []byte("{}"), 0640, 0)
+	if err != nil {
+		t.Errorf("Write should not return error, but was %s", err)
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Errorf("Cannot stat file due to error %s", err)
+		return
+	}
+
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("Permissions mismatch! Expected %o, but was %o", 0640, info.Mode().Perm())
+	}
+
+	entries, _ := os.ReadDir(dir)
+
+	if len(entries) != 1 {
+		t.Errorf("Temporary files should be removed, but directory contains %d entries", len(entries))
+	}
+}
+
+func TestWriteFileAtomicallyFailsForMissingDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", RepositoryFileName)
+
+	err := writeFileAtomically(path, []byte("{}"), 0600, 1)
+
+	if err == nil {
+		t.Errorf("Expected error, but was nil!")
+	}
+}
@@ -0,0 +1,248 @@
+package adapters
+
+import (
+	"dum/internal/machines/entities"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+var machinesBucket []byte = []byte("machines")
+var healthIndexBucket []byte = []byte("health")
+
+// Repository working with embedded bbolt key-value database. Every machine is stored under its own key,
+// active machines are additionally indexed by health level.
+type BoltRepository struct {
+	db *bolt.DB
+}
+
+func (r *BoltRepository) Load(id entities.MachineId) (*entities.Machine, error) {
+	var dto *machineDto
+
+	err := r.db.View(func(tx *bolt.Tx) error {
+		var err error
+		dto, err = getMachineDto(tx, id.String())
+		return err
+	})
+
+	if err != nil || dto == nil {
+		return nil, err
+	}
+
+	return dto.toMachine()
+}
+
+func (r *BoltRepository) List() ([]*entities.Machine, error) {
+	machines := []*entities.Machine{}
+
+	// keys are iterated in byte order, so machines are ordered by id
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(machinesBucket).ForEach(func(k, v []byte) error {
+			var dto machineDto
+			err := json.Unmarshal(v, &dto)
+			if err != nil {
+				return err
+			}
+
+			machine, err := dto.toMachine()
+			if err != nil {
+				return err
+			}
+
+			machines = append(machines, machine)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return machines, nil
+}
+
+func (r *BoltRepository) Save(machine *entities.Machine) error {
+	id := machine.Id.String()
+	newDto := newMachineDto(machine, uuid.NewString())
+
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		current, err := getMachineDto(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if current == nil {
+			err = checkVersion(machine, "")
+		} else {
+			err = checkVersion(machine, current.Version)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if current != nil {
+			err = unindexMachine(tx, current)
+			if err != nil {
+				return err
+			}
+		}
+
+		raw, err := json.Marshal(&newDto)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Bucket(machinesBucket).Put([]byte(id), raw)
+		if err != nil {
+			return err
+		}
+
+		return indexMachine(tx, &newDto, machine.GetHealthLevel())
+	})
+
+	if err != nil {
+		return err
+	}
+
+	machine.SetVersion(entities.MachineVersion(newDto.Version))
+	return nil
+}
+
+func (r *BoltRepository) Delete(id entities.MachineId) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		current, err := getMachineDto(tx, id.String())
+		if err != nil || current == nil {
+			return err
+		}
+
+		err = unindexMachine(tx, current)
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(machinesBucket).Delete([]byte(id.String()))
+	})
+}
+
+// Returns ids of active machines with specific health level.
+func (r *BoltRepository) FindByHealthLevel(level entities.HealthLevel) ([]entities.MachineId, error) {
+	ids := []entities.MachineId{}
+
+	err := r.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(healthIndexBucket).Bucket(healthLevelKey(level))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			id, err := uuid.ParseBytes(k)
+			if err != nil {
+				return err
+			}
+
+			ids = append(ids, entities.MachineId(id))
+			return nil
+		})
+	})
+
+	return ids, err
+}
+
+// Writes consistent copy of the whole database to writer without stopping other readers and writers.
+func (r *BoltRepository) Backup(w io.Writer) (int64, error) {
+	var written int64
+
+	err := r.db.View(func(tx *bolt.Tx) error {
+		var err error
+		written, err = tx.WriteTo(w)
+		return err
+	})
+
+	return written, err
+}
+
+// Returns repository of silences and acknowledgments, which works with the same database.
+func (r *BoltRepository) Silences() *BoltSilenceRepository {
+	return &BoltSilenceRepository{db: r.db}
+}
+
+// Closes underlying database.
+func (r *BoltRepository) Close() error {
+	return r.db.Close()
+}
+
+func getMachineDto(tx *bolt.Tx, id string) (*machineDto, error) {
+	raw := tx.Bucket(machinesBucket).Get([]byte(id))
+	if raw == nil {
+		return nil, nil
+	}
+
+	var dto machineDto
+	err := json.Unmarshal(raw, &dto)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto, nil
+}
+
+func indexMachine(tx *bolt.Tx, dto *machineDto, level entities.HealthLevel) error {
+	if dto.Decommissioned {
+		return nil
+	}
+
+	bucket, err := tx.Bucket(healthIndexBucket).CreateBucketIfNotExists(healthLevelKey(level))
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put([]byte(dto.Id), []byte{})
+}
+
+func unindexMachine(tx *bolt.Tx, dto *machineDto) error {
+	return tx.Bucket(healthIndexBucket).ForEach(func(k, v []byte) error {
+		bucket := tx.Bucket(healthIndexBucket).Bucket(k)
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.Delete([]byte(dto.Id))
+	})
+}
+
+func healthLevelKey(level entities.HealthLevel) []byte {
+	return []byte(strconv.Itoa(int(level)))
+}
+
+// Opens bbolt database at specific path, creating it if necessary.
+func NewBoltRepository(path string) (*BoltRepository, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{machinesBucket, healthIndexBucket, silencesBucket, acknowledgmentsBucket, escalationsBucket} {
+			_, err := tx.CreateBucketIfNotExists(name)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltRepository{
+		db: db,
+	}, nil
+}
@@ -0,0 +1,141 @@
+package adapters
+
+import (
+	"dum/internal/machines/cases"
+	"dum/internal/machines/entities"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBoltRepositoryBehavior(t *testing.T) {
+	repo := newTestBoltRepository(t)
+
+	testRepositoryBehavior(t, func(t *testing.T) cases.MachineRepository {
+		// bbolt database can be opened only once, so instances are sharing it
+		return &BoltRepository{
+			db: repo.db,
+		}
+	})
+}
+
+func TestBoltHealthLevelIndex(t *testing.T) {
+	repo := newTestBoltRepository(t)
+	machine := createTestMachine()
+
+	err := repo.Save(machine)
+	if err != nil {
+		t.Errorf("Failed to save machine, because of error %s", err)
+		return
+	}
+
+	assertIndexed(t, repo, entities.Danger, machine.Id, true)
+
+	loadedMachine, _ := repo.Load(machine.Id)
+	loadedMachine.Report([]entities.MissingUpdate{}, &notificationStrategyMock{})
+	err = repo.Save(loadedMachine)
+	if err != nil {
+		t.Errorf("Failed to save machine, because of error %s", err)
+		return
+	}
+
+	assertIndexed(t, repo, entities.Danger, machine.Id, false)
+	assertIndexed(t, repo, entities.Healthy, machine.Id, true)
+
+	loadedMachine, _ = repo.Load(machine.Id)
+	loadedMachine.Decommission()
+	err = repo.Save(loadedMachine)
+	if err != nil {
+		t.Errorf("Failed to save machine, because of error %s", err)
+		return
+	}
+
+	assertIndexed(t, repo, entities.Healthy, machine.Id, false)
+}
+
+func TestBoltDeleteRemovesFromIndex(t *testing.T) {
+	repo := newTestBoltRepository(t)
+	machine := createTestMachine()
+
+	_ = repo.Save(machine)
+	err := repo.Delete(machine.Id)
+	if err != nil {
+		t.Errorf("Failed to delete machine, because of error %s", err)
+		return
+	}
+
+	assertIndexed(t, repo, entities.Danger, machine.Id, false)
+}
+
+func TestBoltBackup(t *testing.T) {
+	repo := newTestBoltRepository(t)
+	machine := createTestMachine()
+	_ = repo.Save(machine)
+
+	path := filepath.Join(t.TempDir(), "backup.db")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Errorf("Cannot setup test due to error %s", err)
+		return
+	}
+
+	written, err := repo.Backup(file)
+	file.Close()
+
+	if err != nil {
+		t.Errorf("Backup should not return error, but was %s", err)
+		return
+	}
+
+	if written == 0 {
+		t.Errorf("Backup should not be empty!")
+	}
+
+	restored, err := NewBoltRepository(path)
+	if err != nil {
+		t.Errorf("Cannot open backup due to error %s", err)
+		return
+	}
+	defer restored.Close()
+
+	loadedMachine, err := restored.Load(machine.Id)
+	if err != nil {
+		t.Errorf("Failed to load machine from backup, because of error %s", err)
+		return
+	}
+
+	assertMachinesEqual(t, machine, loadedMachine)
+}
+
+func newTestBoltRepository(t *testing.T) *BoltRepository {
+	repo, err := NewBoltRepository(filepath.Join(t.TempDir(), "machines.bolt"))
+	if err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+func assertIndexed(t *testing.T, repo *BoltRepository, level entities.HealthLevel, id entities.MachineId, expected bool) {
+	ids, err := repo.FindByHealthLevel(level)
+	if err != nil {
+		t.Errorf("Failed to find machines, because of error %s", err)
+		return
+	}
+
+	actual := false
+	for _, indexed := range ids {
+		actual = actual || indexed == id
+	}
+
+	if actual != expected {
+		t.Errorf("Index mismatch for level %d! Expected machine to be indexed - %t, but was %t", level, expected, actual)
+	}
+}
+
+type notificationStrategyMock struct{}
+
+func (s *notificationStrategyMock) Notify(e entities.HealthEvent) error {
+	return nil
+}
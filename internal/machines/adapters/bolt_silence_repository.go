@@ -0,0 +1,205 @@
+package adapters
+
+import (
+	"dum/internal/machines/entities"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var silencesBucket []byte = []byte("silences")
+var acknowledgmentsBucket []byte = []byte("acknowledgments")
+var escalationsBucket []byte = []byte("escalations")
+
+// Repository of silences and acknowledgments, which keeps them in the same bbolt database as machines.
+// Silences are stored under their ids, acknowledgments and escalation progress - under ids of machines.
+type BoltSilenceRepository struct {
+	db *bolt.DB
+}
+
+func (r *BoltSilenceRepository) ListSilences() ([]entities.Silence, error) {
+	silences := []entities.Silence{}
+
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(silencesBucket).ForEach(func(k, v []byte) error {
+			s, err := decodeSilence(v)
+			if err != nil {
+				return err
+			}
+
+			silences = append(silences, s)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sortSilences(silences)
+	return silences, nil
+}
+
+func (r *BoltSilenceRepository) LoadSilence(id entities.SilenceId) (*entities.Silence, error) {
+	var result *entities.Silence
+	err := r.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(silencesBucket).Get([]byte(id.String()))
+		if raw == nil {
+			return nil
+		}
+
+		s, err := decodeSilence(raw)
+		result = &s
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *BoltSilenceRepository) SaveSilence(s entities.Silence) error {
+	raw, err := json.Marshal(newSilenceDto(s))
+	if err != nil {
+		return err
+	}
+
+	return r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(silencesBucket).Put([]byte(s.Id.String()), raw)
+	})
+}
+
+func (r *BoltSilenceRepository) DeleteSilence(id entities.SilenceId) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(silencesBucket).Delete([]byte(id.String()))
+	})
+}
+
+func (r *BoltSilenceRepository) ListAcknowledgments() ([]entities.Acknowledgment, error) {
+	acks := []entities.Acknowledgment{}
+
+	// keys are iterated in byte order, so acknowledgments are ordered by machine id
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(acknowledgmentsBucket).ForEach(func(k, v []byte) error {
+			a, err := decodeAcknowledgment(v)
+			if err != nil {
+				return err
+			}
+
+			acks = append(acks, a)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return acks, nil
+}
+
+func (r *BoltSilenceRepository) LoadAcknowledgment(id entities.MachineId) (*entities.Acknowledgment, error) {
+	var result *entities.Acknowledgment
+	err := r.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(acknowledgmentsBucket).Get([]byte(id.String()))
+		if raw == nil {
+			return nil
+		}
+
+		a, err := decodeAcknowledgment(raw)
+		result = &a
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *BoltSilenceRepository) SaveAcknowledgment(a entities.Acknowledgment) error {
+	raw, err := json.Marshal(newAcknowledgmentDto(a))
+	if err != nil {
+		return err
+	}
+
+	return r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(acknowledgmentsBucket).Put([]byte(a.MachineId.String()), raw)
+	})
+}
+
+func (r *BoltSilenceRepository) DeleteAcknowledgment(id entities.MachineId) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(acknowledgmentsBucket).Delete([]byte(id.String()))
+	})
+}
+
+func (r *BoltSilenceRepository) IsAcknowledged(id entities.MachineId, since time.Time) (bool, error) {
+	a, err := r.LoadAcknowledgment(id)
+	if err != nil || a == nil {
+		return false, err
+	}
+
+	return a.Covers(since), nil
+}
+
+func (r *BoltSilenceRepository) LoadEscalation(id entities.MachineId, since time.Time) (int, error) {
+	notified := 0
+	err := r.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(escalationsBucket).Get([]byte(id.String()))
+		if raw == nil {
+			return nil
+		}
+
+		var dto escalationDto
+		if err := json.Unmarshal(raw, &dto); err != nil {
+			return err
+		}
+
+		if dto.Since.Equal(since) {
+			notified = dto.Notified
+		}
+		return nil
+	})
+
+	return notified, err
+}
+
+func (r *BoltSilenceRepository) SaveEscalation(id entities.MachineId, since time.Time, notified int) error {
+	raw, err := json.Marshal(escalationDto{MachineId: id.String(), Since: since, Notified: notified})
+	if err != nil {
+		return err
+	}
+
+	return r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(escalationsBucket).Put([]byte(id.String()), raw)
+	})
+}
+
+func (r *BoltSilenceRepository) DeleteEscalation(id entities.MachineId) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(escalationsBucket).Delete([]byte(id.String()))
+	})
+}
+
+func decodeSilence(raw []byte) (entities.Silence, error) {
+	var dto silenceDto
+	if err := json.Unmarshal(raw, &dto); err != nil {
+		return entities.Silence{}, err
+	}
+
+	return dto.toSilence()
+}
+
+func decodeAcknowledgment(raw []byte) (entities.Acknowledgment, error) {
+	var dto acknowledgmentDto
+	if err := json.Unmarshal(raw, &dto); err != nil {
+		return entities.Acknowledgment{}, err
+	}
+
+	return dto.toAcknowledgment()
+}
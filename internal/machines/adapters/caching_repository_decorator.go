@@ -0,0 +1,133 @@
+package adapters
+
+import (
+	"container/list"
+	"dum/internal/machines/cases"
+	"dum/internal/machines/entities"
+	"sync"
+)
+
+// Decorator for any repository, keeping recently loaded machines in bounded LRU cache. Cached machines
+// are remembered with their versions and every save still goes to decorated repository, so stale cache
+// can't bypass optimistic locking - conflicting machine is invalidated and reloaded on retry.
+type CachingRepositoryDecorator struct {
+	repo     cases.MachineRepository
+	mu       *sync.Mutex
+	capacity int
+	lru      *list.List
+	entries  map[entities.MachineId]*list.Element
+	// Incremented on every change, so load can detect, that its result became stale while it was loading.
+	epoch uint64
+	stats cases.CacheStats
+}
+
+func (r *CachingRepositoryDecorator) Load(id entities.MachineId) (*entities.Machine, error) {
+	r.mu.Lock()
+	if element, ok := r.entries[id]; ok {
+		r.lru.MoveToFront(element)
+		r.stats.Hits++
+		machine := element.Value.(*entities.Machine).Clone()
+		r.mu.Unlock()
+		return machine, nil
+	}
+
+	r.stats.Misses++
+	epoch := r.epoch
+	r.mu.Unlock()
+
+	machine, err := r.repo.Load(id)
+	if err != nil || machine == nil {
+		return machine, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.epoch == epoch {
+		r.put(machine)
+	}
+
+	return machine, nil
+}
+
+func (r *CachingRepositoryDecorator) List() ([]*entities.Machine, error) {
+	return r.repo.List()
+}
+
+func (r *CachingRepositoryDecorator) Save(machine *entities.Machine) error {
+	err := r.repo.Save(machine)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.epoch++
+
+	if err != nil {
+		// machine could be changed by somebody else, so cached version can't be trusted anymore
+		r.invalidate(machine.Id)
+		return err
+	}
+
+	r.put(machine)
+	return nil
+}
+
+func (r *CachingRepositoryDecorator) Delete(id entities.MachineId) error {
+	err := r.repo.Delete(id)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.epoch++
+	r.invalidate(id)
+
+	return err
+}
+
+// Returns current cache counters.
+func (r *CachingRepositoryDecorator) Stats() cases.CacheStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := r.stats
+	stats.Size = r.lru.Len()
+	stats.Capacity = r.capacity
+	return stats
+}
+
+// Remembers copy of machine, evicting least recently used one, if cache is full.
+// Returns decorated repository, so its own capabilities, e.g. health index, can be used.
+func (r *CachingRepositoryDecorator) Unwrap() cases.MachineRepository {
+	return r.repo
+}
+
+func (r *CachingRepositoryDecorator) put(machine *entities.Machine) {
+	if element, ok := r.entries[machine.Id]; ok {
+		element.Value = machine.Clone()
+		r.lru.MoveToFront(element)
+		return
+	}
+
+	r.entries[machine.Id] = r.lru.PushFront(machine.Clone())
+	if r.lru.Len() > r.capacity {
+		oldest := r.lru.Back()
+		r.lru.Remove(oldest)
+		delete(r.entries, oldest.Value.(*entities.Machine).Id)
+		r.stats.Evictions++
+	}
+}
+
+func (r *CachingRepositoryDecorator) invalidate(id entities.MachineId) {
+	if element, ok := r.entries[id]; ok {
+		r.lru.Remove(element)
+		delete(r.entries, id)
+		r.stats.Invalidations++
+	}
+}
+
+func NewCachingRepositoryDecorator(baseRepository cases.MachineRepository, capacity int) *CachingRepositoryDecorator {
+	return &CachingRepositoryDecorator{
+		repo:     baseRepository,
+		mu:       &sync.Mutex{},
+		capacity: capacity,
+		lru:      list.New(),
+		entries:  map[entities.MachineId]*list.Element{},
+	}
+}
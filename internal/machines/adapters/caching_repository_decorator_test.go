@@ -0,0 +1,105 @@
+package adapters
+
+import (
+	"dum/internal/machines/cases"
+	"dum/internal/machines/entities"
+	"testing"
+)
+
+func TestCachingRepositoryBehavior(t *testing.T) {
+	base := newTestJournalRepository(t, createTestJournalConfig(t, 100))
+
+	testRepositoryBehavior(t, func(t *testing.T) cases.MachineRepository {
+		// every instance has its own cache, like several service instances sharing storage
+		return NewCachingRepositoryDecorator(base, 2)
+	})
+}
+
+func TestCacheHitsAndMisses(t *testing.T) {
+	base := newTestJournalRepository(t, createTestJournalConfig(t, 100))
+	machine := createTestMachine()
+	base.Save(machine)
+	repo := NewCachingRepositoryDecorator(base, 10)
+
+	for i := 0; i < 3; i++ {
+		loadedMachine, err := repo.Load(machine.Id)
+		if err != nil {
+			t.Fatalf("Failed to load machine, because of error %s", err)
+		}
+
+		assertMachinesEqual(t, machine, loadedMachine)
+	}
+
+	stats := repo.Stats()
+	if stats.Misses != 1 || stats.Hits != 2 || stats.Size != 1 {
+		t.Errorf("Unexpected cache stats %+v", stats)
+	}
+}
+
+func TestCacheReturnsCopies(t *testing.T) {
+	base := newTestJournalRepository(t, createTestJournalConfig(t, 100))
+	machine := createTestMachine()
+	base.Save(machine)
+	repo := NewCachingRepositoryDecorator(base, 10)
+
+	loadedMachine, _ := repo.Load(machine.Id)
+	loadedMachine.Decommission()
+	loadedMachine.SetVersion("changed")
+
+	cachedMachine, _ := repo.Load(machine.Id)
+	if cachedMachine.IsDecommissioned() || cachedMachine.GetVersion() != machine.GetVersion() {
+		t.Errorf("Changes of loaded machine should not affect cache!")
+	}
+}
+
+func TestStaleCacheDoesNotBypassOptimisticLock(t *testing.T) {
+	base := newTestJournalRepository(t, createTestJournalConfig(t, 100))
+	machine := createTestMachine()
+	base.Save(machine)
+	stale := NewCachingRepositoryDecorator(base, 10)
+	other := NewCachingRepositoryDecorator(base, 10)
+
+	stale.Load(machine.Id)
+	changed, _ := other.Load(machine.Id)
+	err := other.Save(changed)
+	if err != nil {
+		t.Fatalf("Failed to save machine, because of error %s", err)
+	}
+
+	cachedMachine, _ := stale.Load(machine.Id)
+	assertOptimisticLock(t, stale.Save(cachedMachine))
+
+	if stats := stale.Stats(); stats.Invalidations != 1 || stats.Size != 0 {
+		t.Errorf("Conflicting machine should be invalidated, but stats were %+v", stats)
+	}
+
+	reloadedMachine, _ := stale.Load(machine.Id)
+	if reloadedMachine.GetVersion() != changed.GetVersion() {
+		t.Errorf("Version mismatch! Expected %s, but was %s", changed.GetVersion(), reloadedMachine.GetVersion())
+	}
+
+	err = stale.Save(reloadedMachine)
+	if err != nil {
+		t.Errorf("Reloaded machine should be saved, but got error %s", err)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	base := newTestJournalRepository(t, createTestJournalConfig(t, 100))
+	repo := NewCachingRepositoryDecorator(base, 1)
+	first := createTestMachine()
+	second := createTestMachine()
+
+	for _, m := range []*entities.Machine{first, second} {
+		if err := repo.Save(m); err != nil {
+			t.Fatalf("Failed to save machine, because of error %s", err)
+		}
+	}
+
+	repo.Load(first.Id)
+
+	stats := repo.Stats()
+	if stats.Evictions != 2 || stats.Misses != 1 || stats.Size != 1 {
+		t.Errorf("Unexpected cache stats %+v", stats)
+	}
+}
@@ -0,0 +1,441 @@
+package adapters
+
+import (
+	"bytes"
+	"dum/internal/machines/entities"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Slack incoming webhook with Block Kit message.
+	SlackChatFormat string = "slack"
+	// Microsoft Teams incoming webhook with Adaptive Card.
+	TeamsChatFormat string = "teams"
+)
+
+// Chat channel, receiving health level changes.
+type ChatChannel struct {
+	Name string
+	// Format of message - slack or teams.
+	Format string
+	// Incoming webhook URL of channel.
+	URL string
+	// Health levels, which are sent to channel, all levels are sent if empty.
+	Levels []string
+}
+
+// Settings of chat notification strategy.
+type ChatConfig struct {
+	Channels []ChatChannel
+	// Public address of service, used for links to machines. Links are omitted if empty.
+	BaseURL string
+	// Count of the most important missing updates, listed in message.
+	TopUpdates int
+	// Timeout of posting to a single channel.
+	Timeout time.Duration
+	// Count of additional attempts of posting to channel on network errors, 5xx and 429 responses.
+	Retries int
+	// Delay before the first retry, it's doubled for every next one.
+	Backoff time.Duration
+}
+
+// Returns chat settings with reasonable timeout and list of top updates.
+func DefaultChatConfig() ChatConfig {
+	return ChatConfig{
+		TopUpdates: 5,
+		Timeout:    5 * time.Second,
+		Retries:    3,
+		Backoff:    500 * time.Millisecond,
+	}
+}
+
+// Functions building payloads of chat message and digest.
+type chatFormat struct {
+	message func(m chatMessage) interface{}
+	digest  func(d entities.HealthDigest) interface{}
+}
+
+// Notification strategy, which posts health level changes to Slack or Teams channels. Message is
+// colored by health level, lists the most important missing updates and links to the machine.
+type ChatNotificationStrategy struct {
+	config ChatConfig
+	levels [][]entities.HealthLevel
+	client *http.Client
+	logger *log.Logger
+	sleep  func(time.Duration)
+}
+
+func (s *ChatNotificationStrategy) Notify(e entities.HealthEvent) error {
+	if !e.Noteworthy() {
+		return nil
+	}
+
+	m := s.newChatMessage(e)
+	return s.broadcast([]entities.HealthLevel{e.Current}, func(f chatFormat) interface{} { return f.message(m) })
+}
+
+// Posts digest to channels, which receive health level of any event in it.
+func (s *ChatNotificationStrategy) NotifyDigest(d entities.HealthDigest) error {
+	var levels []entities.HealthLevel
+	for _, e := range d.Events {
+		levels = append(levels, e.Current)
+	}
+
+	return s.broadcast(levels, func(f chatFormat) interface{} { return f.digest(d) })
+}
+
+func (s *ChatNotificationStrategy) broadcast(levels []entities.HealthLevel, payload func(chatFormat) interface{}) error {
+	var failed []string
+	for i, channel := range s.config.Channels {
+		if !containsAnyLevel(s.levels[i], levels) {
+			continue
+		}
+
+		body := payload(chatFormats[channel.Format])
+		_, err := deliverWithRetries(s.config.Retries, s.config.Backoff, s.sleep, func() (bool, error) {
+			return s.post(channel.URL, body)
+		})
+		if err != nil {
+			s.logger.Printf("Posting to chat channel %s failed: %s", channel.Name, err)
+			failed = append(failed, fmt.Sprintf("%s - %s", channel.Name, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("chat notification failed: %s", strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// Posts payload to channel, returns whether failed attempt can be retried.
+func (s *ChatNotificationStrategy) post(url string, payload interface{}) (bool, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := s.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return isRetryableStatus(resp.StatusCode), fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return false, nil
+}
+
+// Format independent content of chat message.
+type chatMessage struct {
+	event   entities.HealthEvent
+	updates []entities.MissingUpdate
+	hidden  int
+	link    string
+}
+
+func (m chatMessage) title() string {
+	return fmt.Sprintf("%s is %s", m.event.MachineName, m.event.Current)
+}
+
+func (m chatMessage) summary() string {
+	if m.event.Escalated() {
+		return fmt.Sprintf("%s since %s, escalated to %s",
+			m.event.Current, m.event.Since.UTC().Format(time.RFC3339), m.event.Escalation)
+	}
+
+	return fmt.Sprintf("Health changed from %s to %s at %s",
+		m.event.Previous, m.event.Current, m.event.OccurredAt.UTC().Format(time.RFC3339))
+}
+
+func (s *ChatNotificationStrategy) newChatMessage(e entities.HealthEvent) chatMessage {
+	m := chatMessage{
+		event:   e,
+		updates: topMissingUpdates(e.MissingUpdates, s.config.TopUpdates),
+	}
+	m.hidden = len(e.MissingUpdates) - len(m.updates)
+
+	if s.config.BaseURL != "" {
+		m.link = strings.TrimRight(s.config.BaseURL, "/") + "/api/v1/machines/" + e.MachineId.String()
+	}
+
+	return m
+}
+
+// Returns up to count missing updates, the most severe and the longest missing go first.
+func topMissingUpdates(updates []entities.MissingUpdate, count int) []entities.MissingUpdate {
+	sorted := make([]entities.MissingUpdate, len(updates))
+	copy(sorted, updates)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Severity != sorted[j].Severity {
+			return sorted[i].Severity > sorted[j].Severity
+		}
+		return sorted[i].Duration > sorted[j].Duration
+	})
+
+	if count < len(sorted) {
+		sorted = sorted[:count]
+	}
+
+	return sorted
+}
+
+var chatFormats = map[string]chatFormat{
+	SlackChatFormat: {newSlackMessage, newSlackDigest},
+	TeamsChatFormat: {newTeamsMessage, newTeamsDigest},
+}
+
+// Count of machines, listed in digest message.
+const chatDigestMachines int = 10
+
+func containsAnyLevel(levels []entities.HealthLevel, actual []entities.HealthLevel) bool {
+	for _, l := range actual {
+		if containsLevel(levels, l) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the worst health level of digest events.
+func worstLevel(d entities.HealthDigest) entities.HealthLevel {
+	worst := entities.Healthy
+	for _, e := range d.Events {
+		if e.Current > worst {
+			worst = e.Current
+		}
+	}
+
+	return worst
+}
+
+// Returns lines about machines of digest, limited by chatDigestMachines.
+func digestLines(d entities.HealthDigest) []string {
+	var lines []string
+	for i, e := range d.Events {
+		if i == chatDigestMachines {
+			lines = append(lines, fmt.Sprintf("and %d more", len(d.Events)-i))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s -> %s", e.MachineName, e.Previous, e.Current))
+	}
+
+	return lines
+}
+
+var slackColors = map[entities.HealthLevel]string{
+	entities.Healthy: "#2eb886",
+	entities.Warning: "#daa038",
+	entities.Danger:  "#a30200",
+}
+
+var teamsColors = map[entities.HealthLevel]string{
+	entities.Healthy: "Good",
+	entities.Warning: "Warning",
+	entities.Danger:  "Attention",
+}
+
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string        `json:"type"`
+	Text     *slackText    `json:"text,omitempty"`
+	Elements []interface{} `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackButton struct {
+	Type string    `json:"type"`
+	Text slackText `json:"text"`
+	URL  string    `json:"url"`
+}
+
+func newSlackMessage(m chatMessage) interface{} {
+	blocks := []slackBlock{
+		{Type: "header", Text: &slackText{"plain_text", m.title()}},
+		{Type: "section", Text: &slackText{"mrkdwn", m.summary()}},
+	}
+
+	if len(m.updates) > 0 {
+		var lines []string
+		for _, u := range m.updates {
+			lines = append(lines, fmt.Sprintf("• `%s` %s, missing for %s", u.UpdateId, u.Severity, u.Duration))
+		}
+		if m.hidden > 0 {
+			lines = append(lines, fmt.Sprintf("_and %d more_", m.hidden))
+		}
+		blocks = append(blocks, slackBlock{Type: "section", Text: &slackText{"mrkdwn", strings.Join(lines, "\n")}})
+	}
+
+	blocks = append(blocks, slackBlock{
+		Type:     "context",
+		Elements: []interface{}{slackText{"mrkdwn", "Machine " + m.event.MachineId.String()}},
+	})
+
+	if m.link != "" {
+		blocks = append(blocks, slackBlock{
+			Type:     "actions",
+			Elements: []interface{}{slackButton{"button", slackText{"plain_text", "Open machine"}, m.link}},
+		})
+	}
+
+	return slackMessage{
+		Text:        m.title(),
+		Attachments: []slackAttachment{{Color: slackColors[m.event.Current], Blocks: blocks}},
+	}
+}
+
+func newSlackDigest(d entities.HealthDigest) interface{} {
+	return slackMessage{
+		Text: d.Summary(),
+		Attachments: []slackAttachment{{
+			Color: slackColors[worstLevel(d)],
+			Blocks: []slackBlock{
+				{Type: "header", Text: &slackText{"plain_text", d.Summary()}},
+				{Type: "section", Text: &slackText{"mrkdwn", strings.Join(digestLines(d), "\n")}},
+			},
+		}},
+	}
+}
+
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string       `json:"contentType"`
+	Content     adaptiveCard `json:"content"`
+}
+
+type adaptiveCard struct {
+	Schema  string            `json:"$schema"`
+	Type    string            `json:"type"`
+	Version string            `json:"version"`
+	Body    []adaptiveElement `json:"body"`
+	Actions []adaptiveAction  `json:"actions,omitempty"`
+}
+
+type adaptiveElement struct {
+	Type   string         `json:"type"`
+	Text   string         `json:"text,omitempty"`
+	Size   string         `json:"size,omitempty"`
+	Weight string         `json:"weight,omitempty"`
+	Color  string         `json:"color,omitempty"`
+	Wrap   bool           `json:"wrap,omitempty"`
+	Facts  []adaptiveFact `json:"facts,omitempty"`
+}
+
+type adaptiveFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type adaptiveAction struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+func newTeamsMessage(m chatMessage) interface{} {
+	card := adaptiveCard{
+		Body: []adaptiveElement{
+			{Type: "TextBlock", Text: m.title(), Size: "Large", Weight: "Bolder", Color: teamsColors[m.event.Current], Wrap: true},
+			{Type: "TextBlock", Text: m.summary(), Wrap: true},
+		},
+	}
+
+	if len(m.updates) > 0 {
+		facts := adaptiveElement{Type: "FactSet"}
+		for _, u := range m.updates {
+			facts.Facts = append(facts.Facts, adaptiveFact{u.UpdateId.String(), fmt.Sprintf("%s, missing for %s", u.Severity, u.Duration)})
+		}
+		card.Body = append(card.Body, facts)
+		if m.hidden > 0 {
+			card.Body = append(card.Body, adaptiveElement{Type: "TextBlock", Text: fmt.Sprintf("and %d more", m.hidden), Size: "Small"})
+		}
+	}
+
+	if m.link != "" {
+		card.Actions = []adaptiveAction{{Type: "Action.OpenUrl", Title: "Open machine", URL: m.link}}
+	}
+
+	return newTeamsCard(card)
+}
+
+func newTeamsDigest(d entities.HealthDigest) interface{} {
+	body := []adaptiveElement{
+		{Type: "TextBlock", Text: d.Summary(), Size: "Large", Weight: "Bolder", Color: teamsColors[worstLevel(d)], Wrap: true},
+	}
+	for _, line := range digestLines(d) {
+		body = append(body, adaptiveElement{Type: "TextBlock", Text: line, Wrap: true})
+	}
+
+	return newTeamsCard(adaptiveCard{Body: body})
+}
+
+func newTeamsCard(card adaptiveCard) teamsMessage {
+	card.Schema = "http://adaptivecards.io/schemas/adaptive-card.json"
+	card.Type = "AdaptiveCard"
+	card.Version = "1.4"
+
+	return teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content:     card,
+		}},
+	}
+}
+
+func NewChatNotificationStrategy(c ChatConfig, logger *log.Logger) (*ChatNotificationStrategy, error) {
+	s := &ChatNotificationStrategy{
+		config: c,
+		client: &http.Client{Timeout: c.Timeout},
+		logger: logger,
+		sleep:  time.Sleep,
+	}
+
+	if len(c.Channels) == 0 {
+		return nil, fmt.Errorf("no chat channels are configured")
+	}
+
+	for _, channel := range c.Channels {
+		if _, ok := chatFormats[channel.Format]; !ok {
+			return nil, fmt.Errorf("unknown format '%s' of chat channel %s", channel.Format, channel.Name)
+		}
+
+		if channel.URL == "" {
+			return nil, fmt.Errorf("URL of chat channel %s is not configured", channel.Name)
+		}
+
+		levels, err := parseHealthLevels(channel.Levels)
+		if err != nil {
+			return nil, err
+		}
+		s.levels = append(s.levels, levels)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,375 @@
+package adapters
+
+import (
+	"dum/internal/machines/entities"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSlackMessage(t *testing.T) {
+	standIn := newChatStandIn(t, validateSlackPayload)
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	s := newTestChatStrategy(t, ChatChannel{Name: "ops", Format: SlackChatFormat, URL: server.URL})
+	e := createTestChatEvent()
+
+	err := s.Notify(e)
+
+	if err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	p := standIn.payload(0)
+	attachment := p["attachments"].([]interface{})[0].(map[string]interface{})
+	if attachment["color"] != "#a30200" {
+		t.Errorf("Color mismatch! Expected %s, but was %v", "#a30200", attachment["color"])
+	}
+
+	raw := standIn.raw(0)
+	expectedLink := "https://dum.example.com/api/v1/machines/" + e.MachineId.String()
+	for _, expected := range []string{expectedLink, "web-01 is Danger", e.MissingUpdates[2].UpdateId.String(), "_and 1 more_"} {
+		if !strings.Contains(raw, expected) {
+			t.Errorf("Payload should contain %s, but was %s", expected, raw)
+		}
+	}
+
+	if strings.Contains(raw, e.MissingUpdates[0].UpdateId.String()) {
+		t.Errorf("Payload should not contain the least important update, but was %s", raw)
+	}
+}
+
+func TestChatEscalationMessage(t *testing.T) {
+	standIn := newChatStandIn(t, validateSlackPayload)
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	s := newTestChatStrategy(t, ChatChannel{Name: "ops", Format: SlackChatFormat, URL: server.URL})
+	e := createTestChatEvent()
+	e.Previous = entities.Danger
+	e.Since = e.OccurredAt.Add(-time.Hour)
+	e.Escalation = "tier 2"
+
+	if err := s.Notify(e); err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	if raw := standIn.raw(0); !strings.Contains(raw, "Danger since 2021-06-01T11:00:00Z, escalated to tier 2") {
+		t.Errorf("Escalation payload mismatch! Was %s", raw)
+	}
+}
+
+func TestTeamsMessage(t *testing.T) {
+	standIn := newChatStandIn(t, validateTeamsPayload)
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	s := newTestChatStrategy(t, ChatChannel{Name: "desk", Format: TeamsChatFormat, URL: server.URL})
+	e := createTestChatEvent()
+	e.Current = entities.Warning
+
+	err := s.Notify(e)
+
+	if err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	card := standIn.payload(0)["attachments"].([]interface{})[0].(map[string]interface{})["content"].(map[string]interface{})
+	title := card["body"].([]interface{})[0].(map[string]interface{})
+	if title["color"] != "Warning" || title["text"] != "web-01 is Warning" {
+		t.Errorf("Title mismatch! Was %v", title)
+	}
+
+	facts := card["body"].([]interface{})[2].(map[string]interface{})["facts"].([]interface{})
+	first := facts[0].(map[string]interface{})
+	if first["title"] != e.MissingUpdates[2].UpdateId.String() || first["value"] != "Critical, missing for 72h0m0s" {
+		t.Errorf("The most important update should go first, but was %v", first)
+	}
+
+	action := card["actions"].([]interface{})[0].(map[string]interface{})
+	if action["url"] != "https://dum.example.com/api/v1/machines/"+e.MachineId.String() {
+		t.Errorf("Link mismatch! Was %v", action["url"])
+	}
+}
+
+func TestChatChannelsAreFilteredByLevel(t *testing.T) {
+	slack := newChatStandIn(t, validateSlackPayload)
+	slackServer := httptest.NewServer(slack)
+	defer slackServer.Close()
+
+	teams := newChatStandIn(t, validateTeamsPayload)
+	teamsServer := httptest.NewServer(teams)
+	defer teamsServer.Close()
+
+	s := newTestChatStrategy(t,
+		ChatChannel{Name: "ops", Format: SlackChatFormat, URL: slackServer.URL, Levels: []string{"Danger"}},
+		ChatChannel{Name: "desk", Format: TeamsChatFormat, URL: teamsServer.URL},
+	)
+
+	e := createTestChatEvent()
+	e.Previous = entities.Danger
+	e.Current = entities.Healthy
+	unchanged := createTestChatEvent()
+	unchanged.Previous = entities.Danger
+
+	for _, event := range []entities.HealthEvent{e, unchanged} {
+		if err := s.Notify(event); err != nil {
+			t.Fatalf("Expected nil err, but was %s", err)
+		}
+	}
+
+	if slack.count() != 0 || teams.count() != 1 {
+		t.Errorf("Messages count mismatch! Expected 0 in slack and 1 in teams, but was %d and %d", slack.count(), teams.count())
+	}
+}
+
+func TestChatChannelFailureIsIsolated(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer failing.Close()
+
+	teams := newChatStandIn(t, validateTeamsPayload)
+	teamsServer := httptest.NewServer(teams)
+	defer teamsServer.Close()
+
+	s := newTestChatStrategy(t,
+		ChatChannel{Name: "ops", Format: SlackChatFormat, URL: failing.URL},
+		ChatChannel{Name: "desk", Format: TeamsChatFormat, URL: teamsServer.URL},
+	)
+
+	err := s.Notify(createTestChatEvent())
+
+	if err == nil || !strings.Contains(err.Error(), "ops") {
+		t.Errorf("Expected error of ops channel, but was %v", err)
+	}
+
+	if teams.count() != 1 {
+		t.Errorf("Teams channel should receive message despite failure of another one, but got %d", teams.count())
+	}
+}
+
+func TestChatRetriesOnlyFailedChannel(t *testing.T) {
+	attempts := 0
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer flaky.Close()
+
+	teams := newChatStandIn(t, validateTeamsPayload)
+	teamsServer := httptest.NewServer(teams)
+	defer teamsServer.Close()
+
+	s := newTestChatStrategy(t,
+		ChatChannel{Name: "ops", Format: SlackChatFormat, URL: flaky.URL},
+		ChatChannel{Name: "desk", Format: TeamsChatFormat, URL: teamsServer.URL},
+	)
+
+	if err := s.Notify(createTestChatEvent()); err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	if attempts != 3 || teams.count() != 1 {
+		t.Errorf("Only failed channel should be retried, but was %d attempts and %d messages in teams", attempts, teams.count())
+	}
+}
+
+func TestInvalidChatConfig(t *testing.T) {
+	configs := []ChatConfig{
+		{},
+		{Channels: []ChatChannel{{Name: "ops", Format: "irc", URL: "http://localhost"}}},
+		{Channels: []ChatChannel{{Name: "ops", Format: SlackChatFormat}}},
+		{Channels: []ChatChannel{{Name: "ops", Format: SlackChatFormat, URL: "http://localhost", Levels: []string{"Sick"}}}},
+	}
+
+	for _, c := range configs {
+		if _, err := NewChatNotificationStrategy(c, log.Default()); err == nil {
+			t.Errorf("Strategy should not be created with config %+v", c)
+		}
+	}
+}
+
+func newTestChatStrategy(t *testing.T, channels ...ChatChannel) *ChatNotificationStrategy {
+	c := DefaultChatConfig()
+	c.Channels = channels
+	c.BaseURL = "https://dum.example.com/"
+	c.TopUpdates = 2
+
+	s, err := NewChatNotificationStrategy(c, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("Cannot create strategy %s", err)
+	}
+	s.sleep = func(time.Duration) {}
+
+	return s
+}
+
+func createTestChatEvent() entities.HealthEvent {
+	e := createTestHealthEvent()
+	e.MissingUpdates = []entities.MissingUpdate{
+		{UpdateId: uuid.New(), Severity: entities.Low, Duration: 100 * time.Hour},
+		{UpdateId: uuid.New(), Severity: entities.Critical, Duration: time.Hour},
+		{UpdateId: uuid.New(), Severity: entities.Critical, Duration: 72 * time.Hour},
+	}
+
+	return e
+}
+
+// Local stand-in of chat webhook, which rejects payloads of unexpected shape like the real one.
+type chatStandIn struct {
+	t        *testing.T
+	validate func(map[string]interface{}) error
+	mu       sync.Mutex
+	bodies   []string
+}
+
+func newChatStandIn(t *testing.T, validate func(map[string]interface{}) error) *chatStandIn {
+	return &chatStandIn{t: t, validate: validate}
+}
+
+func (h *chatStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	var p map[string]interface{}
+	err := json.Unmarshal(body, &p)
+	if err == nil {
+		err = h.validate(p)
+	}
+
+	if err != nil {
+		h.t.Errorf("Invalid payload %s - %s", body, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	h.bodies = append(h.bodies, string(body))
+	h.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *chatStandIn) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.bodies)
+}
+
+func (h *chatStandIn) raw(i int) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.bodies[i]
+}
+
+func (h *chatStandIn) payload(i int) map[string]interface{} {
+	var p map[string]interface{}
+	json.Unmarshal([]byte(h.raw(i)), &p)
+	return p
+}
+
+func validateSlackPayload(p map[string]interface{}) error {
+	if _, ok := p["text"].(string); !ok {
+		return fmt.Errorf("fallback text is missing")
+	}
+
+	attachments, ok := p["attachments"].([]interface{})
+	if !ok || len(attachments) == 0 {
+		return fmt.Errorf("attachments are missing")
+	}
+
+	for _, a := range attachments {
+		attachment := a.(map[string]interface{})
+		if color, ok := attachment["color"].(string); !ok || !strings.HasPrefix(color, "#") {
+			return fmt.Errorf("invalid color %v", attachment["color"])
+		}
+
+		blocks, ok := attachment["blocks"].([]interface{})
+		if !ok || len(blocks) == 0 || len(blocks) > 50 {
+			return fmt.Errorf("invalid blocks")
+		}
+
+		for _, b := range blocks {
+			block := b.(map[string]interface{})
+			switch block["type"] {
+			case "header", "section":
+				text, ok := block["text"].(map[string]interface{})
+				if !ok || text["type"] == nil || text["text"] == "" {
+					return fmt.Errorf("block %s has no text", block["type"])
+				}
+			case "context", "actions":
+				if elements, ok := block["elements"].([]interface{}); !ok || len(elements) == 0 {
+					return fmt.Errorf("block %s has no elements", block["type"])
+				}
+			default:
+				return fmt.Errorf("unknown block type %v", block["type"])
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateTeamsPayload(p map[string]interface{}) error {
+	if p["type"] != "message" {
+		return fmt.Errorf("invalid message type %v", p["type"])
+	}
+
+	attachments, ok := p["attachments"].([]interface{})
+	if !ok || len(attachments) != 1 {
+		return fmt.Errorf("single attachment is expected")
+	}
+
+	attachment := attachments[0].(map[string]interface{})
+	if attachment["contentType"] != "application/vnd.microsoft.card.adaptive" {
+		return fmt.Errorf("invalid content type %v", attachment["contentType"])
+	}
+
+	card, ok := attachment["content"].(map[string]interface{})
+	if !ok || card["type"] != "AdaptiveCard" || card["version"] == nil || card["$schema"] == nil {
+		return fmt.Errorf("invalid adaptive card")
+	}
+
+	body, ok := card["body"].([]interface{})
+	if !ok || len(body) == 0 {
+		return fmt.Errorf("card body is missing")
+	}
+
+	for _, e := range body {
+		element := e.(map[string]interface{})
+		switch element["type"] {
+		case "TextBlock":
+			if element["text"] == nil {
+				return fmt.Errorf("text block has no text")
+			}
+		case "FactSet":
+			if facts, ok := element["facts"].([]interface{}); !ok || len(facts) == 0 {
+				return fmt.Errorf("fact set has no facts")
+			}
+		default:
+			return fmt.Errorf("unknown element type %v", element["type"])
+		}
+	}
+
+	if actions, ok := card["actions"].([]interface{}); ok {
+		for _, a := range actions {
+			action := a.(map[string]interface{})
+			if action["type"] != "Action.OpenUrl" || action["url"] == nil {
+				return fmt.Errorf("invalid action %v", action)
+			}
+		}
+	}
+
+	return nil
+}
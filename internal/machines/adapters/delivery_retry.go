@@ -0,0 +1,28 @@
+package adapters
+
+import (
+	"net/http"
+	"time"
+)
+
+// Calls deliver, while it fails with retryable error and retries are left, doubling backoff between
+// attempts. Every destination (webhook URL, chat channel, SMTP server) is retried on its own, so
+// destinations, which got the event, never get it again. Returns count of attempts and the last error.
+func deliverWithRetries(retries int, backoff time.Duration, sleep func(time.Duration), deliver func() (bool, error)) (int, error) {
+	attempts := 0
+	for {
+		attempts++
+		retry, err := deliver()
+		if err == nil || !retry || attempts > retries {
+			return attempts, err
+		}
+
+		sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// Returns true, if request with such response status can succeed later.
+func isRetryableStatus(status int) bool {
+	return status >= 500 || status == http.StatusTooManyRequests
+}
@@ -0,0 +1,404 @@
+package adapters
+
+import (
+	"bytes"
+	"crypto/tls"
+	"dum/internal/machines/entities"
+	"dum/pkg/machines/contract"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+const defaultEmailSubject string = `[DUM] {{.MachineName}} is {{.Current}}`
+
+const defaultEmailText string = `{{if .Escalation}}Machine {{.MachineName}} ({{.MachineId}}) is {{.Current}} since {{.Since}}, escalated to {{.Escalation}}.
+{{else}}Health of machine {{.MachineName}} ({{.MachineId}}) changed from {{.Previous}} to {{.Current}} at {{.OccurredAt}}.
+{{end}}`
+
+// Settings of email notification strategy.
+type EmailConfig struct {
+	// Address of SMTP server.
+	Host string
+	Port int
+	// Credentials for PLAIN authentication, authentication is skipped if username is empty.
+	Username string
+	Password string
+	// Upgrade connection with STARTTLS before authentication.
+	StartTLS bool
+	// Timeout of the whole SMTP session.
+	Timeout time.Duration
+	// Count of additional attempts of sending message on network errors and 4xx replies.
+	Retries int
+	// Delay before the first retry, it's doubled for every next one.
+	Backoff time.Duration
+	// Sender address.
+	From string
+	// Paths to text/template files with subject and plain text body, built-in templates are used if empty.
+	SubjectTemplate string
+	TextTemplate    string
+	// Path to html/template file with HTML body, message has only plain text part if empty.
+	HtmlTemplate string
+	// Rules for choosing recipients of event.
+	Recipients []EmailRecipients
+}
+
+// Recipients of events with some health levels about machines in some groups. Empty list of
+// levels or groups matches any value.
+type EmailRecipients struct {
+	Levels []string
+	Groups []string
+	To     []string
+}
+
+// Data, available in email templates.
+type EmailTemplateData struct {
+	contract.HealthEvent
+	Groups []string
+}
+
+// Notification strategy, which sends email about health level changes over SMTP. Subject and body
+// are rendered from templates, recipients are chosen by current health level and machine groups.
+type EmailNotificationStrategy struct {
+	config    EmailConfig
+	groups    MachineGroups
+	levels    [][]entities.HealthLevel
+	subject   *texttemplate.Template
+	text      *texttemplate.Template
+	html      *htmltemplate.Template
+	tlsConfig *tls.Config
+	logger    *log.Logger
+	now       func() time.Time
+	sleep     func(time.Duration)
+}
+
+func (s *EmailNotificationStrategy) Notify(e entities.HealthEvent) error {
+	if !e.Noteworthy() {
+		return nil
+	}
+
+	groups := s.groups.Of(e.MachineName)
+	to := s.recipients(e.Current, groups)
+	if len(to) == 0 {
+		return nil
+	}
+
+	msg, err := s.render(EmailTemplateData{newHealthEventDto(e), groups}, to)
+	if err != nil {
+		return err
+	}
+
+	err = s.send(to, msg)
+	if err != nil {
+		s.logger.Printf("Email about machine %s to %s failed: %s", e.MachineId, strings.Join(to, ", "), err)
+		return fmt.Errorf("cannot send email - %w", err)
+	}
+
+	return nil
+}
+
+// Sends digest as plain text email to recipients of all events in it.
+func (s *EmailNotificationStrategy) NotifyDigest(d entities.HealthDigest) error {
+	unique := map[string]bool{}
+	var text bytes.Buffer
+	text.WriteString(d.Summary() + ".\n\n")
+	for _, e := range d.Events {
+		for _, to := range s.recipients(e.Current, s.groups.Of(e.MachineName)) {
+			unique[to] = true
+		}
+		fmt.Fprintf(&text, "%s (%s): %s -> %s\n", e.MachineName, e.MachineId, e.Previous, e.Current)
+	}
+
+	if len(unique) == 0 {
+		return nil
+	}
+
+	to := make([]string, 0, len(unique))
+	for rcpt := range unique {
+		to = append(to, rcpt)
+	}
+	sort.Strings(to)
+
+	var msg bytes.Buffer
+	s.writeHeaders(&msg, to, "[DUM] "+d.Summary())
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.Write(text.Bytes())
+
+	if err := s.send(to, msg.Bytes()); err != nil {
+		return fmt.Errorf("cannot send digest email - %w", err)
+	}
+
+	return nil
+}
+
+// Returns sorted unique recipients of rules, matching level and groups.
+func (s *EmailNotificationStrategy) recipients(level entities.HealthLevel, groups []string) []string {
+	unique := map[string]bool{}
+	for i, r := range s.config.Recipients {
+		if !containsLevel(s.levels[i], level) || !intersects(r.Groups, groups) {
+			continue
+		}
+
+		for _, to := range r.To {
+			unique[to] = true
+		}
+	}
+
+	result := make([]string, 0, len(unique))
+	for to := range unique {
+		result = append(result, to)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (s *EmailNotificationStrategy) render(data EmailTemplateData, to []string) ([]byte, error) {
+	var subject, text bytes.Buffer
+	if err := s.subject.Execute(&subject, data); err != nil {
+		return nil, fmt.Errorf("cannot render subject - %w", err)
+	}
+
+	if err := s.text.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("cannot render text body - %w", err)
+	}
+
+	var msg bytes.Buffer
+	s.writeHeaders(&msg, to, subject.String())
+
+	if s.html == nil {
+		msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+		msg.Write(text.Bytes())
+		return msg.Bytes(), nil
+	}
+
+	var html bytes.Buffer
+	if err := s.html.Execute(&html, data); err != nil {
+		return nil, fmt.Errorf("cannot render HTML body - %w", err)
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=utf-8", text.Bytes()},
+		{"text/html; charset=utf-8", html.Bytes()},
+	} {
+		pw, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, err
+		}
+		pw.Write(part.content)
+	}
+	w.Close()
+
+	msg.WriteString("Content-Type: multipart/alternative; boundary=" + w.Boundary() + "\r\n\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+func (s *EmailNotificationStrategy) writeHeaders(msg *bytes.Buffer, to []string, subject string) {
+	msg.WriteString("From: " + s.config.From + "\r\n")
+	msg.WriteString("To: " + strings.Join(to, ", ") + "\r\n")
+	msg.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject)) + "\r\n")
+	msg.WriteString("Date: " + s.now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+}
+
+// Sends message, retrying it on network errors and transient (4xx) replies of server. Permanent
+// (5xx) replies, like unknown recipient, are not retried.
+func (s *EmailNotificationStrategy) send(to []string, msg []byte) error {
+	_, err := deliverWithRetries(s.config.Retries, s.config.Backoff, s.sleep, func() (bool, error) {
+		err := s.transmit(to, msg)
+		var reply *textproto.Error
+		return !errors.As(err, &reply) || reply.Code < 500, err
+	})
+
+	return err
+}
+
+func (s *EmailNotificationStrategy) transmit(to []string, msg []byte) error {
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	conn, err := net.DialTimeout("tcp", addr, s.config.Timeout)
+	if err != nil {
+		return err
+	}
+
+	if s.config.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(s.config.Timeout))
+	}
+
+	c, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if s.config.StartTLS {
+		if err := c.StartTLS(s.tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	if s.config.Username != "" {
+		auth := smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(s.config.From); err != nil {
+		return err
+	}
+
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	// message is accepted already, failed QUIT must not send it again
+	c.Quit()
+	return nil
+}
+
+func containsLevel(levels []entities.HealthLevel, level entities.HealthLevel) bool {
+	if len(levels) == 0 {
+		return true
+	}
+
+	for _, l := range levels {
+		if l == level {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns true, if expected is empty or has common value with actual.
+func intersects(expected []string, actual []string) bool {
+	if len(expected) == 0 {
+		return true
+	}
+
+	for _, e := range expected {
+		for _, a := range actual {
+			if e == a {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Reads template file or returns built-in template, if path is empty.
+func readTemplate(path string, builtin string) (string, error) {
+	if path == "" {
+		return builtin, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read template %s - %w", path, err)
+	}
+
+	return string(raw), nil
+}
+
+// Parses health level names, e.g. from routing rules.
+func parseHealthLevels(names []string) ([]entities.HealthLevel, error) {
+	var result []entities.HealthLevel
+	for _, name := range names {
+		l, err := entities.ParseHealthLevel(name)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, l)
+	}
+
+	return result, nil
+}
+
+func NewEmailNotificationStrategy(c EmailConfig, groups MachineGroups, logger *log.Logger) (*EmailNotificationStrategy, error) {
+	if c.Host == "" || c.From == "" {
+		return nil, fmt.Errorf("SMTP host and sender address should be configured")
+	}
+
+	if err := groups.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := &EmailNotificationStrategy{
+		config:    c,
+		groups:    groups,
+		tlsConfig: &tls.Config{ServerName: c.Host},
+		logger:    logger,
+		now:       time.Now,
+		sleep:     time.Sleep,
+	}
+
+	for _, r := range c.Recipients {
+		levels, err := parseHealthLevels(r.Levels)
+		if err != nil {
+			return nil, err
+		}
+		s.levels = append(s.levels, levels)
+	}
+
+	subject, err := readTemplate(c.SubjectTemplate, defaultEmailSubject)
+	if err != nil {
+		return nil, err
+	}
+	if s.subject, err = texttemplate.New("subject").Parse(subject); err != nil {
+		return nil, err
+	}
+
+	text, err := readTemplate(c.TextTemplate, defaultEmailText)
+	if err != nil {
+		return nil, err
+	}
+	if s.text, err = texttemplate.New("text").Parse(text); err != nil {
+		return nil, err
+	}
+
+	if c.HtmlTemplate != "" {
+		html, err := readTemplate(c.HtmlTemplate, "")
+		if err != nil {
+			return nil, err
+		}
+		if s.html, err = htmltemplate.New("html").Parse(html); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
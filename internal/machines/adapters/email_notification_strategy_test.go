@@ -0,0 +1,485 @@
+package adapters
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"dum/internal/machines/entities"
+	"encoding/base64"
+	"io"
+	"log"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEmailIsSentToRecipientsOfLevelAndGroup(t *testing.T) {
+	server := startFakeSmtpServer(t, nil)
+	c := createTestEmailConfig(server)
+	c.Recipients = []EmailRecipients{
+		{Levels: []string{"Danger"}, To: []string{"oncall@example.com"}},
+		{Groups: []string{"desktops"}, To: []string{"desktop-team@example.com", "oncall@example.com"}},
+		{Groups: []string{"servers"}, To: []string{"server-team@example.com"}},
+		{Levels: []string{"Warning"}, To: []string{"reports@example.com"}},
+	}
+
+	s := newTestEmailStrategy(t, c)
+	err := s.Notify(createTestHealthEvent())
+
+	if err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	messages := server.messages()
+	if len(messages) != 1 {
+		t.Fatalf("Messages count mismatch! Expected 1, but was %d", len(messages))
+	}
+
+	m := messages[0]
+	expected := "desktop-team@example.com,oncall@example.com"
+	if actual := strings.Join(m.to, ","); actual != expected {
+		t.Errorf("Recipients mismatch! Expected %s, but was %s", expected, actual)
+	}
+
+	if m.from != "dum@example.com" {
+		t.Errorf("Sender mismatch! Expected %s, but was %s", "dum@example.com", m.from)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(m.data))
+	if err != nil {
+		t.Fatalf("Cannot parse message %s", err)
+	}
+
+	if subject := msg.Header.Get("Subject"); subject != "[DUM] web-01 is Danger" {
+		t.Errorf("Subject mismatch! Expected %s, but was %s", "[DUM] web-01 is Danger", subject)
+	}
+
+	body, _ := io.ReadAll(msg.Body)
+	if !strings.Contains(string(body), "changed from Healthy to Danger at 2021-06-01T12:00:00Z") {
+		t.Errorf("Unexpected body %s", body)
+	}
+}
+
+func TestEmailIsRenderedFromTemplates(t *testing.T) {
+	server := startFakeSmtpServer(t, nil)
+	dir := t.TempDir()
+	c := createTestEmailConfig(server)
+	c.SubjectTemplate = writeTestTemplate(t, dir, "subject.tmpl", `{{.Current}}: {{.MachineName}} ({{join .Groups ","}})`)
+	c.TextTemplate = writeTestTemplate(t, dir, "text.tmpl", `{{.MachineName}} was {{.Previous}}`)
+	c.HtmlTemplate = writeTestTemplate(t, dir, "html.tmpl", `<b>{{.MachineName}}</b>`)
+
+	_, err := NewEmailNotificationStrategy(c, MachineGroups{}, log.Default())
+	if err == nil {
+		t.Error("Template with unknown function should not be parsed!")
+	}
+
+	c.SubjectTemplate = writeTestTemplate(t, dir, "subject.tmpl", `{{.Current}}: {{.MachineName}} {{.Groups}}`)
+	s := newTestEmailStrategy(t, c)
+	e := createTestHealthEvent()
+	e.MachineName = "web-01 <script>"
+
+	err = s.Notify(e)
+
+	if err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(server.messages()[0].data))
+	if err != nil {
+		t.Fatalf("Cannot parse message %s", err)
+	}
+
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "Danger: web-01 <script> [web]" {
+		t.Errorf("Subject mismatch! Expected %s, but was %s", "Danger: web-01 <script> [web]", subject)
+	}
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("Cannot parse content type %s", err)
+	}
+
+	parts := map[string]string{}
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err != nil {
+			break
+		}
+		content, _ := io.ReadAll(p)
+		parts[p.Header.Get("Content-Type")] = string(content)
+	}
+
+	if actual := parts["text/plain; charset=utf-8"]; actual != "web-01 <script> was Healthy" {
+		t.Errorf("Text part mismatch! Was %s", actual)
+	}
+
+	if actual := parts["text/html; charset=utf-8"]; actual != "<b>web-01 &lt;script&gt;</b>" {
+		t.Errorf("HTML part mismatch! Was %s", actual)
+	}
+}
+
+func TestEmailIsNotSentWithoutChangeOrRecipients(t *testing.T) {
+	server := startFakeSmtpServer(t, nil)
+	c := createTestEmailConfig(server)
+	c.Recipients = []EmailRecipients{{Levels: []string{"Warning"}, To: []string{"reports@example.com"}}}
+	s := newTestEmailStrategy(t, c)
+
+	unchanged := createTestHealthEvent()
+	unchanged.Previous = entities.Warning
+	unchanged.Current = entities.Warning
+
+	for _, e := range []entities.HealthEvent{unchanged, createTestHealthEvent()} {
+		if err := s.Notify(e); err != nil {
+			t.Errorf("Expected nil err, but was %s", err)
+		}
+	}
+
+	if len(server.messages()) != 0 {
+		t.Errorf("Messages count mismatch! Expected 0, but was %d", len(server.messages()))
+	}
+}
+
+func TestEmailIsSentForEscalation(t *testing.T) {
+	server := startFakeSmtpServer(t, nil)
+	s := newTestEmailStrategy(t, createTestEmailConfig(server))
+	e := createTestHealthEvent()
+	e.Previous = entities.Danger
+	e.Since = e.OccurredAt.Add(-2 * time.Hour)
+	e.Escalation = "management"
+
+	if err := s.Notify(e); err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	if len(server.messages()) != 1 {
+		t.Fatalf("Messages count mismatch! Expected 1, but was %d", len(server.messages()))
+	}
+
+	if data := server.messages()[0].data; !strings.Contains(data, "is Danger since 2021-06-01T10:00:00Z, escalated to management") {
+		t.Errorf("Escalation email mismatch! Was %s", data)
+	}
+}
+
+func TestEmailWithStartTlsAndAuth(t *testing.T) {
+	cert := createTestCertificate(t)
+	server := startFakeSmtpServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	c := createTestEmailConfig(server)
+	c.StartTLS = true
+	c.Username = "dum"
+	c.Password = "secret"
+
+	s := newTestEmailStrategy(t, c)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert.Leaf)
+	s.tlsConfig = &tls.Config{ServerName: c.Host, RootCAs: pool}
+
+	err := s.Notify(createTestHealthEvent())
+
+	if err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	m := server.messages()[0]
+	if !m.tls {
+		t.Error("Message should be sent over TLS!")
+	}
+
+	if m.auth != "\x00dum\x00secret" {
+		t.Errorf("Credentials mismatch! Was %q", m.auth)
+	}
+}
+
+func TestEmailSendError(t *testing.T) {
+	server := startFakeSmtpServer(t, nil)
+	c := createTestEmailConfig(server)
+	c.StartTLS = true
+
+	s := newTestEmailStrategy(t, c)
+	err := s.Notify(createTestHealthEvent())
+
+	if err == nil {
+		t.Error("Expected err, because server doesn't support STARTTLS, but was nil")
+	}
+}
+
+func TestEmailIsRetriedOnTransientReplyOnly(t *testing.T) {
+	server := startFakeSmtpServer(t, nil)
+	server.replyToMail("451 try again later")
+	c := createTestEmailConfig(server)
+	c.Retries = 2
+
+	s := newTestEmailStrategy(t, c)
+	if err := s.Notify(createTestHealthEvent()); err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	if len(server.messages()) != 1 {
+		t.Errorf("Messages count mismatch! Expected 1, but was %d", len(server.messages()))
+	}
+
+	server.replyToMail("550 sender rejected")
+	if err := s.Notify(createTestHealthEvent()); err == nil {
+		t.Error("Expected err, because sender is rejected, but was nil")
+	}
+
+	if len(server.messages()) != 1 {
+		t.Errorf("Permanent failure should not be retried, but %d messages were received", len(server.messages()))
+	}
+}
+
+func TestInvalidEmailConfig(t *testing.T) {
+	var cases = []struct {
+		c      EmailConfig
+		groups MachineGroups
+	}{
+		{EmailConfig{From: "dum@example.com"}, nil},
+		{EmailConfig{Host: "localhost"}, nil},
+		{EmailConfig{Host: "localhost", From: "dum@example.com", Recipients: []EmailRecipients{{Levels: []string{"Sick"}}}}, nil},
+		{EmailConfig{Host: "localhost", From: "dum@example.com", TextTemplate: "missing.tmpl"}, nil},
+		{EmailConfig{Host: "localhost", From: "dum@example.com"}, MachineGroups{"broken": {"["}}},
+	}
+
+	for _, testCase := range cases {
+		if _, err := NewEmailNotificationStrategy(testCase.c, testCase.groups, log.Default()); err == nil {
+			t.Errorf("Strategy should not be created with config %+v", testCase.c)
+		}
+	}
+}
+
+func TestMachineGroups(t *testing.T) {
+	groups := MachineGroups{
+		"web":      {"web-*"},
+		"servers":  {"web-*", "db-*"},
+		"desktops": {"desk-*"},
+	}
+
+	if actual := strings.Join(groups.Of("web-01"), ","); actual != "servers,web" {
+		t.Errorf("Groups mismatch! Expected %s, but was %s", "servers,web", actual)
+	}
+
+	if actual := groups.Of("printer"); len(actual) != 0 {
+		t.Errorf("Groups mismatch! Expected none, but was %v", actual)
+	}
+
+	if !groups.Contains("db-01", []string{"desktops", "servers"}) || groups.Contains("db-01", []string{"web"}) || !groups.Contains("printer", nil) {
+		t.Error("Groups membership mismatch!")
+	}
+}
+
+func createTestEmailConfig(server *fakeSmtpServer) EmailConfig {
+	host, port, _ := net.SplitHostPort(server.addr)
+	p, _ := strconv.Atoi(port)
+
+	return EmailConfig{
+		Host:       host,
+		Port:       p,
+		Timeout:    5 * time.Second,
+		From:       "dum@example.com",
+		Recipients: []EmailRecipients{{To: []string{"oncall@example.com"}}},
+	}
+}
+
+func newTestEmailStrategy(t *testing.T, c EmailConfig) *EmailNotificationStrategy {
+	groups := MachineGroups{
+		"web":      {"web-*"},
+		"desktops": {"desk-*", "web-01"},
+	}
+
+	s, err := NewEmailNotificationStrategy(c, groups, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("Cannot create strategy %s", err)
+	}
+	s.sleep = func(time.Duration) {}
+
+	return s
+}
+
+func writeTestTemplate(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+
+	return path
+}
+
+func createTestCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Cannot generate key %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Cannot create certificate %s", err)
+	}
+
+	leaf, _ := x509.ParseCertificate(raw)
+	return tls.Certificate{Certificate: [][]byte{raw}, PrivateKey: key, Leaf: leaf}
+}
+
+type receivedEmail struct {
+	from, auth, data string
+	to               []string
+	tls              bool
+}
+
+// In-process SMTP server, supporting just enough of protocol for net/smtp client.
+// STARTTLS is advertised only if TLS config is given.
+type fakeSmtpServer struct {
+	addr     string
+	tls      *tls.Config
+	listener net.Listener
+
+	mu       sync.Mutex
+	received []receivedEmail
+	// Replies to MAIL commands, used one by one before accepting sender.
+	mailReplies []string
+}
+
+func startFakeSmtpServer(t *testing.T, c *tls.Config) *fakeSmtpServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Cannot start SMTP server %s", err)
+	}
+
+	s := &fakeSmtpServer{addr: l.Addr().String(), tls: c, listener: l}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { l.Close() })
+
+	return s
+}
+
+func (s *fakeSmtpServer) messages() []receivedEmail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]receivedEmail{}, s.received...)
+}
+
+func (s *fakeSmtpServer) replyToMail(replies ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mailReplies = replies
+}
+
+func (s *fakeSmtpServer) nextMailReply() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.mailReplies) == 0 {
+		return ""
+	}
+
+	r := s.mailReplies[0]
+	s.mailReplies = s.mailReplies[1:]
+	return r
+}
+
+func (s *fakeSmtpServer) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+
+	var m receivedEmail
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+	reply("220 fake ESMTP")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch {
+		case verb == "EHLO" || verb == "HELO":
+			if s.tls != nil && !m.tls {
+				reply("250-fake")
+				reply("250-STARTTLS")
+			} else {
+				reply("250-fake")
+			}
+			reply("250 AUTH PLAIN")
+		case verb == "STARTTLS" && s.tls != nil:
+			reply("220 ready")
+			tlsConn := tls.Server(conn, s.tls)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn = tlsConn
+			r = bufio.NewReader(conn)
+			m.tls = true
+		case verb == "AUTH":
+			raw, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(line, "AUTH PLAIN "))
+			m.auth = string(raw)
+			reply("235 accepted")
+		case verb == "MAIL":
+			if r := s.nextMailReply(); r != "" {
+				reply(r)
+				continue
+			}
+			m.from = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+			reply("250 ok")
+		case verb == "RCPT":
+			m.to = append(m.to, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+			reply("250 ok")
+		case verb == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			m.data = data.String()
+			s.mu.Lock()
+			s.received = append(s.received, m)
+			s.mu.Unlock()
+			reply("250 queued")
+		case verb == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package adapters
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Prefix of encrypted files, it's also authenticated as additional data.
+var encryptedFileMagic []byte = []byte("DUMAES1\n")
+
+// Error of opening encrypted file, which was changed after it was sealed or sealed with another key.
+var ErrTampered error = errors.New("encrypted file is tampered or key is wrong")
+
+// Error of opening plaintext file, when encryption is enabled.
+var ErrNotEncrypted error = errors.New("file is not encrypted")
+
+// Authenticated encryption of repository files with AES-GCM.
+type FileCipher struct {
+	aead cipher.AEAD
+}
+
+// Encrypts data, every call uses new random nonce.
+func (c *FileCipher) Seal(plain []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	_, err := io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := append([]byte{}, encryptedFileMagic...)
+	sealed = append(sealed, nonce...)
+	return c.aead.Seal(sealed, nonce, plain, encryptedFileMagic), nil
+}
+
+// Decrypts data, sealed by Seal. Returns an error, if data was changed or sealed with another key.
+func (c *FileCipher) Open(sealed []byte) ([]byte, error) {
+	if !bytes.HasPrefix(sealed, encryptedFileMagic) {
+		return nil, ErrNotEncrypted
+	}
+
+	sealed = sealed[len(encryptedFileMagic):]
+	if len(sealed) < c.aead.NonceSize()+c.aead.Overhead() {
+		return nil, ErrTampered
+	}
+
+	nonce := sealed[:c.aead.NonceSize()]
+	plain, err := c.aead.Open(nil, nonce, sealed[c.aead.NonceSize():], encryptedFileMagic)
+	if err != nil {
+		return nil, ErrTampered
+	}
+
+	return plain, nil
+}
+
+// Creates cipher with AES-128, AES-192 or AES-256 key.
+func NewFileCipher(key []byte) (*FileCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileCipher{aead: aead}, nil
+}
+
+// Parses base64 encoded key, surrounding whitespace is ignored.
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("key is not base64 encoded - %w", err)
+	}
+
+	if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+		return nil, fmt.Errorf("key should have 16, 24 or 32 bytes, but has %d", len(key))
+	}
+
+	return key, nil
+}
+
+// Reads base64 encoded key from file.
+func LoadKey(path string) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseKey(string(raw))
+}
+
+// Reads file and decrypts it, if cipher is set.
+func readSealedFile(path string, c *FileCipher) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil || c == nil {
+		return raw, err
+	}
+
+	plain, err := c.Open(raw)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %s - %w", path, err)
+	}
+
+	return plain, nil
+}
+
+// Encrypts data, if cipher is set, and replaces file atomically.
+func writeSealedFile(path string, data []byte, perm os.FileMode, generations int, c *FileCipher) error {
+	if c != nil {
+		var err error
+		data, err = c.Seal(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeFileAtomically(path, data, perm, generations)
+}
+
+// Re-encrypts store file, its previous generations and quarantine file with new cipher. Files are plaintext
+// before rotation, if c.Cipher is nil, and become plaintext, if newCipher is nil. Returns rotated files.
+// Running service would keep the old key and fail on every following load, so rotation is refused, unless
+// exclusive writer lock is acquired.
+func RotateStoreKey(c FileRepositoryConfig, newCipher *FileCipher) ([]string, error) {
+	writer, err := AcquireWriterLock(c.Path, c.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	defer writer.Release()
+
+	l, err := AcquireFileLock(c.Path, c.Permissions, c.LockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+
+	// store file is the last one, so it's still readable with the old key, if rotation is interrupted
+	paths := []string{quarantinePath(c.Path)}
+	for i := c.Generations; i >= 1; i-- {
+		paths = append(paths, generationPath(c.Path, i))
+	}
+	paths = append(paths, c.Path)
+
+	// all files are opened before writing, so nothing is rotated, if any file can't be opened with the old key
+	rotated := []string{}
+	contents := [][]byte{}
+	for _, path := range paths {
+		plain, err := readSealedFile(path, c.Cipher)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		rotated = append(rotated, path)
+		contents = append(contents, plain)
+	}
+
+	for i, path := range rotated {
+		err = writeSealedFile(path, contents[i], c.Permissions, 0, newCipher)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return rotated, nil
+}
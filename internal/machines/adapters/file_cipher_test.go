@@ -0,0 +1,204 @@
+package adapters
+
+import (
+	"bytes"
+	"crypto/rand"
+	"dum/internal/machines/cases"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	c := newTestCipher(t)
+	plain := []byte(`{"FormatVersion":2}`)
+
+	first, err := c.Seal(plain)
+	if err != nil {
+		t.Fatalf("Data should be sealed, but got error %s", err)
+	}
+
+	second, _ := c.Seal(plain)
+	if bytes.Equal(first, second) {
+		t.Errorf("Every seal should use new nonce!")
+	}
+
+	opened, err := c.Open(first)
+	if err != nil {
+		t.Fatalf("Sealed data should be opened, but got error %s", err)
+	}
+
+	if !bytes.Equal(opened, plain) {
+		t.Errorf("Opened data mismatch! Expected %s, but was %s", plain, opened)
+	}
+}
+
+func TestTamperedDataIsDetected(t *testing.T) {
+	c := newTestCipher(t)
+	sealed, _ := c.Seal([]byte(`{"FormatVersion":2}`))
+
+	for i := len(encryptedFileMagic); i < len(sealed); i++ {
+		tampered := append([]byte{}, sealed...)
+		tampered[i] ^= 1
+
+		_, err := c.Open(tampered)
+		if !errors.Is(err, ErrTampered) {
+			t.Errorf("Change of byte %d should be detected, but got error %v", i, err)
+		}
+	}
+
+	_, err := c.Open(sealed[:len(sealed)-1])
+	if !errors.Is(err, ErrTampered) {
+		t.Errorf("Truncation should be detected, but got error %v", err)
+	}
+
+	_, err = newTestCipher(t).Open(sealed)
+	if !errors.Is(err, ErrTampered) {
+		t.Errorf("Wrong key should be detected, but got error %v", err)
+	}
+
+	_, err = c.Open([]byte("{}"))
+	if !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("Plaintext should be detected, but got error %v", err)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	if _, err := ParseKey(" AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA= \n"); err != nil {
+		t.Errorf("Key should be parsed, but got error %s", err)
+	}
+
+	for _, encoded := range []string{"not base64!", "AAAA"} {
+		if _, err := ParseKey(encoded); err == nil {
+			t.Errorf("Key %s should not be parsed!", encoded)
+		}
+	}
+}
+
+func TestEncryptedFileRepositoryBehavior(t *testing.T) {
+	c := createTestFileRepositoryConfig(t)
+	c.Cipher = newTestCipher(t)
+
+	testRepositoryBehavior(t, func(t *testing.T) cases.MachineRepository {
+		return NewRecoveryFileRepositoryDecorator(NewFileRepository(c), c)
+	})
+}
+
+func TestEncryptedFileHasNoPlaintext(t *testing.T) {
+	c := createTestFileRepositoryConfig(t)
+	c.Cipher = newTestCipher(t)
+	machine := createTestMachine()
+	machine.Name = "secret-machine-name"
+
+	err := NewRecoveryFileRepositoryDecorator(NewFileRepository(c), c).Save(machine)
+	if err != nil {
+		t.Fatalf("Failed to save machine, because of error %s", err)
+	}
+
+	raw, _ := os.ReadFile(c.Path)
+	if strings.Contains(string(raw), machine.Name) || strings.Contains(string(raw), machine.Id.String()) {
+		t.Errorf("Repository file should not contain plaintext!")
+	}
+}
+
+func TestTamperedRepositoryFileIsDetected(t *testing.T) {
+	c := createTestFileRepositoryConfig(t)
+	c.Cipher = newTestCipher(t)
+	repo := NewRecoveryFileRepositoryDecorator(NewFileRepository(c), c)
+	machine := createTestMachine()
+	repo.Save(machine)
+
+	raw, _ := os.ReadFile(c.Path)
+	raw[len(raw)/2] ^= 1
+	os.WriteFile(c.Path, raw, c.Permissions)
+
+	loadedMachine, err := repo.Load(machine.Id)
+	if !errors.Is(err, ErrTampered) {
+		t.Errorf("Tampered file should be detected, but got error %v", err)
+	}
+
+	if loadedMachine != nil {
+		t.Errorf("Machine should not be loaded from tampered file!")
+	}
+}
+
+func TestRotateStoreKey(t *testing.T) {
+	c := createTestFileRepositoryConfig(t)
+	repo := NewRecoveryFileRepositoryDecorator(NewFileRepository(c), c)
+	machine := createTestMachine()
+	repo.Save(machine)
+	repo.Save(machine)
+
+	// plaintext store is encrypted by the first rotation
+	first := newTestCipher(t)
+	rotated, err := RotateStoreKey(c, first)
+	if err != nil {
+		t.Fatalf("Key should be rotated, but got error %s", err)
+	}
+
+	if len(rotated) != 2 {
+		t.Errorf("Rotated files count mismatch! Expected %d, but was %d", 2, len(rotated))
+	}
+
+	c.Cipher = first
+	second := newTestCipher(t)
+	_, err = RotateStoreKey(c, second)
+	if err != nil {
+		t.Fatalf("Key should be rotated, but got error %s", err)
+	}
+
+	_, err = NewFileRepository(c).Load(machine.Id)
+	if !errors.Is(err, ErrTampered) {
+		t.Errorf("Old key should not open rotated store, but got error %v", err)
+	}
+
+	c.Cipher = second
+	loadedMachine, err := NewFileRepository(c).Load(machine.Id)
+	if err != nil {
+		t.Fatalf("New key should open rotated store, but got error %s", err)
+	}
+
+	assertMachinesEqual(t, machine, loadedMachine)
+
+	_, err = readSealedFile(generationPath(c.Path, 1), second)
+	if err != nil {
+		t.Errorf("Previous generation should be rotated, but got error %s", err)
+	}
+}
+
+func TestRotateStoreKeyRefusedWhileWriterIsRunning(t *testing.T) {
+	c := createTestFileRepositoryConfig(t)
+	repo := NewRecoveryFileRepositoryDecorator(NewFileRepository(c), c)
+	machine := createTestMachine()
+	if err := repo.Save(machine); err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+
+	l, err := AcquireSharedWriterLock(c.Path, c.Permissions)
+	if err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+	defer l.Release()
+
+	_, err = RotateStoreKey(c, newTestCipher(t))
+	if !errors.Is(err, cases.ErrStoreBusy) {
+		t.Errorf("Expected error %s, but was %v", cases.ErrStoreBusy, err)
+	}
+
+	if _, err := repo.Load(machine.Id); err != nil {
+		t.Errorf("Store should stay readable with the old key, but got error %s", err)
+	}
+}
+
+func newTestCipher(t *testing.T) *FileCipher {
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	c, err := NewFileCipher(key)
+	if err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+
+	return c
+}
@@ -0,0 +1,91 @@
+package adapters
+
+import (
+	"dum/internal/machines/cases"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Interval between attempts to acquire busy lock.
+const lockPollInterval time.Duration = 10 * time.Millisecond
+
+// Advisory cross-process lock. Lock is held on separate file next to the locked one, because
+// locked file itself is replaced on every save.
+type FileLock struct {
+	f *os.File
+}
+
+// Releases lock and closes lock file. Lock file is not removed, because other process can wait for it.
+func (l *FileLock) Release() error {
+	err := unlockFile(l.f)
+	if closeErr := l.f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func lockPath(path string) string {
+	return path + ".lock"
+}
+
+func writerLockPath(path string) string {
+	return path + ".writer.lock"
+}
+
+// Acquires lock for the file at path, polling it until timeout is elapsed. Returns an error wrapping
+// cases.ErrStoreBusy, if lock is still held by someone else.
+func AcquireFileLock(path string, perm os.FileMode, timeout time.Duration) (*FileLock, error) {
+	return acquireLock(lockPath(path), perm, timeout, true)
+}
+
+// Acquires lock of the single writer for the file at path. Lock should be held while process is running,
+// so the second writer process fails on startup instead of losing writes.
+func AcquireWriterLock(path string, perm os.FileMode) (*FileLock, error) {
+	l, err := acquireLock(writerLockPath(path), perm, 0, true)
+	if err != nil {
+		return nil, fmt.Errorf("another writer is working with %s - %w", path, err)
+	}
+
+	return l, nil
+}
+
+// Acquires writer lock for the file at path, which is shared with other writers, e.g. service instances
+// working with the same file. It's held while process is running, so exclusive writer lock, which is taken
+// by maintenance commands, is refused until all of them are stopped.
+func AcquireSharedWriterLock(path string, perm os.FileMode) (*FileLock, error) {
+	l, err := acquireLock(writerLockPath(path), perm, 0, false)
+	if err != nil {
+		return nil, fmt.Errorf("exclusive writer is working with %s - %w", path, err)
+	}
+
+	return l, nil
+}
+
+func acquireLock(path string, perm os.FileMode, timeout time.Duration, exclusive bool) (*FileLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLockFile(f, exclusive)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		if locked {
+			return &FileLock{f: f}, nil
+		}
+
+		if !time.Now().Before(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("cannot lock %s in %s - %w", path, timeout, cases.ErrStoreBusy)
+		}
+
+		time.Sleep(lockPollInterval)
+	}
+}
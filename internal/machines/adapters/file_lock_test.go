@@ -0,0 +1,158 @@
+package adapters
+
+import (
+	"dum/internal/machines/cases"
+	"dum/internal/machines/entities"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileLockTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), RepositoryFileName)
+	l, err := AcquireFileLock(path, 0600, 0)
+	if err != nil {
+		t.Fatalf("Cannot acquire lock due to error %s", err)
+	}
+
+	_, err = AcquireFileLock(path, 0600, 30*time.Millisecond)
+	if !errors.Is(err, cases.ErrStoreBusy) {
+		t.Errorf("Expected error %s, but was %s", cases.ErrStoreBusy, err)
+	}
+
+	err = l.Release()
+	if err != nil {
+		t.Fatalf("Cannot release lock due to error %s", err)
+	}
+
+	l, err = AcquireFileLock(path, 0600, 0)
+	if err != nil {
+		t.Fatalf("Released lock should be acquired, but got error %s", err)
+	}
+
+	l.Release()
+}
+
+func TestWriterLockRefusesSecondWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), RepositoryFileName)
+	l, err := AcquireWriterLock(path, 0600)
+	if err != nil {
+		t.Fatalf("Cannot acquire writer lock due to error %s", err)
+	}
+	defer l.Release()
+
+	_, err = AcquireWriterLock(path, 0600)
+	if !errors.Is(err, cases.ErrStoreBusy) {
+		t.Errorf("Expected error %s, but was %s", cases.ErrStoreBusy, err)
+	}
+
+	// writer lock should not block usual read-modify-write cycles
+	operationLock, err := AcquireFileLock(path, 0600, 0)
+	if err != nil {
+		t.Fatalf("Cannot acquire lock due to error %s", err)
+	}
+
+	operationLock.Release()
+}
+
+func TestSharedWriterLockRefusesExclusiveWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), RepositoryFileName)
+	first, err := AcquireSharedWriterLock(path, 0600)
+	if err != nil {
+		t.Fatalf("Cannot acquire shared writer lock due to error %s", err)
+	}
+
+	second, err := AcquireSharedWriterLock(path, 0600)
+	if err != nil {
+		t.Fatalf("Shared writer lock should be acquired by the second writer, but got error %s", err)
+	}
+
+	_, err = AcquireWriterLock(path, 0600)
+	if !errors.Is(err, cases.ErrStoreBusy) {
+		t.Errorf("Expected error %s, but was %s", cases.ErrStoreBusy, err)
+	}
+
+	first.Release()
+	second.Release()
+
+	l, err := AcquireWriterLock(path, 0600)
+	if err != nil {
+		t.Fatalf("Writer lock should be acquired after shared ones are released, but got error %s", err)
+	}
+
+	_, err = AcquireSharedWriterLock(path, 0600)
+	if !errors.Is(err, cases.ErrStoreBusy) {
+		t.Errorf("Expected error %s, but was %s", cases.ErrStoreBusy, err)
+	}
+
+	l.Release()
+}
+
+func TestFileRepositorySaveBusy(t *testing.T) {
+	c := createTestFileRepositoryConfig(t)
+	c.LockTimeout = 20 * time.Millisecond
+	l, err := AcquireFileLock(c.Path, c.Permissions, 0)
+	if err != nil {
+		t.Fatalf("Cannot acquire lock due to error %s", err)
+	}
+	defer l.Release()
+
+	repo := NewRecoveryFileRepositoryDecorator(NewFileRepository(c), c)
+	err = repo.Save(createTestMachine())
+	if !errors.Is(err, cases.ErrStoreBusy) {
+		t.Errorf("Expected error %s, but was %s", cases.ErrStoreBusy, err)
+	}
+}
+
+func TestFileRepositoryConcurrentWriters(t *testing.T) {
+	c := createTestFileRepositoryConfig(t)
+	machines := make(chan *entities.Machine, 40)
+	wg := &sync.WaitGroup{}
+
+	// every writer has its own in-process mutex, like separate service instances
+	for w := 0; w < 2; w++ {
+		repo := NewRecoveryFileRepositoryDecorator(NewFileRepository(c), c)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				machine := createTestMachine()
+				if err := repo.Save(machine); err != nil {
+					t.Errorf("Failed to save machine, because of error %s", err)
+					return
+				}
+				machines <- machine
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(machines)
+
+	repo := NewFileRepository(c)
+	for machine := range machines {
+		loadedMachine, err := repo.Load(machine.Id)
+		if err != nil {
+			t.Fatalf("Failed to load machine, because of error %s", err)
+		}
+
+		if loadedMachine == nil {
+			t.Errorf("Machine %s is lost!", machine.Id)
+		}
+	}
+}
+
+func createTestFileRepositoryConfig(t *testing.T) FileRepositoryConfig {
+	return FileRepositoryConfig{
+		Path:        filepath.Join(t.TempDir(), RepositoryFileName),
+		Permissions: 0600,
+		Generations: 1,
+		LockTimeout: 5 * time.Second,
+	}
+}
+
+func noLock() (func() error, error) {
+	return func() error { return nil }, nil
+}
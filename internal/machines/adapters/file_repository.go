@@ -14,6 +14,30 @@ import (
 
 const RepositoryFileName string = "machines.json"
 
+// Settings of file repository.
+type FileRepositoryConfig struct {
+	// Path to repository file.
+	Path string
+	// Permissions of repository file.
+	Permissions os.FileMode
+	// Count of previous file generations, which are kept for recovery.
+	Generations int
+	// Time to wait for cross-process lock of repository file.
+	LockTimeout time.Duration
+	// Cipher for encryption of repository file, file is stored in plaintext if it's nil.
+	Cipher *FileCipher
+}
+
+// Returns configuration for repository file in working directory.
+func DefaultFileRepositoryConfig() FileRepositoryConfig {
+	return FileRepositoryConfig{
+		Path:        RepositoryFileName,
+		Permissions: 0600,
+		Generations: 3,
+		LockTimeout: 5 * time.Second,
+	}
+}
+
 // Function for serialization.
 type serializer func(interface{}) ([]byte, error)
 
@@ -23,22 +47,39 @@ type deserializer func([]byte, interface{}) error
 // Function for reading from file
 type fileReader func(string) ([]byte, error)
 
-// Function for reading from file
+// Function for writing to file
 type fileWriter func(string, []byte, os.FileMode) error
 
-// Repository working with file.
+// Function for acquiring cross-process lock, returns function releasing it.
+type locker func() (func() error, error)
+
+// Returns locker for repository file, configured by c.
+func newFileLocker(c FileRepositoryConfig) locker {
+	return func() (func() error, error) {
+		l, err := AcquireFileLock(c.Path, c.Permissions, c.LockTimeout)
+		if err != nil {
+			return nil, err
+		}
+
+		return l.Release, nil
+	}
+}
+
+// Repository working with file. File is replaced atomically on every save, previous generations
+// of file are used for recovery, if the actual one is corrupted. Read-modify-write cycles are guarded
+// by cross-process file lock, so several processes can share the file.
 type FileRepository struct {
-	versionsMap map[string]MachineVersion
 	mu          *sync.Mutex
+	path        string
+	perm        os.FileMode
+	generations int
 	s           serializer
 	d           deserializer
 	fr          fileReader
 	fw          fileWriter
+	lock        locker
 }
 
-// Machine entity version for changes tracking.
-type MachineVersion string
-
 func (r *FileRepository) Load(id entities.MachineId) (*entities.Machine, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -49,87 +90,133 @@ func (r *FileRepository) Load(id entities.MachineId) (*entities.Machine, error)
 
 	for _, dto := range dtoSet {
 		if dto.Id == id.String() {
-			r.versionsMap[dto.Id] = MachineVersion(dto.Version)
-			return dto.toMachine(), nil
+			return dto.toMachine()
 		}
 	}
 
 	return nil, nil
 }
 
+func (r *FileRepository) List() ([]*entities.Machine, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dtoSet, err := r.loadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	return dtosToMachines(dtoSet)
+}
+
 func (r *FileRepository) Save(machine *entities.Machine) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	release, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	dtoSet, err := r.loadAll()
 	if err != nil {
 		return err
 	}
 
-	for _, dto := range dtoSet {
-		if dto.Id == machine.Id.String() && MachineVersion(dto.Version) != r.versionsMap[machine.Id.String()] {
-			return fmt.Errorf("optimistic lock occured! Expected %s version, but was %s version", r.versionsMap[machine.Name], dto.Version)
-		}
+	err = checkVersion(machine, dtoSet[machine.Id.String()].Version)
+	if err != nil {
+		return err
 	}
 
-	missingUpdateDtoSet := []missingUpdateDto{}
-	for _, update := range machine.GetMissingUpdates() {
-		dto := missingUpdateDto{
-			UpdateId: update.UpdateId.String(),
-			Duration: update.Duration,
-			Severity: int(update.Severity),
-		}
-		missingUpdateDtoSet = append(missingUpdateDtoSet, dto)
+	machineDto := newMachineDto(machine, uuid.NewString())
+	dtoSet[machine.Id.String()] = machineDto
+
+	err = r.saveAll(dtoSet)
+	if err != nil {
+		return err
 	}
-	machineDto := machineDto{
-		Name:           machine.Name,
-		MissingUpdates: missingUpdateDtoSet,
-		Version:        uuid.NewString(),
-		Id:             machine.Id.String(),
+	machine.SetVersion(entities.MachineVersion(machineDto.Version))
+	return nil
+}
+
+func (r *FileRepository) Delete(id entities.MachineId) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	release, err := r.lock()
+	if err != nil {
+		return err
 	}
-	dtoSet[machine.Id.String()] = machineDto
+	defer release()
 
-	raw, err := r.s(&dtoSet)
+	dtoSet, err := r.loadAll()
 	if err != nil {
 		return err
 	}
 
-	err = r.fw(RepositoryFileName, raw, os.ModeAppend)
+	if _, ok := dtoSet[id.String()]; !ok {
+		return nil
+	}
+
+	delete(dtoSet, id.String())
+
+	return r.saveAll(dtoSet)
+}
+
+func (r *FileRepository) saveAll(dtoSet map[string]machineDto) error {
+	raw, err := encodeStore(dtoSet, r.s)
 	if err != nil {
 		return err
 	}
-	r.versionsMap[machine.Id.String()] = MachineVersion(machineDto.Version)
-	return nil
+
+	return r.fw(r.path, raw, r.perm)
 }
 
 func (r *FileRepository) loadAll() (map[string]machineDto, error) {
-	raw, err := r.fr(RepositoryFileName)
+	raw, err := r.fr(r.path)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(raw) == 0 {
-		return map[string]machineDto{}, nil
+	dtoSet, err := r.parse(raw)
+	if err == nil {
+		return dtoSet, nil
 	}
 
-	var dtoSet map[string]machineDto
-	err = r.d(raw, &dtoSet)
+	for i := 1; i <= r.generations; i++ {
+		previous, readErr := r.fr(generationPath(r.path, i))
+		if readErr != nil {
+			continue
+		}
 
-	if err != nil {
-		return nil, err
+		recovered, parseErr := r.parse(previous)
+		if parseErr == nil {
+			return recovered, nil
+		}
 	}
 
-	return dtoSet, nil
+	return nil, err
+}
+
+func (r *FileRepository) parse(raw []byte) (map[string]machineDto, error) {
+	return decodeStore(raw, r.d)
 }
 
-func NewFileRepository() cases.MachineRepository {
+func NewFileRepository(c FileRepositoryConfig) cases.MachineRepository {
 	return &FileRepository{
-		versionsMap: map[string]MachineVersion{},
 		mu:          &sync.Mutex{},
+		path:        c.Path,
+		perm:        c.Permissions,
+		generations: c.Generations,
 		s:           json.Marshal,
 		d:           json.Unmarshal,
-		fr:          os.ReadFile,
-		fw:          os.WriteFile,
+		fr: func(path string) ([]byte, error) {
+			return readSealedFile(path, c.Cipher)
+		},
+		fw: func(path string, data []byte, perm os.FileMode) error {
+			return writeSealedFile(path, data, perm, c.Generations, c.Cipher)
+		},
+		lock: newFileLocker(c),
 	}
 }
 
@@ -140,30 +227,99 @@ type missingUpdateDto struct {
 	Duration time.Duration
 }
 
-func (m missingUpdateDto) toMissingUpdate() entities.MissingUpdate {
+func (m missingUpdateDto) toMissingUpdate() (entities.MissingUpdate, error) {
+	updateId, err := uuid.Parse(m.UpdateId)
+	if err != nil {
+		return entities.MissingUpdate{}, fmt.Errorf("invalid update id '%s' - %w", m.UpdateId, err)
+	}
+
 	return entities.MissingUpdate{
-		UpdateId: uuid.MustParse(m.UpdateId),
+		UpdateId: updateId,
 		Duration: m.Duration,
 		Severity: entities.Severity(m.Severity),
-	}
+	}, nil
 }
 
 // Data transfer object for machine entity.
 type machineDto struct {
 	Id, Name, Version string
 	MissingUpdates    []missingUpdateDto
+	Decommissioned    bool       `json:",omitempty"`
+	HealthChangedAt   *time.Time `json:",omitempty"`
 }
 
-func (m machineDto) toMachine() *entities.Machine {
+func newMachineDto(machine *entities.Machine, version string) machineDto {
+	missingUpdateDtoSet := []missingUpdateDto{}
+	for _, update := range machine.GetMissingUpdates() {
+		dto := missingUpdateDto{
+			UpdateId: update.UpdateId.String(),
+			Duration: update.Duration,
+			Severity: int(update.Severity),
+		}
+		missingUpdateDtoSet = append(missingUpdateDtoSet, dto)
+	}
+
+	dto := machineDto{
+		Name:           machine.Name,
+		MissingUpdates: missingUpdateDtoSet,
+		Version:        version,
+		Id:             machine.Id.String(),
+		Decommissioned: machine.IsDecommissioned(),
+	}
+
+	if changedAt := machine.GetHealthChangedAt(); !changedAt.IsZero() {
+		dto.HealthChangedAt = &changedAt
+	}
+
+	return dto
+}
+
+// Converts DTO to machine entity. Returns an error instead of panic for corrupted records,
+// which can be found and repaired with fsck command.
+func (m machineDto) toMachine() (*entities.Machine, error) {
 	var missingUpdates []entities.MissingUpdate
 
+	id, err := uuid.Parse(m.Id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id of machine '%s' - %w", m.Id, err)
+	}
+
 	for _, dto := range m.MissingUpdates {
-		missingUpdates = append(missingUpdates, dto.toMissingUpdate())
+		update, err := dto.toMissingUpdate()
+		if err != nil {
+			return nil, fmt.Errorf("corrupted machine %s - %w", m.Id, err)
+		}
+
+		missingUpdates = append(missingUpdates, update)
 	}
 
-	return entities.CreateMachine(
-		entities.MachineId(uuid.MustParse(m.Id)),
+	machine := entities.CreateMachine(
+		entities.MachineId(id),
 		m.Name,
 		missingUpdates,
 	)
+
+	if m.Decommissioned {
+		machine.Decommission()
+	}
+
+	if m.HealthChangedAt != nil {
+		machine.SetHealthChangedAt(*m.HealthChangedAt)
+	}
+
+	machine.SetVersion(entities.MachineVersion(m.Version))
+	return machine, nil
+}
+
+// Checks, that machine was loaded from the actual version of persisted state.
+func checkVersion(machine *entities.Machine, actual string) error {
+	if machine.GetVersion() != entities.MachineVersion(actual) {
+		return &cases.OptimisticLockError{
+			MachineId: machine.Id,
+			Expected:  machine.GetVersion(),
+			Actual:    entities.MachineVersion(actual),
+		}
+	}
+
+	return nil
 }
@@ -1,10 +1,12 @@
 package adapters
 
 import (
+	"dum/internal/machines/cases"
 	"dum/internal/machines/entities"
 	"encoding/json"
 	"errors"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -14,7 +16,7 @@ import (
 )
 
 func TestSuccessSaveLoad(t *testing.T) {
-	repo := NewFileRepository()
+	repo := NewFileRepository(DefaultFileRepositoryConfig())
 
 	file, err := os.Create(RepositoryFileName)
 
@@ -23,7 +25,7 @@ func TestSuccessSaveLoad(t *testing.T) {
 		return
 	}
 
-	defer os.Remove(RepositoryFileName)
+	defer removeRepositoryFiles(DefaultFileRepositoryConfig())
 	defer file.Close()
 	machine := entities.CreateMachine(
 		entities.MachineId(uuid.New()),
@@ -77,8 +79,82 @@ func TestSuccessSaveLoad(t *testing.T) {
 	}
 }
 
+func TestSaveLoadDecommissioned(t *testing.T) {
+	repo := NewFileRepository(DefaultFileRepositoryConfig())
+
+	file, err := os.Create(RepositoryFileName)
+
+	if err != nil {
+		t.Errorf("Cannot setup test due to error %s", err)
+		return
+	}
+
+	defer removeRepositoryFiles(DefaultFileRepositoryConfig())
+	defer file.Close()
+	machine := entities.CreateMachine(entities.MachineId(uuid.New()), "testName", []entities.MissingUpdate{})
+	machine.Decommission()
+
+	err = repo.Save(machine)
+	if err != nil {
+		t.Errorf("Failed to save machine, because of error %s", err)
+		return
+	}
+
+	loadedMachine, err := repo.Load(machine.Id)
+	if err != nil {
+		t.Errorf("Failed to load machine, because of error %s", err)
+		return
+	}
+
+	if !loadedMachine.IsDecommissioned() {
+		t.Errorf("Loaded machine should be decommissioned!")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	repo := NewFileRepository(DefaultFileRepositoryConfig())
+
+	file, err := os.Create(RepositoryFileName)
+
+	if err != nil {
+		t.Errorf("Cannot setup test due to error %s", err)
+		return
+	}
+
+	defer removeRepositoryFiles(DefaultFileRepositoryConfig())
+	defer file.Close()
+	machine := entities.CreateMachine(entities.MachineId(uuid.New()), "testName", []entities.MissingUpdate{})
+
+	err = repo.Save(machine)
+	if err != nil {
+		t.Errorf("Failed to save machine, because of error %s", err)
+		return
+	}
+
+	err = repo.Delete(machine.Id)
+	if err != nil {
+		t.Errorf("Failed to delete machine, because of error %s", err)
+		return
+	}
+
+	loadedMachine, err := repo.Load(machine.Id)
+	if err != nil {
+		t.Errorf("Failed to load machine, because of error %s", err)
+		return
+	}
+
+	if loadedMachine != nil {
+		t.Errorf("Deleted machine should not be loaded!")
+	}
+
+	err = repo.Delete(machine.Id)
+	if err != nil {
+		t.Errorf("Deleting absent machine should not return error, but was %s", err)
+	}
+}
+
 func TestOptimisticLockError(t *testing.T) {
-	repo := NewFileRepository()
+	repo := NewFileRepository(DefaultFileRepositoryConfig())
 
 	file, err := os.Create(RepositoryFileName)
 
@@ -87,7 +163,7 @@ func TestOptimisticLockError(t *testing.T) {
 		return
 	}
 
-	defer os.Remove(RepositoryFileName)
+	defer removeRepositoryFiles(DefaultFileRepositoryConfig())
 	defer file.Close()
 	machine := entities.CreateMachine(
 		entities.MachineId(uuid.New()),
@@ -101,8 +177,10 @@ func TestOptimisticLockError(t *testing.T) {
 		})
 
 	_ = repo.Save(machine)
-	repo = NewFileRepository()
-	err = repo.Save(machine)
+	first, _ := repo.Load(machine.Id)
+	second, _ := NewFileRepository(DefaultFileRepositoryConfig()).Load(machine.Id)
+	_ = repo.Save(first)
+	err = repo.Save(second)
 
 	if err == nil {
 		t.Errorf("Optimistic lock doesn't occure!")
@@ -117,7 +195,7 @@ func TestOptimisticLockError(t *testing.T) {
 }
 
 func TestBrokenJsonLoadError(t *testing.T) {
-	repo := NewFileRepository()
+	repo := NewFileRepository(DefaultFileRepositoryConfig())
 
 	file, err := os.Create(RepositoryFileName)
 
@@ -126,7 +204,7 @@ func TestBrokenJsonLoadError(t *testing.T) {
 		return
 	}
 
-	defer os.Remove(RepositoryFileName)
+	defer removeRepositoryFiles(DefaultFileRepositoryConfig())
 	defer file.Close()
 	file.WriteString("not a json")
 
@@ -141,8 +219,94 @@ func TestBrokenJsonLoadError(t *testing.T) {
 	}
 }
 
+func TestCorruptedMachineLoadError(t *testing.T) {
+	c := createTestFileRepositoryConfig(t)
+	id := uuid.New()
+	store := `{"` + id.String() + `":{"Id":"` + id.String() + `","Name":"broken","MissingUpdates":[{"UpdateId":"not-uuid"}]}}`
+	err := os.WriteFile(c.Path, []byte(store), c.Permissions)
+	if err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+
+	machine, err := NewFileRepository(c).Load(entities.MachineId(id))
+
+	if machine != nil {
+		t.Errorf("Corrupted machine should not be loaded!")
+	}
+
+	if err == nil {
+		t.Error("Error should not be nil!")
+	}
+}
+
+func TestRecoveryFromPreviousGeneration(t *testing.T) {
+	c := DefaultFileRepositoryConfig()
+	c.Path = filepath.Join(t.TempDir(), RepositoryFileName)
+	repo := NewFileRepository(c)
+
+	err := os.WriteFile(c.Path, []byte("{}"), c.Permissions)
+	if err != nil {
+		t.Errorf("Cannot setup test due to error %s", err)
+		return
+	}
+
+	machine := createTestMachine()
+	_ = repo.Save(machine)
+	_ = repo.Save(machine)
+
+	err = os.WriteFile(c.Path, []byte(`{"truncated`), c.Permissions)
+	if err != nil {
+		t.Errorf("Cannot corrupt file due to error %s", err)
+		return
+	}
+
+	loadedMachine, err := repo.Load(machine.Id)
+	if err != nil {
+		t.Errorf("Machine should be recovered, but got error %s", err)
+		return
+	}
+
+	if loadedMachine == nil {
+		t.Errorf("Machine should be recovered from previous generation!")
+		return
+	}
+
+	if loadedMachine.Name != machine.Name {
+		t.Errorf("Machine name mismatch! Expected %s, but was %s", machine.Name, loadedMachine.Name)
+	}
+}
+
+func TestConfiguredPathAndPermissions(t *testing.T) {
+	c := FileRepositoryConfig{
+		Path:        filepath.Join(t.TempDir(), "fleet.json"),
+		Permissions: 0640,
+		Generations: 1,
+	}
+	repo := NewRecoveryFileRepositoryDecorator(NewFileRepository(c), c)
+
+	err := repo.Save(createTestMachine())
+	if err != nil {
+		t.Errorf("Failed to save machine, because of error %s", err)
+		return
+	}
+
+	info, err := os.Stat(c.Path)
+	if err != nil {
+		t.Errorf("Repository file should exist at configured path, but got error %s", err)
+		return
+	}
+
+	if info.Mode().Perm() != c.Permissions {
+		t.Errorf("Permissions mismatch! Expected %o, but was %o", c.Permissions, info.Mode().Perm())
+	}
+
+	if _, err := os.Stat(RepositoryFileName); !os.IsNotExist(err) {
+		t.Errorf("Repository file should not be created in working directory!")
+	}
+}
+
 func TestEmptyLoad(t *testing.T) {
-	repo := NewFileRepository()
+	repo := NewFileRepository(DefaultFileRepositoryConfig())
 
 	file, err := os.Create(RepositoryFileName)
 
@@ -151,7 +315,7 @@ func TestEmptyLoad(t *testing.T) {
 		return
 	}
 
-	defer os.Remove(RepositoryFileName)
+	defer removeRepositoryFiles(DefaultFileRepositoryConfig())
 	defer file.Close()
 	file.WriteString("{}")
 
@@ -167,7 +331,7 @@ func TestEmptyLoad(t *testing.T) {
 }
 
 func TestNoFileLoadError(t *testing.T) {
-	repo := NewFileRepository()
+	repo := NewFileRepository(DefaultFileRepositoryConfig())
 
 	machine, err := repo.Load(entities.MachineId(uuid.New()))
 
@@ -181,7 +345,7 @@ func TestNoFileLoadError(t *testing.T) {
 }
 
 func TestNoFileSaveError(t *testing.T) {
-	repo := NewFileRepository()
+	repo := NewFileRepository(DefaultFileRepositoryConfig())
 
 	err := repo.Save(&entities.Machine{})
 
@@ -192,12 +356,13 @@ func TestNoFileSaveError(t *testing.T) {
 
 func TestSerializationError(t *testing.T) {
 	repo := FileRepository{
-		mu:          &sync.Mutex{},
-		versionsMap: map[string]MachineVersion{},
-		s:           func(i interface{}) ([]byte, error) { return nil, errExpected },
-		d:           json.Unmarshal,
-		fw:          os.WriteFile,
-		fr:          os.ReadFile,
+		lock: noLock,
+		mu:   &sync.Mutex{},
+		path: RepositoryFileName,
+		s:    func(i interface{}) ([]byte, error) { return nil, errExpected },
+		d:    json.Unmarshal,
+		fw:   os.WriteFile,
+		fr:   os.ReadFile,
 	}
 
 	file, err := os.Create(RepositoryFileName)
@@ -207,7 +372,7 @@ func TestSerializationError(t *testing.T) {
 		return
 	}
 
-	defer os.Remove(RepositoryFileName)
+	defer removeRepositoryFiles(DefaultFileRepositoryConfig())
 	defer file.Close()
 	machine := entities.CreateMachine(
 		entities.MachineId(uuid.New()),
@@ -233,12 +398,13 @@ func TestSerializationError(t *testing.T) {
 
 func TestFileWriteError(t *testing.T) {
 	repo := FileRepository{
-		mu:          &sync.Mutex{},
-		versionsMap: map[string]MachineVersion{},
-		s:           json.Marshal,
-		d:           json.Unmarshal,
-		fw:          func(s string, b []byte, fm os.FileMode) error { return errExpected },
-		fr:          os.ReadFile,
+		lock: noLock,
+		mu:   &sync.Mutex{},
+		path: RepositoryFileName,
+		s:    json.Marshal,
+		d:    json.Unmarshal,
+		fw:   func(s string, b []byte, fm os.FileMode) error { return errExpected },
+		fr:   os.ReadFile,
 	}
 
 	file, err := os.Create(RepositoryFileName)
@@ -248,7 +414,7 @@ func TestFileWriteError(t *testing.T) {
 		return
 	}
 
-	defer os.Remove(RepositoryFileName)
+	defer removeRepositoryFiles(DefaultFileRepositoryConfig())
 	defer file.Close()
 	machine := entities.CreateMachine(
 		entities.MachineId(uuid.New()),
@@ -272,4 +438,57 @@ func TestFileWriteError(t *testing.T) {
 	}
 }
 
+func removeRepositoryFiles(c FileRepositoryConfig) {
+	os.Remove(c.Path)
+	os.Remove(lockPath(c.Path))
+
+	for i := 1; i <= c.Generations; i++ {
+		os.Remove(generationPath(c.Path, i))
+	}
+}
+
 var errExpected error = errors.New("TEST FAIL")
+
+func TestFileRepositoryBehavior(t *testing.T) {
+	file, err := os.Create(RepositoryFileName)
+
+	if err != nil {
+		t.Errorf("Cannot setup test due to error %s", err)
+		return
+	}
+
+	defer removeRepositoryFiles(DefaultFileRepositoryConfig())
+	file.Close()
+
+	testRepositoryBehavior(t, func(t *testing.T) cases.MachineRepository {
+		return NewFileRepository(DefaultFileRepositoryConfig())
+	})
+}
+
+func BenchmarkFileRepositorySave(b *testing.B) {
+	c := FileRepositoryConfig{
+		Path:        filepath.Join(b.TempDir(), RepositoryFileName),
+		Permissions: 0600,
+	}
+	repo := NewFileRepository(c).(*FileRepository)
+	machines := make([]*entities.Machine, 0, benchmarkFleetSize)
+	dtoSet := map[string]machineDto{}
+	for i := 0; i < benchmarkFleetSize; i++ {
+		machine := createTestMachine()
+		dto := newMachineDto(machine, uuid.NewString())
+		dtoSet[dto.Id] = dto
+		machine.SetVersion(entities.MachineVersion(dto.Version))
+		machines = append(machines, machine)
+	}
+
+	if err := repo.saveAll(dtoSet); err != nil {
+		b.Fatalf("Cannot setup benchmark due to error %s", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := repo.Save(machines[i%len(machines)]); err != nil {
+			b.Fatalf("Failed to save machine, because of error %s", err)
+		}
+	}
+}
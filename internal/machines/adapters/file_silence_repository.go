@@ -0,0 +1,392 @@
+package adapters
+
+import (
+	"dum/internal/machines/entities"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const SilencesFileName string = "silences.json"
+
+// Repository of silences, acknowledgments and escalation progress, which keeps them in memory and writes them to JSON file
+// on every change. File is replaced atomically, so it's never left half-written.
+type FileSilenceRepository struct {
+	path string
+
+	mu              sync.Mutex
+	silences        map[entities.SilenceId]entities.Silence
+	acknowledgments map[entities.MachineId]entities.Acknowledgment
+	escalations     map[entities.MachineId]escalationDto
+}
+
+func (r *FileSilenceRepository) ListSilences() ([]entities.Silence, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := []entities.Silence{}
+	for _, s := range r.silences {
+		result = append(result, s)
+	}
+	sortSilences(result)
+
+	return result, nil
+}
+
+func (r *FileSilenceRepository) LoadSilence(id entities.SilenceId) (*entities.Silence, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.silences[id]
+	if !ok {
+		return nil, nil
+	}
+
+	return &s, nil
+}
+
+func (r *FileSilenceRepository) SaveSilence(s entities.Silence) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous, existed := r.silences[s.Id]
+	r.silences[s.Id] = s
+
+	err := r.save()
+	if err != nil {
+		if existed {
+			r.silences[s.Id] = previous
+		} else {
+			delete(r.silences, s.Id)
+		}
+	}
+
+	return err
+}
+
+func (r *FileSilenceRepository) DeleteSilence(id entities.SilenceId) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous, existed := r.silences[id]
+	if !existed {
+		return nil
+	}
+	delete(r.silences, id)
+
+	err := r.save()
+	if err != nil {
+		r.silences[id] = previous
+	}
+
+	return err
+}
+
+func (r *FileSilenceRepository) ListAcknowledgments() ([]entities.Acknowledgment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := []entities.Acknowledgment{}
+	for _, a := range r.acknowledgments {
+		result = append(result, a)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].MachineId.String() < result[j].MachineId.String() })
+
+	return result, nil
+}
+
+func (r *FileSilenceRepository) LoadAcknowledgment(id entities.MachineId) (*entities.Acknowledgment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.acknowledgments[id]
+	if !ok {
+		return nil, nil
+	}
+
+	return &a, nil
+}
+
+func (r *FileSilenceRepository) SaveAcknowledgment(a entities.Acknowledgment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous, existed := r.acknowledgments[a.MachineId]
+	r.acknowledgments[a.MachineId] = a
+
+	err := r.save()
+	if err != nil {
+		if existed {
+			r.acknowledgments[a.MachineId] = previous
+		} else {
+			delete(r.acknowledgments, a.MachineId)
+		}
+	}
+
+	return err
+}
+
+func (r *FileSilenceRepository) DeleteAcknowledgment(id entities.MachineId) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous, existed := r.acknowledgments[id]
+	if !existed {
+		return nil
+	}
+	delete(r.acknowledgments, id)
+
+	err := r.save()
+	if err != nil {
+		r.acknowledgments[id] = previous
+	}
+
+	return err
+}
+
+func (r *FileSilenceRepository) IsAcknowledged(id entities.MachineId, since time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.acknowledgments[id]
+	return ok && a.Covers(since), nil
+}
+
+func (r *FileSilenceRepository) LoadEscalation(id entities.MachineId, since time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.escalations[id]
+	if !ok || !e.Since.Equal(since) {
+		return 0, nil
+	}
+
+	return e.Notified, nil
+}
+
+func (r *FileSilenceRepository) SaveEscalation(id entities.MachineId, since time.Time, notified int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous, existed := r.escalations[id]
+	r.escalations[id] = escalationDto{MachineId: id.String(), Since: since, Notified: notified}
+
+	err := r.save()
+	if err != nil {
+		if existed {
+			r.escalations[id] = previous
+		} else {
+			delete(r.escalations, id)
+		}
+	}
+
+	return err
+}
+
+func (r *FileSilenceRepository) DeleteEscalation(id entities.MachineId) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous, existed := r.escalations[id]
+	if !existed {
+		return nil
+	}
+	delete(r.escalations, id)
+
+	err := r.save()
+	if err != nil {
+		r.escalations[id] = previous
+	}
+
+	return err
+}
+
+func (r *FileSilenceRepository) save() error {
+	dto := silenceStoreDto{
+		Silences:        []silenceDto{},
+		Acknowledgments: []acknowledgmentDto{},
+		Escalations:     []escalationDto{},
+	}
+
+	for _, s := range r.silences {
+		dto.Silences = append(dto.Silences, newSilenceDto(s))
+	}
+	sort.Slice(dto.Silences, func(i, j int) bool { return dto.Silences[i].Id < dto.Silences[j].Id })
+
+	for _, a := range r.acknowledgments {
+		dto.Acknowledgments = append(dto.Acknowledgments, newAcknowledgmentDto(a))
+	}
+	sort.Slice(dto.Acknowledgments, func(i, j int) bool { return dto.Acknowledgments[i].MachineId < dto.Acknowledgments[j].MachineId })
+
+	for _, e := range r.escalations {
+		dto.Escalations = append(dto.Escalations, e)
+	}
+	sort.Slice(dto.Escalations, func(i, j int) bool { return dto.Escalations[i].MachineId < dto.Escalations[j].MachineId })
+
+	raw, err := json.MarshalIndent(dto, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomically(r.path, raw, 0600, 0)
+}
+
+func (r *FileSilenceRepository) load() error {
+	raw, err := os.ReadFile(r.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var dto silenceStoreDto
+	if err := json.Unmarshal(raw, &dto); err != nil {
+		return err
+	}
+
+	for _, s := range dto.Silences {
+		silence, err := s.toSilence()
+		if err != nil {
+			return err
+		}
+		r.silences[silence.Id] = silence
+	}
+
+	for _, a := range dto.Acknowledgments {
+		ack, err := a.toAcknowledgment()
+		if err != nil {
+			return err
+		}
+		r.acknowledgments[ack.MachineId] = ack
+	}
+
+	for _, e := range dto.Escalations {
+		id, err := uuid.Parse(e.MachineId)
+		if err != nil {
+			return fmt.Errorf("invalid machine id of escalation '%s' - %w", e.MachineId, err)
+		}
+		r.escalations[entities.MachineId(id)] = e
+	}
+
+	return nil
+}
+
+// Orders silences by start time and id.
+func sortSilences(silences []entities.Silence) {
+	sort.Slice(silences, func(i, j int) bool {
+		if !silences[i].StartsAt.Equal(silences[j].StartsAt) {
+			return silences[i].StartsAt.Before(silences[j].StartsAt)
+		}
+		return silences[i].Id.String() < silences[j].Id.String()
+	})
+}
+
+// Data transfer object for file with silences, acknowledgments and escalation progress.
+type silenceStoreDto struct {
+	Silences        []silenceDto
+	Acknowledgments []acknowledgmentDto
+	Escalations     []escalationDto
+}
+
+// Data transfer object for silence entity.
+type silenceDto struct {
+	Id                 string
+	Matchers           []entities.SilenceMatcher
+	StartsAt, EndsAt   time.Time
+	CreatedBy, Comment string
+	CreatedAt          time.Time
+}
+
+func newSilenceDto(s entities.Silence) silenceDto {
+	return silenceDto{
+		Id:        s.Id.String(),
+		Matchers:  s.Matchers,
+		StartsAt:  s.StartsAt,
+		EndsAt:    s.EndsAt,
+		CreatedBy: s.CreatedBy,
+		Comment:   s.Comment,
+		CreatedAt: s.CreatedAt,
+	}
+}
+
+func (s silenceDto) toSilence() (entities.Silence, error) {
+	id, err := uuid.Parse(s.Id)
+	if err != nil {
+		return entities.Silence{}, fmt.Errorf("invalid id of silence '%s' - %w", s.Id, err)
+	}
+
+	silence := entities.Silence{
+		Id:        entities.SilenceId(id),
+		Matchers:  s.Matchers,
+		StartsAt:  s.StartsAt,
+		EndsAt:    s.EndsAt,
+		CreatedBy: s.CreatedBy,
+		Comment:   s.Comment,
+		CreatedAt: s.CreatedAt,
+	}
+
+	return silence, silence.Compile()
+}
+
+// Data transfer object for acknowledgment entity.
+type acknowledgmentDto struct {
+	MachineId          string
+	Since              time.Time
+	CreatedBy, Comment string
+	CreatedAt          time.Time
+}
+
+func newAcknowledgmentDto(a entities.Acknowledgment) acknowledgmentDto {
+	return acknowledgmentDto{
+		MachineId: a.MachineId.String(),
+		Since:     a.Since,
+		CreatedBy: a.CreatedBy,
+		Comment:   a.Comment,
+		CreatedAt: a.CreatedAt,
+	}
+}
+
+func (a acknowledgmentDto) toAcknowledgment() (entities.Acknowledgment, error) {
+	id, err := uuid.Parse(a.MachineId)
+	if err != nil {
+		return entities.Acknowledgment{}, fmt.Errorf("invalid machine id of acknowledgment '%s' - %w", a.MachineId, err)
+	}
+
+	return entities.Acknowledgment{
+		MachineId: entities.MachineId(id),
+		Since:     a.Since,
+		CreatedBy: a.CreatedBy,
+		Comment:   a.Comment,
+		CreatedAt: a.CreatedAt,
+	}, nil
+}
+
+// Data transfer object for progress of escalation, count of notified tiers of machine's health episode.
+type escalationDto struct {
+	MachineId string
+	Since     time.Time
+	Notified  int
+}
+
+// Creates repository, reading silences and acknowledgments from file, if it exists.
+func NewFileSilenceRepository(path string) (*FileSilenceRepository, error) {
+	r := &FileSilenceRepository{
+		path:            path,
+		silences:        map[entities.SilenceId]entities.Silence{},
+		acknowledgments: map[entities.MachineId]entities.Acknowledgment{},
+		escalations:     map[entities.MachineId]escalationDto{},
+	}
+
+	if err := r.load(); err != nil {
+		return nil, fmt.Errorf("cannot read silences from %s - %w", path, err)
+	}
+
+	return r, nil
+}
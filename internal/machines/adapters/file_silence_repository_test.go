@@ -0,0 +1,87 @@
+package adapters
+
+import (
+	"dum/internal/machines/entities"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestFileSilenceRepositoryPersistsChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), SilencesFileName)
+	r, err := NewFileSilenceRepository(path)
+	if err != nil {
+		t.Fatalf("Cannot create repository %s", err)
+	}
+
+	silence := createTestSilence()
+	removed := createTestSilence()
+	ack := entities.Acknowledgment{
+		MachineId: entities.MachineId(uuid.New()),
+		Since:     time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC),
+		CreatedBy: "bob",
+	}
+
+	for _, err := range []error{r.SaveSilence(silence), r.SaveSilence(removed), r.DeleteSilence(removed.Id), r.SaveAcknowledgment(ack)} {
+		if err != nil {
+			t.Fatalf("Expected nil err, but was %s", err)
+		}
+	}
+
+	reopened, err := NewFileSilenceRepository(path)
+	if err != nil {
+		t.Fatalf("Cannot reopen repository %s", err)
+	}
+
+	silences, _ := reopened.ListSilences()
+	if len(silences) != 1 || silences[0].Id != silence.Id || silences[0].Matchers[0].String() != silence.Matchers[0].String() || !silences[0].EndsAt.Equal(silence.EndsAt) {
+		t.Errorf("Silences mismatch! Was %+v", silences)
+	}
+
+	if loaded, _ := reopened.LoadAcknowledgment(ack.MachineId); loaded == nil || loaded.CreatedBy != "bob" {
+		t.Errorf("Acknowledgment mismatch! Was %+v", loaded)
+	}
+
+	if acked, _ := reopened.IsAcknowledged(ack.MachineId, ack.Since); !acked {
+		t.Error("Health episode should be acknowledged!")
+	}
+
+	if acked, _ := reopened.IsAcknowledged(ack.MachineId, ack.Since.Add(time.Hour)); acked {
+		t.Error("Next health episode should not be acknowledged!")
+	}
+
+	reopened.DeleteAcknowledgment(ack.MachineId)
+	if loaded, _ := reopened.LoadAcknowledgment(ack.MachineId); loaded != nil {
+		t.Errorf("Acknowledgment should be deleted, but was %+v", loaded)
+	}
+}
+
+func TestFileSilenceRepositoryKeepsStateOnWriteError(t *testing.T) {
+	r, err := NewFileSilenceRepository(filepath.Join(t.TempDir(), "missing", SilencesFileName))
+	if err != nil {
+		t.Fatalf("Cannot create repository %s", err)
+	}
+
+	silence := createTestSilence()
+	if err := r.SaveSilence(silence); err == nil {
+		t.Fatal("Expected write error, but was nil")
+	}
+
+	if loaded, _ := r.LoadSilence(silence.Id); loaded != nil {
+		t.Errorf("Silence should not be kept, if it's not written, but was %+v", loaded)
+	}
+}
+
+func createTestSilence() entities.Silence {
+	start := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	return entities.Silence{
+		Id:        entities.SilenceId(uuid.New()),
+		Matchers:  []entities.SilenceMatcher{{Label: entities.MachineLabel, Operator: entities.RegexpOperator, Value: "web-.*"}},
+		StartsAt:  start,
+		EndsAt:    start.Add(time.Hour),
+		CreatedBy: "alice",
+		CreatedAt: start,
+	}
+}
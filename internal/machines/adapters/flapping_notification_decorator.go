@@ -0,0 +1,187 @@
+package adapters
+
+import (
+	"dum/internal/machines/entities"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Settings of flap detection and hysteresis, zero values disable corresponding checks.
+type FlapConfig struct {
+	// Count of health level changes in window, which marks machine as flapping.
+	Transitions int
+	// Length of flap detection window.
+	Window time.Duration
+	// Time without health level changes, after which flapping machine is stable again, window by default.
+	StableFor time.Duration
+	// Count of consecutive Healthy reports, after which recovery of machine is notified.
+	RecoveryReports int
+}
+
+// Returns true, if flap detection or hysteresis is configured.
+func (c FlapConfig) Enabled() bool {
+	return c.Transitions > 0 || c.RecoveryReports > 1
+}
+
+// Checks, that flap detection has window and counts at least two changes.
+func (c FlapConfig) Validate() error {
+	if c.Transitions == 0 {
+		return nil
+	}
+
+	if c.Transitions < 2 || c.Window <= 0 {
+		return errors.New("flap detection needs at least 2 transitions and positive window")
+	}
+
+	return nil
+}
+
+// Counters of flap detecting decorator.
+type FlapStats struct {
+	Notified, Flapping, Delayed uint64
+}
+
+// Health of machine, as it's known to flap detection.
+type flapState struct {
+	// The last health level, which was notified.
+	announced   entities.HealthLevel
+	transitions []time.Time
+	flapping    bool
+	// Count of consecutive Healthy reports, which are not notified yet.
+	healthy int
+	// The last handled event, so repeated notification of the same event is not counted twice.
+	seen flapEvent
+}
+
+type flapEvent struct {
+	occurredAt        int64
+	previous, current entities.HealthLevel
+}
+
+// Notification decorator, which keeps history of health level changes of every machine. Machine, which
+// changes its level too often, is marked as flapping and its events are dropped, until it has no changes
+// for a while. Recovery to Healthy is notified only after configured count of consecutive Healthy reports.
+// Events, passed to base strategy, have the last notified level as previous one, so short bounces
+// are not visible at all. Level is remembered as notified only after base strategy succeeds, and the
+// same event, notified again, is not counted as another change. Reminders of escalation policies are passed as is.
+type FlappingNotificationDecorator struct {
+	base   entities.HealthNotificationStrategy
+	config FlapConfig
+	logger *log.Logger
+	now    func() time.Time
+
+	mu       sync.Mutex
+	machines map[entities.MachineId]*flapState
+	stats    FlapStats
+}
+
+func (s *FlappingNotificationDecorator) Notify(e entities.HealthEvent) error {
+	if e.Escalated() {
+		return s.base.Notify(e)
+	}
+
+	s.mu.Lock()
+	now := s.now()
+	state, ok := s.machines[e.MachineId]
+	if !ok {
+		state = &flapState{announced: e.Previous}
+		s.machines[e.MachineId] = state
+	}
+
+	seen := flapEvent{e.OccurredAt.UnixNano(), e.Previous, e.Current}
+	repeated := seen == state.seen
+	state.seen = seen
+
+	if e.Changed() && !repeated {
+		state.transitions = append(dropBefore(state.transitions, now.Add(-s.config.Window)), now)
+	}
+
+	if s.isFlapping(e, state, now) {
+		s.stats.Flapping++
+		s.mu.Unlock()
+		return nil
+	}
+
+	if e.Current == entities.Healthy && state.announced != entities.Healthy {
+		if !repeated {
+			state.healthy++
+		}
+		if state.healthy < s.config.RecoveryReports {
+			s.stats.Delayed++
+			s.mu.Unlock()
+			return nil
+		}
+	}
+
+	e.Previous = state.announced
+	s.mu.Unlock()
+
+	// level is announced only, when base strategy got it, so failed event can be notified again
+	if err := s.base.Notify(e); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state.announced = e.Current
+	state.healthy = 0
+	s.stats.Notified++
+	return nil
+}
+
+func (s *FlappingNotificationDecorator) Close() error {
+	return closeStrategy(s.base)
+}
+
+func (s *FlappingNotificationDecorator) Stats() FlapStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// Updates flapping mark of machine and returns true, if its events should be dropped.
+func (s *FlappingNotificationDecorator) isFlapping(e entities.HealthEvent, state *flapState, now time.Time) bool {
+	if s.config.Transitions <= 0 {
+		return false
+	}
+
+	if !state.flapping && len(state.transitions) >= s.config.Transitions {
+		state.flapping = true
+		state.healthy = 0
+		s.logger.Printf("Machine %s is flapping, its notifications are suppressed until it's stable", e.MachineName)
+	}
+
+	if !state.flapping {
+		return false
+	}
+
+	last := state.transitions[len(state.transitions)-1]
+	if now.Sub(last) < s.config.StableFor {
+		return true
+	}
+
+	state.flapping = false
+	state.transitions = nil
+	s.logger.Printf("Machine %s is stable at %s", e.MachineName, e.Current)
+	return false
+}
+
+func newFlappingNotificationDecorator(base entities.HealthNotificationStrategy, c FlapConfig, logger *log.Logger, now func() time.Time) *FlappingNotificationDecorator {
+	if c.StableFor <= 0 {
+		c.StableFor = c.Window
+	}
+
+	return &FlappingNotificationDecorator{
+		base:     base,
+		config:   c,
+		logger:   logger,
+		now:      now,
+		machines: map[entities.MachineId]*flapState{},
+	}
+}
+
+func NewFlappingNotificationDecorator(base entities.HealthNotificationStrategy, c FlapConfig, logger *log.Logger) *FlappingNotificationDecorator {
+	return newFlappingNotificationDecorator(base, c, logger, time.Now)
+}
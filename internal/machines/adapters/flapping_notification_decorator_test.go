@@ -0,0 +1,129 @@
+package adapters
+
+import (
+	"dum/internal/machines/entities"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFlappingMachineIsSuppressedUntilStable(t *testing.T) {
+	base := &recordingStrategy{}
+	clock := newTestClock()
+	s := newFlappingNotificationDecorator(base, FlapConfig{Transitions: 4, Window: 10 * time.Minute}, newDiscardLogger(), clock.now)
+	e := createTestHealthEvent()
+
+	for _, levels := range [][2]entities.HealthLevel{
+		{entities.Healthy, entities.Warning},
+		{entities.Warning, entities.Danger},
+		{entities.Danger, entities.Warning},
+		{entities.Warning, entities.Danger},
+		{entities.Danger, entities.Warning},
+		{entities.Warning, entities.Danger},
+	} {
+		e.Previous, e.Current = levels[0], levels[1]
+		s.Notify(e)
+		clock.advance(time.Minute)
+	}
+
+	if base.count != 3 {
+		t.Fatalf("Notified events count mismatch! Expected 3, but was %d", base.count)
+	}
+
+	escalated := e
+	escalated.Previous, escalated.Escalation = entities.Danger, "tier 2"
+	s.Notify(escalated)
+
+	e.Previous = entities.Danger
+	clock.advance(3 * time.Minute)
+	s.Notify(e)
+	clock.advance(7 * time.Minute)
+	s.Notify(e)
+
+	if base.count != 5 {
+		t.Fatalf("Notified events count mismatch! Expected 5, but was %d", base.count)
+	}
+
+	if last := base.events[4]; last.Previous != entities.Warning || last.Current != entities.Danger {
+		t.Errorf("Stable event should change the last notified level, but was %s -> %s", last.Previous, last.Current)
+	}
+
+	if stats := s.Stats(); stats.Flapping != 4 || stats.Notified != 4 {
+		t.Errorf("Stats mismatch! Was %+v", stats)
+	}
+}
+
+func TestRecoveryNeedsConsecutiveHealthyReports(t *testing.T) {
+	base := &recordingStrategy{}
+	clock := newTestClock()
+	s := newFlappingNotificationDecorator(base, FlapConfig{RecoveryReports: 2}, newDiscardLogger(), clock.now)
+	e := createTestHealthEvent()
+
+	for _, levels := range [][2]entities.HealthLevel{
+		{entities.Healthy, entities.Danger},
+		{entities.Danger, entities.Healthy},
+		{entities.Healthy, entities.Danger},
+		{entities.Danger, entities.Healthy},
+		{entities.Healthy, entities.Healthy},
+		{entities.Healthy, entities.Healthy},
+	} {
+		e.Previous, e.Current = levels[0], levels[1]
+		s.Notify(e)
+	}
+
+	expected := [][2]entities.HealthLevel{
+		{entities.Healthy, entities.Danger},
+		{entities.Danger, entities.Danger},
+		{entities.Danger, entities.Healthy},
+		{entities.Healthy, entities.Healthy},
+	}
+	if len(base.events) != len(expected) {
+		t.Fatalf("Notified events count mismatch! Expected %d, but was %d", len(expected), len(base.events))
+	}
+
+	for i, levels := range expected {
+		if base.events[i].Previous != levels[0] || base.events[i].Current != levels[1] {
+			t.Errorf("Event %d mismatch! Expected %s -> %s, but was %s -> %s", i, levels[0], levels[1], base.events[i].Previous, base.events[i].Current)
+		}
+	}
+
+	if stats := s.Stats(); stats.Delayed != 2 {
+		t.Errorf("Delayed count mismatch! Expected 2, but was %d", stats.Delayed)
+	}
+}
+
+func TestFailedEventIsNotifiedAgainAndCountedOnce(t *testing.T) {
+	base := &recordingStrategy{err: errors.New("channel is down")}
+	clock := newTestClock()
+	s := newFlappingNotificationDecorator(base, FlapConfig{Transitions: 2, Window: 10 * time.Minute}, newDiscardLogger(), clock.now)
+	e := createTestHealthEvent()
+
+	if err := s.Notify(e); err == nil {
+		t.Fatal("Expected err of base strategy, but was nil")
+	}
+
+	base.err = nil
+	if err := s.Notify(e); err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	if base.count != 2 {
+		t.Fatalf("Retried event should not be counted as flapping, but base got %d events", base.count)
+	}
+
+	if last := base.events[1]; last.Previous != entities.Healthy || last.Current != entities.Danger {
+		t.Errorf("Retried event should keep its change, but was %s -> %s", last.Previous, last.Current)
+	}
+
+	if stats := s.Stats(); stats.Notified != 1 || stats.Flapping != 0 {
+		t.Errorf("Stats mismatch! Was %+v", stats)
+	}
+}
+
+func TestInvalidFlapConfig(t *testing.T) {
+	for _, c := range []FlapConfig{{Transitions: 1, Window: time.Minute}, {Transitions: 3}} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("Expected error for config %+v, but was nil", c)
+		}
+	}
+}
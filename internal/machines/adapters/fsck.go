@@ -0,0 +1,283 @@
+package adapters
+
+import (
+	"dum/internal/machines/entities"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// Kind of problem, found in machine store.
+type FsckProblemKind string
+
+const (
+	// Neither key nor embedded id of record is a valid machine id.
+	FsckInvalidId FsckProblemKind = "invalid-id"
+	// Key of record differs from embedded machine id.
+	FsckKeyMismatch FsckProblemKind = "key-mismatch"
+	// Several machines have the same name.
+	FsckDuplicateName FsckProblemKind = "duplicate-name"
+	// Missing update has invalid id.
+	FsckInvalidUpdateId FsckProblemKind = "invalid-update-id"
+	// Missing update has unknown severity.
+	FsckUnknownSeverity FsckProblemKind = "unknown-severity"
+	// Missing update has negative duration.
+	FsckNegativeDuration FsckProblemKind = "negative-duration"
+)
+
+// Actions, taken for found problems.
+const (
+	FsckRepaired    string = "repaired"
+	FsckQuarantined string = "quarantined"
+)
+
+// Single problem, found in machine store.
+type FsckProblem struct {
+	// Key of broken record in store.
+	Key    string
+	Kind   FsckProblemKind
+	Detail string
+	// Action taken for problem, empty if problem is only reported.
+	Action string `json:",omitempty"`
+}
+
+// Machine-readable result of store check.
+type FsckReport struct {
+	Path     string
+	Machines int
+	Problems []FsckProblem
+	// Path to file with quarantined records, empty if nothing was quarantined.
+	Quarantine string `json:",omitempty"`
+}
+
+// Returns true, if store has problems, which are neither repaired nor quarantined.
+func (r FsckReport) HasUnresolved() bool {
+	return hasUnresolved(r.Problems)
+}
+
+// Duplicate names are only reported, because they are not breaking anything.
+func hasUnresolved(problems []FsckProblem) bool {
+	for _, p := range problems {
+		if p.Action == "" && p.Kind != FsckDuplicateName {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasRepaired(problems []FsckProblem) bool {
+	for _, p := range problems {
+		if p.Action == FsckRepaired {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Options of store check.
+type FsckOptions struct {
+	// Fix problems, which can be fixed without loosing data - key/id mismatches, negative durations.
+	// Missing updates with invalid id or unknown severity are removed.
+	Repair bool
+	// Move records with problems, which are not repaired, to quarantine file next to store.
+	Quarantine bool
+}
+
+func quarantinePath(path string) string {
+	return path + ".quarantine.json"
+}
+
+// Walks store file and reports broken records. Records are repaired or quarantined according to options,
+// original file is kept as previous generation.
+func CheckStoreFile(c FileRepositoryConfig, o FsckOptions) (FsckReport, error) {
+	report := FsckReport{Path: c.Path, Problems: []FsckProblem{}}
+
+	l, err := AcquireFileLock(c.Path, c.Permissions, c.LockTimeout)
+	if err != nil {
+		return report, err
+	}
+	defer l.Release()
+
+	raw, err := readSealedFile(c.Path, c.Cipher)
+	if err != nil {
+		return report, err
+	}
+
+	dtoSet, err := decodeStore(raw, json.Unmarshal)
+	if err != nil {
+		return report, err
+	}
+
+	checked := map[string]machineDto{}
+	quarantined := map[string]machineDto{}
+	changed := false
+
+	for _, key := range sortedKeys(dtoSet) {
+		dto := dtoSet[key]
+		problems, fixed := checkMachineDto(key, dto, o.Repair)
+		target := key
+		if _, err := uuid.Parse(fixed.Id); err == nil && fixed.Id != key {
+			_, inStore := dtoSet[fixed.Id]
+			_, inChecked := checked[fixed.Id]
+			if inStore || inChecked {
+				// repaired record would replace another one, so it's left as is
+				for i := range problems {
+					problems[i].Action = ""
+				}
+				fixed = dto
+			} else if o.Repair {
+				target = fixed.Id
+			}
+		}
+
+		if o.Quarantine && hasUnresolved(problems) {
+			// original record is quarantined, so repairs of its other problems are discarded
+			for i := range problems {
+				problems[i].Action = FsckQuarantined
+			}
+			quarantined[key] = dto
+			changed = true
+		} else {
+			checked[target] = fixed
+			changed = changed || hasRepaired(problems)
+		}
+
+		report.Problems = append(report.Problems, problems...)
+	}
+
+	report.Problems = append(report.Problems, checkDuplicateNames(checked)...)
+	report.Machines = len(checked)
+
+	if !changed {
+		return report, nil
+	}
+
+	if len(quarantined) > 0 {
+		report.Quarantine = quarantinePath(c.Path)
+		err = appendQuarantine(report.Quarantine, quarantined, c.Permissions, c.Cipher)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	repaired, err := encodeStore(checked, json.Marshal)
+	if err != nil {
+		return report, err
+	}
+
+	return report, writeSealedFile(c.Path, repaired, c.Permissions, c.Generations, c.Cipher)
+}
+
+// Returns problems of single record and record with repaired problems, if repair is enabled.
+func checkMachineDto(key string, dto machineDto, repair bool) ([]FsckProblem, machineDto) {
+	problems := []FsckProblem{}
+	action := ""
+	if repair {
+		action = FsckRepaired
+	}
+
+	_, keyErr := uuid.Parse(key)
+	_, idErr := uuid.Parse(dto.Id)
+
+	switch {
+	case keyErr != nil && idErr != nil:
+		problems = append(problems, FsckProblem{Key: key, Kind: FsckInvalidId, Detail: fmt.Sprintf("machine id '%s' is invalid", dto.Id)})
+	case key != dto.Id:
+		problems = append(problems, FsckProblem{Key: key, Kind: FsckKeyMismatch, Detail: fmt.Sprintf("record key differs from machine id '%s'", dto.Id), Action: action})
+		if repair && idErr != nil {
+			dto.Id = key
+		}
+	}
+
+	updates := []missingUpdateDto{}
+	for i, update := range dto.MissingUpdates {
+		keep := true
+
+		if _, err := uuid.Parse(update.UpdateId); err != nil {
+			problems = append(problems, FsckProblem{Key: key, Kind: FsckInvalidUpdateId, Detail: fmt.Sprintf("missing update %d has invalid id '%s'", i, update.UpdateId), Action: action})
+			keep = false
+		}
+
+		severity := entities.Severity(update.Severity)
+		if severity < entities.Unspecified || severity > entities.Critical {
+			problems = append(problems, FsckProblem{Key: key, Kind: FsckUnknownSeverity, Detail: fmt.Sprintf("missing update %d has unknown severity %d", i, update.Severity), Action: action})
+			keep = false
+		}
+
+		if update.Duration < 0 {
+			problems = append(problems, FsckProblem{Key: key, Kind: FsckNegativeDuration, Detail: fmt.Sprintf("missing update %d has negative duration %s", i, update.Duration), Action: action})
+			update.Duration = 0
+		}
+
+		if keep {
+			updates = append(updates, update)
+		}
+	}
+
+	if repair {
+		dto.MissingUpdates = updates
+	}
+
+	return problems, dto
+}
+
+func checkDuplicateNames(dtoSet map[string]machineDto) []FsckProblem {
+	keysByName := map[string][]string{}
+	for _, key := range sortedKeys(dtoSet) {
+		name := dtoSet[key].Name
+		keysByName[name] = append(keysByName[name], key)
+	}
+
+	problems := []FsckProblem{}
+	for _, key := range sortedKeys(dtoSet) {
+		keys := keysByName[dtoSet[key].Name]
+		if len(keys) > 1 {
+			problems = append(problems, FsckProblem{
+				Key:    key,
+				Kind:   FsckDuplicateName,
+				Detail: fmt.Sprintf("name '%s' is used by %d machines", dtoSet[key].Name, len(keys)),
+			})
+		}
+	}
+
+	return problems
+}
+
+// Adds records to quarantine file, keeping records, which were quarantined before.
+func appendQuarantine(path string, quarantined map[string]machineDto, perm os.FileMode, c *FileCipher) error {
+	existing := map[string]machineDto{}
+	raw, err := readSealedFile(path, c)
+	if err == nil {
+		err = json.Unmarshal(raw, &existing)
+	}
+
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for key, dto := range quarantined {
+		existing[key] = dto
+	}
+
+	raw, err = json.MarshalIndent(&existing, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeSealedFile(path, raw, perm, 0, c)
+}
+
+func sortedKeys(dtoSet map[string]machineDto) []string {
+	keys := make([]string, 0, len(dtoSet))
+	for key := range dtoSet {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
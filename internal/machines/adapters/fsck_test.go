@@ -0,0 +1,136 @@
+package adapters
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+const (
+	fsckValidId    string = "8b0e5ac4-2a5a-4b7c-bd8e-4a5b1f0c3d11"
+	fsckOtherId    string = "1a3fccff-2d7b-45f0-a3c4-50a7bb50d06e"
+	fsckUpdateId   string = "0c6f44a4-92a7-4b8a-9c59-7a3a3e7a1b2c"
+	fsckBrokenKey  string = "broken"
+	fsckBrokenName string = "twin"
+)
+
+func TestFsckReportsProblems(t *testing.T) {
+	c := writeFsckTestStore(t)
+	raw, _ := os.ReadFile(c.Path)
+
+	report, err := CheckStoreFile(c, FsckOptions{})
+	if err != nil {
+		t.Fatalf("Store should be checked, but got error %s", err)
+	}
+
+	for _, kind := range []FsckProblemKind{FsckKeyMismatch, FsckInvalidId, FsckDuplicateName, FsckInvalidUpdateId, FsckUnknownSeverity, FsckNegativeDuration} {
+		if countProblems(report, kind, "") == 0 {
+			t.Errorf("Problem %s should be reported, but report was %+v", kind, report.Problems)
+		}
+	}
+
+	if !report.HasUnresolved() {
+		t.Errorf("Report should have unresolved problems!")
+	}
+
+	after, _ := os.ReadFile(c.Path)
+	if string(after) != string(raw) {
+		t.Errorf("Check without options should not change store!")
+	}
+}
+
+func TestFsckRepairAndQuarantine(t *testing.T) {
+	c := writeFsckTestStore(t)
+
+	report, err := CheckStoreFile(c, FsckOptions{Repair: true, Quarantine: true})
+	if err != nil {
+		t.Fatalf("Store should be repaired, but got error %s", err)
+	}
+
+	if report.HasUnresolved() {
+		t.Errorf("All problems should be resolved, but report was %+v", report.Problems)
+	}
+
+	if countProblems(report, FsckInvalidId, FsckQuarantined) != 1 {
+		t.Errorf("Record with invalid id should be quarantined, but report was %+v", report.Problems)
+	}
+
+	repo := NewFileRepository(c)
+	machines, err := repo.List()
+	if err != nil {
+		t.Fatalf("Repaired store should be loaded, but got error %s", err)
+	}
+
+	if len(machines) != 2 {
+		t.Fatalf("Machines count mismatch! Expected %d, but was %d", 2, len(machines))
+	}
+
+	for _, m := range machines {
+		for _, update := range m.GetMissingUpdates() {
+			if update.Duration < 0 {
+				t.Errorf("Negative duration of machine %s should be repaired!", m.Id)
+			}
+		}
+	}
+
+	raw, err := os.ReadFile(report.Quarantine)
+	if err != nil {
+		t.Fatalf("Quarantine file should be readable, but got error %s", err)
+	}
+
+	quarantined := map[string]machineDto{}
+	json.Unmarshal(raw, &quarantined)
+	if _, ok := quarantined[fsckBrokenKey]; !ok {
+		t.Errorf("Quarantine should contain broken record, but was %s", raw)
+	}
+}
+
+func TestFsckDoesNotOverwriteOnCollision(t *testing.T) {
+	c := createTestFileRepositoryConfig(t)
+	store := `{"FormatVersion":2,"Machines":{
+		"` + fsckValidId + `":{"Id":"` + fsckValidId + `","Name":"first","MissingUpdates":[]},
+		"` + fsckOtherId + `":{"Id":"` + fsckValidId + `","Name":"second","MissingUpdates":[]}}}`
+	os.WriteFile(c.Path, []byte(store), c.Permissions)
+
+	report, err := CheckStoreFile(c, FsckOptions{Repair: true})
+	if err != nil {
+		t.Fatalf("Store should be checked, but got error %s", err)
+	}
+
+	if countProblems(report, FsckKeyMismatch, "") != 1 {
+		t.Errorf("Colliding key mismatch should stay unresolved, but report was %+v", report.Problems)
+	}
+
+	if report.Machines != 2 {
+		t.Errorf("Machines count mismatch! Expected %d, but was %d", 2, report.Machines)
+	}
+}
+
+func writeFsckTestStore(t *testing.T) FileRepositoryConfig {
+	c := createTestFileRepositoryConfig(t)
+	store := `{"FormatVersion":2,"Machines":{
+		"` + fsckValidId + `":{"Id":"` + fsckOtherId + `","Name":"` + fsckBrokenName + `","MissingUpdates":[
+			{"UpdateId":"` + fsckUpdateId + `","Severity":3,"Duration":-5},
+			{"UpdateId":"not-uuid","Severity":2,"Duration":1},
+			{"UpdateId":"` + fsckUpdateId + `","Severity":42,"Duration":1}]},
+		"` + fsckBrokenKey + `":{"Id":"also-broken","Name":"lost","MissingUpdates":[]},
+		"a5a9c0d4-5f51-4a2b-a1a4-0f0ab9d1c0e7":{"Id":"a5a9c0d4-5f51-4a2b-a1a4-0f0ab9d1c0e7","Name":"` + fsckBrokenName + `","MissingUpdates":[]}}}`
+
+	err := os.WriteFile(c.Path, []byte(store), c.Permissions)
+	if err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+
+	return c
+}
+
+func countProblems(report FsckReport, kind FsckProblemKind, action string) int {
+	count := 0
+	for _, p := range report.Problems {
+		if p.Kind == kind && p.Action == action {
+			count++
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,249 @@
+package adapters
+
+import (
+	"bytes"
+	"dum/internal/machines/entities"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default file with open incidents.
+const IncidentsFileName string = "incidents.json"
+
+const (
+	incidentTrigger string = "trigger"
+	incidentResolve string = "resolve"
+)
+
+// Settings of incident notification strategy.
+type IncidentConfig struct {
+	// Events API endpoint, compatible with PagerDuty Events v2.
+	URL string
+	// Integration key of service, which incidents are opened for.
+	RoutingKey string
+	// Source of incidents, shown by incident management.
+	Source string
+	// Path to file with open incidents, IncidentsFileName is used, when it's empty.
+	StatePath string
+	// Timeout of a single event.
+	Timeout time.Duration
+	// Count of additional attempts of sending event on network errors, 5xx and 429 responses.
+	Retries int
+	// Delay before the first retry, it's doubled for every next one.
+	Backoff time.Duration
+}
+
+// Returns settings for PagerDuty Events v2 API, state file is left to be chosen by the owner of strategy.
+func DefaultIncidentConfig() IncidentConfig {
+	return IncidentConfig{
+		URL:     "https://events.pagerduty.com/v2/enqueue",
+		Source:  "dum",
+		Timeout: 10 * time.Second,
+		Retries: 3,
+		Backoff: time.Second,
+	}
+}
+
+// Incident, opened for machine.
+type OpenIncident struct {
+	DedupKey    string
+	MachineName string
+	OpenedAt    time.Time
+}
+
+// Notification strategy, which opens incident, when machine goes to danger, and resolves it, when
+// machine becomes healthy again. Machine id is used as deduplication key, open incidents are kept in
+// state file, so repeated reports don't open duplicates even after restart.
+type IncidentNotificationStrategy struct {
+	mu     sync.Mutex
+	config IncidentConfig
+	open   map[string]OpenIncident
+	client *http.Client
+	logger *log.Logger
+	sleep  func(time.Duration)
+}
+
+// Sends event outside of the lock, so slow Events API doesn't block other machines. Concurrent
+// events of the same machine can send the same action twice, which is fine, because Events API
+// deduplicates them by key.
+func (s *IncidentNotificationStrategy) Notify(e entities.HealthEvent) error {
+	id := e.MachineId.String()
+
+	s.mu.Lock()
+	_, isOpen := s.open[id]
+	s.mu.Unlock()
+
+	switch {
+	case e.Current == entities.Danger && !isOpen:
+		err := s.send(newIncidentEvent(s.config, incidentTrigger, e))
+		if err != nil {
+			return fmt.Errorf("cannot open incident for machine %s - %w", id, err)
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.open[id] = OpenIncident{DedupKey: id, MachineName: e.MachineName, OpenedAt: e.OccurredAt}
+		s.logger.Printf("Incident for machine %s is opened", id)
+	case e.Current == entities.Healthy && isOpen:
+		err := s.send(newIncidentEvent(s.config, incidentResolve, e))
+		if err != nil {
+			return fmt.Errorf("cannot resolve incident for machine %s - %w", id, err)
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.open, id)
+		s.logger.Printf("Incident for machine %s is resolved", id)
+	default:
+		return nil
+	}
+
+	return s.saveState()
+}
+
+// Opens and resolves incidents by the latest events of machines in digest, so throttled events still
+// change incidents, just later.
+func (s *IncidentNotificationStrategy) NotifyDigest(d entities.HealthDigest) error {
+	var failed []string
+	for _, e := range d.Events {
+		if err := s.Notify(e); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("incident digest failed: %s", strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// Returns incidents, which are open now, ordered by deduplication key.
+func (s *IncidentNotificationStrategy) OpenIncidents() []OpenIncident {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]OpenIncident, 0, len(s.open))
+	for _, incident := range s.open {
+		result = append(result, incident)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].DedupKey < result[j].DedupKey })
+	return result
+}
+
+func (s *IncidentNotificationStrategy) send(event incidentEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = deliverWithRetries(s.config.Retries, s.config.Backoff, s.sleep, func() (bool, error) {
+		resp, err := s.client.Post(s.config.URL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return true, err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return isRetryableStatus(resp.StatusCode), fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+
+		return false, nil
+	})
+
+	return err
+}
+
+func (s *IncidentNotificationStrategy) saveState() error {
+	raw, err := json.Marshal(s.open)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomically(s.config.StatePath, raw, 0600, 0)
+}
+
+func (s *IncidentNotificationStrategy) loadState() error {
+	raw, err := os.ReadFile(s.config.StatePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, &s.open)
+}
+
+// Event of PagerDuty Events v2 API.
+type incidentEvent struct {
+	RoutingKey  string           `json:"routing_key"`
+	EventAction string           `json:"event_action"`
+	DedupKey    string           `json:"dedup_key"`
+	Payload     *incidentPayload `json:"payload,omitempty"`
+}
+
+type incidentPayload struct {
+	Summary       string                 `json:"summary"`
+	Source        string                 `json:"source"`
+	Severity      string                 `json:"severity"`
+	Timestamp     string                 `json:"timestamp"`
+	CustomDetails map[string]interface{} `json:"custom_details,omitempty"`
+}
+
+func newIncidentEvent(c IncidentConfig, action string, e entities.HealthEvent) incidentEvent {
+	event := incidentEvent{
+		RoutingKey:  c.RoutingKey,
+		EventAction: action,
+		DedupKey:    e.MachineId.String(),
+	}
+
+	if action == incidentTrigger {
+		event.Payload = &incidentPayload{
+			Summary:   fmt.Sprintf("Machine %s is in danger - %d missing updates", e.MachineName, len(e.MissingUpdates)),
+			Source:    c.Source,
+			Severity:  "critical",
+			Timestamp: e.OccurredAt.UTC().Format(time.RFC3339),
+			CustomDetails: map[string]interface{}{
+				"machine_id":      e.MachineId.String(),
+				"machine_name":    e.MachineName,
+				"previous_level":  e.Previous.String(),
+				"missing_updates": len(e.MissingUpdates),
+			},
+		}
+	}
+
+	return event
+}
+
+func NewIncidentNotificationStrategy(c IncidentConfig, logger *log.Logger) (*IncidentNotificationStrategy, error) {
+	if c.URL == "" || c.RoutingKey == "" {
+		return nil, fmt.Errorf("events URL and routing key should be configured")
+	}
+
+	if c.StatePath == "" {
+		c.StatePath = IncidentsFileName
+	}
+
+	s := &IncidentNotificationStrategy{
+		config: c,
+		open:   map[string]OpenIncident{},
+		client: &http.Client{Timeout: c.Timeout},
+		logger: logger,
+		sleep:  time.Sleep,
+	}
+
+	if err := s.loadState(); err != nil {
+		return nil, fmt.Errorf("cannot load open incidents from %s - %w", c.StatePath, err)
+	}
+
+	return s, nil
+}
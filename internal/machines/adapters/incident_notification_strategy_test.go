@@ -0,0 +1,231 @@
+package adapters
+
+import (
+	"dum/internal/machines/entities"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIncidentIsOpenedOnceAndResolved(t *testing.T) {
+	standIn := newEventsApiStandIn()
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	s := newTestIncidentStrategy(t, createTestIncidentConfig(t, server.URL))
+	e := createTestHealthEvent()
+	id := e.MachineId.String()
+
+	for _, levels := range [][2]entities.HealthLevel{
+		{entities.Healthy, entities.Danger},
+		{entities.Danger, entities.Danger},
+		{entities.Danger, entities.Warning},
+		{entities.Warning, entities.Healthy},
+		{entities.Healthy, entities.Healthy},
+	} {
+		e.Previous, e.Current = levels[0], levels[1]
+		if err := s.Notify(e); err != nil {
+			t.Fatalf("Expected nil err, but was %s", err)
+		}
+	}
+
+	events := standIn.received()
+	if len(events) != 2 {
+		t.Fatalf("Events count mismatch! Expected 2, but was %d", len(events))
+	}
+
+	trigger := events[0]
+	if trigger.EventAction != "trigger" || trigger.DedupKey != id || trigger.RoutingKey != "routing-key" {
+		t.Errorf("Trigger event mismatch! Was %+v", trigger)
+	}
+
+	if trigger.Payload == nil || trigger.Payload.Severity != "critical" || trigger.Payload.Source != "dum" || trigger.Payload.Timestamp != "2021-06-01T12:00:00Z" {
+		t.Errorf("Trigger payload mismatch! Was %+v", trigger.Payload)
+	}
+
+	resolve := events[1]
+	if resolve.EventAction != "resolve" || resolve.DedupKey != id || resolve.Payload != nil {
+		t.Errorf("Resolve event mismatch! Was %+v", resolve)
+	}
+
+	if len(s.OpenIncidents()) != 0 {
+		t.Errorf("Incidents should be resolved, but were %v", s.OpenIncidents())
+	}
+}
+
+func TestOpenIncidentsSurviveRestart(t *testing.T) {
+	standIn := newEventsApiStandIn()
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	c := createTestIncidentConfig(t, server.URL)
+	e := createTestHealthEvent()
+
+	if err := newTestIncidentStrategy(t, c).Notify(e); err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	restarted := newTestIncidentStrategy(t, c)
+	open := restarted.OpenIncidents()
+	if len(open) != 1 || open[0].DedupKey != e.MachineId.String() || open[0].MachineName != "web-01" {
+		t.Fatalf("Open incidents mismatch! Was %+v", open)
+	}
+
+	if err := restarted.Notify(e); err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	e.Previous, e.Current = entities.Danger, entities.Healthy
+	if err := restarted.Notify(e); err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	events := standIn.received()
+	if len(events) != 2 || events[1].EventAction != "resolve" {
+		t.Errorf("Restarted strategy should not open duplicate and should resolve, but sent %+v", events)
+	}
+}
+
+func TestIncidentIsNotRememberedIfTriggerFailed(t *testing.T) {
+	standIn := newEventsApiStandIn()
+	standIn.status = http.StatusTooManyRequests
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	c := createTestIncidentConfig(t, server.URL)
+	s := newTestIncidentStrategy(t, c)
+
+	err := s.Notify(createTestHealthEvent())
+
+	if err == nil {
+		t.Fatal("Expected err, but was nil")
+	}
+
+	if len(s.OpenIncidents()) != 0 {
+		t.Errorf("Failed incident should not be remembered, but was %v", s.OpenIncidents())
+	}
+
+	if _, err := os.Stat(c.StatePath); !os.IsNotExist(err) {
+		t.Errorf("State file should not be written, but got %v", err)
+	}
+}
+
+func TestIncidentIsSentWithoutLock(t *testing.T) {
+	standIn := newEventsApiStandIn()
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	s := newTestIncidentStrategy(t, createTestIncidentConfig(t, server.URL))
+	locked := false
+	standIn.onEvent = func() {
+		listed := make(chan struct{})
+		go func() {
+			s.OpenIncidents()
+			close(listed)
+		}()
+
+		select {
+		case <-listed:
+		case <-time.After(time.Second):
+			locked = true
+		}
+	}
+
+	if err := s.Notify(createTestHealthEvent()); err != nil {
+		t.Fatalf("Expected no err, but was %s", err)
+	}
+
+	if locked {
+		t.Error("Incidents should not be locked while event is sent!")
+	}
+
+	if len(s.OpenIncidents()) != 1 {
+		t.Errorf("Incident should be opened, but was %v", s.OpenIncidents())
+	}
+}
+
+func TestInvalidIncidentState(t *testing.T) {
+	c := createTestIncidentConfig(t, "http://localhost")
+	os.WriteFile(c.StatePath, []byte("{"), 0600)
+
+	if _, err := NewIncidentNotificationStrategy(c, log.Default()); err == nil {
+		t.Error("Strategy should not be created with corrupted state!")
+	}
+
+	c.RoutingKey = ""
+	if _, err := NewIncidentNotificationStrategy(c, log.Default()); err == nil {
+		t.Error("Strategy should not be created without routing key!")
+	}
+}
+
+func createTestIncidentConfig(t *testing.T, url string) IncidentConfig {
+	c := DefaultIncidentConfig()
+	c.URL = url
+	c.RoutingKey = "routing-key"
+	c.StatePath = filepath.Join(t.TempDir(), "incidents.json")
+	return c
+}
+
+func newTestIncidentStrategy(t *testing.T, c IncidentConfig) *IncidentNotificationStrategy {
+	s, err := NewIncidentNotificationStrategy(c, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("Cannot create strategy %s", err)
+	}
+	s.sleep = func(time.Duration) {}
+
+	return s
+}
+
+// Local stand-in of Events v2 API, accepting events with valid shape.
+type eventsApiStandIn struct {
+	mu     sync.Mutex
+	status int
+	events []incidentEvent
+	// Called before the response, if it's set.
+	onEvent func()
+}
+
+func newEventsApiStandIn() *eventsApiStandIn {
+	return &eventsApiStandIn{status: http.StatusAccepted}
+}
+
+func (h *eventsApiStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var e incidentEvent
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&e); err != nil || e.RoutingKey == "" || e.DedupKey == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if e.EventAction == "trigger" && (e.Payload == nil || e.Payload.Summary == "" || e.Payload.Source == "") {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if h.onEvent != nil {
+		h.onEvent()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.status == http.StatusAccepted {
+		h.events = append(h.events, e)
+	}
+
+	w.WriteHeader(h.status)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "dedup_key": e.DedupKey})
+}
+
+func (h *eventsApiStandIn) received() []incidentEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]incidentEvent{}, h.events...)
+}
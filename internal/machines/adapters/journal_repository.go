@@ -0,0 +1,366 @@
+package adapters
+
+import (
+	"bufio"
+	"bytes"
+	"dum/internal/machines/entities"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Settings of journaled file repository.
+type JournalRepositoryConfig struct {
+	// Path to snapshot of the whole fleet.
+	SnapshotPath string
+	// Path to journal of changes, made after snapshot.
+	JournalPath string
+	// Permissions of snapshot and journal files.
+	Permissions os.FileMode
+	// Count of journal entries, after which journal is compacted into snapshot.
+	CompactionThreshold int
+	// Open snapshot and journal for reading only, e.g. to back up store of running service. Read-only
+	// repository doesn't take writer lock, doesn't repair journal and is never compacted.
+	ReadOnly bool
+}
+
+// Count of attempts to read snapshot and journal, which are not compacted in the meantime.
+const journalReadAttempts int = 5
+
+var errReadOnlyJournal = errors.New("journal repository is opened read-only")
+
+const (
+	journalSave   string = "save"
+	journalDelete string = "delete"
+)
+
+// Single change of machine, appended to journal.
+type journalEntry struct {
+	Op      string
+	Id      string
+	Machine *machineDto `json:",omitempty"`
+}
+
+// Repository working with snapshot file and append-only journal of changes. All machines are kept in
+// memory index, so loads are not touching the disk and saves are appending single entry to journal.
+// Journal is compacted into new snapshot periodically. Writable repository holds writer lock of snapshot,
+// so another process can't append to the same journal or compact it from stale index.
+type JournalRepository struct {
+	mu        *sync.Mutex
+	config    JournalRepositoryConfig
+	index     map[string]machineDto
+	journal   *os.File
+	journaled int
+	writer    *FileLock
+}
+
+func (r *JournalRepository) Load(id entities.MachineId) (*entities.Machine, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	dto, ok := r.index[id.String()]
+	if !ok {
+		return nil, nil
+	}
+
+	return dto.toMachine()
+}
+
+func (r *JournalRepository) List() ([]*entities.Machine, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return dtosToMachines(r.index)
+}
+
+func (r *JournalRepository) Save(machine *entities.Machine) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.config.ReadOnly {
+		return errReadOnlyJournal
+	}
+
+	id := machine.Id.String()
+	err := checkVersion(machine, r.index[id].Version)
+	if err != nil {
+		return err
+	}
+
+	dto := newMachineDto(machine, uuid.NewString())
+	err = r.append(journalEntry{Op: journalSave, Id: id, Machine: &dto})
+	if err != nil {
+		return err
+	}
+
+	r.index[id] = dto
+	machine.SetVersion(entities.MachineVersion(dto.Version))
+	return r.compactIfNeeded()
+}
+
+func (r *JournalRepository) Delete(id entities.MachineId) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.config.ReadOnly {
+		return errReadOnlyJournal
+	}
+
+	if _, ok := r.index[id.String()]; !ok {
+		return nil
+	}
+
+	err := r.append(journalEntry{Op: journalDelete, Id: id.String()})
+	if err != nil {
+		return err
+	}
+
+	delete(r.index, id.String())
+	return r.compactIfNeeded()
+}
+
+// Writes snapshot of the whole fleet and truncates journal.
+func (r *JournalRepository) Compact() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.config.ReadOnly {
+		return errReadOnlyJournal
+	}
+
+	return r.compact()
+}
+
+// Compacts journal, closes it and releases writer lock. Read-only repository is just closed.
+func (r *JournalRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.config.ReadOnly {
+		return nil
+	}
+
+	err := r.compact()
+	if closeErr := r.journal.Close(); err == nil {
+		err = closeErr
+	}
+
+	if releaseErr := r.writer.Release(); err == nil {
+		err = releaseErr
+	}
+
+	return err
+}
+
+func (r *JournalRepository) append(entry journalEntry) error {
+	raw, err := json.Marshal(&entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.journal.Write(append(raw, '\n'))
+	if err != nil {
+		return err
+	}
+
+	r.journaled++
+	return r.journal.Sync()
+}
+
+func (r *JournalRepository) compactIfNeeded() error {
+	if r.journaled < r.config.CompactionThreshold {
+		return nil
+	}
+
+	return r.compact()
+}
+
+func (r *JournalRepository) compact() error {
+	raw, err := encodeStore(r.index, json.Marshal)
+	if err != nil {
+		return err
+	}
+
+	err = writeFileAtomically(r.config.SnapshotPath, raw, r.config.Permissions, 1)
+	if err != nil {
+		return err
+	}
+
+	// Crash before truncation is safe - journal entries are applied to snapshot idempotently.
+	err = r.journal.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.journal.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	r.journaled = 0
+	return r.journal.Sync()
+}
+
+func loadSnapshot(path string) (map[string]machineDto, error) {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]machineDto{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeStore(raw, json.Unmarshal)
+}
+
+// Applies journal entries to index. Returns count of applied entries and size of valid journal part.
+// Torn last entry, which could be written partially because of crash, is ignored.
+func replayJournal(journal io.Reader, index map[string]machineDto) (int, int64, error) {
+	reader := bufio.NewReader(journal)
+	applied := 0
+	var valid int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return applied, valid, nil
+		}
+
+		if err != nil {
+			return applied, valid, err
+		}
+
+		var entry journalEntry
+		if len(bytes.TrimSpace(line)) == 0 {
+			valid += int64(len(line))
+			continue
+		}
+
+		err = json.Unmarshal(line, &entry)
+		if err != nil {
+			return applied, valid, fmt.Errorf("journal is corrupted at offset %d - %w", valid, err)
+		}
+
+		switch entry.Op {
+		case journalSave:
+			if entry.Machine == nil {
+				return applied, valid, fmt.Errorf("journal is corrupted at offset %d - save without machine", valid)
+			}
+			index[entry.Id] = *entry.Machine
+		case journalDelete:
+			delete(index, entry.Id)
+		default:
+			return applied, valid, fmt.Errorf("journal is corrupted at offset %d - unknown operation '%s'", valid, entry.Op)
+		}
+
+		applied++
+		valid += int64(len(line))
+	}
+}
+
+// Loads snapshot, replays journal on top of it and opens journal for appending. Fails, if another
+// process holds writer lock of snapshot.
+func NewJournalRepository(c JournalRepositoryConfig) (*JournalRepository, error) {
+	if c.ReadOnly {
+		return openReadOnlyJournal(c)
+	}
+
+	writer, err := AcquireWriterLock(c.SnapshotPath, c.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := openJournal(c)
+	if err != nil {
+		writer.Release()
+		return nil, err
+	}
+
+	r.writer = writer
+	return r, nil
+}
+
+func openJournal(c JournalRepositoryConfig) (*JournalRepository, error) {
+	index, err := loadSnapshot(c.SnapshotPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// entries are always written at the end, even if someone else has written there
+	journal, err := os.OpenFile(c.JournalPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, c.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, valid, err := replayJournal(journal, index)
+	if err != nil {
+		journal.Close()
+		return nil, err
+	}
+
+	err = journal.Truncate(valid)
+	if err != nil {
+		journal.Close()
+		return nil, err
+	}
+
+	return &JournalRepository{
+		mu:        &sync.Mutex{},
+		config:    c,
+		index:     index,
+		journal:   journal,
+		journaled: applied,
+	}, nil
+}
+
+// Reads snapshot and journal without changing them. Writer can compact journal between reading of
+// snapshot and journal, so files are read again, if snapshot is replaced in the meantime.
+func openReadOnlyJournal(c JournalRepositoryConfig) (*JournalRepository, error) {
+	for attempt := 1; ; attempt++ {
+		before, _ := os.Stat(c.SnapshotPath)
+
+		index, err := loadSnapshot(c.SnapshotPath)
+		if err != nil {
+			return nil, err
+		}
+
+		raw, err := os.ReadFile(c.JournalPath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		after, _ := os.Stat(c.SnapshotPath)
+		if !sameSnapshot(before, after) {
+			if attempt < journalReadAttempts {
+				continue
+			}
+
+			return nil, fmt.Errorf("snapshot %s is compacted too often to be read", c.SnapshotPath)
+		}
+
+		_, _, err = replayJournal(bytes.NewReader(raw), index)
+		if err != nil {
+			return nil, err
+		}
+
+		return &JournalRepository{
+			mu:     &sync.Mutex{},
+			config: c,
+			index:  index,
+		}, nil
+	}
+}
+
+func sameSnapshot(before os.FileInfo, after os.FileInfo) bool {
+	if before == nil || after == nil {
+		return before == after
+	}
+
+	return os.SameFile(before, after)
+}
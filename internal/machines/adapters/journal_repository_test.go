@@ -0,0 +1,289 @@
+package adapters
+
+import (
+	"dum/internal/machines/cases"
+	"dum/internal/machines/entities"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestJournalRepositoryBehavior(t *testing.T) {
+	repo := newTestJournalRepository(t, createTestJournalConfig(t, 3))
+
+	testRepositoryBehavior(t, func(t *testing.T) cases.MachineRepository {
+		// index is kept in memory of single instance, so instances are sharing it
+		return repo
+	})
+}
+
+func TestJournalReplayAfterReopen(t *testing.T) {
+	c := createTestJournalConfig(t, 100)
+	repo := newTestJournalRepository(t, c)
+	saved := createTestMachine()
+	deleted := createTestMachine()
+
+	for _, m := range []*entities.Machine{saved, deleted} {
+		if err := repo.Save(m); err != nil {
+			t.Fatalf("Failed to save machine, because of error %s", err)
+		}
+	}
+
+	if err := repo.Delete(deleted.Id); err != nil {
+		t.Fatalf("Failed to delete machine, because of error %s", err)
+	}
+
+	// closing would compact journal, so journal file is only closed to check replay
+	closeJournal(repo)
+
+	reopened := newTestJournalRepository(t, c)
+	loadedMachine, err := reopened.Load(saved.Id)
+	if err != nil {
+		t.Fatalf("Failed to load machine, because of error %s", err)
+	}
+
+	assertMachinesEqual(t, saved, loadedMachine)
+	if loadedMachine.GetVersion() != saved.GetVersion() {
+		t.Errorf("Version mismatch! Expected %s, but was %s", saved.GetVersion(), loadedMachine.GetVersion())
+	}
+
+	deletedMachine, _ := reopened.Load(deleted.Id)
+	if deletedMachine != nil {
+		t.Errorf("Deleted machine %s should not be replayed!", deleted.Id)
+	}
+}
+
+func TestJournalCompaction(t *testing.T) {
+	c := createTestJournalConfig(t, 2)
+	repo := newTestJournalRepository(t, c)
+	machine := createTestMachine()
+
+	for i := 0; i < 2; i++ {
+		if err := repo.Save(machine); err != nil {
+			t.Fatalf("Failed to save machine, because of error %s", err)
+		}
+	}
+
+	info, err := os.Stat(c.JournalPath)
+	if err != nil {
+		t.Fatalf("Cannot stat journal due to error %s", err)
+	}
+
+	if info.Size() != 0 {
+		t.Errorf("Journal should be truncated after compaction, but has %d bytes", info.Size())
+	}
+
+	snapshot, err := loadSnapshot(c.SnapshotPath)
+	if err != nil {
+		t.Fatalf("Cannot load snapshot due to error %s", err)
+	}
+
+	if snapshot[machine.Id.String()].Version != string(machine.GetVersion()) {
+		t.Errorf("Snapshot version mismatch! Expected %s, but was %s", machine.GetVersion(), snapshot[machine.Id.String()].Version)
+	}
+}
+
+func TestJournalTornEntryIgnored(t *testing.T) {
+	c := createTestJournalConfig(t, 100)
+	repo := newTestJournalRepository(t, c)
+	machine := createTestMachine()
+
+	if err := repo.Save(machine); err != nil {
+		t.Fatalf("Failed to save machine, because of error %s", err)
+	}
+
+	repo.journal.WriteString(`{"Op":"save","Id":"`)
+	closeJournal(repo)
+
+	reopened := newTestJournalRepository(t, c)
+	loadedMachine, err := reopened.Load(machine.Id)
+	if err != nil {
+		t.Fatalf("Failed to load machine, because of error %s", err)
+	}
+
+	assertMachinesEqual(t, machine, loadedMachine)
+
+	// new entries should be appended after valid part of journal
+	if err := reopened.Save(loadedMachine); err != nil {
+		t.Fatalf("Failed to save machine, because of error %s", err)
+	}
+
+	closeJournal(reopened)
+	newTestJournalRepository(t, c)
+}
+
+func TestJournalCorruptedEntry(t *testing.T) {
+	c := createTestJournalConfig(t, 100)
+	err := os.WriteFile(c.JournalPath, []byte("{\"Op\":\"unknown\",\"Id\":\"1\"}\n"), c.Permissions)
+	if err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+
+	_, err = NewJournalRepository(c)
+	if err == nil {
+		t.Errorf("Corrupted journal should not be opened!")
+	}
+}
+
+func TestJournalSecondWriterRefused(t *testing.T) {
+	c := createTestJournalConfig(t, 100)
+	repo := newTestJournalRepository(t, c)
+
+	if _, err := NewJournalRepository(c); !errors.Is(err, cases.ErrStoreBusy) {
+		t.Errorf("Second writer should be refused with %s, but got %v", cases.ErrStoreBusy, err)
+	}
+
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Failed to close repository, because of error %s", err)
+	}
+
+	reopened, err := NewJournalRepository(c)
+	if err != nil {
+		t.Fatalf("Writer should be opened after the first one is closed, but got %s", err)
+	}
+	reopened.Close()
+}
+
+func TestReadOnlyJournalDoesNotChangeStore(t *testing.T) {
+	c := createTestJournalConfig(t, 100)
+	repo := newTestJournalRepository(t, c)
+	machine := createTestMachine()
+
+	if err := repo.Save(machine); err != nil {
+		t.Fatalf("Failed to save machine, because of error %s", err)
+	}
+
+	readOnly := c
+	readOnly.ReadOnly = true
+	reader, err := NewJournalRepository(readOnly)
+	if err != nil {
+		t.Fatalf("Store of running writer should be opened read-only, but got %s", err)
+	}
+
+	loadedMachine, err := reader.Load(machine.Id)
+	if err != nil {
+		t.Fatalf("Failed to load machine, because of error %s", err)
+	}
+
+	assertMachinesEqual(t, machine, loadedMachine)
+
+	if err := reader.Save(loadedMachine); err == nil {
+		t.Error("Read-only repository should not save machines!")
+	}
+
+	if err := reader.Close(); err != nil {
+		t.Fatalf("Failed to close repository, because of error %s", err)
+	}
+
+	if _, err := os.Stat(c.SnapshotPath); !os.IsNotExist(err) {
+		t.Errorf("Read-only repository should not write snapshot, but got %v", err)
+	}
+
+	// writer keeps appending to its journal, which is not truncated by reader
+	if err := repo.Save(machine); err != nil {
+		t.Fatalf("Failed to save machine, because of error %s", err)
+	}
+
+	if repo.journaled != 2 {
+		t.Errorf("Journaled entries mismatch! Expected %d, but was %d", 2, repo.journaled)
+	}
+
+	closeJournal(repo)
+	reopened := newTestJournalRepository(t, c)
+	if loaded, _ := reopened.Load(machine.Id); loaded == nil || loaded.GetVersion() != machine.GetVersion() {
+		t.Errorf("Latest version of machine should be replayed, but was %+v", loaded)
+	}
+}
+
+func BenchmarkJournalRepositorySave(b *testing.B) {
+	repo, err := NewJournalRepository(JournalRepositoryConfig{
+		SnapshotPath:        filepath.Join(b.TempDir(), "machines.snapshot.json"),
+		JournalPath:         filepath.Join(b.TempDir(), "machines.journal"),
+		Permissions:         0600,
+		CompactionThreshold: 1000000,
+	})
+	if err != nil {
+		b.Fatalf("Cannot setup benchmark due to error %s", err)
+	}
+
+	defer closeJournal(repo)
+	machines := seedBenchmarkFleet(b, repo)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := repo.Save(machines[i%len(machines)]); err != nil {
+			b.Fatalf("Failed to save machine, because of error %s", err)
+		}
+	}
+}
+
+func BenchmarkJournalRepositoryLoad(b *testing.B) {
+	repo, err := NewJournalRepository(JournalRepositoryConfig{
+		SnapshotPath:        filepath.Join(b.TempDir(), "machines.snapshot.json"),
+		JournalPath:         filepath.Join(b.TempDir(), "machines.journal"),
+		Permissions:         0600,
+		CompactionThreshold: 1000000,
+	})
+	if err != nil {
+		b.Fatalf("Cannot setup benchmark due to error %s", err)
+	}
+
+	defer closeJournal(repo)
+	machines := seedBenchmarkFleet(b, repo)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.Load(machines[i%len(machines)].Id); err != nil {
+			b.Fatalf("Failed to load machine, because of error %s", err)
+		}
+	}
+}
+
+const benchmarkFleetSize = 10000
+
+// Fills repository with 10k machines in one snapshot.
+func seedBenchmarkFleet(b *testing.B, repo *JournalRepository) []*entities.Machine {
+	machines := make([]*entities.Machine, 0, benchmarkFleetSize)
+	for i := 0; i < benchmarkFleetSize; i++ {
+		machine := createTestMachine()
+		dto := newMachineDto(machine, uuid.NewString())
+		repo.index[dto.Id] = dto
+		machine.SetVersion(entities.MachineVersion(dto.Version))
+		machines = append(machines, machine)
+	}
+
+	if err := repo.Compact(); err != nil {
+		b.Fatalf("Cannot setup benchmark due to error %s", err)
+	}
+
+	return machines
+}
+
+func createTestJournalConfig(t *testing.T, threshold int) JournalRepositoryConfig {
+	dir := t.TempDir()
+	return JournalRepositoryConfig{
+		SnapshotPath:        filepath.Join(dir, "machines.snapshot.json"),
+		JournalPath:         filepath.Join(dir, "machines.journal"),
+		Permissions:         0600,
+		CompactionThreshold: threshold,
+	}
+}
+
+func newTestJournalRepository(t *testing.T, c JournalRepositoryConfig) *JournalRepository {
+	repo, err := NewJournalRepository(c)
+	if err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+
+	t.Cleanup(func() { closeJournal(repo) })
+	return repo
+}
+
+// Closes journal and releases writer lock without compaction, like crashed process does.
+func closeJournal(repo *JournalRepository) {
+	repo.journal.Close()
+	repo.writer.Release()
+}
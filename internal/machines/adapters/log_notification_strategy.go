@@ -10,8 +10,16 @@ type LogNotificationStrategy struct {
 	logger *log.Logger
 }
 
-func (s LogNotificationStrategy) Notify(id entities.MachineId, level entities.HealthLevel) error {
-	s.logger.Printf(template, id, level)
+func (s LogNotificationStrategy) Notify(e entities.HealthEvent) error {
+	s.logger.Printf(template, e.MachineId, e.Current)
+	return nil
+}
+
+func (s LogNotificationStrategy) NotifyDigest(d entities.HealthDigest) error {
+	s.logger.Printf("Digest: %s", d.Summary())
+	for _, e := range d.Events {
+		s.logger.Printf(template, e.MachineId, e.Current)
+	}
 	return nil
 }
 
@@ -21,4 +29,4 @@ func NewLogNotificationStrategy(logger *log.Logger) entities.HealthNotificationS
 	}
 }
 
-const template string = "Machine %s reporting about it's health change state %d"
+const template string = "Machine %s reporting about it's health change state %s"
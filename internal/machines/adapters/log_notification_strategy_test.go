@@ -23,7 +23,7 @@ func TestLogWriting(t *testing.T) {
 	defer file.Close()
 
 	strategy := NewLogNotificationStrategy(log.New(file, "", 0))
-	err = strategy.Notify(expectedId, healthLevel)
+	err = strategy.Notify(entities.HealthEvent{MachineId: expectedId, Current: healthLevel})
 
 	if err != nil {
 		t.Errorf("Expected nil err, but was %s", err)
@@ -0,0 +1,45 @@
+package adapters
+
+import (
+	"fmt"
+	"path"
+	"sort"
+)
+
+// Groups of machines for notification routing. Every group is a list of machine name patterns
+// in path.Match syntax, e.g. "desk-*", machine belongs to all groups with matching pattern.
+type MachineGroups map[string][]string
+
+// Checks, that all patterns are valid.
+func (g MachineGroups) Validate() error {
+	for group, patterns := range g {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid pattern '%s' of group %s - %w", pattern, group, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns sorted names of groups, which machine with given name belongs to.
+func (g MachineGroups) Of(machineName string) []string {
+	var result []string
+	for group, patterns := range g {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, machineName); ok {
+				result = append(result, group)
+				break
+			}
+		}
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// Returns true, if machine with given name belongs to any of named groups, empty list matches any machine.
+func (g MachineGroups) Contains(machineName string, groups []string) bool {
+	return intersects(groups, g.Of(machineName))
+}
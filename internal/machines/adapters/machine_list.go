@@ -0,0 +1,72 @@
+package adapters
+
+import (
+	"database/sql"
+	"dum/internal/machines/entities"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// Converts machine DTOs to entities, ordered by id.
+func dtosToMachines(dtoSet map[string]machineDto) ([]*entities.Machine, error) {
+	machines := make([]*entities.Machine, 0, len(dtoSet))
+	for _, dto := range dtoSet {
+		machine, err := dto.toMachine()
+		if err != nil {
+			return nil, err
+		}
+
+		machines = append(machines, machine)
+	}
+
+	sort.Slice(machines, func(i, j int) bool {
+		return machines[i].Id.String() < machines[j].Id.String()
+	})
+
+	return machines, nil
+}
+
+// Returns machine ids, selected by query.
+func queryMachineIds(db *sql.DB, query string) ([]entities.MachineId, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []entities.MachineId{}
+	for rows.Next() {
+		var id string
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		parsedId, err := uuid.Parse(id)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, entities.MachineId(parsedId))
+	}
+
+	return ids, rows.Err()
+}
+
+// Loads machines one by one. Machines, deleted after ids were selected, are skipped.
+func loadMachines(ids []entities.MachineId, load func(entities.MachineId) (*entities.Machine, error)) ([]*entities.Machine, error) {
+	machines := make([]*entities.Machine, 0, len(ids))
+	for _, id := range ids {
+		machine, err := load(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if machine != nil {
+			machines = append(machines, machine)
+		}
+	}
+
+	return machines, nil
+}
@@ -0,0 +1,292 @@
+package adapters
+
+import (
+	"database/sql"
+	"dum/internal/machines/cases"
+	"dum/internal/machines/entities"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+var postgresMigrations []sqlMigration = []sqlMigration{
+	{
+		version: 1,
+		statements: []string{
+			`CREATE TABLE machines (
+				id UUID PRIMARY KEY,
+				name TEXT NOT NULL,
+				health_level INTEGER NOT NULL,
+				decommissioned BOOLEAN NOT NULL DEFAULT FALSE,
+				version BIGINT NOT NULL
+			)`,
+			`CREATE INDEX machines_name_idx ON machines (name)`,
+			`CREATE INDEX machines_health_level_idx ON machines (decommissioned, health_level)`,
+			`CREATE TABLE missing_updates (
+				machine_id UUID NOT NULL REFERENCES machines (id) ON DELETE CASCADE,
+				position INTEGER NOT NULL,
+				update_id UUID NOT NULL,
+				severity INTEGER NOT NULL,
+				duration BIGINT NOT NULL,
+				PRIMARY KEY (machine_id, position)
+			)`,
+			`CREATE INDEX missing_updates_update_id_idx ON missing_updates (update_id)`,
+		},
+	},
+	{
+		version: 2,
+		statements: []string{
+			`ALTER TABLE machines ADD COLUMN health_changed_at TIMESTAMPTZ`,
+		},
+	},
+	{
+		version: 3,
+		statements: []string{
+			`CREATE TABLE silences (
+				id UUID PRIMARY KEY,
+				starts_at BIGINT NOT NULL,
+				ends_at BIGINT NOT NULL,
+				created_by TEXT NOT NULL,
+				comment TEXT NOT NULL,
+				created_at BIGINT NOT NULL
+			)`,
+			`CREATE TABLE silence_matchers (
+				silence_id UUID NOT NULL REFERENCES silences (id) ON DELETE CASCADE,
+				position INTEGER NOT NULL,
+				label TEXT NOT NULL,
+				operator TEXT NOT NULL,
+				value TEXT NOT NULL,
+				PRIMARY KEY (silence_id, position)
+			)`,
+			`CREATE TABLE acknowledgments (
+				machine_id UUID PRIMARY KEY,
+				since BIGINT NOT NULL,
+				created_by TEXT NOT NULL,
+				comment TEXT NOT NULL,
+				created_at BIGINT NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 4,
+		statements: []string{
+			`CREATE TABLE escalations (
+				machine_id UUID PRIMARY KEY,
+				since BIGINT NOT NULL,
+				notified INTEGER NOT NULL
+			)`,
+		},
+	},
+}
+
+const postgresMigrationLock string = `LOCK TABLE schema_migrations IN EXCLUSIVE MODE`
+
+// Settings of database connection pool.
+type PoolConfig struct {
+	MaxOpenConnections int
+	MaxIdleConnections int
+	ConnectionLifetime time.Duration
+}
+
+// Repository working with PostgreSQL database. Can be shared by several service instances.
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+func (r *PostgresRepository) Load(id entities.MachineId) (*entities.Machine, error) {
+	var name string
+	var decommissioned bool
+	var version int64
+	var healthChangedAt sql.NullTime
+
+	err := r.db.QueryRow(
+		`SELECT name, decommissioned, version, health_changed_at FROM machines WHERE id = $1`,
+		id.String(),
+	).Scan(&name, &decommissioned, &version, &healthChangedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	missingUpdates, err := r.loadMissingUpdates(id)
+	if err != nil {
+		return nil, err
+	}
+
+	machine := entities.CreateMachine(id, name, missingUpdates)
+	if decommissioned {
+		machine.Decommission()
+	}
+
+	if healthChangedAt.Valid {
+		machine.SetHealthChangedAt(healthChangedAt.Time)
+	}
+
+	machine.SetVersion(formatSqlVersion(version))
+	return machine, nil
+}
+
+func (r *PostgresRepository) List() ([]*entities.Machine, error) {
+	ids, err := queryMachineIds(r.db, `SELECT id FROM machines ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+
+	return loadMachines(ids, r.Load)
+}
+
+func (r *PostgresRepository) Save(machine *entities.Machine) error {
+	id := machine.Id.String()
+	expected, err := parseSqlVersion(machine.GetVersion())
+	if err != nil {
+		return err
+	}
+
+	healthChangedAt := sql.NullTime{Time: machine.GetHealthChangedAt(), Valid: !machine.GetHealthChangedAt().IsZero()}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var result sql.Result
+	if expected != 0 {
+		result, err = tx.Exec(
+			`UPDATE machines SET name = $1, health_level = $2, decommissioned = $3, health_changed_at = $4, version = version + 1
+			WHERE id = $5 AND version = $6`,
+			machine.Name, int(machine.GetHealthLevel()), machine.IsDecommissioned(), healthChangedAt, id, expected,
+		)
+	} else {
+		result, err = tx.Exec(
+			`INSERT INTO machines (id, name, health_level, decommissioned, health_changed_at, version) VALUES ($1, $2, $3, $4, $5, 1)
+			ON CONFLICT (id) DO NOTHING`,
+			id, machine.Name, int(machine.GetHealthLevel()), machine.IsDecommissioned(), healthChangedAt,
+		)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return &cases.OptimisticLockError{
+			MachineId: machine.Id,
+			Expected:  machine.GetVersion(),
+			Actual:    actualSqlVersion(tx, `SELECT version FROM machines WHERE id = $1`, id),
+		}
+	}
+
+	_, err = tx.Exec(`DELETE FROM missing_updates WHERE machine_id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	for i, update := range machine.GetMissingUpdates() {
+		_, err = tx.Exec(
+			`INSERT INTO missing_updates (machine_id, position, update_id, severity, duration) VALUES ($1, $2, $3, $4, $5)`,
+			id, i, update.UpdateId.String(), int(update.Severity), int64(update.Duration),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	machine.SetVersion(formatSqlVersion(expected + 1))
+	return nil
+}
+
+func (r *PostgresRepository) Delete(id entities.MachineId) error {
+	_, err := r.db.Exec(`DELETE FROM machines WHERE id = $1`, id.String())
+	return err
+}
+
+// Returns repository of silences and acknowledgments, which works with the same database.
+func (r *PostgresRepository) Silences() *SqlSilenceRepository {
+	return &SqlSilenceRepository{db: r.db, bind: numberPlaceholders}
+}
+
+// Closes all connections of the pool.
+func (r *PostgresRepository) Close() error {
+	return r.db.Close()
+}
+
+func (r *PostgresRepository) loadMissingUpdates(id entities.MachineId) ([]entities.MissingUpdate, error) {
+	rows, err := r.db.Query(
+		`SELECT update_id, severity, duration FROM missing_updates WHERE machine_id = $1 ORDER BY position`,
+		id.String(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	missingUpdates := []entities.MissingUpdate{}
+	for rows.Next() {
+		var updateId string
+		var severity int
+		var duration int64
+		var parsedId uuid.UUID
+
+		err = rows.Scan(&updateId, &severity, &duration)
+		if err != nil {
+			return nil, err
+		}
+
+		parsedId, err = uuid.Parse(updateId)
+		if err != nil {
+			return nil, err
+		}
+
+		missingUpdates = append(missingUpdates, entities.MissingUpdate{
+			UpdateId: parsedId,
+			Severity: entities.Severity(severity),
+			Duration: time.Duration(duration),
+		})
+	}
+
+	return missingUpdates, rows.Err()
+}
+
+// Connects to PostgreSQL database with specific data source name and migrates its schema to the latest version.
+func NewPostgresRepository(dsn string, pool PoolConfig) (*PostgresRepository, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(pool.MaxOpenConnections)
+	db.SetMaxIdleConns(pool.MaxIdleConnections)
+	db.SetConnMaxLifetime(pool.ConnectionLifetime)
+
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	err = migrate(db, postgresMigrations, postgresMigrationLock)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &PostgresRepository{
+		db: db,
+	}, nil
+}
@@ -7,19 +7,24 @@ import (
 	"sync"
 )
 
-// Decorator for file repository, creates file if it doesn't exist
+// Decorator for file repository, creates file if it doesn't exist. File is created under
+// cross-process lock, which is released before calling decorated repository.
 type RecoveryFileRepositoryDecorator struct {
 	repo cases.MachineRepository
+	path string
+	perm os.FileMode
 	mu   *sync.Mutex
 	o    func(string, int, os.FileMode) (*os.File, error)
 	fir  func(*os.File) (os.FileInfo, error)
 	ofw  func(*os.File, string) (int, error)
+	lock locker
+	c    *FileCipher
 }
 
 func (r *RecoveryFileRepositoryDecorator) Load(id entities.MachineId) (*entities.Machine, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	err := r.createFileIfNotExists()
+	err := r.ensureFile()
 
 	if err != nil {
 		return nil, err
@@ -28,10 +33,22 @@ func (r *RecoveryFileRepositoryDecorator) Load(id entities.MachineId) (*entities
 	return r.repo.Load(id)
 }
 
+func (r *RecoveryFileRepositoryDecorator) List() ([]*entities.Machine, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.ensureFile()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r.repo.List()
+}
+
 func (r *RecoveryFileRepositoryDecorator) Save(machine *entities.Machine) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	err := r.createFileIfNotExists()
+	err := r.ensureFile()
 
 	if err != nil {
 		return err
@@ -40,8 +57,30 @@ func (r *RecoveryFileRepositoryDecorator) Save(machine *entities.Machine) error
 	return r.repo.Save(machine)
 }
 
+func (r *RecoveryFileRepositoryDecorator) Delete(id entities.MachineId) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.ensureFile()
+
+	if err != nil {
+		return err
+	}
+
+	return r.repo.Delete(id)
+}
+
+func (r *RecoveryFileRepositoryDecorator) ensureFile() error {
+	release, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return r.createFileIfNotExists()
+}
+
 func (r *RecoveryFileRepositoryDecorator) createFileIfNotExists() error {
-	file, err := r.o(RepositoryFileName, os.O_RDWR|os.O_CREATE, 0666)
+	file, err := r.o(r.path, os.O_RDWR|os.O_CREATE, r.perm)
 
 	if err != nil {
 		return err
@@ -55,7 +94,15 @@ func (r *RecoveryFileRepositoryDecorator) createFileIfNotExists() error {
 	}
 
 	if info.Size() == 0 {
-		_, err = r.ofw(file, "{}")
+		content := []byte("{}")
+		if r.c != nil {
+			content, err = r.c.Seal(content)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = r.ofw(file, string(content))
 
 		if err != nil {
 			return err
@@ -65,12 +112,16 @@ func (r *RecoveryFileRepositoryDecorator) createFileIfNotExists() error {
 	return nil
 }
 
-func NewRecoveryFileRepositoryDecorator(baseRepository cases.MachineRepository) cases.MachineRepository {
+func NewRecoveryFileRepositoryDecorator(baseRepository cases.MachineRepository, c FileRepositoryConfig) cases.MachineRepository {
 	return &RecoveryFileRepositoryDecorator{
 		repo: baseRepository,
+		path: c.Path,
+		perm: c.Permissions,
 		o:    os.OpenFile,
 		ofw:  func(f *os.File, s string) (int, error) { return f.WriteString(s) },
 		fir:  func(f *os.File) (os.FileInfo, error) { return f.Stat() },
 		mu:   &sync.Mutex{},
+		lock: newFileLocker(c),
+		c:    c.Cipher,
 	}
 }
@@ -3,6 +3,7 @@ package adapters
 import (
 	"dum/internal/machines/entities"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 
@@ -10,9 +11,9 @@ import (
 )
 
 func TestShouldCreateFileIfDoesNotExistOnLoad(t *testing.T) {
-	defer os.Remove(RepositoryFileName)
+	c := createTestRecoveryConfig(t)
 	repoMock := repositoryMock{}
-	decorator := NewRecoveryFileRepositoryDecorator(&repoMock)
+	decorator := NewRecoveryFileRepositoryDecorator(&repoMock, c)
 
 	machine, err := decorator.Load(entities.MachineId(uuid.New()))
 
@@ -24,7 +25,7 @@ func TestShouldCreateFileIfDoesNotExistOnLoad(t *testing.T) {
 		t.Errorf("Expected error to be nil, but was %s", err)
 	}
 
-	raw, err := os.ReadFile(RepositoryFileName)
+	raw, err := os.ReadFile(c.Path)
 
 	if err != nil {
 		t.Errorf("Expected error to be nil on result reading, but was %s", err)
@@ -40,18 +41,18 @@ func TestShouldCreateFileIfDoesNotExistOnLoad(t *testing.T) {
 }
 
 func TestShouldNotCreateFileIfExistsAndNotEmptyOnLoad(t *testing.T) {
-	file, err := os.Create(RepositoryFileName)
+	c := createTestRecoveryConfig(t)
+	file, err := os.Create(c.Path)
 
 	if err != nil {
 		t.Errorf("Cannot setup test due to error %s", err)
 	}
 
-	defer os.Remove(RepositoryFileName)
 	file.WriteString("{}")
 	file.Close()
 
 	repoMock := repositoryMock{}
-	decorator := NewRecoveryFileRepositoryDecorator(&repoMock)
+	decorator := NewRecoveryFileRepositoryDecorator(&repoMock, c)
 
 	machine, err := decorator.Load(entities.MachineId(uuid.New()))
 
@@ -69,16 +70,16 @@ func TestShouldNotCreateFileIfExistsAndNotEmptyOnLoad(t *testing.T) {
 }
 
 func TestShouldOverwriteFileIfEmptyOnLoad(t *testing.T) {
-	file, err := os.Create(RepositoryFileName)
+	c := createTestRecoveryConfig(t)
+	file, err := os.Create(c.Path)
 
 	if err != nil {
 		t.Errorf("Cannot setup test due to error %s", err)
 	}
-	defer os.Remove(RepositoryFileName)
 	file.Close()
 
 	repoMock := repositoryMock{}
-	decorator := NewRecoveryFileRepositoryDecorator(&repoMock)
+	decorator := NewRecoveryFileRepositoryDecorator(&repoMock, c)
 
 	machine, err := decorator.Load(entities.MachineId(uuid.New()))
 
@@ -90,7 +91,7 @@ func TestShouldOverwriteFileIfEmptyOnLoad(t *testing.T) {
 		t.Errorf("Expected error to be nil, but was %s", err)
 	}
 
-	raw, err := os.ReadFile(RepositoryFileName)
+	raw, err := os.ReadFile(c.Path)
 
 	if err != nil {
 		t.Errorf("Expected error to be nil on result reading, but was %s", err)
@@ -106,9 +107,9 @@ func TestShouldOverwriteFileIfEmptyOnLoad(t *testing.T) {
 }
 
 func TestShouldCreateFileIfDoesNotExistOnSave(t *testing.T) {
-	defer os.Remove(RepositoryFileName)
+	c := createTestRecoveryConfig(t)
 	repoMock := repositoryMock{}
-	decorator := NewRecoveryFileRepositoryDecorator(&repoMock)
+	decorator := NewRecoveryFileRepositoryDecorator(&repoMock, c)
 
 	err := decorator.Save(expectedMachine)
 
@@ -116,7 +117,7 @@ func TestShouldCreateFileIfDoesNotExistOnSave(t *testing.T) {
 		t.Errorf("Expected error to be nil, but was %s", err)
 	}
 
-	raw, err := os.ReadFile(RepositoryFileName)
+	raw, err := os.ReadFile(c.Path)
 
 	if err != nil {
 		t.Errorf("Expected error to be nil on result reading, but was %s", err)
@@ -132,18 +133,18 @@ func TestShouldCreateFileIfDoesNotExistOnSave(t *testing.T) {
 }
 
 func TestShouldNotCreateFileIfExistsAndNotEmptyOnSave(t *testing.T) {
-	file, err := os.Create(RepositoryFileName)
+	c := createTestRecoveryConfig(t)
+	file, err := os.Create(c.Path)
 
 	if err != nil {
 		t.Errorf("Cannot setup test due to error %s", err)
 	}
 
-	defer os.Remove(RepositoryFileName)
 	file.WriteString("{}")
 	file.Close()
 
 	repoMock := repositoryMock{}
-	decorator := NewRecoveryFileRepositoryDecorator(&repoMock)
+	decorator := NewRecoveryFileRepositoryDecorator(&repoMock, c)
 
 	err = decorator.Save(expectedMachine)
 
@@ -157,16 +158,16 @@ func TestShouldNotCreateFileIfExistsAndNotEmptyOnSave(t *testing.T) {
 }
 
 func TestShouldOverwriteFileIfEmptyOnSave(t *testing.T) {
-	file, err := os.Create(RepositoryFileName)
+	c := createTestRecoveryConfig(t)
+	file, err := os.Create(c.Path)
 
 	if err != nil {
 		t.Errorf("Cannot setup test due to error %s", err)
 	}
-	defer os.Remove(RepositoryFileName)
 	file.Close()
 
 	repoMock := repositoryMock{}
-	decorator := NewRecoveryFileRepositoryDecorator(&repoMock)
+	decorator := NewRecoveryFileRepositoryDecorator(&repoMock, c)
 
 	err = decorator.Save(expectedMachine)
 
@@ -174,7 +175,7 @@ func TestShouldOverwriteFileIfEmptyOnSave(t *testing.T) {
 		t.Errorf("Expected error to be nil, but was %s", err)
 	}
 
-	raw, err := os.ReadFile(RepositoryFileName)
+	raw, err := os.ReadFile(c.Path)
 
 	if err != nil {
 		t.Errorf("Expected error to be nil on result reading, but was %s", err)
@@ -189,13 +190,42 @@ func TestShouldOverwriteFileIfEmptyOnSave(t *testing.T) {
 	}
 }
 
+func TestShouldCreateFileIfDoesNotExistOnDelete(t *testing.T) {
+	c := createTestRecoveryConfig(t)
+	repoMock := repositoryMock{}
+	decorator := NewRecoveryFileRepositoryDecorator(&repoMock, c)
+	id := entities.MachineId(uuid.New())
+
+	err := decorator.Delete(id)
+
+	if err != nil {
+		t.Errorf("Expected error to be nil, but was %s", err)
+	}
+
+	raw, err := os.ReadFile(c.Path)
+
+	if err != nil {
+		t.Errorf("Expected error to be nil on result reading, but was %s", err)
+	}
+
+	if string(raw) != "{}" {
+		t.Error("Unexpected content of result file!")
+	}
+
+	if repoMock.deletedId == nil || *repoMock.deletedId != id {
+		t.Error("Deleted machine mismatch!")
+	}
+}
+
 func TestLoadOpenFileError(t *testing.T) {
 	decorator := RecoveryFileRepositoryDecorator{
+		lock: noLock,
 		repo: &repositoryMock{},
+		path: filepath.Join(t.TempDir(), RepositoryFileName),
+		perm: 0600,
 		o:    func(s string, i int, fm os.FileMode) (*os.File, error) { return nil, errExpected },
 		mu:   &sync.Mutex{},
 	}
-	defer os.Remove(RepositoryFileName)
 
 	_, err := decorator.Load(entities.MachineId(uuid.New()))
 
@@ -210,11 +240,13 @@ func TestLoadOpenFileError(t *testing.T) {
 
 func TestSaveOpenFileError(t *testing.T) {
 	decorator := RecoveryFileRepositoryDecorator{
+		lock: noLock,
 		repo: &repositoryMock{},
+		path: filepath.Join(t.TempDir(), RepositoryFileName),
+		perm: 0600,
 		o:    func(s string, i int, fm os.FileMode) (*os.File, error) { return nil, errExpected },
 		mu:   &sync.Mutex{},
 	}
-	defer os.Remove(RepositoryFileName)
 
 	err := decorator.Save(&entities.Machine{})
 
@@ -229,11 +261,13 @@ func TestSaveOpenFileError(t *testing.T) {
 
 func TestFileInfoGetError(t *testing.T) {
 	decorator := RecoveryFileRepositoryDecorator{
+		lock: noLock,
 		repo: &repositoryMock{},
+		path: filepath.Join(t.TempDir(), RepositoryFileName),
+		perm: 0600,
 		o:    func(s string, i int, fm os.FileMode) (*os.File, error) { return os.OpenFile(s, i, fm) },
 		fir:  func(f *os.File) (os.FileInfo, error) { return nil, errExpected },
 	}
-	defer os.Remove(RepositoryFileName)
 
 	err := decorator.createFileIfNotExists()
 
@@ -248,12 +282,14 @@ func TestFileInfoGetError(t *testing.T) {
 
 func TestOsFileWriteError(t *testing.T) {
 	decorator := RecoveryFileRepositoryDecorator{
+		lock: noLock,
 		repo: &repositoryMock{},
+		path: filepath.Join(t.TempDir(), RepositoryFileName),
+		perm: 0600,
 		o:    func(s string, i int, fm os.FileMode) (*os.File, error) { return os.OpenFile(s, i, fm) },
 		fir:  func(f *os.File) (os.FileInfo, error) { return f.Stat() },
 		ofw:  func(f *os.File, s string) (int, error) { return 0, errExpected },
 	}
-	defer os.Remove(RepositoryFileName)
 
 	err := decorator.createFileIfNotExists()
 
@@ -268,6 +304,7 @@ func TestOsFileWriteError(t *testing.T) {
 
 type repositoryMock struct {
 	savedMachine *entities.Machine
+	deletedId    *entities.MachineId
 	isLoaded     bool
 }
 
@@ -276,9 +313,25 @@ func (r *repositoryMock) Load(id entities.MachineId) (*entities.Machine, error)
 	return expectedMachine, nil
 }
 
+func (r *repositoryMock) List() ([]*entities.Machine, error) {
+	r.isLoaded = true
+	return []*entities.Machine{expectedMachine}, nil
+}
+
 func (r *repositoryMock) Save(machine *entities.Machine) error {
 	r.savedMachine = machine
 	return nil
 }
 
+func (r *repositoryMock) Delete(id entities.MachineId) error {
+	r.deletedId = &id
+	return nil
+}
+
 var expectedMachine *entities.Machine = &entities.Machine{}
+
+func createTestRecoveryConfig(t *testing.T) FileRepositoryConfig {
+	c := DefaultFileRepositoryConfig()
+	c.Path = filepath.Join(t.TempDir(), RepositoryFileName)
+	return c
+}
@@ -0,0 +1,300 @@
+package adapters
+
+import (
+	"dum/internal/machines/cases"
+	"dum/internal/machines/entities"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Factory creates new repository instance, working with the same storage as previously created ones.
+type repositoryFactory func(t *testing.T) cases.MachineRepository
+
+// Checks behavior, which is expected from every machine repository implementation.
+func testRepositoryBehavior(t *testing.T, newRepository repositoryFactory) {
+	t.Run("SaveLoad", func(t *testing.T) {
+		repo := newRepository(t)
+		machine := createTestMachine()
+
+		err := repo.Save(machine)
+		if err != nil {
+			t.Errorf("Failed to save machine, because of error %s", err)
+			return
+		}
+
+		loadedMachine, err := repo.Load(machine.Id)
+		if err != nil {
+			t.Errorf("Failed to load machine, because of error %s", err)
+			return
+		}
+
+		assertMachinesEqual(t, machine, loadedMachine)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		repo := newRepository(t)
+		first := createTestMachine()
+		second := createTestMachine()
+		second.Decommission()
+
+		for _, m := range []*entities.Machine{first, second} {
+			if err := repo.Save(m); err != nil {
+				t.Errorf("Failed to save machine, because of error %s", err)
+				return
+			}
+		}
+
+		machines, err := repo.List()
+		if err != nil {
+			t.Errorf("Failed to list machines, because of error %s", err)
+			return
+		}
+
+		listed := map[entities.MachineId]*entities.Machine{}
+		for i, m := range machines {
+			if i > 0 && machines[i-1].Id.String() >= m.Id.String() {
+				t.Errorf("Machines should be ordered by id, but %s is listed after %s", m.Id, machines[i-1].Id)
+			}
+			listed[m.Id] = m
+		}
+
+		for _, m := range []*entities.Machine{first, second} {
+			assertMachinesEqual(t, m, listed[m.Id])
+			if listed[m.Id] != nil && listed[m.Id].GetVersion() != m.GetVersion() {
+				t.Errorf("Version mismatch! Expected %s, but was %s", m.GetVersion(), listed[m.Id].GetVersion())
+			}
+		}
+
+		if listed[second.Id] != nil && !listed[second.Id].IsDecommissioned() {
+			t.Errorf("Decommissioned machine should be listed as decommissioned!")
+		}
+	})
+
+	t.Run("LoadAbsent", func(t *testing.T) {
+		repo := newRepository(t)
+
+		machine, err := repo.Load(entities.MachineId(uuid.New()))
+
+		if err != nil {
+			t.Errorf("Error should be nil, but was %s", err)
+		}
+
+		if machine != nil {
+			t.Errorf("Machine should be nil!")
+		}
+	})
+
+	t.Run("UpdateAfterLoad", func(t *testing.T) {
+		repo := newRepository(t)
+		machine := createTestMachine()
+
+		err := repo.Save(machine)
+		if err != nil {
+			t.Errorf("Failed to save machine, because of error %s", err)
+			return
+		}
+
+		loadedMachine, err := repo.Load(machine.Id)
+		if err != nil {
+			t.Errorf("Failed to load machine, because of error %s", err)
+			return
+		}
+
+		loadedMachine.Decommission()
+		err = repo.Save(loadedMachine)
+		if err != nil {
+			t.Errorf("Failed to save loaded machine, because of error %s", err)
+			return
+		}
+
+		reloadedMachine, err := repo.Load(machine.Id)
+		if err != nil {
+			t.Errorf("Failed to load machine, because of error %s", err)
+			return
+		}
+
+		assertMachinesEqual(t, loadedMachine, reloadedMachine)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		repo := newRepository(t)
+		machine := createTestMachine()
+
+		err := repo.Save(machine)
+		if err != nil {
+			t.Errorf("Failed to save machine, because of error %s", err)
+			return
+		}
+
+		err = repo.Delete(machine.Id)
+		if err != nil {
+			t.Errorf("Failed to delete machine, because of error %s", err)
+			return
+		}
+
+		loadedMachine, err := repo.Load(machine.Id)
+		if err != nil {
+			t.Errorf("Failed to load machine, because of error %s", err)
+		}
+
+		if loadedMachine != nil {
+			t.Errorf("Deleted machine should not be loaded!")
+		}
+
+		err = repo.Delete(machine.Id)
+		if err != nil {
+			t.Errorf("Deleting absent machine should not return error, but was %s", err)
+		}
+	})
+
+	t.Run("OptimisticLockOnStaleVersion", func(t *testing.T) {
+		repo := newRepository(t)
+		machine := createTestMachine()
+
+		err := repo.Save(machine)
+		if err != nil {
+			t.Errorf("Failed to save machine, because of error %s", err)
+			return
+		}
+
+		first, _ := repo.Load(machine.Id)
+		second, _ := newRepository(t).Load(machine.Id)
+
+		err = repo.Save(first)
+		if err != nil {
+			t.Errorf("Failed to save machine, because of error %s", err)
+			return
+		}
+
+		assertOptimisticLock(t, repo.Save(second))
+	})
+
+	t.Run("OptimisticLockOnExistingMachine", func(t *testing.T) {
+		repo := newRepository(t)
+		machine := createTestMachine()
+
+		err := repo.Save(machine)
+		if err != nil {
+			t.Errorf("Failed to save machine, because of error %s", err)
+			return
+		}
+
+		duplicate := entities.CreateMachine(machine.Id, machine.Name, machine.GetMissingUpdates())
+
+		assertOptimisticLock(t, newRepository(t).Save(duplicate))
+	})
+
+	t.Run("VersionChangesOnSave", func(t *testing.T) {
+		repo := newRepository(t)
+		machine := createTestMachine()
+
+		err := repo.Save(machine)
+		if err != nil {
+			t.Errorf("Failed to save machine, because of error %s", err)
+			return
+		}
+
+		firstVersion := machine.GetVersion()
+
+		if firstVersion == "" {
+			t.Errorf("Saved machine should have version!")
+		}
+
+		err = repo.Save(machine)
+		if err != nil {
+			t.Errorf("Failed to save machine again, because of error %s", err)
+			return
+		}
+
+		if machine.GetVersion() == firstVersion {
+			t.Errorf("Version should change on save, but was %s", firstVersion)
+		}
+
+		loadedMachine, _ := repo.Load(machine.Id)
+
+		if loadedMachine.GetVersion() != machine.GetVersion() {
+			t.Errorf("Version mismatch! Expected %s, but was %s", machine.GetVersion(), loadedMachine.GetVersion())
+		}
+	})
+}
+
+func assertOptimisticLock(t *testing.T, err error) {
+	if err == nil {
+		t.Errorf("Optimistic lock doesn't occure!")
+		return
+	}
+
+	if !cases.IsOptimisticLock(err) {
+		t.Errorf("Error type mismatch! Expected optimistic lock error, but was %T", err)
+	}
+
+	if !strings.HasPrefix(err.Error(), "optimistic lock occured!") {
+		t.Errorf("Error mismatch! Expected starts with 'optimistic lock occured!', but was '%s'", err)
+	}
+}
+
+func createTestMachine() *entities.Machine {
+	machine := entities.CreateMachine(
+		entities.MachineId(uuid.New()),
+		"testName",
+		[]entities.MissingUpdate{
+			{
+				UpdateId: uuid.New(),
+				Severity: entities.Critical,
+				Duration: time.Hour,
+			},
+			{
+				UpdateId: uuid.New(),
+				Severity: entities.Low,
+				Duration: time.Minute,
+			},
+		})
+
+	// PostgreSQL keeps microseconds only
+	machine.SetHealthChangedAt(time.Date(2021, 6, 1, 12, 30, 15, 123456000, time.UTC))
+	return machine
+}
+
+func assertMachinesEqual(t *testing.T, expected *entities.Machine, actual *entities.Machine) {
+	if actual == nil {
+		t.Errorf("Machine %s should be loaded, but was nil!", expected.Id)
+		return
+	}
+
+	if actual.Id != expected.Id {
+		t.Errorf("Machine ID mismatch! Expected %s, but was %s", expected.Id, actual.Id)
+	}
+
+	if actual.Name != expected.Name {
+		t.Errorf("Machine name mismatch! Expected %s, but was %s", expected.Name, actual.Name)
+	}
+
+	if actual.GetHealthLevel() != expected.GetHealthLevel() {
+		t.Errorf("Machine health level mismatch! Expected %d, but was %d", expected.GetHealthLevel(), actual.GetHealthLevel())
+	}
+
+	if !actual.GetHealthChangedAt().Equal(expected.GetHealthChangedAt()) {
+		t.Errorf("Machine health change time mismatch! Expected %s, but was %s", expected.GetHealthChangedAt(), actual.GetHealthChangedAt())
+	}
+
+	if actual.IsDecommissioned() != expected.IsDecommissioned() {
+		t.Errorf("Machine decommission mismatch! Expected %t, but was %t", expected.IsDecommissioned(), actual.IsDecommissioned())
+	}
+
+	expectedUpdates := expected.GetMissingUpdates()
+	actualUpdates := actual.GetMissingUpdates()
+
+	if len(actualUpdates) != len(expectedUpdates) {
+		t.Errorf("Missing updates length mismatch! Expected %d, but was %d", len(expectedUpdates), len(actualUpdates))
+		return
+	}
+
+	for i := range expectedUpdates {
+		if actualUpdates[i] != expectedUpdates[i] {
+			t.Errorf("Missing update mismatch! Expected %+v, but was %+v", expectedUpdates[i], actualUpdates[i])
+		}
+	}
+}
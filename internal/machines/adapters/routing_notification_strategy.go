@@ -0,0 +1,176 @@
+package adapters
+
+import (
+	"dum/internal/machines/entities"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+// Rule of notification routing. Empty list of levels, groups or tags matches any event, route with
+// levels also matches event, when machine leaves these levels, so channel can close what it opened.
+type NotificationRoute struct {
+	Levels []string
+	Groups []string
+	Tags   []string
+	// Names of channels, which receive matching events.
+	Channels []string
+}
+
+// Notification strategy, which fans out events to channels by routing rules. Channels are isolated
+// from each other - errors and panics of channel are logged and never returned to reporting machine.
+type RoutingNotificationStrategy struct {
+	routes   []NotificationRoute
+	levels   [][]entities.HealthLevel
+	channels map[string]entities.HealthNotificationStrategy
+	groups   MachineGroups
+	tags     MachineGroups
+	logger   *log.Logger
+}
+
+func (s *RoutingNotificationStrategy) Notify(e entities.HealthEvent) error {
+	for _, name := range s.Route(e) {
+		err := isolateChannel(func() error { return s.channels[name].Notify(e) })
+		if err != nil {
+			s.logger.Printf("Notification channel %s failed for machine %s: %s", name, e.MachineId, err)
+		}
+	}
+
+	return nil
+}
+
+// Splits digest by routes, so every channel gets digest of events, which it receives one by one.
+// Channels, which can't notify about digest, get it written to log.
+func (s *RoutingNotificationStrategy) NotifyDigest(d entities.HealthDigest) error {
+	digests := map[string]*entities.HealthDigest{}
+	var names []string
+	for _, e := range d.Events {
+		for _, name := range s.Route(e) {
+			if _, ok := digests[name]; !ok {
+				digests[name] = &entities.HealthDigest{Since: d.Since, Until: d.Until}
+				names = append(names, name)
+			}
+			digests[name].Events = append(digests[name].Events, e)
+		}
+	}
+
+	for _, name := range names {
+		channel, ok := s.channels[name].(DigestNotificationStrategy)
+		if !ok {
+			s.logger.Printf("Notification digest of channel %s: %s", name, digests[name].Summary())
+			continue
+		}
+
+		digest := *digests[name]
+		if err := isolateChannel(func() error { return channel.NotifyDigest(digest) }); err != nil {
+			s.logger.Printf("Notification channel %s failed for digest: %s", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Returns names of channels, which receive event, in order of routes.
+func (s *RoutingNotificationStrategy) Route(e entities.HealthEvent) []string {
+	groups := s.groups.Of(e.MachineName)
+	tags := s.tags.Of(e.MachineName)
+
+	var result []string
+	seen := map[string]bool{}
+	for i, r := range s.routes {
+		if !matchesLevels(s.levels[i], e) || !intersects(r.Groups, groups) || !intersects(r.Tags, tags) {
+			continue
+		}
+
+		for _, name := range r.Channels {
+			if !seen[name] {
+				seen[name] = true
+				result = append(result, name)
+			}
+		}
+	}
+
+	return result
+}
+
+// Calls channel, turning its panic into error.
+func isolateChannel(notify func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic - %v", r)
+		}
+	}()
+
+	return notify()
+}
+
+// Closes channels, which need it, e.g. to send the last digest.
+func (s *RoutingNotificationStrategy) Close() error {
+	var failed []string
+	for name, channel := range s.channels {
+		if err := closeStrategy(channel); err != nil {
+			failed = append(failed, fmt.Sprintf("%s - %s", name, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("cannot close channels: %s", strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// Closes strategy, if it holds some resources, e.g. background goroutine or state file. Decorators and
+// routers close strategies, they wrap, on their own closing.
+func closeStrategy(s entities.HealthNotificationStrategy) error {
+	if c, ok := s.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+func matchesLevels(levels []entities.HealthLevel, e entities.HealthEvent) bool {
+	return containsLevel(levels, e.Current) || (e.Changed() && containsLevel(levels, e.Previous))
+}
+
+func NewRoutingNotificationStrategy(
+	routes []NotificationRoute,
+	channels map[string]entities.HealthNotificationStrategy,
+	groups MachineGroups,
+	tags MachineGroups,
+	logger *log.Logger,
+) (*RoutingNotificationStrategy, error) {
+	if err := groups.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := tags.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := &RoutingNotificationStrategy{
+		routes:   routes,
+		channels: channels,
+		groups:   groups,
+		tags:     tags,
+		logger:   logger,
+	}
+
+	for _, r := range routes {
+		for _, name := range r.Channels {
+			if _, ok := channels[name]; !ok {
+				return nil, fmt.Errorf("route refers to unknown channel %s", name)
+			}
+		}
+
+		levels, err := parseHealthLevels(r.Levels)
+		if err != nil {
+			return nil, err
+		}
+		s.levels = append(s.levels, levels)
+	}
+
+	return s, nil
+}
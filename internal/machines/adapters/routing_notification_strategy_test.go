@@ -0,0 +1,156 @@
+package adapters
+
+import (
+	"dum/internal/machines/entities"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoutesByLevelGroupAndTag(t *testing.T) {
+	s := newTestRoutingStrategy(t)
+
+	var cases = []struct {
+		name              string
+		previous, current entities.HealthLevel
+		expected          string
+	}{
+		{"dc-web-01", entities.Healthy, entities.Danger, "pager,email,log"},
+		{"desk-01", entities.Healthy, entities.Danger, "email,log"},
+		{"printer", entities.Healthy, entities.Danger, "log"},
+		{"desk-01", entities.Healthy, entities.Warning, "chat,log"},
+		{"dc-web-01", entities.Healthy, entities.Warning, "chat,log"},
+		{"dc-web-01", entities.Danger, entities.Healthy, "pager,email,log"},
+		{"dc-web-01", entities.Warning, entities.Healthy, "chat,log"},
+		{"dc-web-01", entities.Healthy, entities.Healthy, "log"},
+	}
+
+	for _, testCase := range cases {
+		e := createTestHealthEvent()
+		e.MachineName = testCase.name
+		e.Previous, e.Current = testCase.previous, testCase.current
+
+		if actual := strings.Join(s.Route(e), ","); actual != testCase.expected {
+			t.Errorf("Channels of %s %s -> %s mismatch! Expected %s, but was %s",
+				testCase.name, testCase.previous, testCase.current, testCase.expected, actual)
+		}
+	}
+}
+
+func TestRoutingIsolatesChannelFailures(t *testing.T) {
+	failing := &recordingStrategy{err: errors.New("SMTP is down")}
+	panicking := &recordingStrategy{panics: true}
+	healthy := &recordingStrategy{}
+	channels := map[string]entities.HealthNotificationStrategy{
+		"email": failing,
+		"pager": panicking,
+		"log":   healthy,
+	}
+
+	s, err := NewRoutingNotificationStrategy(
+		[]NotificationRoute{{Channels: []string{"email", "pager", "log"}}},
+		channels, nil, nil, newDiscardLogger(),
+	)
+	if err != nil {
+		t.Fatalf("Cannot create strategy %s", err)
+	}
+
+	err = s.Notify(createTestHealthEvent())
+
+	if err != nil {
+		t.Errorf("Channel failures should not be returned, but was %s", err)
+	}
+
+	if failing.count != 1 || healthy.count != 1 {
+		t.Errorf("All channels should be notified, but were %d and %d", failing.count, healthy.count)
+	}
+}
+
+func TestInvalidRoutes(t *testing.T) {
+	channels := map[string]entities.HealthNotificationStrategy{"log": &recordingStrategy{}}
+	var cases = []struct {
+		routes []NotificationRoute
+		groups MachineGroups
+	}{
+		{[]NotificationRoute{{Channels: []string{"pager"}}}, nil},
+		{[]NotificationRoute{{Levels: []string{"Sick"}, Channels: []string{"log"}}}, nil},
+		{nil, MachineGroups{"broken": {"["}}},
+	}
+
+	for _, testCase := range cases {
+		if _, err := NewRoutingNotificationStrategy(testCase.routes, channels, testCase.groups, nil, newDiscardLogger()); err == nil {
+			t.Errorf("Strategy should not be created with routes %+v", testCase.routes)
+		}
+	}
+}
+
+func TestRoutingClosesChannels(t *testing.T) {
+	base := &digestRecordingStrategy{}
+	throttled := newThrottlingNotificationDecorator(base, ThrottleConfig{Window: time.Hour, MachineLimit: 1}, newDiscardLogger(), time.Now)
+	s, err := NewRoutingNotificationStrategy(
+		[]NotificationRoute{{Channels: []string{"chat", "log"}}},
+		map[string]entities.HealthNotificationStrategy{"chat": throttled, "log": &recordingStrategy{}},
+		nil, nil, newDiscardLogger(),
+	)
+	if err != nil {
+		t.Fatalf("Cannot create strategy %s", err)
+	}
+
+	e := createTestHealthEvent()
+	s.Notify(e)
+	s.Notify(e)
+	s.Close()
+
+	if len(base.digests) != 1 {
+		t.Errorf("Digest should be sent on close, but digests were %v", base.digests)
+	}
+}
+
+func newTestRoutingStrategy(t *testing.T) *RoutingNotificationStrategy {
+	channels := map[string]entities.HealthNotificationStrategy{}
+	for _, name := range []string{"pager", "email", "chat", "log"} {
+		channels[name] = &recordingStrategy{}
+	}
+
+	s, err := NewRoutingNotificationStrategy(
+		[]NotificationRoute{
+			{Levels: []string{"Danger"}, Tags: []string{"dc"}, Channels: []string{"pager", "email"}},
+			{Levels: []string{"Warning"}, Channels: []string{"chat"}},
+			{Levels: []string{"Danger"}, Groups: []string{"desktops"}, Channels: []string{"email"}},
+			{Channels: []string{"log"}},
+		},
+		channels,
+		MachineGroups{"desktops": {"desk-*"}},
+		MachineGroups{"dc": {"dc-*"}},
+		newDiscardLogger(),
+	)
+	if err != nil {
+		t.Fatalf("Cannot create strategy %s", err)
+	}
+
+	return s
+}
+
+func newDiscardLogger() *log.Logger {
+	return log.New(io.Discard, "", 0)
+}
+
+type recordingStrategy struct {
+	count  int
+	events []entities.HealthEvent
+	err    error
+	panics bool
+}
+
+func (s *recordingStrategy) Notify(e entities.HealthEvent) error {
+	if s.panics {
+		panic("channel is broken")
+	}
+
+	s.count++
+	s.events = append(s.events, e)
+	return s.err
+}
@@ -0,0 +1,219 @@
+package adapters
+
+import (
+	"dum/internal/machines/cases"
+	"dum/internal/machines/entities"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Factory creates new silence repository instance, working with the same storage as previously created ones.
+type silenceRepositoryFactory func(t *testing.T) cases.SilenceRepository
+
+// Checks behavior, which is expected from every silence repository implementation.
+func testSilenceRepositoryBehavior(t *testing.T, newRepository silenceRepositoryFactory) {
+	t.Run("Silences", func(t *testing.T) {
+		repo := newRepository(t)
+		later := createTestSilence()
+		later.StartsAt = later.StartsAt.Add(time.Minute)
+		later.Matchers = append(later.Matchers, entities.SilenceMatcher{Label: entities.TagLabel, Operator: entities.EqualOperator, Value: "dc"})
+		earlier := createTestSilence()
+		removed := createTestSilence()
+
+		for _, s := range []entities.Silence{later, earlier, removed} {
+			if err := repo.SaveSilence(s); err != nil {
+				t.Fatalf("Failed to save silence, because of error %s", err)
+			}
+		}
+
+		earlier.Comment = "maintenance"
+		if err := repo.SaveSilence(earlier); err != nil {
+			t.Fatalf("Failed to replace silence, because of error %s", err)
+		}
+
+		if err := repo.DeleteSilence(removed.Id); err != nil {
+			t.Fatalf("Failed to delete silence, because of error %s", err)
+		}
+
+		silences, err := newRepository(t).ListSilences()
+		if err != nil {
+			t.Fatalf("Failed to list silences, because of error %s", err)
+		}
+
+		if len(silences) != 2 || silences[0].Id != earlier.Id || silences[1].Id != later.Id {
+			t.Fatalf("Silences should be ordered by start, but were %+v", silences)
+		}
+
+		assertSilencesEqual(t, earlier, silences[0])
+		assertSilencesEqual(t, later, silences[1])
+
+		if loaded, err := repo.LoadSilence(removed.Id); err != nil || loaded != nil {
+			t.Errorf("Deleted silence should not be loaded, but was %+v with error %v", loaded, err)
+		}
+
+		if err := repo.DeleteSilence(removed.Id); err != nil {
+			t.Errorf("Deleting of absent silence should not fail, but got %s", err)
+		}
+	})
+
+	t.Run("Acknowledgments", func(t *testing.T) {
+		repo := newRepository(t)
+		since := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+		ack := entities.Acknowledgment{MachineId: entities.MachineId(uuid.New()), Since: since, CreatedBy: "bob", CreatedAt: since}
+
+		if err := repo.SaveAcknowledgment(ack); err != nil {
+			t.Fatalf("Failed to save acknowledgment, because of error %s", err)
+		}
+
+		reopened := newRepository(t)
+		if loaded, _ := reopened.LoadAcknowledgment(ack.MachineId); loaded == nil || loaded.CreatedBy != "bob" || !loaded.Since.Equal(since) {
+			t.Errorf("Acknowledgment mismatch! Was %+v", loaded)
+		}
+
+		if acked, _ := reopened.IsAcknowledged(ack.MachineId, since); !acked {
+			t.Error("Health episode should be acknowledged!")
+		}
+
+		if acked, _ := reopened.IsAcknowledged(ack.MachineId, since.Add(time.Hour)); acked {
+			t.Error("Next health episode should not be acknowledged!")
+		}
+
+		if acks, _ := reopened.ListAcknowledgments(); !containsAcknowledgment(acks, ack.MachineId) {
+			t.Errorf("Acknowledgment should be listed, but was %+v", acks)
+		}
+
+		reopened.DeleteAcknowledgment(ack.MachineId)
+		if loaded, _ := reopened.LoadAcknowledgment(ack.MachineId); loaded != nil {
+			t.Errorf("Acknowledgment should be deleted, but was %+v", loaded)
+		}
+	})
+
+	t.Run("Escalations", func(t *testing.T) {
+		repo := newRepository(t)
+		id := entities.MachineId(uuid.New())
+		since := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+		if notified, err := repo.LoadEscalation(id, since); err != nil || notified != 0 {
+			t.Fatalf("Unknown escalation should have no notified tiers, but was %d with error %v", notified, err)
+		}
+
+		repo.SaveEscalation(id, since, 1)
+		if err := repo.SaveEscalation(id, since, 2); err != nil {
+			t.Fatalf("Failed to save escalation, because of error %s", err)
+		}
+
+		reopened := newRepository(t)
+		if notified, _ := reopened.LoadEscalation(id, since); notified != 2 {
+			t.Errorf("Notified tiers mismatch! Expected %d, but was %d", 2, notified)
+		}
+
+		if notified, _ := reopened.LoadEscalation(id, since.Add(time.Hour)); notified != 0 {
+			t.Errorf("Next health episode should have no notified tiers, but was %d", notified)
+		}
+
+		if err := reopened.DeleteEscalation(id); err != nil {
+			t.Fatalf("Failed to delete escalation, because of error %s", err)
+		}
+
+		if notified, _ := reopened.LoadEscalation(id, since); notified != 0 {
+			t.Errorf("Escalation should be deleted, but had %d notified tiers", notified)
+		}
+
+		if err := reopened.DeleteEscalation(id); err != nil {
+			t.Errorf("Deleting of absent escalation should not fail, but got %s", err)
+		}
+	})
+}
+
+func TestFileSilenceRepositoryBehavior(t *testing.T) {
+	path := filepath.Join(t.TempDir(), SilencesFileName)
+
+	testSilenceRepositoryBehavior(t, func(t *testing.T) cases.SilenceRepository {
+		r, err := NewFileSilenceRepository(path)
+		if err != nil {
+			t.Fatalf("Cannot setup test due to error %s", err)
+		}
+
+		return r
+	})
+}
+
+func TestSqliteSilenceRepositoryBehavior(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "machines.db")
+
+	testSilenceRepositoryBehavior(t, func(t *testing.T) cases.SilenceRepository {
+		repo, err := NewSqliteRepository(path)
+		if err != nil {
+			t.Fatalf("Cannot setup test due to error %s", err)
+		}
+
+		t.Cleanup(func() { repo.Close() })
+		return repo.Silences()
+	})
+}
+
+func TestBoltSilenceRepositoryBehavior(t *testing.T) {
+	repo := newTestBoltRepository(t)
+
+	testSilenceRepositoryBehavior(t, func(t *testing.T) cases.SilenceRepository {
+		return repo.Silences()
+	})
+}
+
+func TestPostgresSilenceRepositoryBehavior(t *testing.T) {
+	dsn := os.Getenv(postgresDsnVariable)
+	if dsn == "" {
+		t.Skipf("%s is not set", postgresDsnVariable)
+	}
+
+	testSilenceRepositoryBehavior(t, func(t *testing.T) cases.SilenceRepository {
+		repo, err := NewPostgresRepository(dsn, testPoolConfig)
+		if err != nil {
+			t.Fatalf("Cannot setup test due to error %s", err)
+		}
+
+		t.Cleanup(func() { repo.Close() })
+		return repo.Silences()
+	})
+}
+
+func TestNumberPlaceholders(t *testing.T) {
+	actual := numberPlaceholders(`DELETE FROM silences WHERE id = ? AND ends_at < ?`)
+	if expected := `DELETE FROM silences WHERE id = $1 AND ends_at < $2`; actual != expected {
+		t.Errorf("Query mismatch! Expected %s, but was %s", expected, actual)
+	}
+}
+
+func assertSilencesEqual(t *testing.T, expected entities.Silence, actual entities.Silence) {
+	if len(actual.Matchers) != len(expected.Matchers) {
+		t.Fatalf("Matchers of silence %s mismatch! Expected %v, but was %v", expected.Id, expected.Matchers, actual.Matchers)
+	}
+
+	for i := range expected.Matchers {
+		if actual.Matchers[i].String() != expected.Matchers[i].String() {
+			t.Errorf("Matcher mismatch! Expected %s, but was %s", expected.Matchers[i], actual.Matchers[i])
+		}
+	}
+
+	if !actual.StartsAt.Equal(expected.StartsAt) || !actual.EndsAt.Equal(expected.EndsAt) || !actual.CreatedAt.Equal(expected.CreatedAt) {
+		t.Errorf("Times of silence %s mismatch! Expected %+v, but was %+v", expected.Id, expected, actual)
+	}
+
+	if actual.CreatedBy != expected.CreatedBy || actual.Comment != expected.Comment {
+		t.Errorf("Author of silence %s mismatch! Expected %+v, but was %+v", expected.Id, expected, actual)
+	}
+}
+
+func containsAcknowledgment(acks []entities.Acknowledgment, id entities.MachineId) bool {
+	for _, a := range acks {
+		if a.MachineId == id {
+			return true
+		}
+	}
+
+	return false
+}
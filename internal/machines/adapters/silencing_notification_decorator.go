@@ -0,0 +1,99 @@
+package adapters
+
+import (
+	"dum/internal/machines/cases"
+	"dum/internal/machines/entities"
+	"log"
+	"sync"
+	"time"
+)
+
+// Counters of silencing decorator.
+type SilenceStats struct {
+	Notified, Acknowledged, Silenced uint64
+}
+
+// Notification decorator, which drops events about acknowledged health episodes and events, matching
+// active silences. If silences can't be read, events are notified, it's better to be noisy than blind.
+type SilencingNotificationDecorator struct {
+	base       entities.HealthNotificationStrategy
+	repository cases.SilenceRepository
+	groups     MachineGroups
+	tags       MachineGroups
+	logger     *log.Logger
+	now        func() time.Time
+
+	mu    sync.Mutex
+	stats SilenceStats
+}
+
+func (s *SilencingNotificationDecorator) Notify(e entities.HealthEvent) error {
+	acked, err := s.repository.IsAcknowledged(e.MachineId, e.Since)
+	if err != nil {
+		s.logger.Printf("Cannot check acknowledgment of machine %s - %s", e.MachineId, err)
+	}
+	if acked {
+		s.mu.Lock()
+		s.stats.Acknowledged++
+		s.mu.Unlock()
+		return nil
+	}
+
+	if silence := s.silenceOf(e); silence != nil {
+		s.logger.Printf("Notification about machine %s is silenced by %s", e.MachineName, silence.Id)
+		s.mu.Lock()
+		s.stats.Silenced++
+		s.mu.Unlock()
+		return nil
+	}
+
+	s.mu.Lock()
+	s.stats.Notified++
+	s.mu.Unlock()
+	return s.base.Notify(e)
+}
+
+func (s *SilencingNotificationDecorator) Close() error {
+	return closeStrategy(s.base)
+}
+
+func (s *SilencingNotificationDecorator) Stats() SilenceStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// Returns the first active silence, matching event, or nil.
+func (s *SilencingNotificationDecorator) silenceOf(e entities.HealthEvent) *entities.Silence {
+	silences, err := s.repository.ListSilences()
+	if err != nil {
+		s.logger.Printf("Cannot read silences - %s", err)
+		return nil
+	}
+
+	now := s.now()
+	labels := e.Labels(s.groups.Of(e.MachineName), s.tags.Of(e.MachineName))
+	for i, silence := range silences {
+		if silence.Matches(labels, now) {
+			return &silences[i]
+		}
+	}
+
+	return nil
+}
+
+func newSilencingNotificationDecorator(base entities.HealthNotificationStrategy, r cases.SilenceRepository, groups, tags MachineGroups, logger *log.Logger, now func() time.Time) *SilencingNotificationDecorator {
+	return &SilencingNotificationDecorator{
+		base:       base,
+		repository: r,
+		groups:     groups,
+		tags:       tags,
+		logger:     logger,
+		now:        now,
+	}
+}
+
+// Creates decorator, which matches groups and tags of machines against silences.
+func NewSilencingNotificationDecorator(base entities.HealthNotificationStrategy, r cases.SilenceRepository, groups, tags MachineGroups, logger *log.Logger) *SilencingNotificationDecorator {
+	return newSilencingNotificationDecorator(base, r, groups, tags, logger, time.Now)
+}
@@ -0,0 +1,72 @@
+package adapters
+
+import (
+	"dum/internal/machines/entities"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSilencingSuppressesMatchingEvents(t *testing.T) {
+	base := &recordingStrategy{}
+	clock := newTestClock()
+	r, _ := NewFileSilenceRepository(filepath.Join(t.TempDir(), SilencesFileName))
+	groups := MachineGroups{"web": {"web-*"}}
+	tags := MachineGroups{"dc": {"web-01"}}
+	s := newSilencingNotificationDecorator(base, r, groups, tags, newDiscardLogger(), clock.now)
+
+	r.SaveSilence(entities.Silence{
+		Id: entities.SilenceId(uuid.New()),
+		Matchers: []entities.SilenceMatcher{
+			{Label: entities.GroupLabel, Operator: entities.EqualOperator, Value: "web"},
+			{Label: entities.TagLabel, Operator: entities.EqualOperator, Value: "dc"},
+		},
+		StartsAt:  clock.now(),
+		EndsAt:    clock.now().Add(time.Hour),
+		CreatedBy: "alice",
+	})
+
+	silenced := createTestHealthEvent()
+	other := createTestHealthEvent()
+	other.MachineName = "web-02"
+
+	s.Notify(silenced)
+	s.Notify(other)
+	clock.advance(time.Hour)
+	s.Notify(silenced)
+
+	if base.count != 2 || base.events[0].MachineName != "web-02" {
+		t.Errorf("Notified events mismatch! Was %+v", base.events)
+	}
+
+	if stats := s.Stats(); stats.Silenced != 1 || stats.Notified != 2 {
+		t.Errorf("Stats mismatch! Was %+v", stats)
+	}
+}
+
+func TestSilencingSuppressesAcknowledgedEpisode(t *testing.T) {
+	base := &recordingStrategy{}
+	clock := newTestClock()
+	r, _ := NewFileSilenceRepository(filepath.Join(t.TempDir(), SilencesFileName))
+	s := newSilencingNotificationDecorator(base, r, MachineGroups{}, MachineGroups{}, newDiscardLogger(), clock.now)
+
+	e := createTestHealthEvent()
+	e.Since = clock.now()
+	r.SaveAcknowledgment(entities.Acknowledgment{MachineId: e.MachineId, Since: e.Since, CreatedBy: "bob"})
+
+	s.Notify(e)
+	recovered := e
+	recovered.Previous, recovered.Current = entities.Danger, entities.Healthy
+	recovered.Since = clock.now().Add(time.Hour)
+	s.Notify(recovered)
+
+	if base.count != 1 || base.events[0].Current != entities.Healthy {
+		t.Errorf("Only events of new episode should be notified, but was %+v", base.events)
+	}
+
+	if stats := s.Stats(); stats.Acknowledged != 1 {
+		t.Errorf("Acknowledged count mismatch! Expected 1, but was %d", stats.Acknowledged)
+	}
+}
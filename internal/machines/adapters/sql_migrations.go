@@ -0,0 +1,83 @@
+package adapters
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Versioned change of database schema.
+type sqlMigration struct {
+	version    int
+	statements []string
+}
+
+// Applies migrations, which are not applied yet. Every migration is applied in its own transaction
+// and remembered in schema_migrations table. Lock statement, if not empty, is executed at the
+// beginning of every transaction to prevent concurrent migrations by several service instances.
+func migrate(db *sql.DB, migrations []sqlMigration, lockStatement string) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		err = applyMigration(db, m, lockStatement)
+		if err != nil {
+			return fmt.Errorf("migration to version %d failed - %w", m.version, err)
+		}
+	}
+
+	return nil
+}
+
+// Database or transaction, which can query single row.
+type rowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func schemaVersion(q rowQuerier) (int, error) {
+	var version int
+	err := q.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+func applyMigration(db *sql.DB, m sqlMigration, lockStatement string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if lockStatement != "" {
+		_, err = tx.Exec(lockStatement)
+		if err != nil {
+			return err
+		}
+	}
+
+	current, err := schemaVersion(tx)
+	if err != nil {
+		return err
+	}
+
+	if m.version <= current {
+		return nil
+	}
+
+	for _, statement := range m.statements {
+		_, err = tx.Exec(statement)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(`INSERT INTO schema_migrations (version) VALUES (%d)`, m.version))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
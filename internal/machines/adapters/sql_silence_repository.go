@@ -0,0 +1,282 @@
+package adapters
+
+import (
+	"database/sql"
+	"dum/internal/machines/entities"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Repository of silences, acknowledgments and escalation progress, which keeps them in the same SQL database as machines,
+// so every service instance, sharing database, sees them. Times are stored as unix nanoseconds.
+type SqlSilenceRepository struct {
+	db *sql.DB
+	// Rewrites ? placeholders of query for database driver.
+	bind func(query string) string
+}
+
+func (r *SqlSilenceRepository) ListSilences() ([]entities.Silence, error) {
+	return r.querySilences(`SELECT id, starts_at, ends_at, created_by, comment, created_at FROM silences ORDER BY starts_at, id`)
+}
+
+func (r *SqlSilenceRepository) LoadSilence(id entities.SilenceId) (*entities.Silence, error) {
+	silences, err := r.querySilences(`SELECT id, starts_at, ends_at, created_by, comment, created_at FROM silences WHERE id = ?`, id.String())
+	if err != nil || len(silences) == 0 {
+		return nil, err
+	}
+
+	return &silences[0], nil
+}
+
+func (r *SqlSilenceRepository) SaveSilence(s entities.Silence) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	id := s.Id.String()
+	_, err = tx.Exec(r.bind(
+		`INSERT INTO silences (id, starts_at, ends_at, created_by, comment, created_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET starts_at = EXCLUDED.starts_at, ends_at = EXCLUDED.ends_at,
+		created_by = EXCLUDED.created_by, comment = EXCLUDED.comment, created_at = EXCLUDED.created_at`),
+		id, s.StartsAt.UnixNano(), s.EndsAt.UnixNano(), s.CreatedBy, s.Comment, s.CreatedAt.UnixNano(),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(r.bind(`DELETE FROM silence_matchers WHERE silence_id = ?`), id)
+	if err != nil {
+		return err
+	}
+
+	for i, m := range s.Matchers {
+		_, err = tx.Exec(
+			r.bind(`INSERT INTO silence_matchers (silence_id, position, label, operator, value) VALUES (?, ?, ?, ?, ?)`),
+			id, i, m.Label, m.Operator, m.Value,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *SqlSilenceRepository) DeleteSilence(id entities.SilenceId) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(r.bind(`DELETE FROM silence_matchers WHERE silence_id = ?`), id.String())
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(r.bind(`DELETE FROM silences WHERE id = ?`), id.String())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SqlSilenceRepository) ListAcknowledgments() ([]entities.Acknowledgment, error) {
+	return r.queryAcknowledgments(`SELECT machine_id, since, created_by, comment, created_at FROM acknowledgments ORDER BY machine_id`)
+}
+
+func (r *SqlSilenceRepository) LoadAcknowledgment(id entities.MachineId) (*entities.Acknowledgment, error) {
+	acks, err := r.queryAcknowledgments(`SELECT machine_id, since, created_by, comment, created_at FROM acknowledgments WHERE machine_id = ?`, id.String())
+	if err != nil || len(acks) == 0 {
+		return nil, err
+	}
+
+	return &acks[0], nil
+}
+
+func (r *SqlSilenceRepository) SaveAcknowledgment(a entities.Acknowledgment) error {
+	_, err := r.db.Exec(r.bind(
+		`INSERT INTO acknowledgments (machine_id, since, created_by, comment, created_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (machine_id) DO UPDATE SET since = EXCLUDED.since, created_by = EXCLUDED.created_by,
+		comment = EXCLUDED.comment, created_at = EXCLUDED.created_at`),
+		a.MachineId.String(), a.Since.UnixNano(), a.CreatedBy, a.Comment, a.CreatedAt.UnixNano(),
+	)
+
+	return err
+}
+
+func (r *SqlSilenceRepository) DeleteAcknowledgment(id entities.MachineId) error {
+	_, err := r.db.Exec(r.bind(`DELETE FROM acknowledgments WHERE machine_id = ?`), id.String())
+	return err
+}
+
+func (r *SqlSilenceRepository) IsAcknowledged(id entities.MachineId, since time.Time) (bool, error) {
+	a, err := r.LoadAcknowledgment(id)
+	if err != nil || a == nil {
+		return false, err
+	}
+
+	return a.Covers(since), nil
+}
+
+func (r *SqlSilenceRepository) LoadEscalation(id entities.MachineId, since time.Time) (int, error) {
+	notified := 0
+	err := r.db.QueryRow(r.bind(`SELECT notified FROM escalations WHERE machine_id = ? AND since = ?`), id.String(), since.UnixNano()).Scan(&notified)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	return notified, err
+}
+
+func (r *SqlSilenceRepository) SaveEscalation(id entities.MachineId, since time.Time, notified int) error {
+	_, err := r.db.Exec(r.bind(
+		`INSERT INTO escalations (machine_id, since, notified) VALUES (?, ?, ?)
+		ON CONFLICT (machine_id) DO UPDATE SET since = EXCLUDED.since, notified = EXCLUDED.notified`),
+		id.String(), since.UnixNano(), notified,
+	)
+
+	return err
+}
+
+func (r *SqlSilenceRepository) DeleteEscalation(id entities.MachineId) error {
+	_, err := r.db.Exec(r.bind(`DELETE FROM escalations WHERE machine_id = ?`), id.String())
+	return err
+}
+
+// Reads silences and then their matchers, rows of silences are closed before the next query, so
+// single connection of SQLite is never waited for by the same repository.
+func (r *SqlSilenceRepository) querySilences(query string, args ...interface{}) ([]entities.Silence, error) {
+	rows, err := r.db.Query(r.bind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	silences := []entities.Silence{}
+	positions := map[string]int{}
+	for rows.Next() {
+		var id, createdBy, comment string
+		var startsAt, endsAt, createdAt int64
+
+		if err = rows.Scan(&id, &startsAt, &endsAt, &createdBy, &comment, &createdAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		parsedId, err := uuid.Parse(id)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("invalid id of silence '%s' - %w", id, err)
+		}
+
+		positions[parsedId.String()] = len(silences)
+		silences = append(silences, entities.Silence{
+			Id:        entities.SilenceId(parsedId),
+			StartsAt:  time.Unix(0, startsAt).UTC(),
+			EndsAt:    time.Unix(0, endsAt).UTC(),
+			CreatedBy: createdBy,
+			Comment:   comment,
+			CreatedAt: time.Unix(0, createdAt).UTC(),
+		})
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil || len(silences) == 0 {
+		return silences, err
+	}
+
+	if err = r.loadMatchers(silences, positions); err != nil {
+		return nil, err
+	}
+
+	for _, s := range silences {
+		if err = s.Compile(); err != nil {
+			return nil, err
+		}
+	}
+
+	return silences, nil
+}
+
+func (r *SqlSilenceRepository) loadMatchers(silences []entities.Silence, positions map[string]int) error {
+	rows, err := r.db.Query(`SELECT silence_id, label, operator, value FROM silence_matchers ORDER BY silence_id, position`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var m entities.SilenceMatcher
+
+		if err = rows.Scan(&id, &m.Label, &m.Operator, &m.Value); err != nil {
+			return err
+		}
+
+		if i, ok := positions[id]; ok {
+			silences[i].Matchers = append(silences[i].Matchers, m)
+		}
+	}
+
+	return rows.Err()
+}
+
+func (r *SqlSilenceRepository) queryAcknowledgments(query string, args ...interface{}) ([]entities.Acknowledgment, error) {
+	rows, err := r.db.Query(r.bind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	acks := []entities.Acknowledgment{}
+	for rows.Next() {
+		var id, createdBy, comment string
+		var since, createdAt int64
+
+		if err = rows.Scan(&id, &since, &createdBy, &comment, &createdAt); err != nil {
+			return nil, err
+		}
+
+		parsedId, err := uuid.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid machine id of acknowledgment '%s' - %w", id, err)
+		}
+
+		acks = append(acks, entities.Acknowledgment{
+			MachineId: entities.MachineId(parsedId),
+			Since:     time.Unix(0, since).UTC(),
+			CreatedBy: createdBy,
+			Comment:   comment,
+			CreatedAt: time.Unix(0, createdAt).UTC(),
+		})
+	}
+
+	return acks, rows.Err()
+}
+
+// Keeps ? placeholders, which are understood by SQLite.
+func keepPlaceholders(query string) string {
+	return query
+}
+
+// Replaces ? placeholders with numbered ones of PostgreSQL.
+func numberPlaceholders(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,32 @@
+package adapters
+
+import (
+	"database/sql"
+	"dum/internal/machines/entities"
+	"strconv"
+)
+
+// Machine versions are stored as integer counters in SQL databases, zero means that machine was never persisted.
+func parseSqlVersion(v entities.MachineVersion) (int64, error) {
+	if v == "" {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(string(v), 10, 64)
+}
+
+func formatSqlVersion(v int64) entities.MachineVersion {
+	return entities.MachineVersion(strconv.FormatInt(v, 10))
+}
+
+// Reads actual version of machine inside transaction, which failed to update it.
+func actualSqlVersion(tx *sql.Tx, query string, id string) entities.MachineVersion {
+	var version int64
+
+	err := tx.QueryRow(query, id).Scan(&version)
+	if err != nil {
+		return ""
+	}
+
+	return formatSqlVersion(version)
+}
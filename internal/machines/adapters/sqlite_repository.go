@@ -0,0 +1,318 @@
+package adapters
+
+import (
+	"database/sql"
+	"dum/internal/machines/cases"
+	"dum/internal/machines/entities"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+)
+
+var sqliteMigrations []sqlMigration = []sqlMigration{
+	{
+		version: 1,
+		statements: []string{
+			`CREATE TABLE machines (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL,
+				health_level INTEGER NOT NULL,
+				decommissioned INTEGER NOT NULL DEFAULT 0,
+				version INTEGER NOT NULL
+			)`,
+			`CREATE INDEX machines_name_idx ON machines (name)`,
+			`CREATE INDEX machines_health_level_idx ON machines (decommissioned, health_level)`,
+			`CREATE TABLE missing_updates (
+				machine_id TEXT NOT NULL REFERENCES machines (id),
+				position INTEGER NOT NULL,
+				update_id TEXT NOT NULL,
+				severity INTEGER NOT NULL,
+				duration INTEGER NOT NULL,
+				PRIMARY KEY (machine_id, position)
+			)`,
+			`CREATE INDEX missing_updates_update_id_idx ON missing_updates (update_id)`,
+		},
+	},
+	{
+		version: 2,
+		statements: []string{
+			`ALTER TABLE machines ADD COLUMN health_changed_at INTEGER`,
+		},
+	},
+	{
+		version: 3,
+		statements: []string{
+			`CREATE TABLE silences (
+				id TEXT PRIMARY KEY,
+				starts_at INTEGER NOT NULL,
+				ends_at INTEGER NOT NULL,
+				created_by TEXT NOT NULL,
+				comment TEXT NOT NULL,
+				created_at INTEGER NOT NULL
+			)`,
+			`CREATE TABLE silence_matchers (
+				silence_id TEXT NOT NULL REFERENCES silences (id),
+				position INTEGER NOT NULL,
+				label TEXT NOT NULL,
+				operator TEXT NOT NULL,
+				value TEXT NOT NULL,
+				PRIMARY KEY (silence_id, position)
+			)`,
+			`CREATE TABLE acknowledgments (
+				machine_id TEXT PRIMARY KEY,
+				since INTEGER NOT NULL,
+				created_by TEXT NOT NULL,
+				comment TEXT NOT NULL,
+				created_at INTEGER NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 4,
+		statements: []string{
+			`CREATE TABLE escalations (
+				machine_id TEXT PRIMARY KEY,
+				since INTEGER NOT NULL,
+				notified INTEGER NOT NULL
+			)`,
+		},
+	},
+}
+
+// Repository working with embedded SQLite database.
+type SqliteRepository struct {
+	db *sql.DB
+	mu *sync.Mutex
+}
+
+func (r *SqliteRepository) Load(id entities.MachineId) (*entities.Machine, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var name string
+	var decommissioned bool
+	var version int64
+	var healthChangedAt sql.NullInt64
+
+	err := r.db.QueryRow(
+		`SELECT name, decommissioned, version, health_changed_at FROM machines WHERE id = ?`,
+		id.String(),
+	).Scan(&name, &decommissioned, &version, &healthChangedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	missingUpdates, err := r.loadMissingUpdates(id)
+	if err != nil {
+		return nil, err
+	}
+
+	machine := entities.CreateMachine(id, name, missingUpdates)
+	if decommissioned {
+		machine.Decommission()
+	}
+
+	if healthChangedAt.Valid {
+		machine.SetHealthChangedAt(time.Unix(0, healthChangedAt.Int64).UTC())
+	}
+
+	machine.SetVersion(formatSqlVersion(version))
+	return machine, nil
+}
+
+func (r *SqliteRepository) List() ([]*entities.Machine, error) {
+	r.mu.Lock()
+	ids, err := queryMachineIds(r.db, `SELECT id FROM machines ORDER BY id`)
+	r.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return loadMachines(ids, r.Load)
+}
+
+func (r *SqliteRepository) Save(machine *entities.Machine) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	id := machine.Id.String()
+	expected, err := parseSqlVersion(machine.GetVersion())
+	if err != nil {
+		return err
+	}
+
+	// health change time is stored as unix nanoseconds, NULL means, that it's unknown
+	var healthChangedAt sql.NullInt64
+	if t := machine.GetHealthChangedAt(); !t.IsZero() {
+		healthChangedAt = sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+	}
+
+	var result sql.Result
+	if expected != 0 {
+		result, err = tx.Exec(
+			`UPDATE machines SET name = ?, health_level = ?, decommissioned = ?, health_changed_at = ?, version = version + 1
+			WHERE id = ? AND version = ?`,
+			machine.Name, int(machine.GetHealthLevel()), machine.IsDecommissioned(), healthChangedAt, id, expected,
+		)
+	} else {
+		result, err = tx.Exec(
+			`INSERT INTO machines (id, name, health_level, decommissioned, health_changed_at, version) VALUES (?, ?, ?, ?, ?, 1)
+			ON CONFLICT (id) DO NOTHING`,
+			id, machine.Name, int(machine.GetHealthLevel()), machine.IsDecommissioned(), healthChangedAt,
+		)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return &cases.OptimisticLockError{
+			MachineId: machine.Id,
+			Expected:  machine.GetVersion(),
+			Actual:    actualSqlVersion(tx, `SELECT version FROM machines WHERE id = ?`, id),
+		}
+	}
+
+	_, err = tx.Exec(`DELETE FROM missing_updates WHERE machine_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	for i, update := range machine.GetMissingUpdates() {
+		_, err = tx.Exec(
+			`INSERT INTO missing_updates (machine_id, position, update_id, severity, duration) VALUES (?, ?, ?, ?, ?)`,
+			id, i, update.UpdateId.String(), int(update.Severity), int64(update.Duration),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	machine.SetVersion(formatSqlVersion(expected + 1))
+	return nil
+}
+
+func (r *SqliteRepository) Delete(id entities.MachineId) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM missing_updates WHERE machine_id = ?`, id.String())
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM machines WHERE id = ?`, id.String())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Returns repository of silences and acknowledgments, which works with the same database.
+func (r *SqliteRepository) Silences() *SqlSilenceRepository {
+	return &SqlSilenceRepository{db: r.db, bind: keepPlaceholders}
+}
+
+// Closes underlying database.
+func (r *SqliteRepository) Close() error {
+	return r.db.Close()
+}
+
+func (r *SqliteRepository) loadMissingUpdates(id entities.MachineId) ([]entities.MissingUpdate, error) {
+	rows, err := r.db.Query(
+		`SELECT update_id, severity, duration FROM missing_updates WHERE machine_id = ? ORDER BY position`,
+		id.String(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	missingUpdates := []entities.MissingUpdate{}
+	for rows.Next() {
+		var updateId string
+		var severity int
+		var duration int64
+		var parsedId uuid.UUID
+
+		err = rows.Scan(&updateId, &severity, &duration)
+		if err != nil {
+			return nil, err
+		}
+
+		parsedId, err = uuid.Parse(updateId)
+		if err != nil {
+			return nil, err
+		}
+
+		missingUpdates = append(missingUpdates, entities.MissingUpdate{
+			UpdateId: parsedId,
+			Severity: entities.Severity(severity),
+			Duration: time.Duration(duration),
+		})
+	}
+
+	return missingUpdates, rows.Err()
+}
+
+// Opens SQLite database at specific path and migrates its schema to the latest version.
+func NewSqliteRepository(path string) (*SqliteRepository, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows only one writer, so all access is serialized through single connection.
+	db.SetMaxOpenConns(1)
+
+	for _, pragma := range []string{`PRAGMA journal_mode = WAL`, `PRAGMA busy_timeout = 5000`} {
+		_, err = db.Exec(pragma)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	err = migrate(db, sqliteMigrations, "")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SqliteRepository{
+		db: db,
+		mu: &sync.Mutex{},
+	}, nil
+}
@@ -0,0 +1,48 @@
+package adapters
+
+import (
+	"dum/internal/machines/cases"
+	"path/filepath"
+	"testing"
+)
+
+func TestSqliteRepositoryBehavior(t *testing.T) {
+	testRepositoryBehavior(t, newSqliteRepositoryFactory(t))
+}
+
+func TestSqliteMigrationsAreIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "machines.db")
+
+	for i := 0; i < 2; i++ {
+		repo, err := NewSqliteRepository(path)
+		if err != nil {
+			t.Errorf("Failed to open repository, because of error %s", err)
+			return
+		}
+
+		version, err := schemaVersion(repo.db)
+		if err != nil {
+			t.Errorf("Failed to read schema version, because of error %s", err)
+		}
+
+		if version != len(sqliteMigrations) {
+			t.Errorf("Schema version mismatch! Expected %d, but was %d", len(sqliteMigrations), version)
+		}
+
+		repo.Close()
+	}
+}
+
+func newSqliteRepositoryFactory(t *testing.T) repositoryFactory {
+	path := filepath.Join(t.TempDir(), "machines.db")
+
+	return func(t *testing.T) cases.MachineRepository {
+		repo, err := NewSqliteRepository(path)
+		if err != nil {
+			t.Fatalf("Cannot setup test due to error %s", err)
+		}
+
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	}
+}
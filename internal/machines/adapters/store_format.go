@@ -0,0 +1,109 @@
+package adapters
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Version of on-disk format of machine store, which is written by file repositories.
+// Version 1 is a bare map of machines by id, version 2 wraps it into versioned envelope.
+const CurrentFormatVersion int = 2
+
+// Versioned envelope of machine store.
+type storeEnvelope struct {
+	FormatVersion int
+	Machines      map[string]machineDto
+}
+
+// Step upgrading raw store document from some version to the next one.
+type formatUpgrade func(raw []byte) ([]byte, error)
+
+// Registry of upgrade steps by version, which they are upgrading from.
+var formatUpgrades = map[int]formatUpgrade{
+	1: upgradeFormatV1,
+}
+
+// Wraps bare map of machines into envelope.
+func upgradeFormatV1(raw []byte) ([]byte, error) {
+	return json.Marshal(struct {
+		FormatVersion int
+		Machines      json.RawMessage
+	}{2, raw})
+}
+
+// Returns format version of raw store document. Documents without version are legacy documents of version 1.
+func detectFormatVersion(raw []byte, d deserializer) (int, error) {
+	var probe struct {
+		FormatVersion int
+	}
+
+	err := d(raw, &probe)
+	if err != nil {
+		return 0, err
+	}
+
+	if probe.FormatVersion == 0 {
+		return 1, nil
+	}
+
+	return probe.FormatVersion, nil
+}
+
+// Applies upgrade steps to raw store document until it has current version.
+func upgradeFormat(raw []byte, version int) ([]byte, error) {
+	if version > CurrentFormatVersion {
+		return nil, fmt.Errorf("format version %d is newer than supported version %d", version, CurrentFormatVersion)
+	}
+
+	for ; version < CurrentFormatVersion; version++ {
+		upgrade, ok := formatUpgrades[version]
+		if !ok {
+			return nil, fmt.Errorf("no upgrade from format version %d", version)
+		}
+
+		var err error
+		raw, err = upgrade(raw)
+		if err != nil {
+			return nil, fmt.Errorf("cannot upgrade from format version %d - %w", version, err)
+		}
+	}
+
+	return raw, nil
+}
+
+// Reads machines from raw store document of any supported version. Empty document is an empty store.
+func decodeStore(raw []byte, d deserializer) (map[string]machineDto, error) {
+	if len(raw) == 0 {
+		return map[string]machineDto{}, nil
+	}
+
+	version, err := detectFormatVersion(raw, d)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err = upgradeFormat(raw, version)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope := storeEnvelope{}
+	err = d(raw, &envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	if envelope.Machines == nil {
+		envelope.Machines = map[string]machineDto{}
+	}
+
+	return envelope.Machines, nil
+}
+
+// Writes machines as raw store document of current version.
+func encodeStore(dtoSet map[string]machineDto, s serializer) ([]byte, error) {
+	return s(&storeEnvelope{
+		FormatVersion: CurrentFormatVersion,
+		Machines:      dtoSet,
+	})
+}
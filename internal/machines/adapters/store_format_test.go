@@ -0,0 +1,125 @@
+package adapters
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const legacyStore string = `{"8b0e5ac4-2a5a-4b7c-bd8e-4a5b1f0c3d11":{"Id":"8b0e5ac4-2a5a-4b7c-bd8e-4a5b1f0c3d11","Name":"legacy","Version":"1","MissingUpdates":[{"UpdateId":"0c6f44a4-92a7-4b8a-9c59-7a3a3e7a1b2c","Severity":4,"Duration":3600000000000}]}}`
+
+func TestDecodeLegacyStore(t *testing.T) {
+	dtoSet, err := decodeStore([]byte(legacyStore), json.Unmarshal)
+	if err != nil {
+		t.Fatalf("Legacy store should be decoded, but got error %s", err)
+	}
+
+	dto, ok := dtoSet["8b0e5ac4-2a5a-4b7c-bd8e-4a5b1f0c3d11"]
+	if !ok {
+		t.Fatalf("Legacy machine should be decoded!")
+	}
+
+	if dto.Name != "legacy" {
+		t.Errorf("Machine name mismatch! Expected %s, but was %s", "legacy", dto.Name)
+	}
+
+	if len(dto.MissingUpdates) != 1 {
+		t.Errorf("Missing updates count mismatch! Expected %d, but was %d", 1, len(dto.MissingUpdates))
+	}
+}
+
+func TestEncodeCurrentFormat(t *testing.T) {
+	raw, err := encodeStore(map[string]machineDto{}, json.Marshal)
+	if err != nil {
+		t.Fatalf("Store should be encoded, but got error %s", err)
+	}
+
+	version, err := detectFormatVersion(raw, json.Unmarshal)
+	if err != nil {
+		t.Fatalf("Version should be detected, but got error %s", err)
+	}
+
+	if version != CurrentFormatVersion {
+		t.Errorf("Format version mismatch! Expected %d, but was %d", CurrentFormatVersion, version)
+	}
+}
+
+func TestDecodeNewerFormatError(t *testing.T) {
+	_, err := decodeStore([]byte(`{"FormatVersion":100,"Machines":{}}`), json.Unmarshal)
+	if err == nil {
+		t.Errorf("Store of newer format should not be decoded!")
+	}
+}
+
+func TestMigrateStoreFile(t *testing.T) {
+	c := createTestFileRepositoryConfig(t)
+	err := os.WriteFile(c.Path, []byte(legacyStore), c.Permissions)
+	if err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+
+	report, err := MigrateStoreFile(c, false)
+	if err != nil {
+		t.Fatalf("Store should be migrated, but got error %s", err)
+	}
+
+	if !report.Migrated || report.FromVersion != 1 || report.ToVersion != CurrentFormatVersion || report.Machines != 1 {
+		t.Errorf("Unexpected migration report %+v", report)
+	}
+
+	backup, err := os.ReadFile(report.Backup)
+	if err != nil {
+		t.Fatalf("Backup should be readable, but got error %s", err)
+	}
+
+	if string(backup) != legacyStore {
+		t.Errorf("Backup should keep original content!")
+	}
+
+	raw, _ := os.ReadFile(c.Path)
+	if version, _ := detectFormatVersion(raw, json.Unmarshal); version != CurrentFormatVersion {
+		t.Errorf("Format version mismatch! Expected %d, but was %d", CurrentFormatVersion, version)
+	}
+
+	report, err = MigrateStoreFile(c, false)
+	if err != nil || report.Migrated {
+		t.Errorf("Migrated store should not be migrated again, but got report %+v and error %v", report, err)
+	}
+}
+
+func TestMigrateStoreFileDryRun(t *testing.T) {
+	c := createTestFileRepositoryConfig(t)
+	err := os.WriteFile(c.Path, []byte(legacyStore), c.Permissions)
+	if err != nil {
+		t.Fatalf("Cannot setup test due to error %s", err)
+	}
+
+	report, err := MigrateStoreFile(c, true)
+	if err != nil {
+		t.Fatalf("Store should be checked, but got error %s", err)
+	}
+
+	if report.Migrated || report.FromVersion != 1 || report.Machines != 1 {
+		t.Errorf("Unexpected migration report %+v", report)
+	}
+
+	raw, _ := os.ReadFile(c.Path)
+	if string(raw) != legacyStore {
+		t.Errorf("Dry run should not change store!")
+	}
+
+	if _, err := os.Stat(backupPath(c.Path, 1)); !os.IsNotExist(err) {
+		t.Errorf("Dry run should not create backup!")
+	}
+}
+
+func TestMigrateAbsentStoreFile(t *testing.T) {
+	c := createTestFileRepositoryConfig(t)
+	c.Path = filepath.Join(t.TempDir(), "absent.json")
+
+	report, err := MigrateStoreFile(c, false)
+	if err != nil || report.Migrated {
+		t.Errorf("Absent store should not be migrated, but got report %+v and error %v", report, err)
+	}
+}
@@ -0,0 +1,90 @@
+package adapters
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Result of store file migration.
+type MigrationReport struct {
+	Path        string
+	FromVersion int
+	ToVersion   int
+	Machines    int
+	// Path to copy of original file, empty if file was not migrated.
+	Backup   string
+	Migrated bool
+}
+
+func backupPath(path string, version int) string {
+	return fmt.Sprintf("%s.v%d.bak", path, version)
+}
+
+// Upgrades store file to current format version. Original file is kept next to it as a backup.
+// With dry run file is only checked and upgraded in memory, nothing is written.
+func MigrateStoreFile(c FileRepositoryConfig, dryRun bool) (MigrationReport, error) {
+	report := MigrationReport{
+		Path:        c.Path,
+		FromVersion: CurrentFormatVersion,
+		ToVersion:   CurrentFormatVersion,
+	}
+
+	l, err := AcquireFileLock(c.Path, c.Permissions, c.LockTimeout)
+	if err != nil {
+		return report, err
+	}
+	defer l.Release()
+
+	original, err := os.ReadFile(c.Path)
+	if os.IsNotExist(err) || (err == nil && len(original) == 0) {
+		return report, nil
+	}
+
+	if err != nil {
+		return report, err
+	}
+
+	raw := original
+	if c.Cipher != nil {
+		raw, err = c.Cipher.Open(original)
+		if err != nil {
+			return report, fmt.Errorf("cannot open %s - %w", c.Path, err)
+		}
+	}
+
+	report.FromVersion, err = detectFormatVersion(raw, json.Unmarshal)
+	if err != nil {
+		return report, err
+	}
+
+	dtoSet, err := decodeStore(raw, json.Unmarshal)
+	if err != nil {
+		return report, err
+	}
+
+	report.Machines = len(dtoSet)
+	if report.FromVersion == CurrentFormatVersion || dryRun {
+		return report, nil
+	}
+
+	upgraded, err := encodeStore(dtoSet, json.Marshal)
+	if err != nil {
+		return report, err
+	}
+
+	backup := backupPath(c.Path, report.FromVersion)
+	err = writeFileAtomically(backup, original, c.Permissions, 0)
+	if err != nil {
+		return report, fmt.Errorf("cannot backup %s - %w", c.Path, err)
+	}
+
+	err = writeSealedFile(c.Path, upgraded, c.Permissions, 0, c.Cipher)
+	if err != nil {
+		return report, err
+	}
+
+	report.Backup = backup
+	report.Migrated = true
+	return report, nil
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package adapters
+
+import "os"
+
+// Flushes directory entry changes (e.g. renames) to disk.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
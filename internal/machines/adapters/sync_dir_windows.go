@@ -0,0 +1,9 @@
+//go:build windows
+// +build windows
+
+package adapters
+
+// Directories can not be flushed on windows, renames are durable after MoveFileEx returns.
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,253 @@
+package adapters
+
+import (
+	"dum/internal/machines/entities"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Settings of notification throttling, zero values disable corresponding checks.
+type ThrottleConfig struct {
+	// Identical events (the same machine and health levels) are notified once per window.
+	DedupWindow time.Duration
+	// Length of rate limiting window.
+	Window time.Duration
+	// Count of events about a single machine, notified per window.
+	MachineLimit int
+	// Count of events, notified by channel per window.
+	ChannelLimit int
+	// Interval, in which events over limits are collected into digest, rate limiting window by default.
+	DigestInterval time.Duration
+}
+
+// Returns true, if some throttling is configured.
+func (c ThrottleConfig) Enabled() bool {
+	return c.DedupWindow > 0 || (c.Window > 0 && (c.MachineLimit > 0 || c.ChannelLimit > 0))
+}
+
+// Strategy, which can notify about digest of events as well as about single events.
+type DigestNotificationStrategy interface {
+	entities.HealthNotificationStrategy
+	NotifyDigest(d entities.HealthDigest) error
+}
+
+// Counters of throttling decorator.
+type ThrottleStats struct {
+	Notified, Deduplicated, Digested uint64
+}
+
+// Notification decorator, which drops duplicated events and limits count of events per machine and
+// per channel. Events over limits are not dropped, but collected and notified periodically as digest.
+// Strategies, which can't notify about digest, get it written to log. Only noteworthy events are
+// throttled, the rest are passed as is and don't count against limits.
+type ThrottlingNotificationDecorator struct {
+	mu     sync.Mutex
+	base   entities.HealthNotificationStrategy
+	config ThrottleConfig
+	logger *log.Logger
+	now    func() time.Time
+
+	seen        map[string]time.Time
+	machineSent map[entities.MachineId][]time.Time
+	channelSent []time.Time
+	pending     map[entities.MachineId]entities.HealthEvent
+	since       time.Time
+	stats       ThrottleStats
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func (s *ThrottlingNotificationDecorator) Notify(e entities.HealthEvent) error {
+	if !e.Noteworthy() {
+		return s.base.Notify(e)
+	}
+
+	s.mu.Lock()
+	now := s.now()
+	s.prune(now, e.MachineId)
+
+	key := fmt.Sprintf("%s/%d/%d", e.MachineId, e.Previous, e.Current)
+	if last, ok := s.seen[key]; ok && now.Sub(last) < s.config.DedupWindow {
+		s.stats.Deduplicated++
+		s.mu.Unlock()
+		return nil
+	}
+	s.seen[key] = now
+
+	if s.overLimit(e.MachineId) {
+		if len(s.pending) == 0 {
+			s.since = now
+		}
+		s.pending[e.MachineId] = e
+		s.stats.Digested++
+		digest := s.dueDigest(now, false)
+		s.mu.Unlock()
+
+		s.notifyDigest(digest)
+		return nil
+	}
+
+	if s.config.Window > 0 {
+		s.machineSent[e.MachineId] = append(s.machineSent[e.MachineId], now)
+		s.channelSent = append(s.channelSent, now)
+	}
+	s.stats.Notified++
+	digest := s.dueDigest(now, false)
+	s.mu.Unlock()
+
+	s.notifyDigest(digest)
+	return s.base.Notify(e)
+}
+
+// Forgets events, which are out of windows, and notifies about digest, if digest interval is over.
+func (s *ThrottlingNotificationDecorator) Flush() {
+	s.mu.Lock()
+	now := s.now()
+	ids := make([]entities.MachineId, 0, len(s.machineSent))
+	for id := range s.machineSent {
+		ids = append(ids, id)
+	}
+	s.prune(now, ids...)
+	for key, last := range s.seen {
+		if now.Sub(last) >= s.config.DedupWindow {
+			delete(s.seen, key)
+		}
+	}
+	digest := s.dueDigest(now, false)
+	s.mu.Unlock()
+
+	s.notifyDigest(digest)
+}
+
+// Stops periodic digest and notifies about collected events immediately.
+func (s *ThrottlingNotificationDecorator) Close() error {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+
+	s.mu.Lock()
+	digest := s.dueDigest(s.now(), true)
+	s.mu.Unlock()
+
+	s.notifyDigest(digest)
+	return nil
+}
+
+func (s *ThrottlingNotificationDecorator) Stats() ThrottleStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+func (s *ThrottlingNotificationDecorator) overLimit(id entities.MachineId) bool {
+	if s.config.Window <= 0 {
+		return false
+	}
+
+	return (s.config.MachineLimit > 0 && len(s.machineSent[id]) >= s.config.MachineLimit) ||
+		(s.config.ChannelLimit > 0 && len(s.channelSent) >= s.config.ChannelLimit)
+}
+
+// Forgets notifications of channel and given machines, which are out of windows.
+func (s *ThrottlingNotificationDecorator) prune(now time.Time, ids ...entities.MachineId) {
+	s.channelSent = dropBefore(s.channelSent, now.Add(-s.config.Window))
+
+	for _, id := range ids {
+		if sent := dropBefore(s.machineSent[id], now.Add(-s.config.Window)); len(sent) > 0 {
+			s.machineSent[id] = sent
+		} else {
+			delete(s.machineSent, id)
+		}
+	}
+}
+
+// Returns digest of pending events and resets them, if digest interval is over or force is set.
+func (s *ThrottlingNotificationDecorator) dueDigest(now time.Time, force bool) *entities.HealthDigest {
+	if len(s.pending) == 0 || (!force && now.Sub(s.since) < s.config.DigestInterval) {
+		return nil
+	}
+
+	d := &entities.HealthDigest{Since: s.since, Until: now}
+	for _, e := range s.pending {
+		d.Events = append(d.Events, e)
+	}
+	sort.Slice(d.Events, func(i, j int) bool { return d.Events[i].MachineName < d.Events[j].MachineName })
+
+	s.pending = map[entities.MachineId]entities.HealthEvent{}
+	return d
+}
+
+func (s *ThrottlingNotificationDecorator) notifyDigest(d *entities.HealthDigest) {
+	if d == nil {
+		return
+	}
+
+	ds, ok := s.base.(DigestNotificationStrategy)
+	if !ok {
+		s.logger.Printf("Notification digest: %s", d.Summary())
+		return
+	}
+
+	if err := ds.NotifyDigest(*d); err != nil {
+		s.logger.Printf("Notification digest failed: %s", err)
+	}
+}
+
+func (s *ThrottlingNotificationDecorator) run(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.Flush()
+		}
+	}
+}
+
+// Returns times, which are not before t, times should be ordered.
+func dropBefore(times []time.Time, t time.Time) []time.Time {
+	i := 0
+	for i < len(times) && times[i].Before(t) {
+		i++
+	}
+
+	return times[i:]
+}
+
+func newThrottlingNotificationDecorator(base entities.HealthNotificationStrategy, c ThrottleConfig, logger *log.Logger, now func() time.Time) *ThrottlingNotificationDecorator {
+	if c.DigestInterval <= 0 {
+		c.DigestInterval = c.Window
+	}
+
+	return &ThrottlingNotificationDecorator{
+		base:        base,
+		config:      c,
+		logger:      logger,
+		now:         now,
+		seen:        map[string]time.Time{},
+		machineSent: map[entities.MachineId][]time.Time{},
+		pending:     map[entities.MachineId]entities.HealthEvent{},
+	}
+}
+
+// Creates decorator, which notifies about digest every digest interval until it's closed.
+func NewThrottlingNotificationDecorator(base entities.HealthNotificationStrategy, c ThrottleConfig, logger *log.Logger) *ThrottlingNotificationDecorator {
+	s := newThrottlingNotificationDecorator(base, c, logger, time.Now)
+	if s.config.DigestInterval > 0 {
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.run(s.config.DigestInterval)
+	}
+
+	return s
+}
@@ -0,0 +1,322 @@
+package adapters
+
+import (
+	"bytes"
+	"dum/internal/machines/entities"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestThrottlingDeduplicatesIdenticalEvents(t *testing.T) {
+	base := &digestRecordingStrategy{}
+	clock := newTestClock()
+	s := newThrottlingNotificationDecorator(base, ThrottleConfig{DedupWindow: time.Minute}, newDiscardLogger(), clock.now)
+	e := createTestHealthEvent()
+
+	s.Notify(e)
+	clock.advance(30 * time.Second)
+	s.Notify(e)
+	other := e
+	other.Previous = entities.Warning
+	s.Notify(other)
+	clock.advance(31 * time.Second)
+	s.Notify(e)
+
+	if base.count != 3 {
+		t.Errorf("Notified events count mismatch! Expected 3, but was %d", base.count)
+	}
+
+	if stats := s.Stats(); stats.Deduplicated != 1 || stats.Notified != 3 {
+		t.Errorf("Stats mismatch! Was %+v", stats)
+	}
+}
+
+func TestThrottlingLimitsMachineAndDigestsTheRest(t *testing.T) {
+	base := &digestRecordingStrategy{}
+	clock := newTestClock()
+	c := ThrottleConfig{Window: 10 * time.Minute, MachineLimit: 2}
+	s := newThrottlingNotificationDecorator(base, c, newDiscardLogger(), clock.now)
+	noisy := createTestHealthEvent()
+	quiet := createTestHealthEvent()
+
+	for i := 0; i < 5; i++ {
+		noisy.Previous, noisy.Current = noisy.Current, entities.HealthLevel((i+1)%3)
+		s.Notify(noisy)
+		clock.advance(time.Minute)
+	}
+	s.Notify(quiet)
+
+	if base.count != 3 {
+		t.Errorf("Notified events count mismatch! Expected 3, but was %d", base.count)
+	}
+
+	if len(base.digests) != 0 {
+		t.Fatalf("Digest should not be sent before interval, but was %v", base.digests)
+	}
+
+	clock.advance(10 * time.Minute)
+	s.Flush()
+
+	if len(base.digests) != 1 {
+		t.Fatalf("Digests count mismatch! Expected 1, but was %d", len(base.digests))
+	}
+
+	d := base.digests[0]
+	if len(d.Events) != 1 || d.Events[0].Current != entities.Danger {
+		t.Errorf("Digest should contain the latest event of machine, but was %+v", d.Events)
+	}
+
+	if summary := d.Summary(); summary != "1 machine went to Danger in the last 13 minutes" {
+		t.Errorf("Summary mismatch! Was %s", summary)
+	}
+
+	s.Notify(noisy)
+	if base.count != 4 {
+		t.Errorf("Machine should be notified again after window, but count was %d", base.count)
+	}
+}
+
+func TestThrottlingPassesUnchangedEvents(t *testing.T) {
+	base := &digestRecordingStrategy{}
+	clock := newTestClock()
+	c := ThrottleConfig{DedupWindow: time.Minute, Window: 10 * time.Minute, MachineLimit: 1}
+	s := newThrottlingNotificationDecorator(base, c, newDiscardLogger(), clock.now)
+	e := createTestHealthEvent()
+	unchanged := e
+	unchanged.Previous = entities.Danger
+
+	for i := 0; i < 3; i++ {
+		s.Notify(unchanged)
+	}
+	s.Notify(e)
+
+	if base.count != 4 {
+		t.Errorf("Notified events count mismatch! Expected 4, but was %d", base.count)
+	}
+
+	if stats := s.Stats(); stats.Notified != 1 || stats.Deduplicated != 0 || stats.Digested != 0 {
+		t.Errorf("Unchanged events should not be throttled, but stats were %+v", stats)
+	}
+}
+
+func TestThrottlingLimitsChannel(t *testing.T) {
+	base := &digestRecordingStrategy{}
+	clock := newTestClock()
+	c := ThrottleConfig{Window: 10 * time.Minute, ChannelLimit: 3}
+	s := newThrottlingNotificationDecorator(base, c, newDiscardLogger(), clock.now)
+
+	for i := 0; i < 40; i++ {
+		e := createTestHealthEvent()
+		e.MachineName = "web-" + uuid.NewString()
+		s.Notify(e)
+	}
+
+	if base.count != 3 {
+		t.Errorf("Notified events count mismatch! Expected 3, but was %d", base.count)
+	}
+
+	s.Close()
+
+	if len(base.digests) != 1 || len(base.digests[0].Events) != 37 {
+		t.Fatalf("Digest should be sent on close with 37 events, but was %v", base.digests)
+	}
+
+	if summary := base.digests[0].Summary(); !strings.HasPrefix(summary, "37 machines went to Danger") {
+		t.Errorf("Summary mismatch! Was %s", summary)
+	}
+}
+
+func TestThrottlingWritesDigestToLogWithoutDigestSupport(t *testing.T) {
+	var out bytes.Buffer
+	clock := newTestClock()
+	c := ThrottleConfig{Window: time.Minute, MachineLimit: 1}
+	s := newThrottlingNotificationDecorator(&recordingStrategy{}, c, log.New(&out, "", 0), clock.now)
+	e := createTestHealthEvent()
+
+	s.Notify(e)
+	e.Current = entities.Warning
+	s.Notify(e)
+	clock.advance(time.Minute)
+	s.Flush()
+
+	if !strings.Contains(out.String(), "Notification digest: 1 machine went to Warning in the last minute") {
+		t.Errorf("Digest should be logged, but log was %s", out.String())
+	}
+}
+
+func TestThrottlingDigestIsSentPeriodically(t *testing.T) {
+	base := &digestRecordingStrategy{done: make(chan struct{}, 1)}
+	c := ThrottleConfig{Window: time.Hour, MachineLimit: 1, DigestInterval: 10 * time.Millisecond}
+	s := NewThrottlingNotificationDecorator(base, c, newDiscardLogger())
+	defer s.Close()
+	e := createTestHealthEvent()
+
+	s.Notify(e)
+	s.Notify(e)
+	e.Current = entities.Healthy
+	s.Notify(e)
+
+	select {
+	case <-base.done:
+	case <-time.After(5 * time.Second):
+		t.Error("Digest should be sent by timer!")
+	}
+}
+
+func TestWebhookDigest(t *testing.T) {
+	receiver := newWebhookReceiver(http.StatusOK)
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	s := newTestWebhookStrategy(server.URL)
+	e := createTestHealthEvent()
+	err := s.NotifyDigest(entities.HealthDigest{Since: e.OccurredAt.Add(-time.Hour), Until: e.OccurredAt, Events: []entities.HealthEvent{e}})
+
+	if err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	body := string(receiver.requests[0].body)
+	if !strings.Contains(body, `"Summary":"1 machine went to Danger in the last hour"`) || !strings.Contains(body, e.MachineId.String()) {
+		t.Errorf("Digest mismatch! Was %s", body)
+	}
+}
+
+func TestChatDigest(t *testing.T) {
+	slack := newChatStandIn(t, validateSlackPayload)
+	slackServer := httptest.NewServer(slack)
+	defer slackServer.Close()
+
+	teams := newChatStandIn(t, validateTeamsPayload)
+	teamsServer := httptest.NewServer(teams)
+	defer teamsServer.Close()
+
+	s := newTestChatStrategy(t,
+		ChatChannel{Name: "ops", Format: SlackChatFormat, URL: slackServer.URL},
+		ChatChannel{Name: "desk", Format: TeamsChatFormat, URL: teamsServer.URL, Levels: []string{"Healthy"}},
+	)
+
+	until := newTestClock().now()
+	d := entities.HealthDigest{Since: until.Add(-10 * time.Minute), Until: until}
+	for i := 0; i < 12; i++ {
+		d.Events = append(d.Events, createTestHealthEvent())
+	}
+
+	if err := s.NotifyDigest(d); err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	if slack.count() != 1 || teams.count() != 0 {
+		t.Fatalf("Digest should be posted to ops channel only, but counts were %d and %d", slack.count(), teams.count())
+	}
+
+	if raw := slack.raw(0); !strings.Contains(raw, "12 machines went to Danger in the last 10 minutes") || !strings.Contains(raw, "and 2 more") {
+		t.Errorf("Digest payload mismatch! Was %s", raw)
+	}
+}
+
+func TestEmailDigest(t *testing.T) {
+	server := startFakeSmtpServer(t, nil)
+	s := newTestEmailStrategy(t, createTestEmailConfig(server))
+	e := createTestHealthEvent()
+
+	err := s.NotifyDigest(entities.HealthDigest{Since: e.OccurredAt.Add(-time.Hour), Until: e.OccurredAt, Events: []entities.HealthEvent{e}})
+
+	if err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	data := server.messages()[0].data
+	if !strings.Contains(data, "Subject: [DUM] 1 machine went to Danger in the last hour") || !strings.Contains(data, "web-01 ("+e.MachineId.String()+"): Healthy -> Danger") {
+		t.Errorf("Digest email mismatch! Was %s", data)
+	}
+}
+
+func TestIncidentDigest(t *testing.T) {
+	standIn := newEventsApiStandIn()
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	s := newTestIncidentStrategy(t, createTestIncidentConfig(t, server.URL))
+	danger := createTestHealthEvent()
+	warning := createTestHealthEvent()
+	warning.Current = entities.Warning
+
+	err := s.NotifyDigest(entities.HealthDigest{Since: danger.OccurredAt.Add(-time.Hour), Until: danger.OccurredAt, Events: []entities.HealthEvent{danger, warning}})
+
+	if err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	if open := s.OpenIncidents(); len(open) != 1 || open[0].DedupKey != danger.MachineId.String() {
+		t.Errorf("Incident should be opened for machine in danger only, but was %+v", open)
+	}
+}
+
+func TestRoutingDigest(t *testing.T) {
+	email := &digestRecordingStrategy{}
+	pager := &digestRecordingStrategy{}
+	s, err := NewRoutingNotificationStrategy(
+		[]NotificationRoute{
+			{Levels: []string{"Danger"}, Channels: []string{"pager"}},
+			{Channels: []string{"email", "log"}},
+		},
+		map[string]entities.HealthNotificationStrategy{"email": email, "pager": pager, "log": &recordingStrategy{}},
+		nil, nil, newDiscardLogger(),
+	)
+	if err != nil {
+		t.Fatalf("Cannot create strategy %s", err)
+	}
+
+	danger := createTestHealthEvent()
+	warning := createTestHealthEvent()
+	warning.Current = entities.Warning
+
+	if err := s.NotifyDigest(entities.HealthDigest{Events: []entities.HealthEvent{danger, warning}}); err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	if len(email.digests) != 1 || len(email.digests[0].Events) != 2 {
+		t.Errorf("Email should get digest of both events, but was %+v", email.digests)
+	}
+
+	if len(pager.digests) != 1 || len(pager.digests[0].Events) != 1 || pager.digests[0].Events[0].Current != entities.Danger {
+		t.Errorf("Pager should get digest of event in danger only, but was %+v", pager.digests)
+	}
+}
+
+type testClock struct {
+	t time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) now() time.Time {
+	return c.t
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+type digestRecordingStrategy struct {
+	recordingStrategy
+	digests []entities.HealthDigest
+	done    chan struct{}
+}
+
+func (s *digestRecordingStrategy) NotifyDigest(d entities.HealthDigest) error {
+	s.digests = append(s.digests, d)
+	if s.done != nil {
+		s.done <- struct{}{}
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package adapters
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"dum/internal/machines/entities"
+	"dum/pkg/machines/contract"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Header with unix time of delivery, which is a part of signed content.
+	WebhookTimestampHeader string = "X-Dum-Timestamp"
+	// Header with HMAC-SHA256 signature of delivery in form sha256=<hex>.
+	WebhookSignatureHeader string = "X-Dum-Signature"
+	// Header with unique id of event, the same for all attempts.
+	WebhookEventHeader string = "X-Dum-Event"
+	// Header with kind of payload - single event or digest.
+	WebhookKindHeader string = "X-Dum-Kind"
+)
+
+const (
+	webhookEventKind  string = "event"
+	webhookDigestKind string = "digest"
+)
+
+const webhookSignaturePrefix string = "sha256="
+
+// Settings of webhook notification strategy.
+type WebhookConfig struct {
+	// URLs, which receive every health event.
+	URLs []string
+	// Secret key of HMAC signature.
+	Secret string
+	// Timeout of a single delivery attempt.
+	Timeout time.Duration
+	// Count of additional attempts after failed one.
+	Retries int
+	// Delay before the first retry, it's doubled for every next one.
+	Backoff time.Duration
+	// Count of the latest deliveries, kept in delivery log.
+	LogSize int
+}
+
+// Returns webhook settings with reasonable timeouts and retries.
+func DefaultWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		Timeout: 5 * time.Second,
+		Retries: 3,
+		Backoff: 500 * time.Millisecond,
+		LogSize: 100,
+	}
+}
+
+// Result of event delivery to single webhook URL.
+type WebhookDelivery struct {
+	URL        string
+	MachineId  entities.MachineId
+	Attempts   int
+	StatusCode int
+	Error      string
+	At         time.Time
+}
+
+// Returns true, if event was accepted by receiver.
+func (d WebhookDelivery) Succeeded() bool {
+	return d.Error == ""
+}
+
+// Notification strategy, which posts JSON health events to webhooks. Every request is signed
+// with HMAC-SHA256 of timestamp and body, so receiver can check the sender and reject replays.
+// Failed deliveries are retried with exponential backoff on network errors, 5xx and 429 responses.
+// Only noteworthy events are posted, reports without health level change are not.
+type WebhookNotificationStrategy struct {
+	config WebhookConfig
+	client *http.Client
+	logger *log.Logger
+	now    func() time.Time
+	sleep  func(time.Duration)
+
+	mu         sync.Mutex
+	deliveries []WebhookDelivery
+}
+
+func (s *WebhookNotificationStrategy) Notify(e entities.HealthEvent) error {
+	if !e.Noteworthy() {
+		return nil
+	}
+
+	body, err := json.Marshal(newHealthEventDto(e))
+	if err != nil {
+		return err
+	}
+
+	eventId := fmt.Sprintf("%s-%d", e.MachineId, e.OccurredAt.UnixNano())
+	return s.broadcast(webhookEventKind, eventId, body, e.MachineId)
+}
+
+// Posts digest with list of events to all webhooks, digest has X-Dum-Kind header set to digest.
+func (s *WebhookNotificationStrategy) NotifyDigest(d entities.HealthDigest) error {
+	dto := contract.HealthDigest{
+		Summary: d.Summary(),
+		Since:   d.Since.UTC().Format(time.RFC3339),
+		Until:   d.Until.UTC().Format(time.RFC3339),
+		Events:  []contract.HealthEvent{},
+	}
+	for _, e := range d.Events {
+		dto.Events = append(dto.Events, newHealthEventDto(e))
+	}
+
+	body, err := json.Marshal(dto)
+	if err != nil {
+		return err
+	}
+
+	digestId := fmt.Sprintf("digest-%d", d.Until.UnixNano())
+	return s.broadcast(webhookDigestKind, digestId, body, entities.MachineId{})
+}
+
+func (s *WebhookNotificationStrategy) broadcast(kind string, id string, body []byte, machineId entities.MachineId) error {
+	var failed []string
+	for _, url := range s.config.URLs {
+		d := s.deliver(url, kind, id, body)
+		d.MachineId = machineId
+		s.record(d)
+
+		if !d.Succeeded() {
+			s.logger.Printf("Webhook delivery of %s %s to %s failed after %d attempts: %s", kind, id, url, d.Attempts, d.Error)
+			failed = append(failed, fmt.Sprintf("%s - %s", url, d.Error))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("webhook delivery failed: %s", strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// Returns delivery log, the oldest delivery goes first.
+func (s *WebhookNotificationStrategy) Deliveries() []WebhookDelivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]WebhookDelivery, len(s.deliveries))
+	copy(result, s.deliveries)
+	return result
+}
+
+func (s *WebhookNotificationStrategy) deliver(url string, kind string, id string, body []byte) WebhookDelivery {
+	d := WebhookDelivery{URL: url}
+	attempts, err := deliverWithRetries(s.config.Retries, s.config.Backoff, s.sleep, func() (bool, error) {
+		status, retry, err := s.post(url, kind, id, body)
+		d.StatusCode = status
+		d.At = s.now()
+		return retry, err
+	})
+
+	d.Attempts = attempts
+	if err != nil {
+		d.Error = err.Error()
+	}
+
+	return d
+}
+
+// Posts signed body, returns status code and whether failed attempt can be retried.
+func (s *WebhookNotificationStrategy) post(url string, kind string, id string, body []byte) (int, bool, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, id)
+	req.Header.Set(WebhookKindHeader, kind)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(s.config.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, true, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, false, nil
+	}
+
+	return resp.StatusCode, isRetryableStatus(resp.StatusCode), fmt.Errorf("unexpected status %d", resp.StatusCode)
+}
+
+func (s *WebhookNotificationStrategy) record(d WebhookDelivery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deliveries = append(s.deliveries, d)
+	if over := len(s.deliveries) - s.config.LogSize; over > 0 {
+		s.deliveries = append([]WebhookDelivery{}, s.deliveries[over:]...)
+	}
+}
+
+// Returns value of signature header for payload, sent at timestamp. Receiver should compute
+// the same value with shared secret and compare it with hmac.Equal.
+func SignWebhookPayload(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return webhookSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Checks signature of webhook payload, can be used by receivers written in Go.
+func VerifyWebhookSignature(secret string, timestamp string, body []byte, signature string) bool {
+	expected := SignWebhookPayload(secret, timestamp, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+func newHealthEventDto(e entities.HealthEvent) contract.HealthEvent {
+	dto := contract.HealthEvent{
+		MachineId:   e.MachineId.String(),
+		MachineName: e.MachineName,
+		Previous:    e.Previous.String(),
+		Current:     e.Current.String(),
+		OccurredAt:  e.OccurredAt.UTC().Format(time.RFC3339),
+		Escalation:  e.Escalation,
+	}
+
+	if !e.Since.IsZero() {
+		dto.Since = e.Since.UTC().Format(time.RFC3339)
+	}
+
+	return dto
+}
+
+func NewWebhookNotificationStrategy(c WebhookConfig, logger *log.Logger) *WebhookNotificationStrategy {
+	return &WebhookNotificationStrategy{
+		config: c,
+		client: &http.Client{Timeout: c.Timeout},
+		logger: logger,
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+}
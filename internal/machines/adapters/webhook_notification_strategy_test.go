@@ -0,0 +1,265 @@
+package adapters
+
+import (
+	"dum/internal/machines/entities"
+	"dum/pkg/machines/contract"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWebhookDeliversSignedEvent(t *testing.T) {
+	receiver := newWebhookReceiver(http.StatusOK)
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	s := newTestWebhookStrategy(server.URL)
+	e := createTestHealthEvent()
+
+	err := s.Notify(e)
+
+	if err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	if len(receiver.requests) != 1 {
+		t.Fatalf("Requests count mismatch! Expected 1, but was %d", len(receiver.requests))
+	}
+
+	r := receiver.requests[0]
+	if !VerifyWebhookSignature(webhookSecret, r.timestamp, r.body, r.signature) {
+		t.Errorf("Signature %s is not valid for timestamp %s", r.signature, r.timestamp)
+	}
+
+	if VerifyWebhookSignature("wrong", r.timestamp, r.body, r.signature) {
+		t.Error("Signature should not be valid for wrong secret!")
+	}
+
+	var dto contract.HealthEvent
+	if err := json.Unmarshal(r.body, &dto); err != nil {
+		t.Fatalf("Cannot parse event %s", err)
+	}
+
+	expected := contract.HealthEvent{
+		MachineId:   e.MachineId.String(),
+		MachineName: "web-01",
+		Previous:    "Healthy",
+		Current:     "Danger",
+		OccurredAt:  "2021-06-01T12:00:00Z",
+	}
+	if dto != expected {
+		t.Errorf("Event mismatch! Expected %v, but was %v", expected, dto)
+	}
+}
+
+func TestWebhookSkipsUnchangedReports(t *testing.T) {
+	receiver := newWebhookReceiver(http.StatusOK)
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	s := newTestWebhookStrategy(server.URL)
+	e := createTestHealthEvent()
+	e.Previous = e.Current
+
+	if err := s.Notify(e); err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	if len(receiver.requests) != 0 {
+		t.Errorf("Unchanged report should not be posted, but was posted %d times", len(receiver.requests))
+	}
+}
+
+func TestWebhookSignatureCoversTimestamp(t *testing.T) {
+	body := []byte(`{"MachineId":"1"}`)
+	signature := SignWebhookPayload(webhookSecret, "1622548800", body)
+
+	if VerifyWebhookSignature(webhookSecret, "1622548801", body, signature) {
+		t.Error("Signature should not be valid for another timestamp!")
+	}
+
+	if VerifyWebhookSignature(webhookSecret, "1622548800", []byte(`{"MachineId":"2"}`), signature) {
+		t.Error("Signature should not be valid for another body!")
+	}
+}
+
+func TestWebhookRetriesWithBackoff(t *testing.T) {
+	receiver := newWebhookReceiver(http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusAccepted)
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	s := newTestWebhookStrategy(server.URL)
+	var delays []time.Duration
+	s.sleep = func(d time.Duration) { delays = append(delays, d) }
+
+	err := s.Notify(createTestHealthEvent())
+
+	if err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	expectedDelays := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}
+	if len(delays) != len(expectedDelays) || delays[0] != expectedDelays[0] || delays[1] != expectedDelays[1] {
+		t.Errorf("Delays mismatch! Expected %v, but was %v", expectedDelays, delays)
+	}
+
+	deliveries := s.Deliveries()
+	if len(deliveries) != 1 || deliveries[0].Attempts != 3 || deliveries[0].StatusCode != http.StatusAccepted || !deliveries[0].Succeeded() {
+		t.Errorf("Delivery log mismatch! Was %+v", deliveries)
+	}
+}
+
+func TestWebhookDoesNotRetryClientErrors(t *testing.T) {
+	receiver := newWebhookReceiver(http.StatusBadRequest)
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	s := newTestWebhookStrategy(server.URL)
+
+	err := s.Notify(createTestHealthEvent())
+
+	if err == nil {
+		t.Fatal("Expected err, but was nil")
+	}
+
+	if len(receiver.requests) != 1 {
+		t.Errorf("Requests count mismatch! Expected 1, but was %d", len(receiver.requests))
+	}
+
+	d := s.Deliveries()[0]
+	if d.Succeeded() || d.StatusCode != http.StatusBadRequest {
+		t.Errorf("Delivery mismatch! Was %+v", d)
+	}
+}
+
+func TestWebhookGivesUpAfterRetries(t *testing.T) {
+	receiver := newWebhookReceiver(http.StatusInternalServerError)
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	healthy := newWebhookReceiver(http.StatusOK)
+	healthyServer := httptest.NewServer(healthy)
+	defer healthyServer.Close()
+
+	s := newTestWebhookStrategy(server.URL, healthyServer.URL)
+
+	err := s.Notify(createTestHealthEvent())
+
+	if err == nil {
+		t.Fatal("Expected err, but was nil")
+	}
+
+	if len(receiver.requests) != 3 {
+		t.Errorf("Requests count mismatch! Expected 3, but was %d", len(receiver.requests))
+	}
+
+	if len(healthy.requests) != 1 {
+		t.Errorf("Healthy webhook should receive event despite failure of another one, but got %d requests", len(healthy.requests))
+	}
+}
+
+func TestWebhookTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	s := newTestWebhookStrategy(server.URL)
+	s.client.Timeout = 50 * time.Millisecond
+	s.config.Retries = 0
+
+	err := s.Notify(createTestHealthEvent())
+
+	if err == nil {
+		t.Fatal("Expected timeout err, but was nil")
+	}
+
+	if d := s.Deliveries()[0]; d.Attempts != 1 || d.StatusCode != 0 {
+		t.Errorf("Delivery mismatch! Was %+v", d)
+	}
+}
+
+func TestWebhookDeliveryLogIsBounded(t *testing.T) {
+	server := httptest.NewServer(newWebhookReceiver(http.StatusOK))
+	defer server.Close()
+
+	s := newTestWebhookStrategy(server.URL)
+	s.config.LogSize = 2
+
+	for i := 0; i < 5; i++ {
+		if err := s.Notify(createTestHealthEvent()); err != nil {
+			t.Fatalf("Expected nil err, but was %s", err)
+		}
+	}
+
+	if len(s.Deliveries()) != 2 {
+		t.Errorf("Delivery log size mismatch! Expected 2, but was %d", len(s.Deliveries()))
+	}
+}
+
+const webhookSecret string = "top-secret"
+
+func newTestWebhookStrategy(urls ...string) *WebhookNotificationStrategy {
+	c := DefaultWebhookConfig()
+	c.URLs = urls
+	c.Secret = webhookSecret
+	c.Retries = 2
+	c.Backoff = 10 * time.Millisecond
+
+	s := NewWebhookNotificationStrategy(c, log.New(io.Discard, "", 0))
+	s.sleep = func(time.Duration) {}
+	return s
+}
+
+func createTestHealthEvent() entities.HealthEvent {
+	return entities.HealthEvent{
+		MachineId:   entities.MachineId(uuid.New()),
+		MachineName: "web-01",
+		Previous:    entities.Healthy,
+		Current:     entities.Danger,
+		OccurredAt:  time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+type receivedWebhook struct {
+	timestamp, signature string
+	body                 []byte
+}
+
+// Test webhook, responding with given statuses in turn, the last one is repeated.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []receivedWebhook
+}
+
+func newWebhookReceiver(statuses ...int) *webhookReceiver {
+	return &webhookReceiver{statuses: statuses}
+}
+
+func (h *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.requests = append(h.requests, receivedWebhook{
+		timestamp: r.Header.Get(WebhookTimestampHeader),
+		signature: r.Header.Get(WebhookSignatureHeader),
+		body:      body,
+	})
+
+	status := h.statuses[len(h.statuses)-1]
+	if len(h.requests) <= len(h.statuses) {
+		status = h.statuses[len(h.requests)-1]
+	}
+	w.WriteHeader(status)
+}
@@ -0,0 +1,293 @@
+package cases
+
+import (
+	"context"
+	"dum/internal/machines/entities"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Tier of escalation policy, which is notified, when machine stays in Danger longer than After.
+type EscalationTier struct {
+	Name     string
+	After    time.Duration
+	Strategy entities.HealthNotificationStrategy
+}
+
+// Escalation policy, notifying its tiers one by one, while machine stays in Danger.
+type EscalationPolicy struct {
+	Name string
+	// Returns true, if policy applies to machine with given name, nil matches any machine.
+	Matches func(machineName string) bool
+	Tiers   []EscalationTier
+}
+
+// Open escalation of machine in Danger.
+type Escalation struct {
+	MachineId   entities.MachineId
+	MachineName string
+	Policy      string
+	// Beginning of health episode, which is escalated.
+	Since time.Time
+	// Count of already notified tiers.
+	Notified int
+}
+
+// Scheduler evaluates escalations over persisted machines. Escalation is opened for machine, which is in
+// Danger, and it's reset, when machine leaves Danger or its health episode is acknowledged. Count of notified
+// tiers is recorded per episode after every page, so restart neither repeats pages, nor loses tiers, which
+// came due, while service was down.
+type EscalationScheduler struct {
+	repository MachineRepository
+	policies   []EscalationPolicy
+	acks       Acknowledgments
+	progress   EscalationProgress
+	logger     *log.Logger
+	now        func() time.Time
+
+	// Serializes evaluations, which notify tiers without holding mu.
+	evaluation sync.Mutex
+
+	mu   sync.Mutex
+	open map[entities.MachineId]Escalation
+}
+
+// Notifies tiers of escalations, which are due, and resets escalations of recovered or acknowledged machines.
+func (s *EscalationScheduler) Evaluate() error {
+	machines, err := s.candidates()
+	if err != nil {
+		return err
+	}
+
+	s.evaluation.Lock()
+	defer s.evaluation.Unlock()
+
+	now := s.now()
+	open := map[entities.MachineId]Escalation{}
+	// machines, which keep their progress, though they have no open escalation in this round
+	kept := map[entities.MachineId]bool{}
+	for _, m := range machines {
+		policy := s.policyOf(m)
+		if policy == nil {
+			continue
+		}
+
+		acked, err := s.acks.IsAcknowledged(m.Id, m.GetHealthChangedAt())
+		if err != nil {
+			s.logger.Printf("Cannot check acknowledgment of machine %s - %s", m.Id, err)
+		}
+		if acked {
+			kept[m.Id] = true
+			continue
+		}
+
+		e, ok := s.open[m.Id]
+		if !ok || !e.Since.Equal(m.GetHealthChangedAt()) || e.Policy != policy.Name {
+			e, err = s.openEscalation(m, policy, ok && e.Since.Equal(m.GetHealthChangedAt()))
+			if err != nil {
+				s.logger.Printf("Cannot load escalation of machine %s, retrying later - %s", m.Id, err)
+				kept[m.Id] = true
+				continue
+			}
+		}
+
+		for e.Notified < len(policy.Tiers) && now.Sub(e.Since) >= policy.Tiers[e.Notified].After {
+			tier := policy.Tiers[e.Notified]
+			if err := s.notify(tier, m, now); err != nil {
+				s.logger.Printf("Escalation of machine %s to %s failed, retrying later - %s", m.Id, tier.Name, err)
+				break
+			}
+			e.Notified++
+
+			if err := s.progress.SaveEscalation(m.Id, e.Since, e.Notified); err != nil {
+				s.logger.Printf("Cannot record escalation of machine %s to %s - %s", m.Id, tier.Name, err)
+			}
+		}
+
+		open[m.Id] = e
+	}
+
+	for id := range s.open {
+		if _, ok := open[id]; ok || kept[id] {
+			continue
+		}
+
+		if err := s.progress.DeleteEscalation(id); err != nil {
+			s.logger.Printf("Cannot reset escalation of machine %s - %s", id, err)
+		}
+	}
+
+	s.mu.Lock()
+	s.open = open
+	s.mu.Unlock()
+	return nil
+}
+
+// Returns open escalations, the oldest one goes first.
+func (s *EscalationScheduler) Escalations() []Escalation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := []Escalation{}
+	for _, e := range s.open {
+		result = append(result, e)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Since.Before(result[j].Since) })
+
+	return result
+}
+
+// Evaluates escalations every interval until context is done.
+func (s *EscalationScheduler) Start(ctx context.Context, wg *sync.WaitGroup, interval time.Duration) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Evaluate(); err != nil {
+					s.logger.Printf("Cannot evaluate escalations - %s", err)
+				}
+			}
+		}
+	}()
+}
+
+// Closes strategies of tiers, which need it.
+func (s *EscalationScheduler) Close() error {
+	var err error
+	for _, p := range s.policies {
+		for _, t := range p.Tiers {
+			if c, ok := t.Strategy.(io.Closer); ok {
+				if closeErr := c.Close(); closeErr != nil {
+					err = closeErr
+				}
+			}
+		}
+	}
+
+	return err
+}
+
+// Opens escalation of machine's episode, continuing from recorded progress. Escalation, whose policy
+// was changed, starts from its first tier again.
+func (s *EscalationScheduler) openEscalation(m *entities.Machine, policy *EscalationPolicy, policyChanged bool) (Escalation, error) {
+	e := Escalation{MachineId: m.Id, MachineName: m.Name, Policy: policy.Name, Since: m.GetHealthChangedAt()}
+	if policyChanged {
+		return e, s.progress.SaveEscalation(m.Id, e.Since, 0)
+	}
+
+	notified, err := s.progress.LoadEscalation(m.Id, e.Since)
+	if err != nil {
+		return e, err
+	}
+
+	if notified > len(policy.Tiers) {
+		notified = len(policy.Tiers)
+	}
+	e.Notified = notified
+
+	return e, nil
+}
+
+// Returns machines, which can be escalated. Repository with health index returns machines in Danger only,
+// others return the whole fleet, which is filtered by policyOf.
+func (s *EscalationScheduler) candidates() ([]*entities.Machine, error) {
+	index, ok := s.repository.(HealthLevelIndex)
+	if !ok {
+		return s.repository.List()
+	}
+
+	ids, err := index.FindByHealthLevel(entities.Danger)
+	if err != nil {
+		return nil, err
+	}
+
+	machines := []*entities.Machine{}
+	for _, id := range ids {
+		m, err := s.repository.Load(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if m != nil {
+			machines = append(machines, m)
+		}
+	}
+
+	return machines, nil
+}
+
+// Returns the first policy, which applies to machine, or nil, if machine should not be escalated.
+func (s *EscalationScheduler) policyOf(m *entities.Machine) *EscalationPolicy {
+	if m.IsDecommissioned() || m.GetHealthLevel() != entities.Danger || m.GetHealthChangedAt().IsZero() {
+		return nil
+	}
+
+	for i, p := range s.policies {
+		if p.Matches == nil || p.Matches(m.Name) {
+			return &s.policies[i]
+		}
+	}
+
+	return nil
+}
+
+func (s *EscalationScheduler) notify(tier EscalationTier, m *entities.Machine, now time.Time) error {
+	return tier.Strategy.Notify(entities.HealthEvent{
+		MachineId:      m.Id,
+		MachineName:    m.Name,
+		Previous:       m.GetHealthLevel(),
+		Current:        m.GetHealthLevel(),
+		OccurredAt:     now,
+		Since:          m.GetHealthChangedAt(),
+		MissingUpdates: m.GetMissingUpdates(),
+		Escalation:     tier.Name,
+	})
+}
+
+func newEscalationScheduler(r MachineRepository, policies []EscalationPolicy, a Acknowledgments, progress EscalationProgress, logger *log.Logger, now func() time.Time) (*EscalationScheduler, error) {
+	sorted := []EscalationPolicy{}
+	for _, p := range policies {
+		if len(p.Tiers) == 0 {
+			return nil, fmt.Errorf("escalation policy %s has no tiers", p.Name)
+		}
+
+		tiers := make([]EscalationTier, len(p.Tiers))
+		copy(tiers, p.Tiers)
+		for _, t := range tiers {
+			if t.Name == "" || t.Strategy == nil {
+				return nil, errors.New("escalation tier should have name and notification strategy")
+			}
+		}
+		sort.SliceStable(tiers, func(i, j int) bool { return tiers[i].After < tiers[j].After })
+
+		p.Tiers = tiers
+		sorted = append(sorted, p)
+	}
+
+	return &EscalationScheduler{
+		repository: r,
+		policies:   sorted,
+		acks:       a,
+		progress:   progress,
+		logger:     logger,
+		now:        now,
+		open:       map[entities.MachineId]Escalation{},
+	}, nil
+}
+
+// Creates scheduler, tiers of every policy are ordered by their delays.
+func NewEscalationScheduler(r MachineRepository, policies []EscalationPolicy, a Acknowledgments, progress EscalationProgress, logger *log.Logger) (*EscalationScheduler, error) {
+	return newEscalationScheduler(r, policies, a, progress, logger, time.Now)
+}
@@ -0,0 +1,390 @@
+package cases
+
+import (
+	"dum/internal/machines/entities"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestEscalationNotifiesTiersInOrder(t *testing.T) {
+	clock := newManualClock()
+	machine := createDangerMachine(clock.now())
+	tier2, management := &escalationStrategyMock{}, &escalationStrategyMock{}
+	s := newTestEscalationScheduler(t, &repositoryMock{loadedMachine: machine}, clock,
+		EscalationTier{Name: "management", After: 2 * time.Hour, Strategy: management},
+		EscalationTier{Name: "tier 2", After: 30 * time.Minute, Strategy: tier2},
+	)
+
+	for _, step := range []time.Duration{10 * time.Minute, 21 * time.Minute, 15 * time.Minute} {
+		clock.advance(step)
+		if err := s.Evaluate(); err != nil {
+			t.Fatalf("Expected nil err, but was %s", err)
+		}
+	}
+
+	if len(tier2.events) != 1 || len(management.events) != 0 {
+		t.Fatalf("Notifications count mismatch! Expected 1 and 0, but was %d and %d", len(tier2.events), len(management.events))
+	}
+
+	e := tier2.events[0]
+	if e.Escalation != "tier 2" || !e.Since.Equal(machine.GetHealthChangedAt()) || e.Current != entities.Danger {
+		t.Errorf("Escalation event mismatch! Was %+v", e)
+	}
+
+	clock.advance(2 * time.Hour)
+	s.Evaluate()
+
+	if len(tier2.events) != 1 || len(management.events) != 1 {
+		t.Errorf("Notifications count mismatch! Expected 1 and 1, but was %d and %d", len(tier2.events), len(management.events))
+	}
+
+	if escalations := s.Escalations(); len(escalations) != 1 || escalations[0].Notified != 2 {
+		t.Errorf("Open escalations mismatch! Was %+v", escalations)
+	}
+}
+
+func TestEscalationResetsOnRecovery(t *testing.T) {
+	clock := newManualClock()
+	repository := &repositoryMock{loadedMachine: createDangerMachine(clock.now())}
+	tier2 := &escalationStrategyMock{}
+	s := newTestEscalationScheduler(t, repository, clock, EscalationTier{Name: "tier 2", After: 30 * time.Minute, Strategy: tier2})
+
+	s.Evaluate()
+	clock.advance(time.Hour)
+	s.Evaluate()
+
+	repository.loadedMachine = entities.CreateMachine(repository.loadedMachine.Id, "web-01", []entities.MissingUpdate{})
+	repository.loadedMachine.SetHealthChangedAt(clock.now())
+	s.Evaluate()
+
+	if escalations := s.Escalations(); len(escalations) != 0 {
+		t.Fatalf("Escalation should be reset, but was %+v", escalations)
+	}
+
+	if progress := s.progress.(*escalationProgressMock); len(progress.episodes) != 0 {
+		t.Fatalf("Progress should be forgotten, but was %+v", progress.episodes)
+	}
+
+	clock.advance(time.Minute)
+	id := repository.loadedMachine.Id
+	repository.loadedMachine = createDangerMachine(clock.now())
+	repository.loadedMachine.Id = id
+	clock.advance(30 * time.Minute)
+	s.Evaluate()
+
+	if len(tier2.events) != 2 {
+		t.Errorf("New episode should be escalated again, but notifications count was %d", len(tier2.events))
+	}
+}
+
+func TestEscalationResetsOnAcknowledgment(t *testing.T) {
+	clock := newManualClock()
+	machine := createDangerMachine(clock.now())
+	acks := newAcknowledgmentsMock()
+	tier2, management := &escalationStrategyMock{}, &escalationStrategyMock{}
+	s, _ := newEscalationScheduler(&repositoryMock{loadedMachine: machine}, []EscalationPolicy{{
+		Name: "default",
+		Tiers: []EscalationTier{
+			{Name: "tier 2", After: 30 * time.Minute, Strategy: tier2},
+			{Name: "management", After: 2 * time.Hour, Strategy: management},
+		},
+	}}, acks, newEscalationProgressMock(), log.Default(), clock.now)
+
+	s.Evaluate()
+	clock.advance(time.Hour)
+	s.Evaluate()
+	acks.acked[machine.Id] = machine.GetHealthChangedAt()
+	clock.advance(2 * time.Hour)
+	s.Evaluate()
+
+	if len(tier2.events) != 1 || len(management.events) != 0 {
+		t.Errorf("Notifications count mismatch! Expected 1 and 0, but was %d and %d", len(tier2.events), len(management.events))
+	}
+
+	if escalations := s.Escalations(); len(escalations) != 0 {
+		t.Errorf("Escalation should be reset, but was %+v", escalations)
+	}
+}
+
+func TestEscalationRetriesFailedTier(t *testing.T) {
+	clock := newManualClock()
+	tier2 := &escalationStrategyMock{errorsLeft: 1}
+	s := newTestEscalationScheduler(t, &repositoryMock{loadedMachine: createDangerMachine(clock.now())}, clock,
+		EscalationTier{Name: "tier 2", After: 30 * time.Minute, Strategy: tier2},
+	)
+
+	s.Evaluate()
+	clock.advance(time.Hour)
+	s.Evaluate()
+	clock.advance(time.Minute)
+	s.Evaluate()
+	s.Evaluate()
+
+	if tier2.calls != 2 || len(tier2.events) != 1 {
+		t.Errorf("Tier should be notified once after failure, but calls were %d", tier2.calls)
+	}
+}
+
+func TestEscalationDoesNotRepageRecordedTiersAfterRestart(t *testing.T) {
+	clock := newManualClock()
+	repository := &repositoryMock{loadedMachine: createDangerMachine(clock.now())}
+	progress := newEscalationProgressMock()
+	tier2, management := &escalationStrategyMock{}, &escalationStrategyMock{}
+	tiers := []EscalationTier{
+		{Name: "tier 2", After: 30 * time.Minute, Strategy: tier2},
+		{Name: "management", After: 2 * time.Hour, Strategy: management},
+	}
+
+	s := newTestEscalationSchedulerWithProgress(t, repository, progress, clock, tiers...)
+	s.Evaluate()
+	clock.advance(time.Hour)
+	s.Evaluate()
+
+	s = newTestEscalationSchedulerWithProgress(t, repository, progress, clock, tiers...)
+	s.Evaluate()
+
+	if len(tier2.events) != 1 {
+		t.Fatalf("Tier, which was paged before restart, should not be paged again, but was %+v", tier2.events)
+	}
+
+	if escalations := s.Escalations(); len(escalations) != 1 || escalations[0].Notified != 1 {
+		t.Fatalf("Open escalations mismatch! Was %+v", escalations)
+	}
+
+	clock.advance(time.Hour)
+	s.Evaluate()
+
+	if len(management.events) != 1 {
+		t.Errorf("Tier, which is due after restart, should be notified, but count was %d", len(management.events))
+	}
+}
+
+func TestEscalationPagesTiersDueDuringDowntime(t *testing.T) {
+	clock := newManualClock()
+	machine := createDangerMachine(clock.now().Add(-time.Hour))
+	progress := newEscalationProgressMock()
+	tier2, management := &escalationStrategyMock{}, &escalationStrategyMock{}
+	s := newTestEscalationSchedulerWithProgress(t, &repositoryMock{loadedMachine: machine}, progress, clock,
+		EscalationTier{Name: "tier 2", After: 30 * time.Minute, Strategy: tier2},
+		EscalationTier{Name: "management", After: 2 * time.Hour, Strategy: management},
+	)
+
+	s.Evaluate()
+
+	if len(tier2.events) != 1 || len(management.events) != 0 {
+		t.Fatalf("Notifications count mismatch! Expected 1 and 0, but was %d and %d", len(tier2.events), len(management.events))
+	}
+
+	if notified, _ := progress.LoadEscalation(machine.Id, machine.GetHealthChangedAt()); notified != 1 {
+		t.Errorf("Recorded progress mismatch! Expected %d, but was %d", 1, notified)
+	}
+}
+
+func TestEscalationNotifiesTiersWithoutLock(t *testing.T) {
+	clock := newManualClock()
+	tier2 := &escalationStrategyMock{}
+	s := newTestEscalationScheduler(t, &repositoryMock{loadedMachine: createDangerMachine(clock.now())}, clock,
+		EscalationTier{Name: "tier 2", After: 30 * time.Minute, Strategy: tier2},
+	)
+	tier2.onNotify = func() { s.Escalations() }
+
+	s.Evaluate()
+	clock.advance(time.Hour)
+
+	done := make(chan struct{})
+	go func() {
+		s.Evaluate()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Escalations should be readable, while tier is notified")
+	}
+
+	if len(tier2.events) != 1 {
+		t.Errorf("Notifications count mismatch! Expected 1, but was %d", len(tier2.events))
+	}
+}
+
+func TestEscalationSkipsMachinesOutOfPolicy(t *testing.T) {
+	clock := newManualClock()
+	tier2 := &escalationStrategyMock{}
+	unknown := createDangerMachine(time.Time{})
+	s, _ := newEscalationScheduler(&repositoryMock{loadedMachine: unknown}, []EscalationPolicy{{
+		Name:  "default",
+		Tiers: []EscalationTier{{Name: "tier 2", After: time.Minute, Strategy: tier2}},
+	}}, newAcknowledgmentsMock(), newEscalationProgressMock(), log.Default(), clock.now)
+
+	clock.advance(time.Hour)
+	s.Evaluate()
+
+	other := createDangerMachine(clock.now().Add(-time.Hour))
+	s, _ = newEscalationScheduler(&repositoryMock{loadedMachine: other}, []EscalationPolicy{{
+		Name:    "desktops",
+		Matches: func(name string) bool { return name != "web-01" },
+		Tiers:   []EscalationTier{{Name: "tier 2", After: time.Minute, Strategy: tier2}},
+	}}, newAcknowledgmentsMock(), newEscalationProgressMock(), log.Default(), clock.now)
+	s.Evaluate()
+
+	if len(tier2.events) != 0 {
+		t.Errorf("Machines without health change time or policy should not be escalated, but was %+v", tier2.events)
+	}
+}
+
+func TestInvalidEscalationPolicy(t *testing.T) {
+	for _, p := range []EscalationPolicy{
+		{Name: "empty"},
+		{Name: "unnamed", Tiers: []EscalationTier{{After: time.Minute, Strategy: &escalationStrategyMock{}}}},
+		{Name: "silent", Tiers: []EscalationTier{{Name: "tier 2", After: time.Minute}}},
+	} {
+		if _, err := NewEscalationScheduler(&repositoryMock{}, []EscalationPolicy{p}, newAcknowledgmentsMock(), newEscalationProgressMock(), log.Default()); err == nil {
+			t.Errorf("Expected error for policy %s, but was nil", p.Name)
+		}
+	}
+}
+
+func TestEscalationUsesHealthIndex(t *testing.T) {
+	clock := newManualClock()
+	machine := createDangerMachine(clock.now())
+	repository := &indexedRepositoryMock{repositoryMock: repositoryMock{loadedMachine: machine}, danger: []entities.MachineId{machine.Id}}
+	tier2 := &escalationStrategyMock{}
+	s := newTestEscalationScheduler(t, repository, clock, EscalationTier{Name: "tier 2", After: 30 * time.Minute, Strategy: tier2})
+
+	s.Evaluate()
+	clock.advance(time.Hour)
+	if err := s.Evaluate(); err != nil {
+		t.Fatalf("Expected nil err, but was %s", err)
+	}
+
+	if len(tier2.events) != 1 {
+		t.Errorf("Notifications count mismatch! Expected %d, but was %d", 1, len(tier2.events))
+	}
+
+	if repository.listCalls != 0 {
+		t.Errorf("Machines should be found by index, but the whole fleet was listed %d times", repository.listCalls)
+	}
+}
+
+func newTestEscalationScheduler(t *testing.T, r MachineRepository, c *manualClock, tiers ...EscalationTier) *EscalationScheduler {
+	return newTestEscalationSchedulerWithProgress(t, r, newEscalationProgressMock(), c, tiers...)
+}
+
+func newTestEscalationSchedulerWithProgress(t *testing.T, r MachineRepository, p *escalationProgressMock, c *manualClock, tiers ...EscalationTier) *EscalationScheduler {
+	s, err := newEscalationScheduler(r, []EscalationPolicy{{Name: "default", Tiers: tiers}}, newAcknowledgmentsMock(), p, log.Default(), c.now)
+	if err != nil {
+		t.Fatalf("Cannot create scheduler %s", err)
+	}
+
+	return s
+}
+
+func createDangerMachine(since time.Time) *entities.Machine {
+	m := entities.CreateMachine(entities.MachineId(uuid.New()), "web-01", []entities.MissingUpdate{
+		{UpdateId: uuid.New(), Severity: entities.Critical, Duration: time.Hour},
+	})
+	m.SetHealthChangedAt(since)
+
+	return m
+}
+
+type manualClock struct {
+	t time.Time
+}
+
+func newManualClock() *manualClock {
+	return &manualClock{time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *manualClock) now() time.Time {
+	return c.t
+}
+
+func (c *manualClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+type escalationStrategyMock struct {
+	events     []entities.HealthEvent
+	calls      int
+	errorsLeft int
+	onNotify   func()
+}
+
+func (m *escalationStrategyMock) Notify(e entities.HealthEvent) error {
+	m.calls++
+	if m.onNotify != nil {
+		m.onNotify()
+	}
+	if m.errorsLeft > 0 {
+		m.errorsLeft--
+		return errReport
+	}
+
+	m.events = append(m.events, e)
+	return nil
+}
+
+type acknowledgmentsMock struct {
+	acked map[entities.MachineId]time.Time
+}
+
+func newAcknowledgmentsMock() *acknowledgmentsMock {
+	return &acknowledgmentsMock{acked: map[entities.MachineId]time.Time{}}
+}
+
+func (a *acknowledgmentsMock) IsAcknowledged(id entities.MachineId, since time.Time) (bool, error) {
+	acked, ok := a.acked[id]
+	return ok && acked.Equal(since), nil
+}
+
+type escalationProgressMock struct {
+	episodes map[entities.MachineId]Escalation
+}
+
+func newEscalationProgressMock() *escalationProgressMock {
+	return &escalationProgressMock{episodes: map[entities.MachineId]Escalation{}}
+}
+
+func (p *escalationProgressMock) LoadEscalation(id entities.MachineId, since time.Time) (int, error) {
+	e, ok := p.episodes[id]
+	if !ok || !e.Since.Equal(since) {
+		return 0, nil
+	}
+
+	return e.Notified, nil
+}
+
+func (p *escalationProgressMock) SaveEscalation(id entities.MachineId, since time.Time, notified int) error {
+	p.episodes[id] = Escalation{MachineId: id, Since: since, Notified: notified}
+	return nil
+}
+
+func (p *escalationProgressMock) DeleteEscalation(id entities.MachineId) error {
+	delete(p.episodes, id)
+	return nil
+}
+
+// Repository with health index, which counts listing of the whole fleet.
+type indexedRepositoryMock struct {
+	repositoryMock
+	danger    []entities.MachineId
+	listCalls int
+}
+
+func (r *indexedRepositoryMock) List() ([]*entities.Machine, error) {
+	r.listCalls++
+	return r.repositoryMock.List()
+}
+
+func (r *indexedRepositoryMock) FindByHealthLevel(level entities.HealthLevel) ([]entities.MachineId, error) {
+	if level != entities.Danger {
+		return []entities.MachineId{}, nil
+	}
+
+	return r.danger, nil
+}
@@ -0,0 +1,44 @@
+package cases
+
+import (
+	"dum/internal/machines/entities"
+	"errors"
+)
+
+// Error for commands, targeting unknown machine.
+var ErrMachineNotFound error = errors.New("machine is not found")
+
+// Command for removing machine with all its history.
+type DeleteMachineCommand struct {
+	MachineId  entities.MachineId
+	Repository MachineRepository
+}
+
+func (c *DeleteMachineCommand) Execute() error {
+	return c.Repository.Delete(c.MachineId)
+}
+
+// Command for retiring machine. Decommissioned machine keeps its history.
+type DecommissionMachineCommand struct {
+	MachineId  entities.MachineId
+	Repository MachineRepository
+}
+
+func (c *DecommissionMachineCommand) Execute() error {
+	return retryOnConflict(conflictAttempts, c.decommission)
+}
+
+func (c *DecommissionMachineCommand) decommission() error {
+	machine, err := c.Repository.Load(c.MachineId)
+	if err != nil {
+		return err
+	}
+
+	if machine == nil {
+		return ErrMachineNotFound
+	}
+
+	machine.Decommission()
+
+	return c.Repository.Save(machine)
+}
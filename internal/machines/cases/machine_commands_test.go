@@ -0,0 +1,90 @@
+package cases
+
+import (
+	"dum/internal/machines/entities"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestDeleteMachine(t *testing.T) {
+	repositoryMock := repositoryMock{}
+	id := entities.MachineId(uuid.New())
+
+	command := DeleteMachineCommand{
+		MachineId:  id,
+		Repository: &repositoryMock,
+	}
+
+	err := command.Execute()
+
+	if err != nil {
+		t.Errorf("Execute should not return error %s!", err)
+	}
+
+	if repositoryMock.deletedId == nil || *repositoryMock.deletedId != id {
+		t.Errorf("Machine %s should be deleted!", id)
+	}
+}
+
+func TestDecommissionMachine(t *testing.T) {
+	repositoryMock := repositoryMock{
+		loadedMachine: entities.CreateMachine(entities.MachineId(uuid.New()), existingMachineName, []entities.MissingUpdate{}),
+	}
+
+	command := DecommissionMachineCommand{
+		MachineId:  repositoryMock.loadedMachine.Id,
+		Repository: &repositoryMock,
+	}
+
+	err := command.Execute()
+
+	if err != nil {
+		t.Errorf("Execute should not return error %s!", err)
+	}
+
+	if repositoryMock.savedMachine == nil {
+		t.Errorf("Machine should be saved!")
+		return
+	}
+
+	if !repositoryMock.savedMachine.IsDecommissioned() {
+		t.Errorf("Saved machine should be decommissioned!")
+	}
+}
+
+func TestDecommissionUnknownMachine(t *testing.T) {
+	repositoryMock := repositoryMock{}
+
+	command := DecommissionMachineCommand{
+		MachineId:  entities.MachineId(uuid.New()),
+		Repository: &repositoryMock,
+	}
+
+	err := command.Execute()
+
+	if err != ErrMachineNotFound {
+		t.Errorf("Error mismatch! Expected %s, but was %s!", ErrMachineNotFound, err)
+	}
+
+	if repositoryMock.savedMachine != nil {
+		t.Errorf("Should not try to save unknown machine!")
+	}
+}
+
+func TestDecommissionReturnsLoadError(t *testing.T) {
+	repositoryMock := repositoryMock{
+		shouldReturnLoadError: true,
+	}
+
+	command := DecommissionMachineCommand{
+		MachineId:  entities.MachineId(uuid.New()),
+		Repository: &repositoryMock,
+	}
+
+	err := command.Execute()
+
+	if err != errLoad {
+		t.Errorf("Error mismatch! Expected %s, but was %s!", errLoad, err)
+	}
+}
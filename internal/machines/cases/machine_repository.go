@@ -1,6 +1,9 @@
 package cases
 
-import "dum/internal/machines/entities"
+import (
+	"dum/internal/machines/entities"
+	"errors"
+)
 
 // Interface for accessing and persisting machine entities. Should implement optimistic locking strategy
 // to support horizontal scaling of service.
@@ -8,6 +11,32 @@ type MachineRepository interface {
 	// Loading machine entity from some storage.
 	Load(id entities.MachineId) (*entities.Machine, error)
 
+	// Loading all machines, including decommissioned ones, ordered by id.
+	List() ([]*entities.Machine, error)
+
 	// Saving machine enitity to some storage.
 	Save(machine *entities.Machine) error
+
+	// Deleting machine entity from some storage. Deleting of absent machine is not an error.
+	Delete(id entities.MachineId) error
+}
+
+// Repository, which finds active machines by health level without reading the whole fleet.
+type HealthLevelIndex interface {
+	// Returns ids of active machines with specific health level.
+	FindByHealthLevel(level entities.HealthLevel) ([]entities.MachineId, error)
+}
+
+// Error for repositories, which cannot get access to storage in time, because it's locked by someone else.
+// Operation can be retried later.
+var ErrStoreBusy error = errors.New("store is busy")
+
+// Counters of repository cache usage.
+type CacheStats struct {
+	Hits          uint64
+	Misses        uint64
+	Evictions     uint64
+	Invalidations uint64
+	Size          int
+	Capacity      int
 }
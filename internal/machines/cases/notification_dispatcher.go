@@ -0,0 +1,222 @@
+package cases
+
+import (
+	"dum/internal/machines/entities"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Settings of asynchronous notification dispatch.
+type DispatcherConfig struct {
+	// Count of dispatching workers, notifications are sent synchronously, if it's zero.
+	Workers int
+	// Count of events, waiting for dispatch, new events are dropped, when queue is full.
+	QueueSize int
+	// Time, which noteworthy event waits for free place in full queue, before it's dropped. Unchanged
+	// events are dropped right away.
+	EnqueueTimeout time.Duration
+}
+
+// Returns dispatcher settings, suitable for a single service instance.
+func DefaultDispatcherConfig() DispatcherConfig {
+	return DispatcherConfig{
+		Workers:        4,
+		QueueSize:      1000,
+		EnqueueTimeout: 5 * time.Second,
+	}
+}
+
+// Counters of notification dispatcher.
+type DispatcherStats struct {
+	Queued, Delivered, Failed, Dropped uint64
+	QueueLength, QueueSize, Workers    int
+}
+
+// Dispatcher queues health events and notifies base strategy from its own workers, so reporting
+// machine never waits for slow notification channels. Events of the same machine are always handled
+// by the same worker, so they are notified in order they occured. Every event is notified once,
+// strategies retry failed destinations on their own, so receivers, which got event, don't get it again.
+type NotificationDispatcher struct {
+	base   entities.HealthNotificationStrategy
+	config DispatcherConfig
+	logger *log.Logger
+	queues []chan entities.HealthEvent
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+
+	statsMu sync.Mutex
+	stats   DispatcherStats
+}
+
+// Puts event to queue of its machine. Unchanged event is dropped and logged, if queue is full or dispatcher
+// is closed. Noteworthy event waits for free place in queue and returns an error, if it's dropped, because
+// report is saved already and nobody would send it again.
+func (d *NotificationDispatcher) Notify(e entities.HealthEvent) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		return d.drop(e, "dispatcher is closed")
+	}
+
+	queue := d.queues[queueIndex(e.MachineId, len(d.queues))]
+	select {
+	case queue <- e:
+		d.count(func(s *DispatcherStats) { s.Queued++ })
+		return nil
+	default:
+	}
+
+	if !e.Noteworthy() {
+		return d.drop(e, "queue is full")
+	}
+
+	timer := time.NewTimer(d.config.EnqueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case queue <- e:
+		d.count(func(s *DispatcherStats) { s.Queued++ })
+		return nil
+	case <-timer.C:
+		return d.drop(e, fmt.Sprintf("queue is full for %s", d.config.EnqueueTimeout))
+	}
+}
+
+// Stops accepting events, waits until queued events are notified and closes base strategy, if it needs it.
+func (d *NotificationDispatcher) Close() error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	for _, q := range d.queues {
+		close(q)
+	}
+	d.mu.Unlock()
+
+	d.wg.Wait()
+
+	if c, ok := d.base.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+func (d *NotificationDispatcher) Stats() DispatcherStats {
+	d.statsMu.Lock()
+	s := d.stats
+	d.statsMu.Unlock()
+
+	for _, q := range d.queues {
+		s.QueueLength += len(q)
+	}
+
+	return s
+}
+
+func (d *NotificationDispatcher) start() {
+	for _, q := range d.queues {
+		d.wg.Add(1)
+		go d.work(q)
+	}
+}
+
+func (d *NotificationDispatcher) work(queue <-chan entities.HealthEvent) {
+	defer d.wg.Done()
+
+	for e := range queue {
+		if err := d.safeNotify(e); err != nil {
+			d.count(func(s *DispatcherStats) { s.Failed++ })
+			d.logger.Printf("Cannot notify about health of machine %s - %s", e.MachineId, err)
+		} else {
+			d.count(func(s *DispatcherStats) { s.Delivered++ })
+		}
+	}
+}
+
+func (d *NotificationDispatcher) safeNotify(e entities.HealthEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+
+	return d.base.Notify(e)
+}
+
+// Counts and logs dropped event, returns an error for noteworthy one.
+func (d *NotificationDispatcher) drop(e entities.HealthEvent, reason string) error {
+	d.count(func(s *DispatcherStats) { s.Dropped++ })
+	d.logger.Printf("Dropped health event of machine %s - %s", e.MachineId, reason)
+
+	if e.Noteworthy() {
+		return fmt.Errorf("health event of machine %s is dropped - %s", e.MachineId, reason)
+	}
+
+	return nil
+}
+
+func (d *NotificationDispatcher) count(f func(s *DispatcherStats)) {
+	d.statsMu.Lock()
+	defer d.statsMu.Unlock()
+
+	f(&d.stats)
+}
+
+func queueIndex(id entities.MachineId, count int) int {
+	h := fnv.New32a()
+	h.Write(id[:])
+	return int(h.Sum32() % uint32(count))
+}
+
+func newNotificationDispatcher(base entities.HealthNotificationStrategy, c DispatcherConfig, logger *log.Logger) (*NotificationDispatcher, error) {
+	if c.Workers <= 0 {
+		return nil, fmt.Errorf("dispatcher needs at least one worker, but was %d", c.Workers)
+	}
+
+	if c.QueueSize < c.Workers {
+		return nil, fmt.Errorf("dispatcher queue size %d is less than count of workers %d", c.QueueSize, c.Workers)
+	}
+
+	d := &NotificationDispatcher{
+		base:   base,
+		config: c,
+		logger: logger,
+		stats: DispatcherStats{
+			QueueSize: c.QueueSize,
+			Workers:   c.Workers,
+		},
+	}
+
+	for i := 0; i < c.Workers; i++ {
+		// the first queues get the rest of division, so total capacity is exactly the queue size
+		size := c.QueueSize / c.Workers
+		if i < c.QueueSize%c.Workers {
+			size++
+		}
+		d.queues = append(d.queues, make(chan entities.HealthEvent, size))
+	}
+
+	return d, nil
+}
+
+// Creates dispatcher and starts its workers, dispatcher should be closed to notify queued events.
+func NewNotificationDispatcher(base entities.HealthNotificationStrategy, c DispatcherConfig, logger *log.Logger) (*NotificationDispatcher, error) {
+	d, err := newNotificationDispatcher(base, c, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	d.start()
+	return d, nil
+}
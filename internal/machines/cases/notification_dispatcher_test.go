@@ -0,0 +1,215 @@
+package cases
+
+import (
+	"dum/internal/machines/entities"
+	"errors"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestDispatcherDoesNotWaitForSlowStrategy(t *testing.T) {
+	base := newDispatchedStrategyMock()
+	base.release = make(chan struct{})
+	d, _ := NewNotificationDispatcher(base, DispatcherConfig{Workers: 2, QueueSize: 10}, log.Default())
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			d.Notify(createDispatchedEvent(entities.MachineId(uuid.New())))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Notify should not wait for base strategy!")
+	}
+
+	close(base.release)
+	d.Close()
+
+	if stats := d.Stats(); stats.Queued != 5 || stats.Delivered != 5 || stats.QueueLength != 0 {
+		t.Errorf("Stats mismatch! Was %+v", stats)
+	}
+}
+
+func TestDispatcherKeepsOrderOfMachineEvents(t *testing.T) {
+	base := newDispatchedStrategyMock()
+	d, _ := NewNotificationDispatcher(base, DispatcherConfig{Workers: 4, QueueSize: 100}, log.Default())
+	id := entities.MachineId(uuid.New())
+	start := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 50; i++ {
+		e := createDispatchedEvent(id)
+		e.OccurredAt = start.Add(time.Duration(i) * time.Second)
+		d.Notify(e)
+		d.Notify(createDispatchedEvent(entities.MachineId(uuid.New())))
+	}
+	d.Close()
+
+	var last time.Time
+	for _, e := range base.notified() {
+		if e.MachineId != id {
+			continue
+		}
+
+		if e.OccurredAt.Before(last) {
+			t.Fatalf("Events of machine are out of order, %s goes after %s", e.OccurredAt, last)
+		}
+		last = e.OccurredAt
+	}
+}
+
+func TestDispatcherNotifiesEventOnce(t *testing.T) {
+	base := newDispatchedStrategyMock()
+	base.failuresLeft = 1
+	d, _ := NewNotificationDispatcher(base, DispatcherConfig{Workers: 1, QueueSize: 2}, log.Default())
+
+	d.Notify(createDispatchedEvent(entities.MachineId(uuid.New())))
+	d.Notify(createDispatchedEvent(entities.MachineId(uuid.New())))
+	d.Close()
+
+	if stats := d.Stats(); stats.Failed != 1 || stats.Delivered != 1 {
+		t.Errorf("Stats mismatch! Was %+v", stats)
+	}
+
+	if calls := base.calls(); calls != 2 {
+		t.Errorf("Failed event should not be notified again, but strategy was called %d times", calls)
+	}
+}
+
+func TestDispatcherDropsEventsOverQueueSize(t *testing.T) {
+	base := newDispatchedStrategyMock()
+	d, _ := newNotificationDispatcher(base, DispatcherConfig{Workers: 1, QueueSize: 2}, log.Default())
+
+	for i := 0; i < 2; i++ {
+		d.Notify(createDispatchedEvent(entities.MachineId(uuid.New())))
+	}
+
+	if err := d.Notify(createDispatchedEvent(entities.MachineId(uuid.New()))); err == nil {
+		t.Error("Dropped noteworthy event should be reported!")
+	}
+
+	unchanged := createDispatchedEvent(entities.MachineId(uuid.New()))
+	unchanged.Previous = unchanged.Current
+	if err := d.Notify(unchanged); err != nil {
+		t.Errorf("Dropped unchanged event should not be reported, but got %s", err)
+	}
+
+	if stats := d.Stats(); stats.Queued != 2 || stats.Dropped != 2 || stats.QueueLength != 2 {
+		t.Errorf("Stats mismatch! Was %+v", stats)
+	}
+
+	d.start()
+	d.Close()
+	d.Notify(createDispatchedEvent(entities.MachineId(uuid.New())))
+
+	if stats := d.Stats(); stats.Delivered != 2 || stats.Dropped != 3 {
+		t.Errorf("Stats mismatch! Was %+v", stats)
+	}
+}
+
+func TestDispatcherWaitsForPlaceForNoteworthyEvent(t *testing.T) {
+	base := newDispatchedStrategyMock()
+	d, _ := newNotificationDispatcher(base, DispatcherConfig{Workers: 1, QueueSize: 1, EnqueueTimeout: 5 * time.Second}, log.Default())
+	d.Notify(createDispatchedEvent(entities.MachineId(uuid.New())))
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		d.start()
+	}()
+
+	if err := d.Notify(createDispatchedEvent(entities.MachineId(uuid.New()))); err != nil {
+		t.Errorf("Noteworthy event should wait for free place, but got %s", err)
+	}
+	d.Close()
+
+	if stats := d.Stats(); stats.Delivered != 2 || stats.Dropped != 0 {
+		t.Errorf("Stats mismatch! Was %+v", stats)
+	}
+}
+
+func TestDispatcherRecoversPanickingStrategy(t *testing.T) {
+	base := newDispatchedStrategyMock()
+	base.panics = true
+	d, _ := NewNotificationDispatcher(base, DispatcherConfig{Workers: 1, QueueSize: 1}, log.Default())
+
+	d.Notify(createDispatchedEvent(entities.MachineId(uuid.New())))
+	d.Close()
+
+	if stats := d.Stats(); stats.Failed != 1 {
+		t.Errorf("Failed count mismatch! Expected 1, but was %d", stats.Failed)
+	}
+}
+
+func TestDispatcherRejectsInvalidConfig(t *testing.T) {
+	for _, c := range []DispatcherConfig{{}, {Workers: 4, QueueSize: 2}} {
+		if _, err := NewNotificationDispatcher(newDispatchedStrategyMock(), c, log.Default()); err == nil {
+			t.Errorf("Expected error for config %+v, but was nil", c)
+		}
+	}
+}
+
+func createDispatchedEvent(id entities.MachineId) entities.HealthEvent {
+	return entities.HealthEvent{
+		MachineId:   id,
+		MachineName: "machine",
+		Previous:    entities.Healthy,
+		Current:     entities.Danger,
+		OccurredAt:  time.Now(),
+	}
+}
+
+type dispatchedStrategyMock struct {
+	mu           sync.Mutex
+	events       []entities.HealthEvent
+	failuresLeft int
+	attempts     int
+	panics       bool
+	release      chan struct{}
+}
+
+func newDispatchedStrategyMock() *dispatchedStrategyMock {
+	return &dispatchedStrategyMock{}
+}
+
+func (m *dispatchedStrategyMock) Notify(e entities.HealthEvent) error {
+	if m.release != nil {
+		<-m.release
+	}
+
+	if m.panics {
+		panic("broken strategy")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.attempts++
+	if m.failuresLeft > 0 {
+		m.failuresLeft--
+		return errors.New("channel is unavailable")
+	}
+
+	m.events = append(m.events, e)
+	return nil
+}
+
+func (m *dispatchedStrategyMock) notified() []entities.HealthEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]entities.HealthEvent{}, m.events...)
+}
+
+func (m *dispatchedStrategyMock) calls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.attempts
+}
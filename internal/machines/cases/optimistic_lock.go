@@ -0,0 +1,49 @@
+package cases
+
+import (
+	"dum/internal/machines/entities"
+	"errors"
+	"fmt"
+)
+
+// How many times load-modify-save cycle is executed, if machine is changed concurrently.
+const conflictAttempts int = 3
+
+// Error of saving machine, which was changed by somebody else after it was loaded.
+type OptimisticLockError struct {
+	MachineId entities.MachineId
+	Expected  entities.MachineVersion
+	Actual    entities.MachineVersion
+}
+
+func (e *OptimisticLockError) Error() string {
+	return fmt.Sprintf("optimistic lock occured! Expected %s version of machine %s, but was %s version", e.format(e.Expected), e.MachineId, e.format(e.Actual))
+}
+
+func (e *OptimisticLockError) format(v entities.MachineVersion) string {
+	if v == "" {
+		return "no"
+	}
+
+	return string(v)
+}
+
+// Returns true, if error is caused by optimistic lock.
+func IsOptimisticLock(err error) bool {
+	var lockErr *OptimisticLockError
+	return errors.As(err, &lockErr)
+}
+
+// Executes load-modify-save cycle again, while it fails because of optimistic lock.
+func retryOnConflict(attempts int, cycle func() error) error {
+	var err error
+
+	for i := 0; i < attempts; i++ {
+		err = cycle()
+		if !IsOptimisticLock(err) {
+			return err
+		}
+	}
+
+	return err
+}
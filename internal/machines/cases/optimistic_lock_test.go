@@ -0,0 +1,43 @@
+package cases
+
+import (
+	"dum/internal/machines/entities"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestOptimisticLockErrorMessage(t *testing.T) {
+	id := entities.MachineId(uuid.New())
+	err := &OptimisticLockError{MachineId: id, Expected: "1", Actual: "2"}
+
+	if !strings.HasPrefix(err.Error(), "optimistic lock occured!") {
+		t.Errorf("Error mismatch! Expected starts with 'optimistic lock occured!', but was '%s'", err)
+	}
+
+	if !strings.Contains(err.Error(), id.String()) {
+		t.Errorf("Error should contain machine id, but was '%s'", err)
+	}
+}
+
+func TestIsOptimisticLock(t *testing.T) {
+	var cases = []struct {
+		err      error
+		expected bool
+	}{
+		{nil, false},
+		{errSave, false},
+		{&OptimisticLockError{}, true},
+		{fmt.Errorf("wrapped - %w", &OptimisticLockError{}), true},
+	}
+
+	for _, testCase := range cases {
+		actual := IsOptimisticLock(testCase.err)
+
+		if actual != testCase.expected {
+			t.Errorf("Result mismatch for %v! Expected %t, but was %t", testCase.err, testCase.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package cases
+
+import (
+	"sync"
+	"time"
+)
+
+// Poison command, which was panicking during execution, with the information about the panic.
+type QuarantinedCommand struct {
+	Command Command
+	Reason  string
+	Stack   []byte
+	At      time.Time
+}
+
+// Interface for storing poison commands away from processing.
+type Quarantine interface {
+	// Puts command to quarantine.
+	Put(c QuarantinedCommand)
+}
+
+// Quarantine keeping last quarantined commands in memory.
+type MemoryQuarantine struct {
+	mu       *sync.Mutex
+	commands []QuarantinedCommand
+	capacity int
+}
+
+func (q *MemoryQuarantine) Put(c QuarantinedCommand) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.commands) == q.capacity {
+		q.commands = q.commands[1:]
+	}
+
+	q.commands = append(q.commands, c)
+}
+
+// Returns quarantined commands, oldest first.
+func (q *MemoryQuarantine) List() []QuarantinedCommand {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	result := make([]QuarantinedCommand, len(q.commands))
+	copy(result, q.commands)
+	return result
+}
+
+func NewMemoryQuarantine(capacity int) *MemoryQuarantine {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &MemoryQuarantine{
+		mu:       &sync.Mutex{},
+		commands: []QuarantinedCommand{},
+		capacity: capacity,
+	}
+}
@@ -0,0 +1,25 @@
+package cases
+
+import "testing"
+
+func TestMemoryQuarantineKeepsLatestCommands(t *testing.T) {
+	q := NewMemoryQuarantine(2)
+	first := &commandMock{}
+	second := &commandMock{}
+	third := &commandMock{}
+
+	q.Put(QuarantinedCommand{Command: first})
+	q.Put(QuarantinedCommand{Command: second})
+	q.Put(QuarantinedCommand{Command: third})
+
+	actual := q.List()
+
+	if len(actual) != 2 {
+		t.Errorf("Quarantine length mismatch! Expected %d, but was %d", 2, len(actual))
+		return
+	}
+
+	if actual[0].Command != second || actual[1].Command != third {
+		t.Errorf("Quarantine should keep only latest commands!")
+	}
+}
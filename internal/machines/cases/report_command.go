@@ -1,6 +1,9 @@
 package cases
 
-import "dum/internal/machines/entities"
+import (
+	"dum/internal/machines/entities"
+	"fmt"
+)
 
 // Command for reporting about some missing updates of some machine.
 type ReportCommand struct {
@@ -9,27 +12,77 @@ type ReportCommand struct {
 	MissingUpdates       []entities.MissingUpdate
 	NotificationStrategy entities.HealthNotificationStrategy
 	Repository           MachineRepository
+	// Returns decommissioned machine back to service instead of rejecting the report.
+	ReactivateDecommissioned bool
 }
 
+// Error of notification about saved report. Report itself is not failed because of it.
+type NotificationError struct {
+	Err error
+}
+
+func (e *NotificationError) Error() string {
+	return fmt.Sprintf("machine is saved, but notification failed - %s", e.Err)
+}
+
+func (e *NotificationError) Unwrap() error {
+	return e.Err
+}
+
+// Reports machine and notifies about its health once, after machine is saved. Event of attempt,
+// failed because of conflict or store error, is never notified.
 func (c *ReportCommand) Execute() error {
-	machine, err := c.Repository.Load(c.MachineId)
+	var event entities.HealthEvent
+	err := retryOnConflict(conflictAttempts, func() error {
+		var err error
+		event, err = c.report()
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
+	if err := c.NotificationStrategy.Notify(event); err != nil {
+		return &NotificationError{Err: err}
+	}
+
+	return nil
+}
+
+func (c *ReportCommand) report() (entities.HealthEvent, error) {
+	machine, err := c.Repository.Load(c.MachineId)
+	if err != nil {
+		return entities.HealthEvent{}, err
+	}
+
 	if machine == nil {
 		machine = entities.CreateMachine(c.MachineId, c.MachineName, []entities.MissingUpdate{})
 	}
 
-	err = machine.Report(c.MissingUpdates, c.NotificationStrategy)
+	if machine.IsDecommissioned() && c.ReactivateDecommissioned {
+		machine.Reactivate()
+	}
+
+	events := &eventCollector{}
+	err = machine.Report(c.MissingUpdates, events)
 	if err != nil {
-		return err
+		return entities.HealthEvent{}, err
 	}
 
 	err = c.Repository.Save(machine)
 	if err != nil {
-		return err
+		return entities.HealthEvent{}, err
 	}
 
+	return events.event, nil
+}
+
+// Strategy, which keeps event of report instead of notifying about it.
+type eventCollector struct {
+	event entities.HealthEvent
+}
+
+func (c *eventCollector) Notify(e entities.HealthEvent) error {
+	c.event = e
 	return nil
 }
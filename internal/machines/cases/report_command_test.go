@@ -133,12 +133,13 @@ func TestExecuteReturnsReportError(t *testing.T) {
 		t.Errorf("Execute should return error!")
 	}
 
-	if err != errReport {
-		t.Errorf("Error mismatch! Expected %s, but was %s!", errReport, err)
+	var notificationErr *NotificationError
+	if !errors.As(err, &notificationErr) || !errors.Is(err, errReport) {
+		t.Errorf("Error mismatch! Expected notification error %s, but was %s!", errReport, err)
 	}
 
-	if repositoryMock.savedMachine != nil {
-		t.Errorf("Should not try to save machine if report failed!")
+	if repositoryMock.savedMachine == nil {
+		t.Errorf("Machine should be saved before notification!")
 	}
 }
 
@@ -169,18 +170,138 @@ func TestExecuteReturnsSaveError(t *testing.T) {
 		t.Errorf("Error mismatch! Expected %s, but was %s!", errReport, err)
 	}
 
-	if strategyMock.wasCalled == false {
-		t.Errorf("Notification strategy should be called!")
+	if strategyMock.wasCalled {
+		t.Errorf("Notification strategy should not be called, if machine is not saved!")
+	}
+}
+
+func TestExecuteReturnsErrorForDecommissionedMachine(t *testing.T) {
+	strategyMock := notificationStrategyMock{}
+	machine := entities.CreateMachine(entities.MachineId(uuid.New()), existingMachineName, []entities.MissingUpdate{})
+	machine.Decommission()
+	repositoryMock := repositoryMock{
+		loadedMachine: machine,
+	}
+
+	command := ReportCommand{
+		MachineName:          existingMachineName,
+		MissingUpdates:       expectedMissingUpdates,
+		Repository:           &repositoryMock,
+		NotificationStrategy: &strategyMock,
+	}
+
+	err := command.Execute()
+
+	if err != entities.ErrMachineDecommissioned {
+		t.Errorf("Error mismatch! Expected %s, but was %s!", entities.ErrMachineDecommissioned, err)
+	}
+
+	if repositoryMock.savedMachine != nil {
+		t.Errorf("Should not try to save decommissioned machine!")
+	}
+}
+
+func TestExecuteReactivatesDecommissionedMachine(t *testing.T) {
+	strategyMock := notificationStrategyMock{}
+	machine := entities.CreateMachine(entities.MachineId(uuid.New()), existingMachineName, []entities.MissingUpdate{})
+	machine.Decommission()
+	repositoryMock := repositoryMock{
+		loadedMachine: machine,
+	}
+
+	command := ReportCommand{
+		MachineName:              existingMachineName,
+		MissingUpdates:           expectedMissingUpdates,
+		Repository:               &repositoryMock,
+		NotificationStrategy:     &strategyMock,
+		ReactivateDecommissioned: true,
+	}
+
+	err := command.Execute()
+
+	if err != nil {
+		t.Errorf("Execute should not return error %s!", err)
+	}
+
+	if repositoryMock.savedMachine == nil {
+		t.Errorf("Machine should be saved!")
+		return
+	}
+
+	if repositoryMock.savedMachine.IsDecommissioned() {
+		t.Errorf("Machine should be reactivated!")
+	}
+}
+
+func TestExecuteRetriesOnOptimisticLock(t *testing.T) {
+	strategyMock := notificationStrategyMock{}
+	repositoryMock := repositoryMock{
+		lockErrorsLeft: conflictAttempts - 1,
+	}
+
+	command := ReportCommand{
+		MachineName:          newMachineName,
+		MissingUpdates:       expectedMissingUpdates,
+		Repository:           &repositoryMock,
+		NotificationStrategy: &strategyMock,
+	}
+
+	err := command.Execute()
+
+	if err != nil {
+		t.Errorf("Execute should not return error %s!", err)
+	}
+
+	if strategyMock.calls != 1 {
+		t.Errorf("Notification should be sent once after conflicts, but was sent %d times", strategyMock.calls)
+	}
+
+	if repositoryMock.loadCalls != conflictAttempts {
+		t.Errorf("Load calls mismatch! Expected %d, but was %d", conflictAttempts, repositoryMock.loadCalls)
+	}
+
+	if repositoryMock.savedMachine == nil {
+		t.Errorf("Machine should be saved!")
+	}
+}
+
+func TestExecuteReturnsOptimisticLockErrorIfRetriesExhausted(t *testing.T) {
+	strategyMock := notificationStrategyMock{}
+	repositoryMock := repositoryMock{
+		lockErrorsLeft: conflictAttempts,
+	}
+
+	command := ReportCommand{
+		MachineName:          newMachineName,
+		MissingUpdates:       expectedMissingUpdates,
+		Repository:           &repositoryMock,
+		NotificationStrategy: &strategyMock,
+	}
+
+	err := command.Execute()
+
+	if !IsOptimisticLock(err) {
+		t.Errorf("Error mismatch! Expected optimistic lock error, but was %s!", err)
+	}
+
+	if strategyMock.calls != 0 {
+		t.Errorf("Notification should not be sent for unsaved machine, but was sent %d times", strategyMock.calls)
+	}
+
+	if repositoryMock.loadCalls != conflictAttempts {
+		t.Errorf("Load calls mismatch! Expected %d, but was %d", conflictAttempts, repositoryMock.loadCalls)
 	}
 }
 
 type notificationStrategyMock struct {
 	shouldReturnError bool
 	wasCalled         bool
+	calls             int
 }
 
-func (m *notificationStrategyMock) Notify(id entities.MachineId, level entities.HealthLevel) error {
+func (m *notificationStrategyMock) Notify(e entities.HealthEvent) error {
 	m.wasCalled = true
+	m.calls++
 
 	if m.shouldReturnError {
 		return errReport
@@ -192,11 +313,17 @@ func (m *notificationStrategyMock) Notify(id entities.MachineId, level entities.
 type repositoryMock struct {
 	loadedMachine         *entities.Machine
 	savedMachine          *entities.Machine
+	deletedId             *entities.MachineId
 	shouldReturnLoadError bool
 	shouldReturnSaveError bool
+	lockErrorsLeft        int
+	busyErrorsLeft        int
+	loadCalls             int
 }
 
 func (r *repositoryMock) Load(id entities.MachineId) (*entities.Machine, error) {
+	r.loadCalls++
+
 	if r.shouldReturnLoadError {
 		return nil, errLoad
 	}
@@ -204,7 +331,29 @@ func (r *repositoryMock) Load(id entities.MachineId) (*entities.Machine, error)
 	return r.loadedMachine, nil
 }
 
+func (r *repositoryMock) List() ([]*entities.Machine, error) {
+	if r.shouldReturnLoadError {
+		return nil, errLoad
+	}
+
+	if r.loadedMachine == nil {
+		return []*entities.Machine{}, nil
+	}
+
+	return []*entities.Machine{r.loadedMachine}, nil
+}
+
 func (r *repositoryMock) Save(machine *entities.Machine) error {
+	if r.lockErrorsLeft > 0 {
+		r.lockErrorsLeft--
+		return &OptimisticLockError{MachineId: machine.Id}
+	}
+
+	if r.busyErrorsLeft > 0 {
+		r.busyErrorsLeft--
+		return ErrStoreBusy
+	}
+
 	r.savedMachine = machine
 
 	if r.shouldReturnSaveError {
@@ -214,6 +363,11 @@ func (r *repositoryMock) Save(machine *entities.Machine) error {
 	return nil
 }
 
+func (r *repositoryMock) Delete(id entities.MachineId) error {
+	r.deletedId = &id
+	return nil
+}
+
 const newMachineName string = "the machine"
 const existingMachineName string = "the old machine"
 
@@ -2,20 +2,87 @@ package cases
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"runtime/debug"
 	"sync"
+	"time"
 )
 
+const defaultQuarantineCapacity int = 100
+
+// Settings of retrying commands, failed because of busy store.
+const (
+	defaultBusyAttempts int           = 5
+	defaultBusyBackoff  time.Duration = 100 * time.Millisecond
+)
+
+// State of processor worker.
+type WorkerState string
+
+const (
+	WorkerIdle    WorkerState = "idle"
+	WorkerBusy    WorkerState = "busy"
+	WorkerDead    WorkerState = "dead"
+	WorkerStopped WorkerState = "stopped"
+)
+
+// Snapshot of processor worker liveness.
+type WorkerStatus struct {
+	Name        string
+	State       WorkerState
+	Restarts    int
+	Processed   int
+	Failed      int
+	Quarantined int
+	LastSeen    time.Time
+}
+
+// Returns true, if worker is still able to process commands.
+func (s WorkerStatus) IsAlive() bool {
+	return s.State == WorkerIdle || s.State == WorkerBusy
+}
+
+// Error, which is returned instead of panic, occured while executing command.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
 // Processing commands from channel.
 type ReportProcessor struct {
-	commandChan <-chan Command
-	logger      *log.Logger
+	name         string
+	commandChan  <-chan Command
+	logger       *log.Logger
+	quarantine   Quarantine
+	mu           *sync.Mutex
+	status       WorkerStatus
+	busyAttempts int
+	busyBackoff  time.Duration
 }
 
 func NewReportProcessor(commandChannel <-chan Command, logger *log.Logger) *ReportProcessor {
+	return newReportProcessor("processor", commandChannel, NewMemoryQuarantine(defaultQuarantineCapacity), logger)
+}
+
+func newReportProcessor(name string, commandChannel <-chan Command, quarantine Quarantine, logger *log.Logger) *ReportProcessor {
 	return &ReportProcessor{
-		commandChan: commandChannel,
-		logger:      logger,
+		name:         name,
+		commandChan:  commandChannel,
+		logger:       logger,
+		quarantine:   quarantine,
+		mu:           &sync.Mutex{},
+		busyAttempts: defaultBusyAttempts,
+		busyBackoff:  defaultBusyBackoff,
+		status: WorkerStatus{
+			Name:  name,
+			State: WorkerStopped,
+		},
 	}
 }
 
@@ -24,30 +91,125 @@ func (p *ReportProcessor) Start(ctx context.Context, wg *sync.WaitGroup) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		for {
-			select {
-			case <-ctx.Done():
-				p.logger.Println("Stopping processor...")
-				for command := range p.commandChan {
-					p.execute(command)
-				}
-				p.logger.Println("Stopped processor!")
-				return
-			case command, ok := <-p.commandChan:
-				if ok {
-					p.execute(command)
-				}
-			}
+		err := p.Run(ctx)
+		if err != nil {
+			p.logger.Printf("Processor %s died - %s", p.name, err)
 		}
 	}()
 }
 
-func (r *ReportProcessor) execute(c Command) {
-	err := c.Execute()
+// Processes commands until context is done and channel is drained. Returns an error,
+// if processing loop itself has died because of panic.
+func (p *ReportProcessor) Run(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.setState(WorkerDead)
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+
+	p.setState(WorkerIdle)
+	for {
+		select {
+		case <-ctx.Done():
+			p.logger.Println("Stopping processor...")
+			for command := range p.commandChan {
+				p.execute(command)
+			}
+			p.logger.Println("Stopped processor!")
+			p.setState(WorkerStopped)
+			return nil
+		case command, ok := <-p.commandChan:
+			if ok {
+				p.execute(command)
+			}
+		}
+	}
+}
+
+// Returns current liveness status of processor.
+func (p *ReportProcessor) Status() WorkerStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
+	return p.status
+}
+
+func (p *ReportProcessor) execute(c Command) {
+	p.setState(WorkerBusy)
+	err := p.executeWithRetry(c)
+
+	// machine is saved, so command is not failed and should not be retried
+	var notificationErr *NotificationError
+	if errors.As(err, &notificationErr) {
+		p.logger.Printf("Command %T is executed, but notification failed - %s", c, notificationErr.Err)
+		err = nil
+	}
+
+	p.mu.Lock()
+	p.status.Processed++
 	if err != nil {
-		r.logger.Printf("Got an error while executing command - %s", err)
+		p.status.Failed++
+	}
+	p.mu.Unlock()
+
+	if panicErr, ok := err.(*PanicError); ok {
+		p.logger.Printf("Command %T panicked, putting it to quarantine - %s\n%s", c, panicErr, panicErr.Stack)
+		p.quarantine.Put(QuarantinedCommand{
+			Command: c,
+			Reason:  panicErr.Error(),
+			Stack:   panicErr.Stack,
+			At:      time.Now(),
+		})
+
+		p.mu.Lock()
+		p.status.Quarantined++
+		p.mu.Unlock()
+	} else if err != nil {
+		p.logger.Printf("Got an error while executing command - %s", err)
 	} else {
-		r.logger.Println("Successfuly executed command!")
+		p.logger.Println("Successfuly executed command!")
+	}
+
+	p.setState(WorkerIdle)
+}
+
+// Executes command, retrying it with exponential backoff, while store is busy.
+func (p *ReportProcessor) executeWithRetry(c Command) error {
+	backoff := p.busyBackoff
+	err := safeExecute(c)
+
+	for attempt := 1; attempt < p.busyAttempts && errors.Is(err, ErrStoreBusy); attempt++ {
+		p.logger.Printf("Store is busy, retrying command in %s - %s", backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+		err = safeExecute(c)
 	}
+
+	return err
+}
+
+func (p *ReportProcessor) setState(state WorkerState) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.status.State = state
+	p.status.LastSeen = time.Now()
+}
+
+func (p *ReportProcessor) restarted() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.status.Restarts++
+}
+
+func safeExecute(c Command) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+
+	return c.Execute()
 }
@@ -3,6 +3,7 @@ package cases
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"sync"
 	"testing"
@@ -52,3 +53,140 @@ func (c *commandMock) Execute() error {
 
 	return nil
 }
+
+func TestReportProcessorQuarantinesPanickingCommands(t *testing.T) {
+	commandChannel := make(chan Command, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	quarantine := NewMemoryQuarantine(10)
+	p := newReportProcessor("test", commandChannel, quarantine, log.Default())
+	wg := &sync.WaitGroup{}
+	poison := &panickingCommandMock{}
+	healthy := &commandMock{}
+
+	p.Start(ctx, wg)
+
+	commandChannel <- poison
+	commandChannel <- healthy
+
+	close(commandChannel)
+	cancel()
+	wg.Wait()
+
+	if !healthy.wasExecuted {
+		t.Errorf("Command after poison one should be executed, but it wasn't!")
+	}
+
+	quarantined := quarantine.List()
+
+	if len(quarantined) != 1 {
+		t.Errorf("Quarantine length mismatch! Expected %d, but was %d", 1, len(quarantined))
+		return
+	}
+
+	if quarantined[0].Command != poison {
+		t.Errorf("Poison command should be quarantined!")
+	}
+
+	if len(quarantined[0].Stack) == 0 {
+		t.Errorf("Stack of panic should be recorded!")
+	}
+
+	status := p.Status()
+
+	if status.Processed != 2 || status.Failed != 1 || status.Quarantined != 1 {
+		t.Errorf("Status mismatch! Expected 2 processed, 1 failed and 1 quarantined, but was %+v", status)
+	}
+
+	if status.State != WorkerStopped {
+		t.Errorf("State mismatch! Expected %s, but was %s", WorkerStopped, status.State)
+	}
+}
+
+type panickingCommandMock struct{}
+
+func (c *panickingCommandMock) Execute() error {
+	panic("poison")
+}
+
+func TestReportProcessorRetriesBusyStore(t *testing.T) {
+	p := NewReportProcessor(make(chan Command), log.Default())
+	p.busyBackoff = time.Millisecond
+	c := &busyCommandMock{busyLeft: 2}
+
+	p.execute(c)
+
+	if c.executions != 3 {
+		t.Errorf("Executions count mismatch! Expected %d, but was %d", 3, c.executions)
+	}
+
+	if status := p.Status(); status.Failed != 0 {
+		t.Errorf("Command should succeed after retries, but %d failed", status.Failed)
+	}
+}
+
+func TestReportProcessorGivesUpOnBusyStore(t *testing.T) {
+	p := NewReportProcessor(make(chan Command), log.Default())
+	p.busyBackoff = time.Millisecond
+	c := &busyCommandMock{busyLeft: 100}
+
+	p.execute(c)
+
+	if c.executions != defaultBusyAttempts {
+		t.Errorf("Executions count mismatch! Expected %d, but was %d", defaultBusyAttempts, c.executions)
+	}
+
+	if status := p.Status(); status.Failed != 1 {
+		t.Errorf("Failed count mismatch! Expected %d, but was %d", 1, status.Failed)
+	}
+}
+
+func TestReportProcessorNotifiesOnceAfterBusyStore(t *testing.T) {
+	p := NewReportProcessor(make(chan Command), log.Default())
+	p.busyBackoff = time.Millisecond
+	strategy := &notificationStrategyMock{}
+	c := &ReportCommand{
+		MachineName:          newMachineName,
+		MissingUpdates:       expectedMissingUpdates,
+		Repository:           &repositoryMock{busyErrorsLeft: 2},
+		NotificationStrategy: strategy,
+	}
+
+	p.execute(c)
+
+	if strategy.calls != 1 {
+		t.Errorf("Notification should be sent once after store is free, but was sent %d times", strategy.calls)
+	}
+}
+
+func TestReportProcessorDoesNotFailReportOnNotificationError(t *testing.T) {
+	p := NewReportProcessor(make(chan Command), log.Default())
+	repository := &repositoryMock{}
+	p.execute(&ReportCommand{
+		MachineName:          newMachineName,
+		MissingUpdates:       expectedMissingUpdates,
+		Repository:           repository,
+		NotificationStrategy: &notificationStrategyMock{shouldReturnError: true},
+	})
+
+	if status := p.Status(); status.Failed != 0 || status.Processed != 1 {
+		t.Errorf("Report should not fail because of notification, but status was %+v", status)
+	}
+
+	if repository.savedMachine == nil {
+		t.Error("Machine should be saved!")
+	}
+}
+
+type busyCommandMock struct {
+	busyLeft, executions int
+}
+
+func (c *busyCommandMock) Execute() error {
+	c.executions++
+	if c.busyLeft > 0 {
+		c.busyLeft--
+		return fmt.Errorf("cannot lock - %w", ErrStoreBusy)
+	}
+
+	return nil
+}
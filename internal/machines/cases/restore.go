@@ -0,0 +1,63 @@
+package cases
+
+import (
+	"dum/internal/machines/entities"
+	"fmt"
+)
+
+// Mode of restoring machines, which already exist in repository.
+type RestoreMode string
+
+const (
+	// Existing machines are replaced by restored ones.
+	RestoreOverwrite RestoreMode = "overwrite"
+	// Existing machines are kept as is.
+	RestoreSkip RestoreMode = "skip"
+)
+
+// Result of restoring machines from backup.
+type RestoreReport struct {
+	Restored int
+	Skipped  int
+	Errors   []error
+}
+
+// Restores machines from backup into repository. Every machine is saved independently with optimistic lock,
+// so restore can be done online, while commands are processed.
+func RestoreMachines(r MachineRepository, machines []*entities.Machine, mode RestoreMode) RestoreReport {
+	report := RestoreReport{Errors: []error{}}
+
+	for _, machine := range machines {
+		skipped := false
+		err := retryOnConflict(conflictAttempts, func() error {
+			existing, err := r.Load(machine.Id)
+			if err != nil {
+				return err
+			}
+
+			if existing != nil && mode == RestoreSkip {
+				skipped = true
+				return nil
+			}
+
+			if existing != nil {
+				machine.SetVersion(existing.GetVersion())
+			} else {
+				machine.SetVersion("")
+			}
+
+			return r.Save(machine)
+		})
+
+		switch {
+		case err != nil:
+			report.Errors = append(report.Errors, fmt.Errorf("cannot restore machine %s - %w", machine.Id, err))
+		case skipped:
+			report.Skipped++
+		default:
+			report.Restored++
+		}
+	}
+
+	return report
+}
@@ -0,0 +1,70 @@
+package cases
+
+import (
+	"dum/internal/machines/entities"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRestoreOverwritesExistingMachine(t *testing.T) {
+	existing := entities.CreateMachine(entities.MachineId(uuid.New()), existingMachineName, expectedMissingUpdates)
+	existing.SetVersion("1")
+	repo := &repositoryMock{loadedMachine: existing}
+	restored := entities.CreateMachine(existing.Id, newMachineName, expectedMissingUpdates)
+
+	report := RestoreMachines(repo, []*entities.Machine{restored}, RestoreOverwrite)
+
+	if report.Restored != 1 || len(report.Errors) != 0 {
+		t.Errorf("Unexpected restore report %+v", report)
+	}
+
+	if repo.savedMachine != restored {
+		t.Errorf("Restored machine should be saved!")
+	}
+
+	if restored.GetVersion() != existing.GetVersion() {
+		t.Errorf("Restored machine should replace version %s, but had %s", existing.GetVersion(), restored.GetVersion())
+	}
+}
+
+func TestRestoreSkipsExistingMachine(t *testing.T) {
+	existing := entities.CreateMachine(entities.MachineId(uuid.New()), existingMachineName, expectedMissingUpdates)
+	repo := &repositoryMock{loadedMachine: existing}
+	restored := entities.CreateMachine(existing.Id, newMachineName, expectedMissingUpdates)
+
+	report := RestoreMachines(repo, []*entities.Machine{restored}, RestoreSkip)
+
+	if report.Skipped != 1 || report.Restored != 0 {
+		t.Errorf("Unexpected restore report %+v", report)
+	}
+
+	if repo.savedMachine != nil {
+		t.Errorf("Existing machine should not be overwritten!")
+	}
+}
+
+func TestRestoreRetriesConflicts(t *testing.T) {
+	repo := &repositoryMock{lockErrorsLeft: 1}
+	restored := entities.CreateMachine(entities.MachineId(uuid.New()), newMachineName, expectedMissingUpdates)
+
+	report := RestoreMachines(repo, []*entities.Machine{restored}, RestoreOverwrite)
+
+	if report.Restored != 1 || repo.loadCalls != 2 {
+		t.Errorf("Unexpected restore report %+v after %d loads", report, repo.loadCalls)
+	}
+}
+
+func TestRestoreCollectsErrors(t *testing.T) {
+	repo := &repositoryMock{shouldReturnSaveError: true}
+	machines := []*entities.Machine{
+		entities.CreateMachine(entities.MachineId(uuid.New()), newMachineName, expectedMissingUpdates),
+		entities.CreateMachine(entities.MachineId(uuid.New()), newMachineName, expectedMissingUpdates),
+	}
+
+	report := RestoreMachines(repo, machines, RestoreOverwrite)
+
+	if report.Restored != 0 || len(report.Errors) != 2 {
+		t.Errorf("Unexpected restore report %+v", report)
+	}
+}
@@ -0,0 +1,56 @@
+package cases
+
+import (
+	"dum/internal/machines/entities"
+	"time"
+)
+
+// Acknowledgments of machine health episodes - operator's confirmation, that problem is known.
+// Health episode is identified by time, since which machine has its health level.
+type Acknowledgments interface {
+	IsAcknowledged(id entities.MachineId, since time.Time) (bool, error)
+}
+
+// Progress of escalations, which survives restarts. Progress is kept per health episode, so the count of
+// notified tiers of previous episode never applies to the new one.
+type EscalationProgress interface {
+	// Returns count of notified tiers of machine's episode, which started at since, zero for unknown episode.
+	LoadEscalation(id entities.MachineId, since time.Time) (int, error)
+
+	// Records count of notified tiers of machine's episode, replacing progress of any previous episode.
+	SaveEscalation(id entities.MachineId, since time.Time, notified int) error
+
+	// Forgets progress of machine. Deleting of absent progress is not an error.
+	DeleteEscalation(id entities.MachineId) error
+}
+
+// Interface for accessing and persisting silences and acknowledgments, which suppress notifications,
+// and progress of escalations.
+type SilenceRepository interface {
+	Acknowledgments
+	EscalationProgress
+
+	// Loading all silences, including expired ones, ordered by start time.
+	ListSilences() ([]entities.Silence, error)
+
+	// Loading silence, returns nil, if there is no such silence.
+	LoadSilence(id entities.SilenceId) (*entities.Silence, error)
+
+	// Creating or replacing silence.
+	SaveSilence(s entities.Silence) error
+
+	// Deleting silence. Deleting of absent silence is not an error.
+	DeleteSilence(id entities.SilenceId) error
+
+	// Loading acknowledgments of all machines.
+	ListAcknowledgments() ([]entities.Acknowledgment, error)
+
+	// Loading acknowledgment of machine, returns nil, if machine is not acknowledged.
+	LoadAcknowledgment(id entities.MachineId) (*entities.Acknowledgment, error)
+
+	// Creating or replacing acknowledgment of machine, machine has at most one acknowledgment.
+	SaveAcknowledgment(a entities.Acknowledgment) error
+
+	// Deleting acknowledgment of machine. Deleting of absent acknowledgment is not an error.
+	DeleteAcknowledgment(id entities.MachineId) error
+}
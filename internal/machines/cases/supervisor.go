@@ -0,0 +1,78 @@
+package cases
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const defaultRestartDelay time.Duration = time.Second
+
+// Supervisor starts set of processors and restarts them, if some of them died.
+type Supervisor struct {
+	processors   []*ReportProcessor
+	logger       *log.Logger
+	restartDelay time.Duration
+}
+
+// Starts all processors. Every died processor will be restarted after restart delay
+// until context is done.
+func (s *Supervisor) Start(ctx context.Context, wg *sync.WaitGroup) {
+	for _, p := range s.processors {
+		wg.Add(1)
+		go s.supervise(ctx, p, wg)
+	}
+}
+
+// Returns liveness of every supervised processor.
+func (s *Supervisor) Liveness() []WorkerStatus {
+	result := []WorkerStatus{}
+
+	for _, p := range s.processors {
+		result = append(result, p.Status())
+	}
+
+	return result
+}
+
+func (s *Supervisor) supervise(ctx context.Context, p *ReportProcessor, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for {
+		err := p.Run(ctx)
+		if err == nil {
+			return
+		}
+
+		s.logger.Printf("Processor %s died - %s", p.name, err)
+
+		if panicErr, ok := err.(*PanicError); ok {
+			s.logger.Printf("%s", panicErr.Stack)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(s.restartDelay):
+		}
+
+		p.restarted()
+		s.logger.Printf("Restarting processor %s...", p.name)
+	}
+}
+
+func NewSupervisor(commandChannel <-chan Command, workers int, quarantine Quarantine, logger *log.Logger) *Supervisor {
+	processors := []*ReportProcessor{}
+
+	for i := 0; i < workers; i++ {
+		name := fmt.Sprintf("processor-%d", i)
+		processors = append(processors, newReportProcessor(name, commandChannel, quarantine, logger))
+	}
+
+	return &Supervisor{
+		processors:   processors,
+		logger:       logger,
+		restartDelay: defaultRestartDelay,
+	}
+}
@@ -0,0 +1,76 @@
+package cases
+
+import (
+	"context"
+	"log"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSupervisorRestartsDiedProcessor(t *testing.T) {
+	commandChannel := make(chan Command, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	quarantine := &panickingQuarantineMock{}
+	s := NewSupervisor(commandChannel, 1, quarantine, log.Default())
+	s.restartDelay = time.Millisecond
+	wg := &sync.WaitGroup{}
+	healthy := &commandMock{}
+
+	s.Start(ctx, wg)
+
+	commandChannel <- &panickingCommandMock{}
+	commandChannel <- healthy
+
+	close(commandChannel)
+	cancel()
+	wg.Wait()
+
+	if !healthy.wasExecuted {
+		t.Errorf("Command should be executed by restarted processor, but it wasn't!")
+	}
+
+	liveness := s.Liveness()
+
+	if len(liveness) != 1 {
+		t.Errorf("Liveness length mismatch! Expected %d, but was %d", 1, len(liveness))
+		return
+	}
+
+	if liveness[0].Restarts != 1 {
+		t.Errorf("Restarts mismatch! Expected %d, but was %d", 1, liveness[0].Restarts)
+	}
+
+	if liveness[0].Name != "processor-0" {
+		t.Errorf("Name mismatch! Expected %s, but was %s", "processor-0", liveness[0].Name)
+	}
+}
+
+func TestWorkerStatusIsAlive(t *testing.T) {
+	var cases = []struct {
+		state    WorkerState
+		expected bool
+	}{
+		{WorkerIdle, true},
+		{WorkerBusy, true},
+		{WorkerDead, false},
+		{WorkerStopped, false},
+	}
+
+	for _, testCase := range cases {
+		actual := WorkerStatus{State: testCase.state}.IsAlive()
+
+		if actual != testCase.expected {
+			t.Errorf("Liveness mismatch for %s! Expected %t, but was %t", testCase.state, testCase.expected, actual)
+		}
+	}
+}
+
+type panickingQuarantineMock struct {
+	calls int
+}
+
+func (q *panickingQuarantineMock) Put(c QuarantinedCommand) {
+	q.calls++
+	panic("quarantine is broken")
+}
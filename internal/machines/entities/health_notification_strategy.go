@@ -1,6 +1,92 @@
 package entities
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 // Interface for notifiying about machine health level changes.
 type HealthNotificationStrategy interface {
-	Notify(machineId MachineId, level HealthLevel) error
+	Notify(e HealthEvent) error
+}
+
+// Event about machine health, raised on every report of machine.
+type HealthEvent struct {
+	MachineId   MachineId
+	MachineName string
+	Previous    HealthLevel
+	Current     HealthLevel
+	OccurredAt  time.Time
+	// Time, since which machine has current health level.
+	Since time.Time
+	// Updates, reported as missing on machine.
+	MissingUpdates []MissingUpdate
+	// Name of escalation tier, which is notified about machine staying at current level for too long.
+	// Empty for events, raised by reports.
+	Escalation string
+}
+
+// Returns true, if health level of machine was changed.
+func (e HealthEvent) Changed() bool {
+	return e.Previous != e.Current
+}
+
+// Returns true, if event is a reminder of escalation policy rather than a report.
+func (e HealthEvent) Escalated() bool {
+	return e.Escalation != ""
+}
+
+// Returns true, if event is worth a message to people - health level was changed or machine is escalated.
+// Events are raised by every report, so strategies, writing to people, skip the rest of them.
+func (e HealthEvent) Noteworthy() bool {
+	return e.Changed() || e.Escalated()
+}
+
+// Digest of health events, which were not notified one by one, e.g. because of rate limits.
+type HealthDigest struct {
+	Since time.Time
+	Until time.Time
+	// The latest event of every machine in digest.
+	Events []HealthEvent
+}
+
+// Returns short description of digest, e.g. "37 machines went to Danger in the last 10 minutes".
+func (d HealthDigest) Summary() string {
+	counts := map[HealthLevel]int{}
+	for _, e := range d.Events {
+		counts[e.Current]++
+	}
+
+	var parts []string
+	for _, l := range []HealthLevel{Danger, Warning, Healthy} {
+		switch counts[l] {
+		case 0:
+		case 1:
+			parts = append(parts, fmt.Sprintf("1 machine went to %s", l))
+		default:
+			parts = append(parts, fmt.Sprintf("%d machines went to %s", counts[l], l))
+		}
+	}
+
+	if len(parts) == 0 {
+		return "No machine health changes"
+	}
+
+	return fmt.Sprintf("%s in the last %s", strings.Join(parts, ", "), formatPeriod(d.Until.Sub(d.Since)))
+}
+
+func formatPeriod(d time.Duration) string {
+	switch {
+	case d == time.Hour:
+		return "hour"
+	case d == time.Minute:
+		return "minute"
+	case d > 0 && d%time.Hour == 0:
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	case d > 0 && d%time.Minute == 0:
+		return fmt.Sprintf("%d minutes", d/time.Minute)
+	default:
+		return d.Round(time.Second).String()
+	}
 }
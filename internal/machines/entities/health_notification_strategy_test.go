@@ -0,0 +1,49 @@
+package entities
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDigestSummary(t *testing.T) {
+	since := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	var cases = []struct {
+		levels   []HealthLevel
+		period   time.Duration
+		expected string
+	}{
+		{[]HealthLevel{Danger, Danger, Warning}, 10 * time.Minute, "2 machines went to Danger, 1 machine went to Warning in the last 10 minutes"},
+		{[]HealthLevel{Healthy}, time.Hour, "1 machine went to Healthy in the last hour"},
+		{[]HealthLevel{Danger}, 90 * time.Second, "1 machine went to Danger in the last 1m30s"},
+		{nil, time.Minute, "No machine health changes"},
+	}
+
+	for _, testCase := range cases {
+		d := HealthDigest{Since: since, Until: since.Add(testCase.period)}
+		for _, l := range testCase.levels {
+			d.Events = append(d.Events, HealthEvent{Current: l})
+		}
+
+		if actual := d.Summary(); actual != testCase.expected {
+			t.Errorf("Summary mismatch! Expected '%s', but was '%s'", testCase.expected, actual)
+		}
+	}
+}
+
+func TestNoteworthyEvents(t *testing.T) {
+	var cases = []struct {
+		e        HealthEvent
+		expected bool
+	}{
+		{HealthEvent{Previous: Healthy, Current: Danger}, true},
+		{HealthEvent{Previous: Danger, Current: Danger}, false},
+		{HealthEvent{Previous: Danger, Current: Danger, Escalation: "tier 2"}, true},
+	}
+
+	for _, c := range cases {
+		if actual := c.e.Noteworthy(); actual != c.expected {
+			t.Errorf("Noteworthy mismatch for %+v! Expected %t, but was %t", c.e, c.expected, actual)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package entities
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,6 +24,10 @@ func (id MachineId) String() string {
 	return uuid.UUID(id).String()
 }
 
+// Version of persisted machine state, which is used by repositories for optimistic locking.
+// Empty version means, that machine was never persisted.
+type MachineVersion string
+
 // Severity represents a level of update importance.
 type Severity int
 
@@ -32,16 +38,38 @@ const (
 	Critical
 )
 
+func (s Severity) String() string {
+	switch s {
+	case Unspecified:
+		return "Unspecified"
+	case Low:
+		return "Low"
+	case Important:
+		return "Important"
+	case Critical:
+		return "Critical"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
 // Machine is an entitiy type which represents a machine
 // with some health level. It can process reports about
 // updates, missing on this machine.
 type Machine struct {
-	h       health
-	Name    string
-	Id      MachineId
-	missing []MissingUpdate
+	h              health
+	Name           string
+	Id             MachineId
+	missing        []MissingUpdate
+	decommissioned bool
+	version        MachineVersion
+	// Time of the last health level change - beginning of the current health episode.
+	healthChangedAt time.Time
 }
 
+// Error for reports about decommissioned machine.
+var ErrMachineDecommissioned error = errors.New("machine is decommissioned")
+
 // Creates a machine with specific missing updates and health level.
 func CreateMachine(id MachineId, name string, mu []MissingUpdate) *Machine {
 	health := &health{}
@@ -54,21 +82,88 @@ func CreateMachine(id MachineId, name string, mu []MissingUpdate) *Machine {
 	}
 }
 
+// Returns independent copy of machine, changes of copy don't affect the original.
+func (m *Machine) Clone() *Machine {
+	clone := *m
+	if m.missing != nil {
+		clone.missing = make([]MissingUpdate, len(m.missing))
+		copy(clone.missing, m.missing)
+	}
+
+	return &clone
+}
+
 // Returns health level of machine.
 func (m *Machine) GetHealthLevel() HealthLevel {
 	return m.h.level
 }
 
+// Returns time, since which machine has its current health level. Zero time means, that machine
+// was persisted before it was tracked and it's unknown until the next report.
+func (m *Machine) GetHealthChangedAt() time.Time {
+	return m.healthChangedAt
+}
+
+// Restores time of the last health level change. Should be used only by repositories on load.
+func (m *Machine) SetHealthChangedAt(t time.Time) {
+	m.healthChangedAt = t
+}
+
 // Returns current set of missing updates
 func (m *Machine) GetMissingUpdates() []MissingUpdate {
 	return m.missing
 }
 
+// Returns version of persisted state, this machine was loaded from.
+func (m *Machine) GetVersion() MachineVersion {
+	return m.version
+}
+
+// Remembers version of persisted state. Should be used only by repositories on load and save.
+func (m *Machine) SetVersion(v MachineVersion) {
+	m.version = v
+}
+
+// Returns true, if machine is retired. Decommissioned machine keeps its history,
+// but doesn't take part in health calculations and doesn't accept reports.
+func (m *Machine) IsDecommissioned() bool {
+	return m.decommissioned
+}
+
+// Retires machine.
+func (m *Machine) Decommission() {
+	m.decommissioned = true
+}
+
+// Returns previously decommissioned machine back to service.
+func (m *Machine) Reactivate() {
+	m.decommissioned = false
+}
+
 // Processes message about missing updates appearing for this machine.
 func (m *Machine) Report(mu []MissingUpdate, s HealthNotificationStrategy) error {
+	if m.decommissioned {
+		return ErrMachineDecommissioned
+	}
+
+	now := time.Now()
+	previous := m.h.level
 	m.h = m.h.Recalculate(mu)
 	m.missing = mu
-	err := s.Notify(m.Id, m.h.level)
+	if previous != m.h.level || m.healthChangedAt.IsZero() {
+		m.healthChangedAt = now
+	}
+
+	err := s.Notify(HealthEvent{
+		MachineId:   m.Id,
+		MachineName: m.Name,
+		Previous:    previous,
+		Current:     m.h.level,
+		OccurredAt:  now,
+		Since:       m.healthChangedAt,
+
+		MissingUpdates: mu,
+	})
 
 	if err != nil {
 		return err
@@ -85,3 +180,27 @@ const (
 	Warning
 	Danger
 )
+
+func (l HealthLevel) String() string {
+	switch l {
+	case Healthy:
+		return "Healthy"
+	case Warning:
+		return "Warning"
+	case Danger:
+		return "Danger"
+	default:
+		return fmt.Sprintf("HealthLevel(%d)", int(l))
+	}
+}
+
+// Parses health level from its name, e.g. from configuration.
+func ParseHealthLevel(s string) (HealthLevel, error) {
+	for _, l := range []HealthLevel{Healthy, Warning, Danger} {
+		if l.String() == s {
+			return l, nil
+		}
+	}
+
+	return Healthy, fmt.Errorf("unknown health level '%s'", s)
+}
@@ -3,6 +3,7 @@ package entities
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -65,6 +66,88 @@ func TestReport(t *testing.T) {
 	}
 }
 
+func TestReportEvent(t *testing.T) {
+	strategyMock := &notificationStrategy{}
+	machine := Machine{
+		h:       health{Warning},
+		missing: []MissingUpdate{},
+		Name:    machineName,
+		Id:      MachineId(uuid.New()),
+	}
+
+	before := time.Now()
+	err := machine.Report(createMissingUpdates(Critical), strategyMock)
+
+	if err != nil {
+		t.Errorf("Report should not return error %s!", err)
+	}
+
+	e := strategyMock.reportedEvent
+	if e.MachineName != machineName {
+		t.Errorf("Event machine name mismatch! Expected %s, but was %s", machineName, e.MachineName)
+	}
+
+	if e.Previous != Warning || e.Current != Danger {
+		t.Errorf("Event levels mismatch! Expected %s -> %s, but was %s -> %s", Warning, Danger, e.Previous, e.Current)
+	}
+
+	if len(e.MissingUpdates) != 1 || e.MissingUpdates[0].Severity != Critical {
+		t.Errorf("Event missing updates mismatch! Was %v", e.MissingUpdates)
+	}
+
+	if !e.Changed() {
+		t.Error("Event should be changed!")
+	}
+
+	if e.OccurredAt.Before(before) {
+		t.Errorf("Event time %s is before report %s", e.OccurredAt, before)
+	}
+}
+
+func TestReportTracksHealthChange(t *testing.T) {
+	strategyMock := &notificationStrategy{}
+	machine := CreateMachine(MachineId(uuid.New()), machineName, []MissingUpdate{})
+
+	machine.Report(createMissingUpdates(Critical), strategyMock)
+	since := machine.GetHealthChangedAt()
+	if since.IsZero() || !strategyMock.reportedEvent.Since.Equal(since) {
+		t.Fatalf("Health change time mismatch! Machine has %s, event has %s", since, strategyMock.reportedEvent.Since)
+	}
+
+	machine.Report(createMissingUpdates(Important), strategyMock)
+	if !machine.GetHealthChangedAt().Equal(since) {
+		t.Errorf("Health change time should be kept, while level is the same, but was %s", machine.GetHealthChangedAt())
+	}
+
+	machine.Report([]MissingUpdate{}, strategyMock)
+	if !machine.GetHealthChangedAt().After(since) {
+		t.Errorf("Health change time should be updated on recovery, but was %s", machine.GetHealthChangedAt())
+	}
+}
+
+func TestHealthLevelString(t *testing.T) {
+	if Danger.String() != "Danger" || HealthLevel(7).String() != "HealthLevel(7)" {
+		t.Errorf("Health level string mismatch! Was %s and %s", Danger, HealthLevel(7))
+	}
+
+	if Important.String() != "Important" || Severity(7).String() != "Severity(7)" {
+		t.Errorf("Severity string mismatch! Was %s and %s", Important, Severity(7))
+	}
+}
+
+func TestParseHealthLevel(t *testing.T) {
+	for _, l := range []HealthLevel{Healthy, Warning, Danger} {
+		actual, err := ParseHealthLevel(l.String())
+		if err != nil || actual != l {
+			t.Errorf("Health level mismatch! Expected %s, but was %s (%v)", l, actual, err)
+		}
+	}
+
+	if _, err := ParseHealthLevel("Sick"); err == nil {
+		t.Error("Unknown health level should not be parsed!")
+	}
+}
+
 func TestReportReturnsError(t *testing.T) {
 	strategyMock := &notificationStrategy{
 		shouldReturnError: true,
@@ -88,6 +171,86 @@ func TestReportReturnsError(t *testing.T) {
 	}
 }
 
+func TestReportReturnsErrorIfDecommissioned(t *testing.T) {
+	strategyMock := &notificationStrategy{
+		reportedHealthLevel: -1,
+	}
+
+	machine := CreateMachine(MachineId(uuid.New()), machineName, []MissingUpdate{})
+	machine.Decommission()
+
+	err := machine.Report(createMissingUpdates(Critical), strategyMock)
+
+	if err != ErrMachineDecommissioned {
+		t.Errorf("Error mismatch! Expected %s, but was %s", ErrMachineDecommissioned, err)
+	}
+
+	if strategyMock.reportedHealthLevel != -1 {
+		t.Errorf("Strategy should not be notified about decommissioned machine!")
+	}
+
+	if len(machine.GetMissingUpdates()) != 0 {
+		t.Errorf("Decommissioned machine should keep its missing updates!")
+	}
+}
+
+func TestDecommissionAndReactivate(t *testing.T) {
+	machine := CreateMachine(MachineId(uuid.New()), machineName, []MissingUpdate{})
+
+	if machine.IsDecommissioned() {
+		t.Errorf("New machine should not be decommissioned!")
+	}
+
+	machine.Decommission()
+
+	if !machine.IsDecommissioned() {
+		t.Errorf("Machine should be decommissioned!")
+	}
+
+	machine.Reactivate()
+
+	if machine.IsDecommissioned() {
+		t.Errorf("Machine should be reactivated!")
+	}
+}
+
+func TestVersion(t *testing.T) {
+	machine := CreateMachine(MachineId(uuid.New()), machineName, []MissingUpdate{})
+
+	if machine.GetVersion() != "" {
+		t.Errorf("New machine should not have version, but was %s", machine.GetVersion())
+	}
+
+	machine.SetVersion("42")
+
+	if machine.GetVersion() != "42" {
+		t.Errorf("Version mismatch! Expected %s, but was %s", "42", machine.GetVersion())
+	}
+}
+
+func TestClone(t *testing.T) {
+	machine := CreateMachine(MachineId(uuid.New()), machineName, createMissingUpdates(Critical))
+	machine.SetVersion("1")
+
+	clone := machine.Clone()
+	clone.GetMissingUpdates()[0].Severity = Important
+	clone.Report(createMissingUpdates(Low), &notificationStrategy{})
+	clone.SetVersion("2")
+	clone.Decommission()
+
+	if machine.GetHealthLevel() != Danger || machine.GetMissingUpdates()[0].Severity != Critical {
+		t.Errorf("Changes of clone should not affect the original!")
+	}
+
+	if machine.GetVersion() != "1" || machine.IsDecommissioned() {
+		t.Errorf("Changes of clone should not affect the original!")
+	}
+
+	if clone.Id != machine.Id || clone.Name != machine.Name {
+		t.Errorf("Clone should have the same identity!")
+	}
+}
+
 func TestGetHealthLevel(t *testing.T) {
 	machine := Machine{
 		h:       health{Warning},
@@ -147,16 +310,18 @@ func BenchmarkReport(b *testing.B) {
 type notificationStrategy struct {
 	reportedMachineId   MachineId
 	reportedHealthLevel HealthLevel
+	reportedEvent       HealthEvent
 	shouldReturnError   bool
 }
 
-func (s *notificationStrategy) Notify(machineId MachineId, level HealthLevel) error {
+func (s *notificationStrategy) Notify(e HealthEvent) error {
 	if s.shouldReturnError {
 		return errReport
 	}
 
-	s.reportedMachineId = machineId
-	s.reportedHealthLevel = level
+	s.reportedMachineId = e.MachineId
+	s.reportedHealthLevel = e.Current
+	s.reportedEvent = e
 	return nil
 }
 
@@ -0,0 +1,198 @@
+package entities
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Labels of health event, which can be matched by silences.
+const (
+	MachineLabel string = "machine"
+	TagLabel     string = "tag"
+	GroupLabel   string = "group"
+	UpdateLabel  string = "update"
+	LevelLabel   string = "level"
+)
+
+// Operators of silence matchers, regular expressions are anchored and match the whole value.
+const (
+	EqualOperator     string = "="
+	NotEqualOperator  string = "!="
+	RegexpOperator    string = "=~"
+	NotRegexpOperator string = "!~"
+)
+
+// Type for silence identifier.
+type SilenceId uuid.UUID
+
+func (id SilenceId) String() string {
+	return uuid.UUID(id).String()
+}
+
+// Condition of silence on a single label, e.g. tag = dc or machine =~ web-.*
+type SilenceMatcher struct {
+	Label    string
+	Operator string
+	Value    string
+	// Expression of regexp operators, it's compiled once by Compile.
+	re *regexp.Regexp
+}
+
+// Checks, that matcher has known label and operator and valid regular expression.
+func (m SilenceMatcher) Validate() error {
+	return m.Compile()
+}
+
+// Checks matcher and compiles its regular expression, so events are matched without compiling it again.
+func (m *SilenceMatcher) Compile() error {
+	switch m.Label {
+	case MachineLabel, TagLabel, GroupLabel, UpdateLabel, LevelLabel:
+	default:
+		return fmt.Errorf("unknown label '%s'", m.Label)
+	}
+
+	switch m.Operator {
+	case EqualOperator, NotEqualOperator:
+		return nil
+	case RegexpOperator, NotRegexpOperator:
+		re, err := m.regexp()
+		m.re = re
+		return err
+	default:
+		return fmt.Errorf("unknown operator '%s'", m.Operator)
+	}
+}
+
+// Returns true, if any of label values matches positive operator, or none of them matches negative one.
+// Matcher, which is not compiled, compiles its expression on every call.
+func (m SilenceMatcher) Matches(values []string) bool {
+	re := m.re
+	if re == nil && (m.Operator == RegexpOperator || m.Operator == NotRegexpOperator) {
+		var err error
+		if re, err = m.regexp(); err != nil {
+			return false
+		}
+	}
+
+	found := false
+	for _, v := range values {
+		if (re != nil && re.MatchString(v)) || (re == nil && v == m.Value) {
+			found = true
+			break
+		}
+	}
+
+	if m.Operator == NotEqualOperator || m.Operator == NotRegexpOperator {
+		return !found
+	}
+
+	return found
+}
+
+// Returns matcher as it's written, e.g. machine=~web-.*
+func (m SilenceMatcher) String() string {
+	return m.Label + m.Operator + m.Value
+}
+
+func (m SilenceMatcher) regexp() (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + m.Value + ")$")
+}
+
+// Silence suppresses notifications about events, matching all its matchers, between start and end times.
+type Silence struct {
+	Id        SilenceId
+	Matchers  []SilenceMatcher
+	StartsAt  time.Time
+	EndsAt    time.Time
+	CreatedBy string
+	Comment   string
+	CreatedAt time.Time
+}
+
+// Checks, that silence has valid matchers, creator and time range.
+func (s Silence) Validate() error {
+	if len(s.Matchers) == 0 {
+		return errors.New("silence should have at least one matcher")
+	}
+
+	if err := s.Compile(); err != nil {
+		return err
+	}
+
+	if s.CreatedBy == "" {
+		return errors.New("silence should have creator")
+	}
+
+	if !s.EndsAt.After(s.StartsAt) {
+		return errors.New("silence should end after its start")
+	}
+
+	return nil
+}
+
+// Compiles matchers of silence in place, so silence, which is loaded or validated once, matches events
+// without compiling expressions.
+func (s Silence) Compile() error {
+	for i := range s.Matchers {
+		if err := s.Matchers[i].Compile(); err != nil {
+			return fmt.Errorf("invalid matcher %s - %w", s.Matchers[i], err)
+		}
+	}
+
+	return nil
+}
+
+// Returns true, if silence applies at given time.
+func (s Silence) IsActive(at time.Time) bool {
+	return !at.Before(s.StartsAt) && at.Before(s.EndsAt)
+}
+
+// Returns true, if silence applies at given time and all its matchers match event labels.
+func (s Silence) Matches(labels map[string][]string, at time.Time) bool {
+	if !s.IsActive(at) {
+		return false
+	}
+
+	for _, m := range s.Matchers {
+		if !m.Matches(labels[m.Label]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Operator's confirmation, that machine problem is known. Acknowledgment applies to health episode,
+// started at Since, and it's outdated, when machine changes its health level.
+type Acknowledgment struct {
+	MachineId MachineId
+	Since     time.Time
+	CreatedBy string
+	Comment   string
+	CreatedAt time.Time
+}
+
+// Returns true, if acknowledgment applies to health episode, started at since.
+func (a Acknowledgment) Covers(since time.Time) bool {
+	return !since.IsZero() && a.Since.Equal(since)
+}
+
+// Returns labels of event for silence matching, groups and tags of machine are defined outside of entities.
+func (e HealthEvent) Labels(groups []string, tags []string) map[string][]string {
+	updates := []string{}
+	for _, u := range e.MissingUpdates {
+		updates = append(updates, u.UpdateId.String())
+	}
+
+	return map[string][]string{
+		MachineLabel: {e.MachineName},
+		TagLabel:     tags,
+		GroupLabel:   groups,
+		UpdateLabel:  updates,
+		LevelLabel:   {e.Current.String()},
+	}
+}
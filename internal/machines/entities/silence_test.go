@@ -0,0 +1,109 @@
+package entities
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSilenceMatchers(t *testing.T) {
+	update := uuid.New()
+	e := HealthEvent{
+		MachineName:    "web-01",
+		Current:        Danger,
+		MissingUpdates: []MissingUpdate{{UpdateId: update, Severity: Critical}},
+	}
+	labels := e.Labels([]string{"servers", "web"}, []string{"dc"})
+
+	var cases = []struct {
+		matcher  SilenceMatcher
+		expected bool
+	}{
+		{SilenceMatcher{Label: MachineLabel, Operator: EqualOperator, Value: "web-01"}, true},
+		{SilenceMatcher{Label: MachineLabel, Operator: EqualOperator, Value: "web"}, false},
+		{SilenceMatcher{Label: MachineLabel, Operator: RegexpOperator, Value: "web-.*"}, true},
+		{SilenceMatcher{Label: MachineLabel, Operator: RegexpOperator, Value: "web"}, false},
+		{SilenceMatcher{Label: GroupLabel, Operator: EqualOperator, Value: "web"}, true},
+		{SilenceMatcher{Label: GroupLabel, Operator: NotEqualOperator, Value: "web"}, false},
+		{SilenceMatcher{Label: TagLabel, Operator: NotRegexpOperator, Value: "lab|test"}, true},
+		{SilenceMatcher{Label: UpdateLabel, Operator: EqualOperator, Value: update.String()}, true},
+		{SilenceMatcher{Label: LevelLabel, Operator: EqualOperator, Value: "Warning"}, false},
+	}
+
+	for _, c := range cases {
+		if actual := c.matcher.Matches(labels[c.matcher.Label]); actual != c.expected {
+			t.Errorf("Matcher %+v mismatch! Expected %t, but was %t", c.matcher, c.expected, actual)
+		}
+	}
+}
+
+func TestSilenceMatchesWhileActive(t *testing.T) {
+	start := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := Silence{
+		Matchers: []SilenceMatcher{
+			{Label: MachineLabel, Operator: RegexpOperator, Value: "web-.*"},
+			{Label: LevelLabel, Operator: EqualOperator, Value: "Danger"},
+		},
+		StartsAt:  start,
+		EndsAt:    start.Add(time.Hour),
+		CreatedBy: "alice",
+	}
+	labels := HealthEvent{MachineName: "web-01", Current: Danger}.Labels(nil, nil)
+
+	if err := s.Validate(); err != nil {
+		t.Fatalf("Silence should be valid, but got error %s", err)
+	}
+
+	if s.Matchers[0].re == nil {
+		t.Error("Expression of validated silence should be compiled once!")
+	}
+
+	if s.Matches(labels, start.Add(-time.Second)) || !s.Matches(labels, start) || s.Matches(labels, start.Add(time.Hour)) {
+		t.Error("Silence should match only between its start and end!")
+	}
+
+	labels[LevelLabel] = []string{"Warning"}
+	if s.Matches(labels, start) {
+		t.Error("Silence should match only if all matchers match!")
+	}
+}
+
+func TestInvalidSilence(t *testing.T) {
+	start := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	valid := Silence{
+		Matchers:  []SilenceMatcher{{Label: TagLabel, Operator: EqualOperator, Value: "dc"}},
+		StartsAt:  start,
+		EndsAt:    start.Add(time.Hour),
+		CreatedBy: "alice",
+	}
+
+	invalid := []func(s *Silence){
+		func(s *Silence) { s.Matchers = nil },
+		func(s *Silence) { s.Matchers[0].Label = "owner" },
+		func(s *Silence) { s.Matchers[0].Operator = "~" },
+		func(s *Silence) {
+			s.Matchers[0] = SilenceMatcher{Label: MachineLabel, Operator: RegexpOperator, Value: "web-("}
+		},
+		func(s *Silence) { s.CreatedBy = "" },
+		func(s *Silence) { s.EndsAt = s.StartsAt },
+	}
+
+	for i, change := range invalid {
+		s := valid
+		s.Matchers = append([]SilenceMatcher{}, valid.Matchers...)
+		change(&s)
+		if err := s.Validate(); err == nil {
+			t.Errorf("Expected error for silence %d, but was nil", i)
+		}
+	}
+}
+
+func TestAcknowledgmentCoversEpisode(t *testing.T) {
+	since := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	a := Acknowledgment{Since: since}
+
+	if !a.Covers(since) || a.Covers(since.Add(time.Minute)) || (Acknowledgment{}).Covers(time.Time{}) {
+		t.Error("Acknowledgment should cover its own health episode only!")
+	}
+}
@@ -0,0 +1,148 @@
+package ports
+
+import (
+	"dum/internal/machines/cases"
+	"dum/internal/machines/entities"
+	"dum/pkg/machines/contract"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// Handler for acknowledging the current health episode of machine, which stops its escalation and
+// suppresses its notifications until machine changes health level.
+type AcknowledgeHandler struct {
+	repo    cases.MachineRepository
+	acks    cases.SilenceRepository
+	pattern regexp.Regexp
+	now     func() time.Time
+}
+
+func (h *AcknowledgeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/v1/acknowledgments" {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+		h.listAcknowledgments(w)
+		return
+	}
+
+	id, status := parseMachineId(&h.pattern, r)
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		h.acknowledge(w, r, id)
+	case http.MethodGet:
+		h.getAcknowledgment(w, id)
+	case http.MethodDelete:
+		if err := h.acks.DeleteAcknowledgment(id); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func (h *AcknowledgeHandler) acknowledge(w http.ResponseWriter, r *http.Request, id entities.MachineId) {
+	// body is optional, acknowledgment without it is anonymous
+	var request contract.AcknowledgmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	m, err := h.repo.Load(id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if m == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// healthy machine or machine with unknown health episode has nothing to acknowledge
+	if m.GetHealthLevel() == entities.Healthy || m.GetHealthChangedAt().IsZero() {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	err = h.acks.SaveAcknowledgment(entities.Acknowledgment{
+		MachineId: id,
+		Since:     m.GetHealthChangedAt(),
+		CreatedBy: request.CreatedBy,
+		Comment:   request.Comment,
+		CreatedAt: h.now().UTC(),
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AcknowledgeHandler) getAcknowledgment(w http.ResponseWriter, id entities.MachineId) {
+	a, err := h.acks.LoadAcknowledgment(id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if a == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	response := toAcknowledgmentResponse(*a)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&response)
+}
+
+func (h *AcknowledgeHandler) listAcknowledgments(w http.ResponseWriter) {
+	acks, err := h.acks.ListAcknowledgments()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := []contract.AcknowledgmentResponse{}
+	for _, a := range acks {
+		response = append(response, toAcknowledgmentResponse(a))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&response)
+}
+
+func toAcknowledgmentResponse(a entities.Acknowledgment) contract.AcknowledgmentResponse {
+	return contract.AcknowledgmentResponse{
+		MachineId: a.MachineId.String(),
+		Since:     a.Since.Format(time.RFC3339Nano),
+		CreatedBy: a.CreatedBy,
+		Comment:   a.Comment,
+		CreatedAt: a.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func NewAcknowledgeHandler(r cases.MachineRepository, a cases.SilenceRepository) *AcknowledgeHandler {
+	return &AcknowledgeHandler{
+		repo:    r,
+		acks:    a,
+		pattern: *regexp.MustCompile(`^/api/v1/machines/([^/]+)/acknowledge$`),
+		now:     time.Now,
+	}
+}
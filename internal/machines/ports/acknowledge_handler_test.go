@@ -0,0 +1,87 @@
+package ports
+
+import (
+	"dum/internal/machines/entities"
+	"dum/pkg/machines/contract"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestAcknowledgeMachine(t *testing.T) {
+	since := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	danger := entities.CreateMachine(entities.MachineId(uuid.New()), "web-01", []entities.MissingUpdate{
+		{UpdateId: uuid.New(), Severity: entities.Critical, Duration: time.Hour},
+	})
+	danger.SetHealthChangedAt(since)
+	healthy := entities.CreateMachine(entities.MachineId(uuid.New()), "web-02", []entities.MissingUpdate{})
+	healthy.SetHealthChangedAt(since)
+
+	acks := newSilenceRepositoryMock()
+	handler := NewAcknowledgeHandler(newMemoryRepositoryMock(danger, healthy), acks)
+
+	var requests = []struct {
+		id       string
+		expected int
+	}{
+		{danger.Id.String(), http.StatusNoContent},
+		{healthy.Id.String(), http.StatusConflict},
+		{uuid.NewString(), http.StatusNotFound},
+		{"not-an-id", http.StatusBadRequest},
+	}
+
+	for _, c := range requests {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/machines/"+c.id+"/acknowledge", nil))
+
+		if recorder.Code != c.expected {
+			t.Errorf("Response code mismatch for %s! Expected %d, but was %d!", c.id, c.expected, recorder.Code)
+		}
+	}
+
+	if acked, _ := acks.IsAcknowledged(danger.Id, since); !acked {
+		t.Error("Health episode should be acknowledged!")
+	}
+}
+
+func TestAcknowledgmentCrud(t *testing.T) {
+	since := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	danger := entities.CreateMachine(entities.MachineId(uuid.New()), "web-01", []entities.MissingUpdate{
+		{UpdateId: uuid.New(), Severity: entities.Critical, Duration: time.Hour},
+	})
+	danger.SetHealthChangedAt(since)
+	acks := newSilenceRepositoryMock()
+	handler := NewAcknowledgeHandler(newMemoryRepositoryMock(danger), acks)
+	path := "/api/v1/machines/" + danger.Id.String() + "/acknowledge"
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"CreatedBy":"alice","Comment":"rollback is running"}`)))
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("Response code mismatch! Expected %d, but was %d!", http.StatusNoContent, recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/acknowledgments", nil))
+	var list []contract.AcknowledgmentResponse
+	json.NewDecoder(recorder.Body).Decode(&list)
+	if len(list) != 1 || list[0].CreatedBy != "alice" || list[0].Since != "2021-06-01T12:00:00Z" {
+		t.Errorf("Acknowledgments mismatch! Was %+v", list)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, path, nil))
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", http.StatusNoContent, recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", http.StatusNotFound, recorder.Code)
+	}
+}
@@ -0,0 +1,111 @@
+package ports
+
+import (
+	"dum/internal/machines/cases"
+	"dum/pkg/machines/contract"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Limit of archive size, which can be imported over HTTP.
+const maxImportSize int64 = 256 * 1024 * 1024
+
+// Handler for administrative operations - export and import of the whole machine store.
+type AdminHandler struct {
+	repo cases.MachineRepository
+	now  func() time.Time
+}
+
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.export(w, r)
+	case http.MethodPost:
+		h.importArchive(w, r)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// Writes archive of all machines, format is selected by "format" query parameter, ndjson by default.
+func (h *AdminHandler) export(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = NdjsonArchiveFormat
+	}
+
+	if format != NdjsonArchiveFormat && format != JsonArchiveFormat {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	machines, err := h.repo.List()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if format == NdjsonArchiveFormat {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename=machines."+format)
+	w.WriteHeader(http.StatusOK)
+
+	WriteArchive(w, machines, format, h.now())
+}
+
+// Restores machines from archive in request body, existing machines are handled according to "mode"
+// query parameter - skip (default) or overwrite, so the fleet is overwritten only, if it's asked explicitly.
+func (h *AdminHandler) importArchive(w http.ResponseWriter, r *http.Request) {
+	mode := cases.RestoreMode(r.URL.Query().Get("mode"))
+	if mode == "" {
+		mode = cases.RestoreSkip
+	}
+
+	if mode != cases.RestoreOverwrite && mode != cases.RestoreSkip {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	body := &countingReader{r: http.MaxBytesReader(w, r.Body, maxImportSize)}
+	_, machines, err := ReadArchive(body)
+	if err != nil && body.n >= maxImportSize {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	report := cases.RestoreMachines(h.repo, machines, mode)
+	response := contract.ImportResponse{
+		Restored: report.Restored,
+		Skipped:  report.Skipped,
+		Failed:   len(report.Errors),
+	}
+
+	for _, err := range report.Errors {
+		response.Errors = append(response.Errors, err.Error())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if response.Failed == 0 {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusMultiStatus)
+	}
+
+	json.NewEncoder(w).Encode(&response)
+}
+
+func NewAdminHandler(r cases.MachineRepository) *AdminHandler {
+	return &AdminHandler{
+		repo: r,
+		now:  time.Now,
+	}
+}
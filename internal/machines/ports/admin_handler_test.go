@@ -0,0 +1,168 @@
+package ports
+
+import (
+	"bytes"
+	"dum/internal/machines/entities"
+	"dum/pkg/machines/contract"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExportArchive(t *testing.T) {
+	repo := newMemoryRepositoryMock(createArchiveTestMachines()...)
+	handler := NewAdminHandler(repo)
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/admin/export?format=json", nil))
+
+	if recorder.Code != 200 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 200, recorder.Code)
+	}
+
+	var archive contract.Archive
+	err := json.NewDecoder(recorder.Body).Decode(&archive)
+	if err != nil {
+		t.Fatalf("Cannot decode archive due to error %s", err)
+	}
+
+	if archive.Header.Machines != 2 || len(archive.Machines) != 2 {
+		t.Errorf("Machines count mismatch! Expected %d, but was %d", 2, len(archive.Machines))
+	}
+}
+
+func TestExportUnknownFormat(t *testing.T) {
+	handler := NewAdminHandler(newMemoryRepositoryMock())
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/admin/export?format=xml", nil))
+
+	if recorder.Code != 400 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 400, recorder.Code)
+	}
+}
+
+func TestImportArchive(t *testing.T) {
+	machines := createArchiveTestMachines()
+	existing := entities.CreateMachine(machines[0].Id, "existing", []entities.MissingUpdate{})
+	repo := newMemoryRepositoryMock(existing)
+	handler := NewAdminHandler(repo)
+	body := &bytes.Buffer{}
+	WriteArchive(body, machines, NdjsonArchiveFormat, time.Now())
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/admin/import?mode=skip", body))
+
+	if recorder.Code != 200 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 200, recorder.Code)
+	}
+
+	var response contract.ImportResponse
+	json.NewDecoder(recorder.Body).Decode(&response)
+	if response.Restored != 1 || response.Skipped != 1 || response.Failed != 0 {
+		t.Errorf("Unexpected import response %+v", response)
+	}
+
+	if repo.machines[machines[0].Id].Name != "existing" {
+		t.Errorf("Existing machine should be skipped!")
+	}
+
+	if repo.machines[machines[1].Id] == nil {
+		t.Errorf("Absent machine should be restored!")
+	}
+}
+
+func TestImportInvalidArchive(t *testing.T) {
+	handler := NewAdminHandler(newMemoryRepositoryMock())
+
+	for _, target := range []string{"/api/v1/admin/import", "/api/v1/admin/import?mode=merge"} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, target, bytes.NewBufferString("not an archive")))
+
+		if recorder.Code != 400 {
+			t.Errorf("Response code mismatch! Expected %d, but was %d!", 400, recorder.Code)
+		}
+	}
+}
+
+func TestImportSkipsExistingMachinesByDefault(t *testing.T) {
+	machines := createArchiveTestMachines()
+	existing := entities.CreateMachine(machines[0].Id, "existing", []entities.MissingUpdate{})
+	repo := newMemoryRepositoryMock(existing)
+	handler := NewAdminHandler(repo)
+	archive := &bytes.Buffer{}
+	WriteArchive(archive, machines, NdjsonArchiveFormat, time.Now())
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/admin/import", archive))
+
+	if recorder.Code != 200 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 200, recorder.Code)
+	}
+
+	if repo.machines[existing.Id].Name != "existing" {
+		t.Errorf("Existing machine should be overwritten only with explicit mode!")
+	}
+}
+
+func TestImportTooLargeArchive(t *testing.T) {
+	handler := NewAdminHandler(newMemoryRepositoryMock())
+	recorder := httptest.NewRecorder()
+	body := io.LimitReader(&blankReader{}, maxImportSize+1)
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/admin/import", body))
+
+	if recorder.Code != 413 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 413, recorder.Code)
+	}
+}
+
+// Reader of endless whitespace.
+type blankReader struct{}
+
+func (r *blankReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = ' '
+	}
+
+	return len(p), nil
+}
+
+type memoryRepositoryMock struct {
+	machines map[entities.MachineId]*entities.Machine
+}
+
+func newMemoryRepositoryMock(machines ...*entities.Machine) *memoryRepositoryMock {
+	r := &memoryRepositoryMock{machines: map[entities.MachineId]*entities.Machine{}}
+	for _, m := range machines {
+		r.machines[m.Id] = m
+	}
+
+	return r
+}
+
+func (r *memoryRepositoryMock) Load(id entities.MachineId) (*entities.Machine, error) {
+	return r.machines[id], nil
+}
+
+func (r *memoryRepositoryMock) List() ([]*entities.Machine, error) {
+	machines := []*entities.Machine{}
+	for _, m := range r.machines {
+		machines = append(machines, m)
+	}
+
+	return machines, nil
+}
+
+func (r *memoryRepositoryMock) Save(machine *entities.Machine) error {
+	r.machines[machine.Id] = machine
+	return nil
+}
+
+func (r *memoryRepositoryMock) Delete(id entities.MachineId) error {
+	delete(r.machines, id)
+	return nil
+}
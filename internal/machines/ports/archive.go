@@ -0,0 +1,202 @@
+package ports
+
+import (
+	"dum/internal/machines/entities"
+	"dum/pkg/machines/contract"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Version of machine store archive format.
+const ArchiveFormatVersion int = 1
+
+// Formats of machine store archive.
+const (
+	// Header line followed by line per machine.
+	NdjsonArchiveFormat string = "ndjson"
+	// Single JSON document with header and array of machines.
+	JsonArchiveFormat string = "json"
+)
+
+// Writes machines as portable archive in given format.
+func WriteArchive(w io.Writer, machines []*entities.Machine, format string, createdAt time.Time) error {
+	header := contract.ArchiveHeader{
+		FormatVersion: ArchiveFormatVersion,
+		CreatedAt:     createdAt.UTC().Format(time.RFC3339),
+		Machines:      len(machines),
+	}
+
+	enc := json.NewEncoder(w)
+	switch format {
+	case JsonArchiveFormat:
+		archive := contract.Archive{Header: header, Machines: []contract.ArchivedMachine{}}
+		for _, m := range machines {
+			archive.Machines = append(archive.Machines, toArchivedMachine(m))
+		}
+
+		return enc.Encode(&archive)
+	case NdjsonArchiveFormat:
+		err := enc.Encode(&header)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range machines {
+			archived := toArchivedMachine(m)
+			err = enc.Encode(&archived)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unknown archive format '%s'", format)
+	}
+}
+
+// Reads archive of any format. Returns an error, if archive is truncated or contains invalid machines.
+func ReadArchive(r io.Reader) (contract.ArchiveHeader, []*entities.Machine, error) {
+	dec := json.NewDecoder(r)
+
+	var first json.RawMessage
+	err := dec.Decode(&first)
+	if err != nil {
+		return contract.ArchiveHeader{}, nil, fmt.Errorf("cannot read archive header - %w", err)
+	}
+
+	var probe struct {
+		Header *contract.ArchiveHeader
+	}
+
+	err = json.Unmarshal(first, &probe)
+	if err != nil {
+		return contract.ArchiveHeader{}, nil, fmt.Errorf("cannot read archive header - %w", err)
+	}
+
+	var archive contract.Archive
+	if probe.Header != nil {
+		err = json.Unmarshal(first, &archive)
+	} else {
+		archive.Machines, err = readArchivedMachines(dec)
+		if err == nil {
+			err = json.Unmarshal(first, &archive.Header)
+		}
+	}
+
+	if err != nil {
+		return archive.Header, nil, err
+	}
+
+	return fromArchive(archive)
+}
+
+func readArchivedMachines(dec *json.Decoder) ([]contract.ArchivedMachine, error) {
+	machines := []contract.ArchivedMachine{}
+	for {
+		var archived contract.ArchivedMachine
+		err := dec.Decode(&archived)
+		if err == io.EOF {
+			return machines, nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("cannot read archived machine %d - %w", len(machines), err)
+		}
+
+		machines = append(machines, archived)
+	}
+}
+
+func fromArchive(archive contract.Archive) (contract.ArchiveHeader, []*entities.Machine, error) {
+	header := archive.Header
+	if header.FormatVersion != ArchiveFormatVersion {
+		return header, nil, fmt.Errorf("unsupported archive format version %d", header.FormatVersion)
+	}
+
+	if header.Machines != len(archive.Machines) {
+		return header, nil, fmt.Errorf("archive is truncated, expected %d machines, but got %d", header.Machines, len(archive.Machines))
+	}
+
+	machines := []*entities.Machine{}
+	for i, archived := range archive.Machines {
+		machine, err := fromArchivedMachine(archived)
+		if err != nil {
+			return header, nil, fmt.Errorf("invalid archived machine %d - %w", i, err)
+		}
+
+		machines = append(machines, machine)
+	}
+
+	return header, machines, nil
+}
+
+func toArchivedMachine(m *entities.Machine) contract.ArchivedMachine {
+	archived := contract.ArchivedMachine{
+		Id:              m.Id.String(),
+		Name:            m.Name,
+		Decommissioned:  m.IsDecommissioned(),
+		MissingUpdates:  toMissingUpdateDtos(m.GetMissingUpdates()),
+		HealthChangedAt: formatHealthChangedAt(m),
+	}
+
+	return archived
+}
+
+// Returns time of the last health level change in RFC 3339 format or empty string, if it's unknown.
+func formatHealthChangedAt(m *entities.Machine) string {
+	if m.GetHealthChangedAt().IsZero() {
+		return ""
+	}
+
+	return m.GetHealthChangedAt().UTC().Format(time.RFC3339Nano)
+}
+
+func toMissingUpdateDtos(updates []entities.MissingUpdate) []contract.MissingUpdate {
+	result := []contract.MissingUpdate{}
+	for _, update := range updates {
+		result = append(result, contract.MissingUpdate{
+			UpdateId: update.UpdateId.String(),
+			Severity: int(update.Severity),
+			Duration: update.Duration.String(),
+		})
+	}
+
+	return result
+}
+
+func fromArchivedMachine(archived contract.ArchivedMachine) (*entities.Machine, error) {
+	id, err := uuid.Parse(archived.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	missingUpdates := []entities.MissingUpdate{}
+	for _, dto := range archived.MissingUpdates {
+		update, err := convert(dto)
+		if err != nil {
+			return nil, err
+		}
+
+		missingUpdates = append(missingUpdates, update)
+	}
+
+	machine := entities.CreateMachine(entities.MachineId(id), archived.Name, missingUpdates)
+	if archived.Decommissioned {
+		machine.Decommission()
+	}
+
+	if archived.HealthChangedAt != "" {
+		changedAt, err := time.Parse(time.RFC3339Nano, archived.HealthChangedAt)
+		if err != nil {
+			return nil, err
+		}
+		machine.SetHealthChangedAt(changedAt)
+	}
+
+	return machine, nil
+}
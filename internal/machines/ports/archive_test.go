@@ -0,0 +1,99 @@
+package ports
+
+import (
+	"bytes"
+	"dum/internal/machines/entities"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	for _, format := range []string{NdjsonArchiveFormat, JsonArchiveFormat} {
+		machines := createArchiveTestMachines()
+		buffer := &bytes.Buffer{}
+
+		err := WriteArchive(buffer, machines, format, time.Now())
+		if err != nil {
+			t.Errorf("Archive in %s format should be written, but got error %s", format, err)
+			continue
+		}
+
+		header, restored, err := ReadArchive(buffer)
+		if err != nil {
+			t.Errorf("Archive in %s format should be read, but got error %s", format, err)
+			continue
+		}
+
+		if header.Machines != len(machines) || len(restored) != len(machines) {
+			t.Errorf("Machines count mismatch! Expected %d, but was %d", len(machines), len(restored))
+			continue
+		}
+
+		for i, m := range machines {
+			if restored[i].Id != m.Id || restored[i].Name != m.Name {
+				t.Errorf("Machine mismatch! Expected %s, but was %s", m.Id, restored[i].Id)
+			}
+
+			if restored[i].IsDecommissioned() != m.IsDecommissioned() {
+				t.Errorf("Decommission mismatch for machine %s!", m.Id)
+			}
+
+			if !restored[i].GetHealthChangedAt().Equal(m.GetHealthChangedAt()) {
+				t.Errorf("Health change time mismatch for machine %s! Expected %s, but was %s", m.Id, m.GetHealthChangedAt(), restored[i].GetHealthChangedAt())
+			}
+
+			if len(restored[i].GetMissingUpdates()) != len(m.GetMissingUpdates()) ||
+				(len(m.GetMissingUpdates()) > 0 && restored[i].GetMissingUpdates()[0] != m.GetMissingUpdates()[0]) {
+				t.Errorf("Missing updates mismatch for machine %s!", m.Id)
+			}
+		}
+	}
+}
+
+func TestTruncatedArchiveError(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	WriteArchive(buffer, createArchiveTestMachines(), NdjsonArchiveFormat, time.Now())
+	lines := strings.SplitAfter(buffer.String(), "\n")
+
+	_, _, err := ReadArchive(strings.NewReader(strings.Join(lines[:2], "")))
+	if err == nil {
+		t.Errorf("Truncated archive should not be read!")
+	}
+}
+
+func TestUnsupportedArchiveVersionError(t *testing.T) {
+	_, _, err := ReadArchive(strings.NewReader(`{"FormatVersion":100,"Machines":0}`))
+	if err == nil {
+		t.Errorf("Archive of unsupported version should not be read!")
+	}
+}
+
+func TestInvalidArchivedMachineError(t *testing.T) {
+	archive := `{"FormatVersion":1,"Machines":1}
+{"Id":"not-uuid","Name":"broken","MissingUpdates":[]}`
+
+	_, _, err := ReadArchive(strings.NewReader(archive))
+	if err == nil {
+		t.Errorf("Archive with invalid machine should not be read!")
+	}
+}
+
+func createArchiveTestMachines() []*entities.Machine {
+	decommissioned := entities.CreateMachine(entities.MachineId(uuid.New()), "retired", []entities.MissingUpdate{})
+	decommissioned.Decommission()
+	decommissioned.SetHealthChangedAt(time.Date(2021, 6, 1, 12, 0, 0, 500, time.UTC))
+
+	return []*entities.Machine{
+		entities.CreateMachine(entities.MachineId(uuid.New()), "active", []entities.MissingUpdate{
+			{
+				UpdateId: uuid.New(),
+				Severity: entities.Critical,
+				Duration: 36 * time.Hour,
+			},
+		}),
+		decommissioned,
+	}
+}
@@ -0,0 +1,38 @@
+package ports
+
+import (
+	"io"
+	"net/http"
+)
+
+// Source of consistent copy of the whole database.
+type BackupSource interface {
+	Backup(w io.Writer) (int64, error)
+}
+
+// Handler writing online copy of the whole database, e.g. bbolt file, which cannot be opened by another
+// process while service is running.
+type BackupHandler struct {
+	source   BackupSource
+	fileName string
+}
+
+func (h *BackupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", "attachment; filename="+h.fileName)
+	w.WriteHeader(http.StatusOK)
+
+	h.source.Backup(w)
+}
+
+func NewBackupHandler(s BackupSource, fileName string) *BackupHandler {
+	return &BackupHandler{
+		source:   s,
+		fileName: fileName,
+	}
+}
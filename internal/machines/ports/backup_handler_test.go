@@ -0,0 +1,48 @@
+package ports
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBackupIsWrittenAsAttachment(t *testing.T) {
+	handler := NewBackupHandler(&backupSourceMock{content: "database"}, "machines.bolt")
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/admin/backup", nil))
+
+	if recorder.Code != 200 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 200, recorder.Code)
+	}
+
+	if actual := recorder.Body.String(); actual != "database" {
+		t.Errorf("Backup mismatch! Expected %s, but was %s", "database", actual)
+	}
+
+	expected := "attachment; filename=machines.bolt"
+	if actual := recorder.Header().Get("Content-Disposition"); actual != expected {
+		t.Errorf("Content disposition mismatch! Expected %s, but was %s", expected, actual)
+	}
+}
+
+func TestBackupNotImplementedIfNotGetMethod(t *testing.T) {
+	handler := NewBackupHandler(&backupSourceMock{}, "machines.bolt")
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/admin/backup", nil))
+
+	if recorder.Code != 501 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 501, recorder.Code)
+	}
+}
+
+type backupSourceMock struct {
+	content string
+}
+
+func (s *backupSourceMock) Backup(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, s.content)
+	return int64(n), err
+}
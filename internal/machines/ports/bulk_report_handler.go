@@ -0,0 +1,221 @@
+package ports
+
+import (
+	"bufio"
+	"bytes"
+	"dum/internal/machines/cases"
+	"dum/internal/machines/entities"
+	"dum/pkg/machines/contract"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+const maxBulkItems int = 10000
+const maxBulkLineSize int = 1024 * 1024
+const maxBulkBodySize int64 = 32 * 1024 * 1024
+
+var errBulkTooLarge error = fmt.Errorf("bulk request can not contain more than %d reports or lines longer than %d bytes", maxBulkItems, maxBulkLineSize)
+
+// Handler for reporting about missing updates of many machines at once. Accepts JSON array
+// or NDJSON stream of reports. Every report is validated and processed independently, ?reactivate=true
+// query is applied to all of them.
+type BulkReportHandler struct {
+	strategy    entities.HealthNotificationStrategy
+	repo        cases.MachineRepository
+	commandChan chan<- cases.Command
+}
+
+func (h *BulkReportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.reportMissingUpdates(w, r)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func (h *BulkReportHandler) reportMissingUpdates(w http.ResponseWriter, r *http.Request) {
+	var items []json.RawMessage
+	var err error
+
+	body := &countingReader{r: http.MaxBytesReader(w, r.Body, maxBulkBodySize)}
+	if isNdjson(r) {
+		items, err = readNdjson(body)
+	} else {
+		items, err = readJsonArray(body)
+	}
+
+	if err == errBulkTooLarge || (err != nil && body.n >= maxBulkBodySize) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	reactivate := r.URL.Query().Get("reactivate") == "true"
+	response := contract.BulkReportResponse{
+		Results: []contract.BulkReportItemResult{},
+	}
+
+	for i, raw := range items {
+		result := h.reportItem(i, raw, reactivate)
+
+		if result.Status == http.StatusAccepted {
+			response.Accepted++
+		} else {
+			response.Rejected++
+		}
+
+		response.Results = append(response.Results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if response.Rejected == 0 {
+		w.WriteHeader(http.StatusAccepted)
+	} else {
+		w.WriteHeader(http.StatusMultiStatus)
+	}
+
+	json.NewEncoder(w).Encode(&response)
+}
+
+func (h *BulkReportHandler) reportItem(index int, raw json.RawMessage, reactivate bool) contract.BulkReportItemResult {
+	result := contract.BulkReportItemResult{
+		Index:  index,
+		Status: http.StatusBadRequest,
+	}
+
+	var item contract.BulkReportItem
+	err := json.Unmarshal(raw, &item)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.MachineId = item.MachineId
+
+	id, err := uuid.Parse(item.MachineId)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	command, err := newReportCommand(entities.MachineId(id), item.ReportRequest, h.strategy, h.repo)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	command.ReactivateDecommissioned = reactivate
+
+	h.commandChan <- command
+	result.Status = http.StatusAccepted
+	return result
+}
+
+func NewBulkReportHandler(s entities.HealthNotificationStrategy, r cases.MachineRepository, c chan<- cases.Command) *BulkReportHandler {
+	return &BulkReportHandler{
+		strategy:    s,
+		repo:        r,
+		commandChan: c,
+	}
+}
+
+func isNdjson(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/x-ndjson" || mediaType == "application/ndjson"
+}
+
+// Decodes array item by item, so request with too many reports is rejected without reading all of them.
+func readJsonArray(body io.Reader) ([]json.RawMessage, error) {
+	dec := json.NewDecoder(body)
+	if err := expectDelim(dec, '['); err != nil {
+		return nil, err
+	}
+
+	items := []json.RawMessage{}
+	for dec.More() {
+		if len(items) == maxBulkItems {
+			return nil, errBulkTooLarge
+		}
+
+		var item json.RawMessage
+		if err := dec.Decode(&item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	if err := expectDelim(dec, ']'); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func expectDelim(dec *json.Decoder, expected json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != expected {
+		return fmt.Errorf("expected '%s', but got %v", expected, token)
+	}
+
+	return nil
+}
+
+func readNdjson(body io.Reader) ([]json.RawMessage, error) {
+	items := []json.RawMessage{}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBulkLineSize)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		if len(items) == maxBulkItems {
+			return nil, errBulkTooLarge
+		}
+
+		item := make(json.RawMessage, len(line))
+		copy(item, line)
+		items = append(items, item)
+	}
+
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, errBulkTooLarge
+		}
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// Counts bytes read from body, so limit of request size can be told apart from other errors.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
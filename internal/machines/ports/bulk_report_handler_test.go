@@ -0,0 +1,168 @@
+package ports
+
+import (
+	"dum/internal/machines/cases"
+	"dum/pkg/machines/contract"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBulkAcceptedJsonArray(t *testing.T) {
+	c := make(chan cases.Command, 2)
+	handler := NewBulkReportHandler(nil, nil, c)
+	recorder := httptest.NewRecorder()
+	body := `[
+		{ "MachineId": "1a3fccff-2d7b-45f0-a3c4-50a7bb50d06e", "MachineName": "first", "MissingUpdates": [{ "duration": "30s", "updateId": "1a3fccff-2d7b-45f0-a3c4-50a7bb50d06c", "severity": 2 }] },
+		{ "MachineId": "1a3fccff-2d7b-45f0-a3c4-50a7bb50d06f", "MachineName": "second", "MissingUpdates": [] }
+	]`
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/reports", strings.NewReader(body)))
+
+	if recorder.Code != 202 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 202, recorder.Code)
+	}
+
+	response := decodeBulkResponse(t, recorder)
+
+	if response.Accepted != 2 || response.Rejected != 0 {
+		t.Errorf("Result mismatch! Expected 2 accepted and 0 rejected, but was %d and %d", response.Accepted, response.Rejected)
+	}
+
+	if len(c) != 2 {
+		t.Errorf("Commands count mismatch! Expected %d, but was %d", 2, len(c))
+	}
+
+	command := (<-c).(*cases.ReportCommand)
+
+	if command.MachineName != "first" {
+		t.Errorf("Machine name mismatch! Expected %s, but was %s", "first", command.MachineName)
+	}
+}
+
+func TestBulkMultiStatusNdjson(t *testing.T) {
+	c := make(chan cases.Command, 3)
+	handler := NewBulkReportHandler(nil, nil, c)
+	recorder := httptest.NewRecorder()
+	body := strings.Join([]string{
+		`{ "MachineId": "1a3fccff-2d7b-45f0-a3c4-50a7bb50d06e", "MachineName": "valid", "MissingUpdates": [] }`,
+		`{ "MachineId": "not-an-id", "MachineName": "invalid id", "MissingUpdates": [] }`,
+		``,
+		`not a json`,
+		`{ "MachineId": "1a3fccff-2d7b-45f0-a3c4-50a7bb50d06f", "MachineName": "invalid update", "MissingUpdates": [{ "duration": "30s", "updateId": "nope", "severity": 2 }] }`,
+	}, "\n")
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/reports", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/x-ndjson")
+
+	handler.ServeHTTP(recorder, request)
+
+	if recorder.Code != 207 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 207, recorder.Code)
+	}
+
+	response := decodeBulkResponse(t, recorder)
+
+	if response.Accepted != 1 || response.Rejected != 3 {
+		t.Errorf("Result mismatch! Expected 1 accepted and 3 rejected, but was %d and %d", response.Accepted, response.Rejected)
+	}
+
+	expectedStatuses := []int{202, 400, 400, 400}
+
+	for i, result := range response.Results {
+		if result.Index != i {
+			t.Errorf("Index mismatch! Expected %d, but was %d", i, result.Index)
+		}
+
+		if result.Status != expectedStatuses[i] {
+			t.Errorf("Status of item %d mismatch! Expected %d, but was %d", i, expectedStatuses[i], result.Status)
+		}
+	}
+
+	if response.Results[1].MachineId != "not-an-id" {
+		t.Errorf("Machine id should be reported for rejected item, but was '%s'", response.Results[1].MachineId)
+	}
+
+	if len(c) != 1 {
+		t.Errorf("Commands count mismatch! Expected %d, but was %d", 1, len(c))
+	}
+}
+
+func TestBulkBadRequestIfNotJsonArray(t *testing.T) {
+	handler := NewBulkReportHandler(nil, nil, make(chan cases.Command))
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/reports", strings.NewReader(`{ "MachineId": "x" }`)))
+
+	if recorder.Code != 400 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 400, recorder.Code)
+	}
+}
+
+func TestBulkTooManyItemsInJsonArray(t *testing.T) {
+	handler := NewBulkReportHandler(nil, nil, make(chan cases.Command, maxBulkItems))
+	recorder := httptest.NewRecorder()
+	body := "[" + strings.Repeat(`{},`, maxBulkItems) + "{}]"
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/reports", strings.NewReader(body)))
+
+	if recorder.Code != 413 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 413, recorder.Code)
+	}
+}
+
+func TestBulkTooLargeBody(t *testing.T) {
+	for _, contentType := range []string{"application/json", "application/x-ndjson"} {
+		handler := NewBulkReportHandler(nil, nil, make(chan cases.Command))
+		recorder := httptest.NewRecorder()
+		body := "[" + strings.Repeat(" ", int(maxBulkBodySize))
+		request := httptest.NewRequest(http.MethodPost, "/api/v1/reports", strings.NewReader(body))
+		request.Header.Set("Content-Type", contentType)
+
+		handler.ServeHTTP(recorder, request)
+
+		if recorder.Code != 413 {
+			t.Errorf("Response code of %s mismatch! Expected %d, but was %d!", contentType, 413, recorder.Code)
+		}
+	}
+}
+
+func TestBulkReactivatesDecommissionedMachines(t *testing.T) {
+	c := make(chan cases.Command, 1)
+	handler := NewBulkReportHandler(nil, nil, c)
+	recorder := httptest.NewRecorder()
+	body := `[{ "MachineId": "1a3fccff-2d7b-45f0-a3c4-50a7bb50d06e", "MachineName": "first", "MissingUpdates": [] }]`
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/reports?reactivate=true", strings.NewReader(body)))
+
+	if recorder.Code != 202 {
+		t.Fatalf("Response code mismatch! Expected %d, but was %d!", 202, recorder.Code)
+	}
+
+	if command := (<-c).(*cases.ReportCommand); !command.ReactivateDecommissioned {
+		t.Error("Bulk report should reactivate decommissioned machine!")
+	}
+}
+
+func TestBulkNotImplementedIfNotPostMethod(t *testing.T) {
+	handler := NewBulkReportHandler(nil, nil, make(chan cases.Command))
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/reports", nil))
+
+	if recorder.Code != 501 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 501, recorder.Code)
+	}
+}
+
+func decodeBulkResponse(t *testing.T, recorder *httptest.ResponseRecorder) contract.BulkReportResponse {
+	var response contract.BulkReportResponse
+	err := json.NewDecoder(recorder.Body).Decode(&response)
+
+	if err != nil {
+		t.Errorf("Cannot decode response due to error %s", err)
+	}
+
+	return response
+}
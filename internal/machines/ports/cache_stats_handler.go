@@ -0,0 +1,49 @@
+package ports
+
+import (
+	"dum/internal/machines/cases"
+	"dum/pkg/machines/contract"
+	"encoding/json"
+	"net/http"
+)
+
+// Source of repository cache counters.
+type CacheStatsSource interface {
+	Stats() cases.CacheStats
+}
+
+// Handler exposing usage of repository cache.
+type CacheStatsHandler struct {
+	source CacheStatsSource
+}
+
+func (h *CacheStatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	stats := h.source.Stats()
+	response := contract.CacheStatsResponse{
+		Hits:          stats.Hits,
+		Misses:        stats.Misses,
+		Evictions:     stats.Evictions,
+		Invalidations: stats.Invalidations,
+		Size:          stats.Size,
+		Capacity:      stats.Capacity,
+	}
+
+	if requests := stats.Hits + stats.Misses; requests > 0 {
+		response.HitRatio = float64(stats.Hits) / float64(requests)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&response)
+}
+
+func NewCacheStatsHandler(s CacheStatsSource) *CacheStatsHandler {
+	return &CacheStatsHandler{
+		source: s,
+	}
+}
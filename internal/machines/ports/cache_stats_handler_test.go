@@ -0,0 +1,40 @@
+package ports
+
+import (
+	"dum/internal/machines/cases"
+	"dum/pkg/machines/contract"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCacheStats(t *testing.T) {
+	handler := NewCacheStatsHandler(&cacheStatsSourceMock{cases.CacheStats{Hits: 3, Misses: 1, Size: 1, Capacity: 10}})
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/admin/cache", nil))
+
+	if recorder.Code != 200 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 200, recorder.Code)
+	}
+
+	var response contract.CacheStatsResponse
+	json.NewDecoder(recorder.Body).Decode(&response)
+
+	if response.Hits != 3 || response.Capacity != 10 {
+		t.Errorf("Unexpected cache stats response %+v", response)
+	}
+
+	if response.HitRatio != 0.75 {
+		t.Errorf("Hit ratio mismatch! Expected %f, but was %f", 0.75, response.HitRatio)
+	}
+}
+
+type cacheStatsSourceMock struct {
+	stats cases.CacheStats
+}
+
+func (s *cacheStatsSourceMock) Stats() cases.CacheStats {
+	return s.stats
+}
@@ -0,0 +1,78 @@
+package ports
+
+import (
+	"dum/internal/machines/cases"
+	"dum/pkg/machines/contract"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Source of processor workers liveness.
+type LivenessSource interface {
+	Liveness() []cases.WorkerStatus
+}
+
+// Source of commands, which were put to quarantine.
+type QuarantineSource interface {
+	List() []cases.QuarantinedCommand
+}
+
+// Handler exposing liveness of processor workers and quarantined commands. Responds with 503, if any of
+// workers is dead. Stacks of quarantined commands are written to log only.
+type LivenessHandler struct {
+	source     LivenessSource
+	quarantine QuarantineSource
+}
+
+func (h *LivenessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	response := contract.LivenessResponse{
+		Alive:      true,
+		Workers:    []contract.WorkerStatus{},
+		Quarantine: []contract.QuarantinedCommand{},
+	}
+
+	for _, status := range h.source.Liveness() {
+		response.Alive = response.Alive && status.IsAlive()
+		response.Workers = append(response.Workers, contract.WorkerStatus{
+			Name:        status.Name,
+			State:       string(status.State),
+			Alive:       status.IsAlive(),
+			Restarts:    status.Restarts,
+			Processed:   status.Processed,
+			Failed:      status.Failed,
+			Quarantined: status.Quarantined,
+			LastSeen:    status.LastSeen.Format(time.RFC3339),
+		})
+	}
+
+	for _, c := range h.quarantine.List() {
+		response.Quarantine = append(response.Quarantine, contract.QuarantinedCommand{
+			Command: fmt.Sprintf("%T", c.Command),
+			Reason:  c.Reason,
+			At:      c.At.Format(time.RFC3339),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if response.Alive {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.NewEncoder(w).Encode(&response)
+}
+
+func NewLivenessHandler(s LivenessSource, q QuarantineSource) *LivenessHandler {
+	return &LivenessHandler{
+		source:     s,
+		quarantine: q,
+	}
+}
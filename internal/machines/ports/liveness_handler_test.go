@@ -0,0 +1,108 @@
+package ports
+
+import (
+	"dum/internal/machines/cases"
+	"dum/pkg/machines/contract"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLivenessIsOkIfAllWorkersAlive(t *testing.T) {
+	handler := NewLivenessHandler(&livenessSourceMock{
+		statuses: []cases.WorkerStatus{
+			{Name: "first", State: cases.WorkerIdle},
+			{Name: "second", State: cases.WorkerBusy},
+		},
+	}, cases.NewMemoryQuarantine(1))
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/health/workers", nil))
+
+	if recorder.Code != 200 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 200, recorder.Code)
+	}
+
+	var response contract.LivenessResponse
+	err := json.NewDecoder(recorder.Body).Decode(&response)
+	if err != nil {
+		t.Errorf("Cannot decode response due to error %s", err)
+		return
+	}
+
+	if !response.Alive {
+		t.Errorf("Response should be alive!")
+	}
+
+	if len(response.Workers) != 2 {
+		t.Errorf("Workers length mismatch! Expected %d, but was %d", 2, len(response.Workers))
+	}
+}
+
+func TestLivenessIsUnavailableIfSomeWorkerDied(t *testing.T) {
+	handler := NewLivenessHandler(&livenessSourceMock{
+		statuses: []cases.WorkerStatus{
+			{Name: "first", State: cases.WorkerIdle},
+			{Name: "second", State: cases.WorkerDead},
+		},
+	}, cases.NewMemoryQuarantine(1))
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/health/workers", nil))
+
+	if recorder.Code != 503 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 503, recorder.Code)
+	}
+}
+
+func TestLivenessNotImplementedIfNotGetMethod(t *testing.T) {
+	handler := NewLivenessHandler(&livenessSourceMock{}, cases.NewMemoryQuarantine(1))
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/health/workers", nil))
+
+	if recorder.Code != 501 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 501, recorder.Code)
+	}
+}
+
+func TestLivenessExposesQuarantinedCommands(t *testing.T) {
+	quarantine := cases.NewMemoryQuarantine(1)
+	quarantine.Put(cases.QuarantinedCommand{
+		Command: &commandMock{},
+		Reason:  "panic: boom",
+		Stack:   []byte("goroutine 1"),
+		At:      time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC),
+	})
+	handler := NewLivenessHandler(&livenessSourceMock{}, quarantine)
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/health/workers", nil))
+
+	var response contract.LivenessResponse
+	err := json.NewDecoder(recorder.Body).Decode(&response)
+	if err != nil {
+		t.Fatalf("Cannot decode response due to error %s", err)
+	}
+
+	expected := contract.QuarantinedCommand{Command: "*ports.commandMock", Reason: "panic: boom", At: "2021-06-01T12:00:00Z"}
+	if len(response.Quarantine) != 1 || response.Quarantine[0] != expected {
+		t.Errorf("Quarantine mismatch! Expected %+v, but was %+v", expected, response.Quarantine)
+	}
+}
+
+type livenessSourceMock struct {
+	statuses []cases.WorkerStatus
+}
+
+func (s *livenessSourceMock) Liveness() []cases.WorkerStatus {
+	return s.statuses
+}
+
+type commandMock struct{}
+
+func (c *commandMock) Execute() error {
+	return nil
+}
@@ -0,0 +1,117 @@
+package ports
+
+import (
+	"dum/internal/machines/cases"
+	"dum/internal/machines/entities"
+	"dum/pkg/machines/contract"
+	"encoding/json"
+	"net/http"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+// Handler for reading machine state and managing machine lifecycle - deleting and decommissioning.
+type MachineHandler struct {
+	repo                cases.MachineRepository
+	machinePattern      regexp.Regexp
+	decommissionPattern regexp.Regexp
+	commandChan         chan<- cases.Command
+}
+
+func (h *MachineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.getMachine(w, r)
+	case http.MethodDelete:
+		h.deleteMachine(w, r)
+	case http.MethodPost:
+		h.decommissionMachine(w, r)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func (h *MachineHandler) getMachine(w http.ResponseWriter, r *http.Request) {
+	id, status := parseMachineId(&h.machinePattern, r)
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		return
+	}
+
+	m, err := h.repo.Load(id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if m == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	response := contract.MachineResponse{
+		Id:              m.Id.String(),
+		Name:            m.Name,
+		HealthLevel:     m.GetHealthLevel().String(),
+		Decommissioned:  m.IsDecommissioned(),
+		MissingUpdates:  toMissingUpdateDtos(m.GetMissingUpdates()),
+		HealthChangedAt: formatHealthChangedAt(m),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&response)
+}
+
+func (h *MachineHandler) deleteMachine(w http.ResponseWriter, r *http.Request) {
+	id, status := parseMachineId(&h.machinePattern, r)
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		return
+	}
+
+	h.commandChan <- &cases.DeleteMachineCommand{
+		MachineId:  id,
+		Repository: h.repo,
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *MachineHandler) decommissionMachine(w http.ResponseWriter, r *http.Request) {
+	id, status := parseMachineId(&h.decommissionPattern, r)
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		return
+	}
+
+	h.commandChan <- &cases.DecommissionMachineCommand{
+		MachineId:  id,
+		Repository: h.repo,
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func NewMachineHandler(r cases.MachineRepository, c chan<- cases.Command) *MachineHandler {
+	return &MachineHandler{
+		repo:                r,
+		machinePattern:      *regexp.MustCompile(`^/api/v1/machines/([^/]+)$`),
+		decommissionPattern: *regexp.MustCompile(`^/api/v1/machines/([^/]+)/decommission$`),
+		commandChan:         c,
+	}
+}
+
+// Extracts machine id from request path. Returns status code, describing the result of parsing.
+func parseMachineId(pattern *regexp.Regexp, r *http.Request) (entities.MachineId, int) {
+	matches := pattern.FindStringSubmatch(r.URL.Path)
+	if len(matches) == 0 {
+		return entities.MachineId{}, http.StatusNotFound
+	}
+
+	id, err := uuid.Parse(matches[1])
+	if err != nil {
+		return entities.MachineId{}, http.StatusBadRequest
+	}
+
+	return entities.MachineId(id), http.StatusOK
+}
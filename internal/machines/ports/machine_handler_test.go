@@ -0,0 +1,111 @@
+package ports
+
+import (
+	"dum/internal/machines/cases"
+	"dum/pkg/machines/contract"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetMachine(t *testing.T) {
+	machines := createArchiveTestMachines()
+	handler := NewMachineHandler(newMemoryRepositoryMock(machines...), make(chan cases.Command))
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/machines/"+machines[0].Id.String(), nil))
+
+	if recorder.Code != 200 {
+		t.Fatalf("Response code mismatch! Expected %d, but was %d!", 200, recorder.Code)
+	}
+
+	var response contract.MachineResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatalf("Cannot decode response due to error %s", err)
+	}
+
+	if response.Id != machines[0].Id.String() || response.Name != "active" || response.HealthLevel != "Danger" {
+		t.Errorf("Machine mismatch! Was %+v", response)
+	}
+
+	if len(response.MissingUpdates) != 1 || response.MissingUpdates[0].Duration != "36h0m0s" {
+		t.Errorf("Missing updates mismatch! Was %+v", response.MissingUpdates)
+	}
+}
+
+func TestGetMachineNotFound(t *testing.T) {
+	handler := NewMachineHandler(newMemoryRepositoryMock(), make(chan cases.Command))
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/machines/1a3fccff-2d7b-45f0-a3c4-50a7bb50d06e", nil))
+
+	if recorder.Code != 404 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 404, recorder.Code)
+	}
+}
+
+func TestDeleteAccepted(t *testing.T) {
+	c := make(chan cases.Command, 1)
+	handler := NewMachineHandler(nil, c)
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/api/v1/machines/1a3fccff-2d7b-45f0-a3c4-50a7bb50d06e", nil))
+
+	if recorder.Code != 202 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 202, recorder.Code)
+	}
+
+	command := <-c
+
+	if _, ok := command.(*cases.DeleteMachineCommand); !ok {
+		t.Errorf("Command type mismatch! Expected delete command, but was %T", command)
+	}
+}
+
+func TestDecommissionAccepted(t *testing.T) {
+	c := make(chan cases.Command, 1)
+	handler := NewMachineHandler(nil, c)
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/machines/1a3fccff-2d7b-45f0-a3c4-50a7bb50d06e/decommission", nil))
+
+	if recorder.Code != 202 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 202, recorder.Code)
+	}
+
+	command := <-c
+
+	if _, ok := command.(*cases.DecommissionMachineCommand); !ok {
+		t.Errorf("Command type mismatch! Expected decommission command, but was %T", command)
+	}
+}
+
+func TestMachineHandlerBadRequestIfIdIsNotValid(t *testing.T) {
+	handler := NewMachineHandler(nil, make(chan cases.Command))
+
+	requests := []*http.Request{
+		httptest.NewRequest(http.MethodDelete, "/api/v1/machines/test", nil),
+		httptest.NewRequest(http.MethodPost, "/api/v1/machines/test/decommission", nil),
+	}
+
+	for _, r := range requests {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, r)
+
+		if recorder.Code != 400 {
+			t.Errorf("Response code mismatch! Expected %d, but was %d!", 400, recorder.Code)
+		}
+	}
+}
+
+func TestMachineHandlerNotFound(t *testing.T) {
+	handler := NewMachineHandler(nil, make(chan cases.Command))
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/machines/1a3fccff-2d7b-45f0-a3c4-50a7bb50d06e/something", nil))
+
+	if recorder.Code != 404 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 404, recorder.Code)
+	}
+}
@@ -0,0 +1,46 @@
+package ports
+
+import (
+	"dum/internal/machines/cases"
+	"dum/pkg/machines/contract"
+	"encoding/json"
+	"net/http"
+)
+
+// Source of notification dispatcher counters.
+type NotificationStatsSource interface {
+	Stats() cases.DispatcherStats
+}
+
+// Handler exposing state of notification queue.
+type NotificationStatsHandler struct {
+	source NotificationStatsSource
+}
+
+func (h *NotificationStatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	stats := h.source.Stats()
+	response := contract.NotificationStatsResponse{
+		Queued:      stats.Queued,
+		Delivered:   stats.Delivered,
+		Failed:      stats.Failed,
+		Dropped:     stats.Dropped,
+		QueueLength: stats.QueueLength,
+		QueueSize:   stats.QueueSize,
+		Workers:     stats.Workers,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&response)
+}
+
+func NewNotificationStatsHandler(s NotificationStatsSource) *NotificationStatsHandler {
+	return &NotificationStatsHandler{
+		source: s,
+	}
+}
@@ -0,0 +1,36 @@
+package ports
+
+import (
+	"dum/internal/machines/cases"
+	"dum/pkg/machines/contract"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNotificationStats(t *testing.T) {
+	handler := NewNotificationStatsHandler(&notificationStatsSourceMock{cases.DispatcherStats{Queued: 5, Delivered: 3, Dropped: 1, QueueLength: 2, QueueSize: 10, Workers: 2}})
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/admin/notifications", nil))
+
+	if recorder.Code != 200 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 200, recorder.Code)
+	}
+
+	var response contract.NotificationStatsResponse
+	json.NewDecoder(recorder.Body).Decode(&response)
+
+	if response.Queued != 5 || response.Delivered != 3 || response.Dropped != 1 || response.QueueLength != 2 || response.Workers != 2 {
+		t.Errorf("Unexpected notification stats response %+v", response)
+	}
+}
+
+type notificationStatsSourceMock struct {
+	stats cases.DispatcherStats
+}
+
+func (s *notificationStatsSourceMock) Stats() cases.DispatcherStats {
+	return s.stats
+}
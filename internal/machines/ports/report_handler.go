@@ -49,38 +49,46 @@ func (h *ReportHandler) reportMissingUpdates(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	command, err := newReportCommand(entities.MachineId(id), request, h.strategy, h.repo)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	command.ReactivateDecommissioned = r.URL.Query().Get("reactivate") == "true"
+
+	h.commandChan <- command
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func NewReportHandler(s entities.HealthNotificationStrategy, r cases.MachineRepository, c chan<- cases.Command) *ReportHandler {
+	return &ReportHandler{
+		strategy:           s,
+		repo:               r,
+		machineNamePattern: *regexp.MustCompile(`^/api/v1/machines/(.*)/report`),
+		commandChan:        c,
+	}
+}
+
+func newReportCommand(id entities.MachineId, request contract.ReportRequest, s entities.HealthNotificationStrategy, r cases.MachineRepository) (*cases.ReportCommand, error) {
 	var missingUpdates []entities.MissingUpdate
 
 	for _, dto := range request.MissingUpdates {
 		missingUpdate, err := convert(dto)
 
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			return
+			return nil, err
 		}
 
 		missingUpdates = append(missingUpdates, missingUpdate)
 	}
 
-	command := cases.ReportCommand{
+	return &cases.ReportCommand{
 		MachineName:          request.MachineName,
-		Repository:           h.repo,
-		NotificationStrategy: h.strategy,
+		Repository:           r,
+		NotificationStrategy: s,
 		MissingUpdates:       missingUpdates,
-		MachineId:            entities.MachineId(id),
-	}
-
-	h.commandChan <- &command
-	w.WriteHeader(http.StatusAccepted)
-}
-
-func NewReportHandler(s entities.HealthNotificationStrategy, r cases.MachineRepository, c chan<- cases.Command) *ReportHandler {
-	return &ReportHandler{
-		strategy:           s,
-		repo:               r,
-		machineNamePattern: *regexp.MustCompile(`^/api/v1/machines/(.*)/report`),
-		commandChan:        c,
-	}
+		MachineId:            id,
+	}, nil
 }
 
 func convert(dto contract.MissingUpdate) (entities.MissingUpdate, error) {
@@ -90,8 +98,14 @@ func convert(dto contract.MissingUpdate) (entities.MissingUpdate, error) {
 		return entities.MissingUpdate{}, err
 	}
 
+	updateId, err := uuid.Parse(dto.UpdateId)
+
+	if err != nil {
+		return entities.MissingUpdate{}, err
+	}
+
 	return entities.MissingUpdate{
-		UpdateId: uuid.MustParse(dto.UpdateId),
+		UpdateId: updateId,
 		Duration: duration,
 		Severity: entities.Severity(dto.Severity),
 	}, nil
@@ -81,6 +81,24 @@ func TestBadRequestIfCannotDeserializeDto(t *testing.T) {
 	}
 }
 
+func TestBadRequestIfUpdateIdIsNotValid(t *testing.T) {
+	handler := NewReportHandler(nil, nil, make(chan<- cases.Command))
+	writerMock := responseWriter{
+		c: &writerResultContainer{},
+	}
+
+	url, _ := url.Parse("/api/v1/machines/1a3fccff-2d7b-45f0-a3c4-50a7bb50d06d/report")
+	handler.ServeHTTP(writerMock, &http.Request{
+		URL:    url,
+		Method: http.MethodPost,
+		Body:   io.NopCloser(strings.NewReader("{ \"MachineName\": \"test\", \"MissingUpdates\": [{ \"duration\": \"30s\", \"updateId\": \"not-a-uuid\", \"severity\": 2 }] }")),
+	})
+
+	if writerMock.c.writtenStatusCode != 400 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 400, writerMock.c.writtenStatusCode)
+	}
+}
+
 func TestAccepted(t *testing.T) {
 	c := make(chan cases.Command, 1)
 	handler := NewReportHandler(nil, nil, c)
@@ -110,6 +128,31 @@ func TestAccepted(t *testing.T) {
 	}
 }
 
+func TestAcceptedWithReactivation(t *testing.T) {
+	c := make(chan cases.Command, 1)
+	handler := NewReportHandler(nil, nil, c)
+	writerMock := responseWriter{
+		c: &writerResultContainer{},
+	}
+
+	url, _ := url.Parse("/api/v1/machines/1a3fccff-2d7b-45f0-a3c4-50a7bb50d06e/report?reactivate=true")
+	handler.ServeHTTP(writerMock, &http.Request{
+		URL:    url,
+		Method: http.MethodPost,
+		Body:   io.NopCloser(strings.NewReader("{ \"MachineName\": \"test\", \"MissingUpdates\": [] }")),
+	})
+
+	if writerMock.c.writtenStatusCode != 202 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 202, writerMock.c.writtenStatusCode)
+	}
+
+	command := (<-c).(*cases.ReportCommand)
+
+	if !command.ReactivateDecommissioned {
+		t.Errorf("Command should reactivate decommissioned machine!")
+	}
+}
+
 func TestNotImplementedIfNotPostMethod(t *testing.T) {
 	handler := NewReportHandler(nil, nil, make(chan<- cases.Command))
 	writerMock := responseWriter{
@@ -0,0 +1,55 @@
+package ports
+
+import (
+	"net/http"
+	"regexp"
+)
+
+// Router dispatches requests to handlers by method and path pattern.
+type Router struct {
+	routes []route
+}
+
+type route struct {
+	method  string
+	pattern *regexp.Regexp
+	handler http.Handler
+}
+
+// Registers handler for requests with specific method and path, matching the pattern.
+func (r *Router) Handle(method string, pattern string, h http.Handler) {
+	r.routes = append(r.routes, route{
+		method:  method,
+		pattern: regexp.MustCompile(pattern),
+		handler: h,
+	})
+}
+
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	pathMatched := false
+
+	for _, route := range r.routes {
+		if !route.pattern.MatchString(req.URL.Path) {
+			continue
+		}
+
+		if route.method == req.Method {
+			route.handler.ServeHTTP(w, req)
+			return
+		}
+
+		pathMatched = true
+	}
+
+	if pathMatched {
+		w.WriteHeader(http.StatusNotImplemented)
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func NewRouter() *Router {
+	return &Router{
+		routes: []route{},
+	}
+}
@@ -0,0 +1,63 @@
+package ports
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouterDispatchesByMethodAndPath(t *testing.T) {
+	router := NewRouter()
+	reportHandler := &handlerMock{}
+	deleteHandler := &handlerMock{}
+	router.Handle(http.MethodPost, `^/api/v1/machines/[^/]+/report$`, reportHandler)
+	router.Handle(http.MethodDelete, `^/api/v1/machines/[^/]+$`, deleteHandler)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/machines/id/report", nil))
+
+	if !reportHandler.wasCalled {
+		t.Errorf("Report handler should be called!")
+	}
+
+	if deleteHandler.wasCalled {
+		t.Errorf("Delete handler should not be called!")
+	}
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/api/v1/machines/id", nil))
+
+	if !deleteHandler.wasCalled {
+		t.Errorf("Delete handler should be called!")
+	}
+}
+
+func TestRouterNotFound(t *testing.T) {
+	router := NewRouter()
+	router.Handle(http.MethodPost, `^/api/v1/machines/[^/]+/report$`, &handlerMock{})
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/something", nil))
+
+	if recorder.Code != 404 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 404, recorder.Code)
+	}
+}
+
+func TestRouterNotImplementedForUnknownMethod(t *testing.T) {
+	router := NewRouter()
+	router.Handle(http.MethodPost, `^/api/v1/machines/[^/]+/report$`, &handlerMock{})
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/api/v1/machines/id/report", nil))
+
+	if recorder.Code != 501 {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", 501, recorder.Code)
+	}
+}
+
+type handlerMock struct {
+	wasCalled bool
+}
+
+func (h *handlerMock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.wasCalled = true
+}
@@ -0,0 +1,203 @@
+package ports
+
+import (
+	"dum/internal/machines/cases"
+	"dum/internal/machines/entities"
+	"dum/pkg/machines/contract"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Handler for managing silences, which suppress notifications about matching machines.
+type SilenceHandler struct {
+	repo    cases.SilenceRepository
+	pattern regexp.Regexp
+	now     func() time.Time
+}
+
+func (h *SilenceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/v1/silences" {
+		switch r.Method {
+		case http.MethodGet:
+			h.listSilences(w)
+		case http.MethodPost:
+			h.saveSilence(w, r, nil)
+		default:
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+		return
+	}
+
+	id, status := h.parseSilenceId(r)
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		return
+	}
+
+	s, err := h.repo.LoadSilence(id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if s == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.writeSilence(w, http.StatusOK, *s)
+	case http.MethodPut:
+		h.saveSilence(w, r, s)
+	case http.MethodDelete:
+		if err := h.repo.DeleteSilence(id); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func (h *SilenceHandler) listSilences(w http.ResponseWriter) {
+	silences, err := h.repo.ListSilences()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	now := h.now()
+	response := []contract.SilenceResponse{}
+	for _, s := range silences {
+		response = append(response, toSilenceResponse(s, now))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&response)
+}
+
+// Creates new silence or replaces existing one, keeping its id and creation time.
+func (h *SilenceHandler) saveSilence(w http.ResponseWriter, r *http.Request, existing *entities.Silence) {
+	var request contract.SilenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	now := h.now()
+	s, err := toSilence(request, now)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	status := http.StatusCreated
+	if existing != nil {
+		s.Id, s.CreatedAt = existing.Id, existing.CreatedAt
+		status = http.StatusOK
+	}
+
+	if err := s.Validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.SaveSilence(s); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	h.writeSilence(w, status, s)
+}
+
+func (h *SilenceHandler) writeSilence(w http.ResponseWriter, status int, s entities.Silence) {
+	response := toSilenceResponse(s, h.now())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&response)
+}
+
+func (h *SilenceHandler) parseSilenceId(r *http.Request) (entities.SilenceId, int) {
+	matches := h.pattern.FindStringSubmatch(r.URL.Path)
+	if len(matches) == 0 {
+		return entities.SilenceId{}, http.StatusNotFound
+	}
+
+	id, err := uuid.Parse(matches[1])
+	if err != nil {
+		return entities.SilenceId{}, http.StatusBadRequest
+	}
+
+	return entities.SilenceId(id), http.StatusOK
+}
+
+func toSilence(r contract.SilenceRequest, now time.Time) (entities.Silence, error) {
+	startsAt := now
+	if r.StartsAt != "" {
+		var err error
+		if startsAt, err = time.Parse(time.RFC3339, r.StartsAt); err != nil {
+			return entities.Silence{}, err
+		}
+	}
+
+	endsAt, err := time.Parse(time.RFC3339, r.EndsAt)
+	if err != nil {
+		return entities.Silence{}, err
+	}
+
+	matchers := []entities.SilenceMatcher{}
+	for _, m := range r.Matchers {
+		matchers = append(matchers, entities.SilenceMatcher{Label: m.Label, Operator: m.Operator, Value: m.Value})
+	}
+
+	return entities.Silence{
+		Id:        entities.SilenceId(uuid.New()),
+		Matchers:  matchers,
+		StartsAt:  startsAt.UTC(),
+		EndsAt:    endsAt.UTC(),
+		CreatedBy: r.CreatedBy,
+		Comment:   r.Comment,
+		CreatedAt: now.UTC(),
+	}, nil
+}
+
+func toSilenceResponse(s entities.Silence, now time.Time) contract.SilenceResponse {
+	matchers := []contract.SilenceMatcher{}
+	for _, m := range s.Matchers {
+		matchers = append(matchers, contract.SilenceMatcher{Label: m.Label, Operator: m.Operator, Value: m.Value})
+	}
+
+	status := "active"
+	if now.Before(s.StartsAt) {
+		status = "pending"
+	} else if !s.IsActive(now) {
+		status = "expired"
+	}
+
+	return contract.SilenceResponse{
+		Id:        s.Id.String(),
+		Matchers:  matchers,
+		StartsAt:  s.StartsAt.Format(time.RFC3339),
+		EndsAt:    s.EndsAt.Format(time.RFC3339),
+		CreatedBy: s.CreatedBy,
+		Comment:   s.Comment,
+		CreatedAt: s.CreatedAt.Format(time.RFC3339),
+		Status:    status,
+	}
+}
+
+func NewSilenceHandler(r cases.SilenceRepository) *SilenceHandler {
+	return &SilenceHandler{
+		repo:    r,
+		pattern: *regexp.MustCompile(`^/api/v1/silences/([^/]+)$`),
+		now:     time.Now,
+	}
+}
@@ -0,0 +1,158 @@
+package ports
+
+import (
+	"dum/internal/machines/entities"
+	"dum/pkg/machines/contract"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSilenceCrud(t *testing.T) {
+	repo := newSilenceRepositoryMock()
+	handler := NewSilenceHandler(repo)
+	handler.now = func() time.Time { return time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC) }
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/silences", strings.NewReader(
+		`{"Matchers":[{"Label":"machine","Operator":"=~","Value":"web-.*"}],"EndsAt":"2021-06-01T14:00:00Z","CreatedBy":"alice"}`)))
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("Response code mismatch! Expected %d, but was %d!", http.StatusCreated, recorder.Code)
+	}
+
+	var created contract.SilenceResponse
+	json.NewDecoder(recorder.Body).Decode(&created)
+	if created.Status != "active" || created.StartsAt != "2021-06-01T12:00:00Z" || created.CreatedBy != "alice" {
+		t.Errorf("Created silence mismatch! Was %+v", created)
+	}
+
+	path := "/api/v1/silences/" + created.Id
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, path, strings.NewReader(
+		`{"Matchers":[{"Label":"tag","Operator":"=","Value":"dc"}],"StartsAt":"2021-06-01T13:00:00Z","EndsAt":"2021-06-01T14:00:00Z","CreatedBy":"bob"}`)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Response code mismatch! Expected %d, but was %d!", http.StatusOK, recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/silences", nil))
+	var list []contract.SilenceResponse
+	json.NewDecoder(recorder.Body).Decode(&list)
+	if len(list) != 1 || list[0].Id != created.Id || list[0].Status != "pending" || list[0].Matchers[0].Label != "tag" {
+		t.Errorf("Silences mismatch! Was %+v", list)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, path, nil))
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", http.StatusNoContent, recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Response code mismatch! Expected %d, but was %d!", http.StatusNotFound, recorder.Code)
+	}
+}
+
+func TestInvalidSilence(t *testing.T) {
+	handler := NewSilenceHandler(newSilenceRepositoryMock())
+
+	for _, body := range []string{
+		`{"Matchers":[],"EndsAt":"2099-06-01T14:00:00Z","CreatedBy":"alice"}`,
+		`{"Matchers":[{"Label":"rack","Operator":"=","Value":"1"}],"EndsAt":"2099-06-01T14:00:00Z","CreatedBy":"alice"}`,
+		`{"Matchers":[{"Label":"machine","Operator":"=~","Value":"("}],"EndsAt":"2099-06-01T14:00:00Z","CreatedBy":"alice"}`,
+		`{"Matchers":[{"Label":"machine","Operator":"=","Value":"web-01"}],"EndsAt":"2099-06-01T14:00:00Z"}`,
+		`{"Matchers":[{"Label":"machine","Operator":"=","Value":"web-01"}],"EndsAt":"tomorrow","CreatedBy":"alice"}`,
+		`{"Matchers":[{"Label":"machine","Operator":"=","Value":"web-01"}],"EndsAt":"2001-06-01T14:00:00Z","CreatedBy":"alice"}`,
+	} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/silences", strings.NewReader(body)))
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("Response code mismatch for %s! Expected %d, but was %d!", body, http.StatusBadRequest, recorder.Code)
+		}
+	}
+}
+
+type silenceRepositoryMock struct {
+	silences        map[entities.SilenceId]entities.Silence
+	acknowledgments map[entities.MachineId]entities.Acknowledgment
+}
+
+func newSilenceRepositoryMock() *silenceRepositoryMock {
+	return &silenceRepositoryMock{
+		silences:        map[entities.SilenceId]entities.Silence{},
+		acknowledgments: map[entities.MachineId]entities.Acknowledgment{},
+	}
+}
+
+func (r *silenceRepositoryMock) IsAcknowledged(id entities.MachineId, since time.Time) (bool, error) {
+	a, ok := r.acknowledgments[id]
+	return ok && a.Covers(since), nil
+}
+
+func (r *silenceRepositoryMock) ListSilences() ([]entities.Silence, error) {
+	result := []entities.Silence{}
+	for _, s := range r.silences {
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+func (r *silenceRepositoryMock) LoadSilence(id entities.SilenceId) (*entities.Silence, error) {
+	if s, ok := r.silences[id]; ok {
+		return &s, nil
+	}
+	return nil, nil
+}
+
+func (r *silenceRepositoryMock) SaveSilence(s entities.Silence) error {
+	r.silences[s.Id] = s
+	return nil
+}
+
+func (r *silenceRepositoryMock) DeleteSilence(id entities.SilenceId) error {
+	delete(r.silences, id)
+	return nil
+}
+
+func (r *silenceRepositoryMock) ListAcknowledgments() ([]entities.Acknowledgment, error) {
+	result := []entities.Acknowledgment{}
+	for _, a := range r.acknowledgments {
+		result = append(result, a)
+	}
+	return result, nil
+}
+
+func (r *silenceRepositoryMock) LoadAcknowledgment(id entities.MachineId) (*entities.Acknowledgment, error) {
+	if a, ok := r.acknowledgments[id]; ok {
+		return &a, nil
+	}
+	return nil, nil
+}
+
+func (r *silenceRepositoryMock) SaveAcknowledgment(a entities.Acknowledgment) error {
+	r.acknowledgments[a.MachineId] = a
+	return nil
+}
+
+func (r *silenceRepositoryMock) DeleteAcknowledgment(id entities.MachineId) error {
+	delete(r.acknowledgments, id)
+	return nil
+}
+
+func (r *silenceRepositoryMock) LoadEscalation(id entities.MachineId, since time.Time) (int, error) {
+	return 0, nil
+}
+
+func (r *silenceRepositoryMock) SaveEscalation(id entities.MachineId, since time.Time, notified int) error {
+	return nil
+}
+
+func (r *silenceRepositoryMock) DeleteEscalation(id entities.MachineId) error {
+	return nil
+}
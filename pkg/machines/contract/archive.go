@@ -0,0 +1,32 @@
+package contract
+
+// Data transfer object for header of machine store archive
+type ArchiveHeader struct {
+	FormatVersion int
+	CreatedAt     string
+	Machines      int
+}
+
+// Data transfer object for archived machine
+type ArchivedMachine struct {
+	Id             string
+	Name           string
+	Decommissioned bool `json:",omitempty"`
+	MissingUpdates []MissingUpdate
+	// Time of the last health level change in RFC 3339 format, empty if it's unknown.
+	HealthChangedAt string `json:",omitempty"`
+}
+
+// Data transfer object for machine store archive in JSON format
+type Archive struct {
+	Header   ArchiveHeader
+	Machines []ArchivedMachine
+}
+
+// Data transfer object for result of archive import
+type ImportResponse struct {
+	Restored int
+	Skipped  int
+	Failed   int
+	Errors   []string `json:",omitempty"`
+}
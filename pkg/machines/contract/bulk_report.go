@@ -0,0 +1,22 @@
+package contract
+
+// Data transfer object for single report of bulk request
+type BulkReportItem struct {
+	MachineId string
+	ReportRequest
+}
+
+// Data transfer object for result of single report processing
+type BulkReportItemResult struct {
+	Index     int
+	MachineId string
+	Status    int
+	Error     string `json:",omitempty"`
+}
+
+// Data transfer object for bulk report response
+type BulkReportResponse struct {
+	Accepted int
+	Rejected int
+	Results  []BulkReportItemResult
+}
@@ -0,0 +1,12 @@
+package contract
+
+// Data transfer object for repository cache usage
+type CacheStatsResponse struct {
+	Hits          uint64
+	Misses        uint64
+	Evictions     uint64
+	Invalidations uint64
+	Size          int
+	Capacity      int
+	HitRatio      float64
+}
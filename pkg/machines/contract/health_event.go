@@ -0,0 +1,21 @@
+package contract
+
+// Data transfer object for machine health event, sent by notification strategies
+type HealthEvent struct {
+	MachineId   string
+	MachineName string
+	Previous    string
+	Current     string
+	OccurredAt  string
+	Since       string `json:",omitempty"`
+	// Name of escalation tier for reminders about machine, staying at its level for too long.
+	Escalation string `json:",omitempty"`
+}
+
+// Data transfer object for digest of health events, which were not sent one by one
+type HealthDigest struct {
+	Summary string
+	Since   string
+	Until   string
+	Events  []HealthEvent
+}
@@ -0,0 +1,27 @@
+package contract
+
+// Data transfer object for processor worker status
+type WorkerStatus struct {
+	Name        string
+	State       string
+	Alive       bool
+	Restarts    int
+	Processed   int
+	Failed      int
+	Quarantined int
+	LastSeen    string
+}
+
+// Data transfer object for command, which was put to quarantine
+type QuarantinedCommand struct {
+	Command string
+	Reason  string
+	At      string
+}
+
+// Data transfer object for liveness response
+type LivenessResponse struct {
+	Alive      bool
+	Workers    []WorkerStatus
+	Quarantine []QuarantinedCommand
+}
@@ -0,0 +1,12 @@
+package contract
+
+// Data transfer object for machine state
+type MachineResponse struct {
+	Id             string
+	Name           string
+	HealthLevel    string
+	Decommissioned bool
+	MissingUpdates []MissingUpdate
+	// Time, since which machine has its health level, in RFC 3339 format, empty if it's unknown.
+	HealthChangedAt string `json:",omitempty"`
+}
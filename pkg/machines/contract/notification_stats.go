@@ -0,0 +1,12 @@
+package contract
+
+// Data transfer object for notification dispatcher counters
+type NotificationStatsResponse struct {
+	Queued      uint64
+	Delivered   uint64
+	Failed      uint64
+	Dropped     uint64
+	QueueLength int
+	QueueSize   int
+	Workers     int
+}
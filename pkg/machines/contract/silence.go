@@ -0,0 +1,50 @@
+package contract
+
+// Data transfer object for condition of silence on a single label
+type SilenceMatcher struct {
+	// One of machine, tag, group, update or level.
+	Label string
+	// One of =, !=, =~ or !~, regular expressions match the whole value.
+	Operator string
+	Value    string
+}
+
+// Data transfer object for creating or replacing silence
+type SilenceRequest struct {
+	Matchers []SilenceMatcher
+	// Start time in RFC 3339 format, silence starts immediately, if it's empty.
+	StartsAt string `json:",omitempty"`
+	// End time in RFC 3339 format.
+	EndsAt    string
+	CreatedBy string
+	Comment   string `json:",omitempty"`
+}
+
+// Data transfer object for silence
+type SilenceResponse struct {
+	Id        string
+	Matchers  []SilenceMatcher
+	StartsAt  string
+	EndsAt    string
+	CreatedBy string
+	Comment   string `json:",omitempty"`
+	CreatedAt string
+	// One of pending, active or expired.
+	Status string
+}
+
+// Data transfer object for acknowledging the current health episode of machine
+type AcknowledgmentRequest struct {
+	CreatedBy string `json:",omitempty"`
+	Comment   string `json:",omitempty"`
+}
+
+// Data transfer object for acknowledgment of machine health episode
+type AcknowledgmentResponse struct {
+	MachineId string
+	// Beginning of acknowledged health episode in RFC 3339 format.
+	Since     string
+	CreatedBy string `json:",omitempty"`
+	Comment   string `json:",omitempty"`
+	CreatedAt string
+}